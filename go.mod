@@ -8,12 +8,14 @@ require (
 	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-language-server/protocol v0.7.0
 	github.com/go-language-server/uri v0.2.0
+	github.com/go-logr/logr v1.4.1
 	github.com/go-rod/rod v0.114.3
 	github.com/go-zeromq/zmq4 v0.16.0
 	github.com/gofrs/uuid v4.4.0+incompatible
 	github.com/gowebapi/webapi v0.0.0-20221221115732-41cedfc27a0b
 	github.com/janpfeifer/must v0.0.2
 	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.8.1
 	go.lsp.dev/jsonrpc2 v0.10.0
 	golang.org/x/exp v0.0.0-20240119083558-1b970713d09a
@@ -25,12 +27,10 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/francoispqt/gojay v1.2.13 // indirect
 	github.com/go-language-server/jsonrpc2 v0.4.2 // indirect
-	github.com/go-logr/logr v1.4.1 // indirect
 	github.com/go-zeromq/goczmq/v4 v4.2.2 // indirect
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/segmentio/asm v1.2.0 // indirect
 	github.com/segmentio/encoding v0.4.0 // indirect
 	github.com/ysmood/fetchup v0.2.3 // indirect
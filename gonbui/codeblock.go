@@ -0,0 +1,51 @@
+package gonbui
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// This file implements DisplayCodeBlock, to display code or text with a button to copy it to the clipboard.
+
+// DisplayCodeBlock displays the code in a formatted block, with a "copy" button that copies it to the
+// clipboard. It's useful for cells that generate code or snippets.
+//
+// The lang (e.g.: "go", "sql", "json") is used as the language of the block, for front-ends that highlight
+// the syntax of code. Leave it empty ("") for plain text.
+//
+// It also includes markdown and plain text alternatives, for front-ends (or converters like `nbconvert`)
+// that don't render HTML or run its Javascript.
+func DisplayCodeBlock(lang, code string) {
+	if !IsNotebook {
+		return
+	}
+	DisplayMIMEMap(map[string]any{
+		"text/html":     codeBlockHtml(UniqueId(), lang, code),
+		"text/markdown": codeBlockMarkdown(lang, code),
+		"text/plain":    code,
+	})
+}
+
+// codeBlockHtml returns the HTML used by DisplayCodeBlock, with the block identified by id.
+func codeBlockHtml(id, lang, code string) string {
+	class := ""
+	if lang != "" {
+		class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(lang))
+	}
+	return fmt.Sprintf(`<div style="position: relative">
+<button title="Copy to clipboard" style="position: absolute; top: 4px; right: 4px; font-size: small"
+	onclick="navigator.clipboard.writeText(document.getElementById('%[1]s').innerText).then(() => { this.innerText = 'Copied'; setTimeout(() => { this.innerText = 'Copy'; }, 1500); })">Copy</button>
+<pre><code id="%[1]s"%[2]s>%[3]s</code></pre>
+</div>`, id, class, html.EscapeString(code))
+}
+
+// codeBlockMarkdown returns the code as a fenced markdown block, with a fence longer than any sequence of
+// backticks in the code.
+func codeBlockMarkdown(lang, code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s", fence, lang, strings.TrimSuffix(code, "\n"), fence)
+}
@@ -0,0 +1,68 @@
+package gonbui
+
+import (
+	"bytes"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+	"io"
+	"net/url"
+	"os"
+	"path"
+)
+
+// ServeFile makes the file in filePath available to be fetched by the front-end (the browser),
+// and returns the URL from where it can be fetched.
+//
+// It can be used, for instance, to offer a link to download a CSV file or a plot generated by the
+// program:
+//
+//	fileUrl, err := gonbui.ServeFile("/tmp/results.csv")
+//	if err != nil { ... }
+//	gonbui.DisplayHtmlf(`<a href="%s" download>results.csv</a>`, fileUrl)
+//
+// The file is copied to a directory served by Jupyter (see protocol.GONB_FILES_DIR_ENV), which is
+// removed when the kernel shuts down. Files served with the same base name overwrite each other.
+//
+// It returns an error if GoNB couldn't find the Jupyter root directory (or if not running in GoNB).
+func ServeFile(filePath string) (fileUrl string, err error) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open file %q to serve", filePath)
+	}
+	defer func() { _ = src.Close() }()
+	return serveContent(path.Base(filePath), src)
+}
+
+// ServeData is like ServeFile, but it serves the given content, under the given file name.
+// Only the base name of name is used.
+func ServeData(name string, data []byte) (fileUrl string, err error) {
+	return serveContent(path.Base(name), bytes.NewReader(data))
+}
+
+// serveContent writes the content read from r to the directory of served files, see ServeFile.
+func serveContent(name string, r io.Reader) (fileUrl string, err error) {
+	filesDir, filesUrl := os.Getenv(protocol.GONB_FILES_DIR_ENV), os.Getenv(protocol.GONB_FILES_URL_ENV)
+	if filesDir == "" || filesUrl == "" {
+		return "", errors.Errorf("serving files not available: environment variables %s and %s are not set, "+
+			"either not running in GoNB or it failed to find Jupyter's root directory",
+			protocol.GONB_FILES_DIR_ENV, protocol.GONB_FILES_URL_ENV)
+	}
+	if err = os.MkdirAll(filesDir, 0755); err != nil {
+		return "", errors.Wrapf(err, "failed to create directory %q for the served files", filesDir)
+	}
+	dstPath := path.Join(filesDir, name)
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create served file %q", dstPath)
+	}
+	_, err = io.Copy(dst, r)
+	if err == nil {
+		err = dst.Close()
+	} else {
+		_ = dst.Close()
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to write served file %q", dstPath)
+	}
+	return path.Join(filesUrl, url.PathEscape(name)), nil
+}
@@ -187,6 +187,8 @@ func SendData(data *protocol.DisplayData) {
 // calling comms.DeliverValue, until the pipe is closed.
 func pollReaderPipe() {
 	Logf("pollReaderPipe() started")
+	// No more replies can arrive: fail any pending (and future) input requests, so they don't block forever.
+	defer failInputReplies(errors.New("communication with GoNB closed before a reply was received"))
 	for gonbReaderPipe != nil {
 		valueMsg := &protocol.CommValue{}
 		err := gonbDecoder.Decode(valueMsg)
@@ -223,6 +225,9 @@ func pollReaderPipe() {
 			}
 			mu.Unlock()
 
+		} else if valueMsg.Address == protocol.GonbuiInputReplyAddress {
+			deliverInputReply(valueMsg)
+
 		} else if OnCommValueUpdate != nil {
 			// Generic Comms update.
 			Logf("dispatching OnCommValueUpdate(%q)", valueMsg.Address)
@@ -315,15 +320,89 @@ func DisplayHtmlf(htmlFormat string, args ...any) {
 // This also renders math formulas using latex, use `$x^2$` for formulas inlined in text, or
 // double "$" for formulas in a separate line -- e.g.:
 // `$$f(x) = \int_{-\infty}^{\infty} e^{-x^2} dx$$`.
+//
+// The markdown source is also sent as a plain text alternative, for front-ends (or converters like
+// `nbconvert`) that can't render markdown.
 func DisplayMarkdown(markdown string) {
 	if !IsNotebook {
 		return
 	}
 	SendData(&protocol.DisplayData{
-		Data: map[protocol.MIMEType]any{protocol.MIMETextMarkdown: markdown},
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMETextMarkdown: markdown,
+			protocol.MIMETextPlain:    markdown,
+		},
 	})
 }
 
+// DisplayMIMEMap displays the content given in several alternative representations at once, keyed by their
+// MIME type -- e.g.: `map[string]any{"text/html": "<b>Hello</b>", "text/plain": "Hello"}`.
+//
+// The front-end (Jupyter) picks the representation it renders best, and converters like `nbconvert`
+// can fall back to a simpler one (usually "text/plain").
+//
+// The content usually is either a string or []byte, depending on the MIME type.
+func DisplayMIMEMap(data map[string]any) {
+	if !IsNotebook {
+		return
+	}
+	mimeData := make(map[protocol.MIMEType]any, len(data))
+	for mimeType, content := range data {
+		mimeData[protocol.MIMEType(mimeType)] = content
+	}
+	SendData(&protocol.DisplayData{Data: mimeData})
+}
+
+// DisplayError displays the error in the notebook as an error output (in red), as opposed to
+// the normal output of the cell.
+// The traceback includes the chain of wrapped errors, if any.
+//
+// It's useful to report recoverable errors, in a way that is visually distinct from other outputs.
+func DisplayError(err error) {
+	if !IsNotebook || err == nil {
+		return
+	}
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{protocol.MIMEJupyterError: errorReport(err)},
+	})
+}
+
+// errorReport creates the protocol.ErrorReport for the error, with the error chain as traceback.
+//
+// Consecutive errors in the chain with the same message (e.g.: the stack and message layers of
+// `github.com/pkg/errors`) are collapsed into one entry, with the type of the innermost of them.
+func errorReport(err error) protocol.ErrorReport {
+	const (
+		colorRed   = "\033[0;31m"
+		colorReset = "\033[0m"
+	)
+	type chainEntry struct {
+		name, msg string
+	}
+	var chain []chainEntry
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		entry := chainEntry{name: fmt.Sprintf("%T", cause), msg: cause.Error()}
+		if len(chain) > 0 && chain[len(chain)-1].msg == entry.msg {
+			chain[len(chain)-1] = entry
+			continue
+		}
+		chain = append(chain, entry)
+	}
+	report := protocol.ErrorReport{
+		Name:  chain[0].name,
+		Value: chain[0].msg,
+	}
+	for ii, entry := range chain {
+		prefix := "  caused by "
+		if ii == 0 {
+			prefix = ""
+		}
+		report.Traceback = append(report.Traceback,
+			fmt.Sprintf("%s%s%s%s: %s", prefix, colorRed, entry.name, colorReset, entry.msg))
+	}
+	return report
+}
+
 // UpdateHtml displays the given HTML in the notebook on an output block with the given `id`:
 // the block identified by 'id' is created automatically the first time this function is
 // called, and simply updated thereafter.
@@ -450,6 +529,101 @@ func RequestInput(prompt string, password bool) {
 	})
 }
 
+var (
+	// Control input requests and replies, see ReadInput.
+	nextInputId     int
+	inputRepliesMap = make(map[int]chan protocol.InputReply)
+
+	// inputRepliesError is set once the pipe from GoNB is closed, after which no replies can be received.
+	inputRepliesError error
+)
+
+// ReadInput requests input from the Jupyter notebook, and returns the value entered by the user.
+// The prompt is displayed in front of the input field, leave it empty ("") if not needed.
+//
+// Unlike RequestInput, the value is returned directly, as opposed to being written to the program's stdin.
+//
+// It returns an error if not running in a notebook, or if the front-end doesn't allow input -- e.g.: when
+// the notebook is executed non-interactively, with `nbconvert` or `nbexec`.
+func ReadInput(prompt string) (string, error) {
+	return readInput(prompt, false)
+}
+
+// ReadPassword requests a password (or any secret) from the Jupyter notebook, and returns the value entered by
+// the user. The front-end doesn't echo the value typed.
+// The prompt is displayed in front of the input field, leave it empty ("") if not needed.
+//
+// It returns an error if not running in a notebook, or if the front-end doesn't allow input, see ReadInput.
+func ReadPassword(prompt string) (string, error) {
+	return readInput(prompt, true)
+}
+
+// readInput implements ReadInput and ReadPassword: it sends an input request to GoNB and waits for its reply.
+func readInput(prompt string, password bool) (string, error) {
+	if !IsNotebook {
+		return "", errors.New("input not available: program is not being executed by GoNB")
+	}
+	if err := Open(); err != nil {
+		return "", err
+	}
+	mu.Lock()
+	if inputRepliesError != nil {
+		mu.Unlock()
+		return "", errors.Wrap(inputRepliesError, "input request failed")
+	}
+	id := nextInputId
+	nextInputId++
+	replyChan := make(chan protocol.InputReply, 1)
+	inputRepliesMap[id] = replyChan
+	mu.Unlock()
+
+	SendData(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{
+			protocol.MIMEJupyterInput: &protocol.InputRequest{
+				Prompt:   prompt,
+				Password: password,
+				Reply:    true,
+				ReplyId:  id,
+			},
+		},
+	})
+	reply := <-replyChan
+	if reply.Error != "" {
+		return "", errors.Errorf("input request failed: %s", reply.Error)
+	}
+	return reply.Value, nil
+}
+
+// deliverInputReply delivers the reply to an input request sent by readInput.
+func deliverInputReply(valueMsg *protocol.CommValue) {
+	reply, ok := valueMsg.Value.(protocol.InputReply)
+	if !ok {
+		log.Printf("Received invalid input reply %+v !? Communication to front-end may have become unstable!", valueMsg)
+		return
+	}
+	mu.Lock()
+	replyChan, found := inputRepliesMap[reply.Id]
+	delete(inputRepliesMap, reply.Id)
+	mu.Unlock()
+	if !found {
+		log.Printf("Received input reply for unknown request %d !?", reply.Id)
+		return
+	}
+	replyChan <- reply
+}
+
+// failInputReplies replies with err to all pending input requests, and makes future ones fail immediately.
+// It is called when the pipe from GoNB is closed.
+func failInputReplies(err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	inputRepliesError = err
+	for id, replyChan := range inputRepliesMap {
+		replyChan <- protocol.InputReply{Id: id, Error: err.Error()}
+		delete(inputRepliesMap, id)
+	}
+}
+
 // EmbedImageAsPNGSrc returns a string that can be used as in an HTML <img> tag, as its source (it's `src` field).
 // This simplifies embedding an image in HTML without requiring separate files. It embeds it as a PNG file
 // base64 encoded.
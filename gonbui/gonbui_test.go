@@ -0,0 +1,332 @@
+package gonbui
+
+import (
+	"encoding/base64"
+	"encoding/gob"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureSendData replaces the pipes to GoNB during the execution of fn, and returns the
+// protocol.DisplayData sent.
+func captureSendData(t *testing.T, fn func()) []*protocol.DisplayData {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	backReader, backWriter, err := os.Pipe() // Not used, but it keeps openLocked from opening the real pipes.
+	require.NoError(t, err)
+	mu.Lock()
+	IsNotebook = true
+	gonbWriterPipe, gonbReaderPipe = writer, backReader
+	gonbEncoder = gob.NewEncoder(writer)
+	mu.Unlock()
+
+	var received []*protocol.DisplayData
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		decoder := gob.NewDecoder(reader)
+		for {
+			data := &protocol.DisplayData{}
+			if err := decoder.Decode(data); err != nil {
+				return
+			}
+			received = append(received, data)
+		}
+	}()
+	fn()
+
+	mu.Lock()
+	closePipesLocked()
+	IsNotebook = false
+	mu.Unlock()
+	<-done
+	_ = reader.Close()
+	_ = backWriter.Close()
+	require.NoError(t, Error())
+	return received
+}
+
+func TestErrorReport(t *testing.T) {
+	// Simple error.
+	report := errorReport(errors.New("bad"))
+	assert.Equal(t, "*errors.fundamental", report.Name)
+	assert.Equal(t, "bad", report.Value)
+	assert.Equal(t, []string{"\033[0;31m*errors.fundamental\033[0m: bad"}, report.Traceback)
+
+	// Chain of wrapped errors: the stack and message layers of pkg/errors are collapsed.
+	err := errors.Wrap(errors.Wrap(os.ErrNotExist, "opening file"), "loading config")
+	report = errorReport(err)
+	assert.Equal(t, "*errors.withMessage", report.Name)
+	assert.Equal(t, "loading config: opening file: file does not exist", report.Value)
+	assert.Equal(t, []string{
+		"\033[0;31m*errors.withMessage\033[0m: loading config: opening file: file does not exist",
+		"  caused by \033[0;31m*errors.withMessage\033[0m: opening file: file does not exist",
+		"  caused by \033[0;31m*errors.errorString\033[0m: file does not exist",
+	}, report.Traceback)
+}
+
+func TestDisplayError(t *testing.T) {
+	received := captureSendData(t, func() {
+		DisplayError(nil) // No-op.
+		DisplayError(errors.New("bad"))
+	})
+	require.Len(t, received, 1)
+	report, ok := received[0].Data[protocol.MIMEJupyterError].(protocol.ErrorReport)
+	require.Truef(t, ok, "Expected a protocol.ErrorReport for %q", protocol.MIMEJupyterError)
+	assert.Equal(t, "bad", report.Value)
+}
+
+func TestDisplayMIMEMap(t *testing.T) {
+	received := captureSendData(t, func() {
+		DisplayMIMEMap(map[string]any{
+			"text/html":  "<b>Hello</b>",
+			"text/plain": "Hello",
+			"image/png":  []byte{1, 2, 3},
+		})
+	})
+	require.Len(t, received, 1)
+	assert.Equal(t, map[protocol.MIMEType]any{
+		protocol.MIMETextHTML:  "<b>Hello</b>",
+		protocol.MIMETextPlain: "Hello",
+		protocol.MIMEImagePNG:  []byte{1, 2, 3},
+	}, received[0].Data)
+}
+
+func TestServeFile(t *testing.T) {
+	t.Setenv(protocol.GONB_FILES_DIR_ENV, "")
+	t.Setenv(protocol.GONB_FILES_URL_ENV, "")
+	_, err := ServeFile("results.csv")
+	require.Error(t, err)
+
+	filesDir := path.Join(t.TempDir(), "files")
+	t.Setenv(protocol.GONB_FILES_DIR_ENV, filesDir)
+	t.Setenv(protocol.GONB_FILES_URL_ENV, "/files/jupyter_files/abc/files")
+	srcPath := path.Join(t.TempDir(), "my results.csv")
+	require.NoError(t, os.WriteFile(srcPath, []byte("a,b\n1,2\n"), 0644))
+	fileUrl, err := ServeFile(srcPath)
+	require.NoError(t, err)
+	assert.Equal(t, "/files/jupyter_files/abc/files/my%20results.csv", fileUrl)
+	contents, err := os.ReadFile(path.Join(filesDir, "my results.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(contents))
+
+	_, err = ServeFile(path.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestDisplayAudio(t *testing.T) {
+	// Minimal WAV header: the MIME type is detected from the content.
+	wav := append([]byte("RIFF\x24\x00\x00\x00WAVEfmt "), make([]byte, 28)...)
+	received := captureSendData(t, func() {
+		require.NoError(t, DisplayAudio(wav, ""))
+		require.NoError(t, DisplayAudioWithOptions([]byte{1, 2, 3}, "audio/mpeg",
+			MediaOptions{Autoplay: true, Loop: true}))
+		require.Error(t, DisplayAudio([]byte("not audio"), ""))
+	})
+	require.Len(t, received, 2)
+	assert.Equal(t, `<audio controls src="data:audio/wave;base64,`+base64.StdEncoding.EncodeToString(wav)+`"></audio>`,
+		received[0].Data[protocol.MIMETextHTML])
+	assert.Equal(t, `<audio autoplay loop src="data:audio/mpeg;base64,AQID"></audio>`,
+		received[1].Data[protocol.MIMETextHTML])
+}
+
+func TestDisplayVideo(t *testing.T) {
+	filesDir := t.TempDir()
+	t.Setenv(protocol.GONB_FILES_DIR_ENV, filesDir)
+	t.Setenv(protocol.GONB_FILES_URL_ENV, "/files/jupyter_files/abc/files")
+	defer func(size int) { MaxEmbeddedVideoSize = size }(MaxEmbeddedVideoSize)
+	MaxEmbeddedVideoSize = 4
+
+	received := captureSendData(t, func() {
+		require.NoError(t, DisplayVideo([]byte{1, 2, 3}, "video/mp4"))
+		require.NoError(t, DisplayVideoWithOptions([]byte{1, 2, 3, 4, 5}, "video/webm", MediaOptions{Autoplay: true, Muted: true}))
+		require.NoError(t, DisplayGIF([]byte("GIF89a")))
+		require.Error(t, DisplayVideo([]byte("not video"), ""))
+	})
+	require.Len(t, received, 3)
+	assert.Equal(t, `<video controls src="data:video/mp4;base64,AQID"></video>`, received[0].Data[protocol.MIMETextHTML])
+
+	// Larger videos are served from a file.
+	html := received[1].Data[protocol.MIMETextHTML].(string)
+	assert.Regexp(t, `^<video autoplay muted src="/files/jupyter_files/abc/files/video_\w+\.webm"></video>$`, html)
+	entries, err := os.ReadDir(filesDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Regexp(t, `<img src="/files/jupyter_files/abc/files/video_\w+\.gif"/>`, received[2].Data[protocol.MIMETextHTML])
+}
+
+func TestValueToHtml(t *testing.T) {
+	type point struct {
+		X, Y   int
+		hidden bool
+	}
+	assert.Equal(t, "<table>\n<tr><th>X</th><th>Y</th></tr>\n<tr><td>1</td><td>2</td></tr>\n</table>",
+		valueToHtml([]point{{X: 1, Y: 2}}))
+	assert.Equal(t, "<table>\n<tr><th>Key</th><th>Value</th></tr>\n<tr><td>a</td><td>1</td></tr>\n"+
+		"<tr><td>b</td><td>2</td></tr>\n</table>", valueToHtml(map[string]int{"b": 2, "a": 1}))
+	assert.Equal(t, "<pre>{\n  &#34;X&#34;: 1,\n  &#34;Y&#34;: 2\n}</pre>", valueToHtml(&point{X: 1, Y: 2}))
+	assert.Equal(t, "<pre>&lt;nil&gt;</pre>", valueToHtml(nil))
+
+	MaxDisplayValueRows = 2
+	defer func() { MaxDisplayValueRows = 100 }()
+	assert.Contains(t, valueToHtml([]int{1, 2, 3, 4}), "... 2 more")
+}
+
+// replyToNextInput replies value to the next input request registered, once it is registered.
+func replyToNextInput(value string) {
+	for {
+		mu.Lock()
+		id := nextInputId - 1
+		_, found := inputRepliesMap[id]
+		mu.Unlock()
+		if found {
+			deliverInputReply(&protocol.CommValue{
+				Address: protocol.GonbuiInputReplyAddress,
+				Value:   protocol.InputReply{Id: id, Value: value},
+			})
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	_, err := ReadInput("Name:")
+	assert.Error(t, err, "ReadInput should fail if not running in a notebook")
+
+	var value string
+	received := captureSendData(t, func() {
+		go replyToNextInput("gopher")
+		value, err = ReadInput("Name:")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "gopher", value)
+	require.Len(t, received, 1)
+	req := received[0].Data[protocol.MIMEJupyterInput].(protocol.InputRequest)
+	assert.Equal(t, "Name:", req.Prompt)
+	assert.True(t, req.Reply)
+	assert.False(t, req.Password)
+}
+
+func TestReadPassword(t *testing.T) {
+	var value string
+	var err error
+	received := captureSendData(t, func() {
+		go replyToNextInput("secret")
+		value, err = ReadPassword("Password:")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "secret", value)
+	require.Len(t, received, 1)
+	req := received[0].Data[protocol.MIMEJupyterInput].(protocol.InputRequest)
+	assert.True(t, req.Password)
+}
+
+func TestDisplayCodeBlock(t *testing.T) {
+	received := captureSendData(t, func() { DisplayCodeBlock("go", "a := `x` < 1\n") })
+	require.Len(t, received, 1)
+	htmlContent := received[0].Data[protocol.MIMETextHTML].(string)
+	assert.Contains(t, htmlContent, `class="language-go"`)
+	assert.Contains(t, htmlContent, "a := `x` &lt; 1")
+	assert.Contains(t, htmlContent, "navigator.clipboard.writeText")
+	assert.Equal(t, "```go\na := `x` < 1\n```", received[0].Data[protocol.MIMETextMarkdown])
+	assert.Equal(t, "a := `x` < 1\n", received[0].Data[protocol.MIMETextPlain])
+
+	assert.Equal(t, "````\n```\n````", codeBlockMarkdown("", "```"))
+}
+
+func TestStructTreeHtml(t *testing.T) {
+	type node struct {
+		Name   string
+		next   *node
+		Values map[string]int
+	}
+	root := &node{Name: "root", Values: map[string]int{"b": 2, "a": 1}}
+	root.next = &node{Name: "child", next: root}
+	got := structTreeHtml(root)
+	assert.Contains(t, got, "<b>Name</b>: &#34;root&#34;")
+	assert.Contains(t, got, "<b>Name</b>: &#34;child&#34;")
+	assert.Contains(t, got, "(cycle, already displayed)")
+	assert.Contains(t, got, "<b>&#34;a&#34;</b>: 1")
+	assert.True(t, strings.Index(got, "<b>&#34;a&#34;</b>: 1") < strings.Index(got, "<b>&#34;b&#34;</b>: 2"), "map keys should be sorted")
+
+	defer func(previous int) { MaxDisplayStructItems = previous }(MaxDisplayStructItems)
+	MaxDisplayStructItems = 2
+	got = structTreeHtml([]int{1, 2, 3, 4})
+	assert.Contains(t, got, "(4 items)")
+	assert.Contains(t, got, "... 2 more")
+}
+
+func TestStructTreeText(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	assert.Equal(t, "*gonbui.point\n  X: 1 int\n  Y: 2 int\n", structTreeText(&point{X: 1, Y: 2}))
+
+	// Self-referencing maps and slices are not expanded again, in both the plain text and the HTML.
+	m := map[string]any{"a": 1}
+	m["self"] = m
+	got := structTreeText(m)
+	assert.Equal(t, "map[string]interface {} (2 items)\n  \"a\": 1 interface {}\n"+
+		"  \"self\": interface {} (cycle, already displayed)\n", got)
+	assert.Contains(t, structTreeHtml(m), "(cycle, already displayed)")
+	list := []any{1, nil}
+	list[1] = list
+	assert.Contains(t, structTreeText(list), "1: interface {} (cycle, already displayed)")
+
+	defer func(previous int) { MaxDisplayStructItems = previous }(MaxDisplayStructItems)
+	MaxDisplayStructItems = 2
+	assert.Equal(t, "[]int (4 items)\n  0: 1 int\n  1: 2 int\n  ... 2 more\n", structTreeText([]int{1, 2, 3, 4}))
+}
+
+func TestDisplayTable(t *testing.T) {
+	defer func(previous int) { MaxDisplayValueRows = previous }(MaxDisplayValueRows)
+	MaxDisplayValueRows = 2
+	received := captureSendData(t, func() {
+		DisplayTable([]string{"id", "name"}, [][]string{{"1", "a"}, {"2", "<b>"}, {"3", "c"}})
+	})
+	require.Len(t, received, 1)
+	assert.Equal(t, "<table>\n<tr><th>id</th><th>name</th></tr>\n<tr><td>1</td><td>a</td></tr>\n"+
+		"<tr><td>2</td><td>&lt;b&gt;</td></tr>\n<tr><td colspan=\"2\">... 1 more</td></tr>\n</table>",
+		received[0].Data[protocol.MIMETextHTML])
+	assert.Equal(t, "id\tname\n1\ta\n2\t<b>\n... 1 more\n", received[0].Data[protocol.MIMETextPlain])
+}
+
+func TestReadInputPipeClosed(t *testing.T) {
+	defer func() {
+		mu.Lock()
+		inputRepliesError = nil
+		mu.Unlock()
+	}()
+	var err error
+	_ = captureSendData(t, func() {
+		go func() {
+			// Wait for the request to be registered, and then close the replies.
+			for {
+				mu.Lock()
+				pending := len(inputRepliesMap)
+				mu.Unlock()
+				if pending > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			failInputReplies(errors.New("pipe closed"))
+		}()
+		_, err = ReadInput("Name:")
+	})
+	require.ErrorContains(t, err, "pipe closed")
+
+	// Later requests fail immediately.
+	_ = captureSendData(t, func() {
+		_, err = ReadInput("Name:")
+	})
+	require.ErrorContains(t, err, "pipe closed")
+}
@@ -0,0 +1,160 @@
+package gonbui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"html"
+	"net/http"
+	"strings"
+)
+
+// This file implements the display of audio and video content.
+
+// MediaOptions configures how audio and video content is played in the notebook.
+type MediaOptions struct {
+	// Controls displays the play/pause, volume, etc. controls.
+	Controls bool
+
+	// Autoplay starts playing as soon as the content is displayed. Notice browsers usually block
+	// autoplay of content with sound, unless the user interacted with the page.
+	Autoplay bool
+
+	// Loop restarts playing once it reaches the end.
+	Loop bool
+
+	// Muted starts with the sound muted.
+	Muted bool
+}
+
+// DefaultMediaOptions used by DisplayAudio and DisplayVideo: only the controls are displayed.
+var DefaultMediaOptions = MediaOptions{Controls: true}
+
+// attributes returns the HTML attributes of the `<audio>` or `<video>` elements for the options.
+func (opts MediaOptions) attributes() string {
+	var attrs []string
+	if opts.Controls {
+		attrs = append(attrs, "controls")
+	}
+	if opts.Autoplay {
+		attrs = append(attrs, "autoplay")
+	}
+	if opts.Loop {
+		attrs = append(attrs, "loop")
+	}
+	if opts.Muted {
+		attrs = append(attrs, "muted")
+	}
+	return strings.Join(attrs, " ")
+}
+
+// DisplayAudio displays an audio player in the notebook for the given audio content, e.g.: the
+// contents of a WAV or MP3 file, with the DefaultMediaOptions.
+//
+// The mimeType is the type of the audio content (e.g.: "audio/wav" or "audio/mpeg" for MP3).
+// If left empty, it is detected from the content, and an error is returned if it is not recognized
+// as audio.
+//
+// The audio is embedded in the output (base64 encoded), so it's saved with the notebook.
+func DisplayAudio(data []byte, mimeType string) error {
+	return DisplayAudioWithOptions(data, mimeType, DefaultMediaOptions)
+}
+
+// DisplayAudioWithOptions is like DisplayAudio, but the player is configured with the given options.
+func DisplayAudioWithOptions(data []byte, mimeType string, opts MediaOptions) error {
+	src, err := embedMediaSrc(data, mimeType, "audio/")
+	if err != nil {
+		return err
+	}
+	DisplayHtml(fmt.Sprintf(`<audio %s src="%s"></audio>`, opts.attributes(), src))
+	return nil
+}
+
+// MaxEmbeddedVideoSize is the size above which DisplayVideo and DisplayGIF serve the content from a file
+// (see ServeData), instead of embedding it in the notebook, if serving files is available.
+var MaxEmbeddedVideoSize = 1 << 20
+
+// videoExtensions maps the video MIME types to the extensions of the files they are served from.
+var videoExtensions = map[string]string{
+	"image/gif":  ".gif",
+	"video/mp4":  ".mp4",
+	"video/ogg":  ".ogv",
+	"video/webm": ".webm",
+}
+
+// DisplayVideo displays a video player in the notebook for the given video content, e.g.: the
+// contents of an MP4 or WebM file, with the DefaultMediaOptions.
+//
+// The mimeType is the type of the video content (e.g.: "video/mp4" or "video/webm").
+// If left empty, it is detected from the content, and an error is returned if it is not recognized
+// as video.
+//
+// Videos up to MaxEmbeddedVideoSize are embedded in the output (base64 encoded). Larger ones are
+// served from a file (see ServeData), so they don't bloat the notebook -- but they are only available
+// while the kernel is running. If serving files is not available, they are embedded anyway.
+func DisplayVideo(data []byte, mimeType string) error {
+	return DisplayVideoWithOptions(data, mimeType, DefaultMediaOptions)
+}
+
+// DisplayVideoWithOptions is like DisplayVideo, but the player is configured with the given options.
+func DisplayVideoWithOptions(data []byte, mimeType string, opts MediaOptions) error {
+	src, err := videoSrc(data, mimeType, "video/")
+	if err != nil {
+		return err
+	}
+	DisplayHtml(fmt.Sprintf(`<video %s src="%s"></video>`, opts.attributes(), src))
+	return nil
+}
+
+// DisplayGIF displays the given GIF content, usually an animation.
+// Like DisplayVideo, large GIFs are served from a file instead of being embedded in the notebook.
+func DisplayGIF(data []byte) error {
+	src, err := videoSrc(data, "image/gif", "image/gif")
+	if err != nil {
+		return err
+	}
+	DisplayHtml(fmt.Sprintf(`<img src="%s"/>`, src))
+	return nil
+}
+
+// videoSrc returns the URL to use as the `src` of the element displaying the video: if larger than
+// MaxEmbeddedVideoSize the video is served from a file, otherwise it is embedded.
+func videoSrc(data []byte, mimeType, mimePrefix string) (string, error) {
+	mimeType, err := mediaType(data, mimeType, mimePrefix)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxEmbeddedVideoSize {
+		fileUrl, err := ServeData(fmt.Sprintf("video_%s%s", UniqueId(), videoExtensions[mimeType]), data)
+		if err == nil {
+			return html.EscapeString(fileUrl), nil
+		}
+		Logf("Failed to serve video, embedding it instead: %+v", err)
+	}
+	return embedMediaSrc(data, mimeType, mimePrefix)
+}
+
+// mediaType returns mimeType, or if it is empty, the MIME type detected from the data, in which case
+// it must start with mimePrefix (e.g.: "audio/").
+func mediaType(data []byte, mimeType, mimePrefix string) (string, error) {
+	if mimeType != "" {
+		return mimeType, nil
+	}
+	mimeType = http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, mimePrefix) {
+		return "", errors.Errorf("content type detected as %q, expected %s*: pass the mimeType explicitly",
+			mimeType, mimePrefix)
+	}
+	return mimeType, nil
+}
+
+// embedMediaSrc returns the data encoded as a "data:" URL, to be used as the `src` of an HTML element.
+// See mediaType about mimeType and mimePrefix.
+func embedMediaSrc(data []byte, mimeType, mimePrefix string) (string, error) {
+	mimeType, err := mediaType(data, mimeType, mimePrefix)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", html.EscapeString(mimeType),
+		base64.StdEncoding.EncodeToString(data)), nil
+}
@@ -0,0 +1,274 @@
+// Package plots offers a minimal line/scatter chart, rendered as SVG in pure Go (no dependencies
+// and no javascript), for quick data exploration in notebooks.
+//
+// Example:
+//
+//	xs := []float64{0, 1, 2, 3, 4}
+//	err := plots.New("Growth").
+//		Add("linear", xs, []float64{0, 1, 2, 3, 4}).
+//		Add("quadratic", xs, []float64{0, 1, 4, 9, 16}).
+//		Display()
+//
+// For anything more sophisticated, consider the `plotly` package, or one of the many Go plotting
+// libraries: their output can be displayed with `gonbui.DisplaySvg` or `gonbui.DisplayImage`.
+package plots
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/gonbui"
+	"github.com/pkg/errors"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Palette of colors used for the series, in order.
+var Palette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// Default size of the charts, in pixels.
+var (
+	DefaultWidth  = 640
+	DefaultHeight = 400
+)
+
+// Margins around the plot area, in pixels, to make room for the title, ticks and labels.
+const (
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 32
+	marginBottom = 48
+)
+
+// Series is a sequence of (x, y) points of a Chart.
+type Series struct {
+	// Name of the series, displayed in the legend. The legend is only displayed if some series is named.
+	Name string
+
+	// X, Y coordinates of the points. Points with NaN or infinite values are skipped (and break the line).
+	X, Y []float64
+
+	// Scatter draws only the points, instead of a line connecting them.
+	Scatter bool
+}
+
+// Chart of one or more series of points. Create it with New, add series with Add (or AddScatter),
+// and display it with Display.
+type Chart struct {
+	Title, XLabel, YLabel string
+	Width, Height         int
+	Series                []Series
+}
+
+// New creates a new empty chart with the given title (it can be empty) and the default size.
+func New(title string) *Chart {
+	return &Chart{Title: title, Width: DefaultWidth, Height: DefaultHeight}
+}
+
+// Line displays a chart with a single line series: a shortcut to `New("").Add("", x, y).Display()`.
+// If x is nil, the indices of y are used.
+func Line(x, y []float64) error {
+	return New("").Add("", x, y).Display()
+}
+
+// Scatter displays a chart with a single scatter series: a shortcut to `New("").AddScatter("", x, y).Display()`.
+func Scatter(x, y []float64) error {
+	return New("").AddScatter("", x, y).Display()
+}
+
+// Add a line series to the chart. If x is nil, the indices of y are used.
+// It returns the chart itself, so calls can be chained.
+func (c *Chart) Add(name string, x, y []float64) *Chart {
+	c.Series = append(c.Series, Series{Name: name, X: x, Y: y})
+	return c
+}
+
+// AddScatter adds a scatter series (only the points are drawn) to the chart. If x is nil, the indices of y are used.
+// It returns the chart itself, so calls can be chained.
+func (c *Chart) AddScatter(name string, x, y []float64) *Chart {
+	c.Series = append(c.Series, Series{Name: name, X: x, Y: y, Scatter: true})
+	return c
+}
+
+// Labels sets the labels of the x and y axes.
+// It returns the chart itself, so calls can be chained.
+func (c *Chart) Labels(xLabel, yLabel string) *Chart {
+	c.XLabel, c.YLabel = xLabel, yLabel
+	return c
+}
+
+// Display renders the chart as SVG and displays it in the notebook.
+func (c *Chart) Display() error {
+	svg, err := c.SVG()
+	if err != nil {
+		return err
+	}
+	gonbui.DisplaySvg(svg)
+	return nil
+}
+
+// SVG renders the chart and returns the SVG content.
+func (c *Chart) SVG() (string, error) {
+	if len(c.Series) == 0 {
+		return "", errors.New("chart has no series to plot")
+	}
+	if c.Width <= marginLeft+marginRight || c.Height <= marginTop+marginBottom {
+		return "", errors.Errorf("chart size %dx%d is too small", c.Width, c.Height)
+	}
+	xRange, yRange := newRange(), newRange()
+	for ii, series := range c.Series {
+		if series.X != nil && len(series.X) != len(series.Y) {
+			return "", errors.Errorf("series #%d (%q) has %d x values and %d y values", ii, series.Name, len(series.X), len(series.Y))
+		}
+		for jj, y := range series.Y {
+			x := series.x(jj)
+			if isValid(x) && isValid(y) {
+				xRange.update(x)
+				yRange.update(y)
+			}
+		}
+	}
+	if xRange.empty() {
+		return "", errors.New("chart has no valid (finite) points to plot")
+	}
+	xTicks, yTicks := xRange.ticks(), yRange.ticks()
+
+	plotW, plotH := float64(c.Width-marginLeft-marginRight), float64(c.Height-marginTop-marginBottom)
+	toX := func(x float64) float64 { return marginLeft + (x-xRange.min)/(xRange.max-xRange.min)*plotW }
+	toY := func(y float64) float64 { return marginTop + plotH - (y-yRange.min)/(yRange.max-yRange.min)*plotH }
+
+	var b strings.Builder
+	w := func(format string, args ...any) { _, _ = fmt.Fprintf(&b, format, args...) }
+	w(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`,
+		c.Width, c.Height)
+	w(`<rect width="100%%" height="100%%" fill="white"/>`)
+	if c.Title != "" {
+		w(`<text x="%d" y="%d" text-anchor="middle" font-size="14">%s</text>`,
+			c.Width/2, marginTop-12, html.EscapeString(c.Title))
+	}
+
+	// Grid and ticks.
+	for _, tick := range xTicks {
+		x := toX(tick)
+		w(`<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`, x, marginTop, x, marginTop+plotH)
+		w(`<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, marginTop+plotH+16, formatTick(tick))
+	}
+	for _, tick := range yTicks {
+		y := toY(tick)
+		w(`<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`, marginLeft, y, marginLeft+plotW, y)
+		w(`<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, marginLeft-6, y, formatTick(tick))
+	}
+	w(`<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#808080"/>`, marginLeft, marginTop, plotW, plotH)
+	if c.XLabel != "" {
+		w(`<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, marginLeft+plotW/2, c.Height-8, html.EscapeString(c.XLabel))
+	}
+	if c.YLabel != "" {
+		w(`<text transform="translate(14 %.1f) rotate(-90)" text-anchor="middle">%s</text>`,
+			marginTop+plotH/2, html.EscapeString(c.YLabel))
+	}
+
+	// Series.
+	var legend []int
+	for ii, series := range c.Series {
+		color := Palette[ii%len(Palette)]
+		if series.Name != "" {
+			legend = append(legend, ii)
+		}
+		var points []string
+		flushLine := func() {
+			if len(points) > 1 {
+				w(`<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(points, " "))
+			}
+			points = points[:0]
+		}
+		for jj, y := range series.Y {
+			x := series.x(jj)
+			if !isValid(x) || !isValid(y) {
+				flushLine()
+				continue
+			}
+			if series.Scatter {
+				w(`<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`, toX(x), toY(y), color)
+			} else {
+				points = append(points, fmt.Sprintf("%.1f,%.1f", toX(x), toY(y)))
+			}
+		}
+		flushLine()
+	}
+
+	// Legend, on the top-right corner of the plot area.
+	for row, ii := range legend {
+		x, y := marginLeft+plotW-8, float64(marginTop+16+row*16)
+		w(`<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`,
+			x-16, y, x, y, Palette[ii%len(Palette)])
+		w(`<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			x-20, y, html.EscapeString(c.Series[ii].Name))
+	}
+	w(`</svg>`)
+	return b.String(), nil
+}
+
+// x returns the x coordinate of the point ii of the series.
+func (s *Series) x(ii int) float64 {
+	if s.X == nil {
+		return float64(ii)
+	}
+	return s.X[ii]
+}
+
+// isValid returns whether v is a finite value.
+func isValid(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// valueRange of the values in one of the axes.
+type valueRange struct {
+	min, max float64
+}
+
+func newRange() *valueRange {
+	return &valueRange{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (r *valueRange) empty() bool { return r.min > r.max }
+
+func (r *valueRange) update(v float64) {
+	r.min = math.Min(r.min, v)
+	r.max = math.Max(r.max, v)
+}
+
+// ticks returns "nice" values (multiples of 1, 2 or 5 times a power of 10) to mark on the axis, and expands
+// the range to the closest ticks outside it.
+func (r *valueRange) ticks() []float64 {
+	if r.min == r.max {
+		delta := math.Max(math.Abs(r.min)/2, 1)
+		r.min, r.max = r.min-delta, r.max+delta
+	}
+	rawStep := (r.max - r.min) / 5
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := 10 * magnitude
+	for _, mult := range []float64{1, 2, 5} {
+		if rawStep <= mult*magnitude {
+			step = mult * magnitude
+			break
+		}
+	}
+	first, last := math.Floor(r.min/step), math.Ceil(r.max/step)
+	r.min, r.max = first*step, last*step
+	var ticks []float64
+	for ii := first; ii <= last; ii++ {
+		ticks = append(ticks, ii*step)
+	}
+	return ticks
+}
+
+// formatTick formats the value of a tick compactly.
+func formatTick(v float64) string {
+	if v == 0 {
+		return "0" // Avoid "-0".
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
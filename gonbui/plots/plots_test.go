@@ -0,0 +1,41 @@
+package plots
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTicks(t *testing.T) {
+	r := &valueRange{min: 0.3, max: 9.2}
+	assert.Equal(t, []float64{0, 2, 4, 6, 8, 10}, r.ticks())
+	assert.Equal(t, &valueRange{min: 0, max: 10}, r)
+
+	r = &valueRange{min: 3, max: 3}
+	assert.Equal(t, []float64{1, 2, 3, 4, 5}, r.ticks())
+	assert.Equal(t, "0", formatTick(-0.0))
+	assert.Equal(t, "0.3", formatTick(3*0.1))
+}
+
+func TestSVG(t *testing.T) {
+	svg, err := New("A <title>").
+		Add("line", nil, []float64{1, 2, math.NaN(), 4, 5}).
+		AddScatter("", []float64{0, 1}, []float64{3, 2}).
+		Labels("x", "y").
+		SVG()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, "A &lt;title&gt;")
+	assert.Equal(t, 2, strings.Count(svg, "<polyline"), "Line should be broken at the NaN value")
+	assert.Equal(t, 2, strings.Count(svg, "<circle"))
+	assert.Contains(t, svg, ">line</text>") // Legend.
+
+	_, err = New("").SVG()
+	assert.Error(t, err)
+	_, err = New("").Add("", []float64{1}, []float64{1, 2}).SVG()
+	assert.Error(t, err)
+	_, err = New("").Add("", nil, []float64{math.Inf(1)}).SVG()
+	assert.Error(t, err)
+}
@@ -68,6 +68,16 @@ const (
 	// Notice that the Wasm program gets this value from a global variable automatically introduced in the Go code,
 	// see `%help`.
 	GONB_WASM_URL_ENV = "GONB_WASM_URL"
+
+	// GONB_FILES_DIR_ENV is the directory "${GONB_JUPYTER_ROOT}/jupyter_files/<kernel unique id>/files/"
+	// where files to be served by Jupyter are stored, see `gonbui.ServeFile`.
+	// It is only set if GoNB managed to find the Jupyter root directory, and it is removed
+	// when the kernel shuts down.
+	// See GONB_FILES_URL_ENV.
+	GONB_FILES_DIR_ENV = "GONB_FILES_DIR"
+
+	// GONB_FILES_URL_ENV is the URL path from where Jupyter serves the files stored in GONB_FILES_DIR_ENV.
+	GONB_FILES_URL_ENV = "GONB_FILES_URL"
 )
 
 type MIMEType string
@@ -81,11 +91,17 @@ const (
 	MIMEImageSVG       MIMEType = "image/svg+xml"
 
 	// MIMEJupyterInput maps to an `*InputRequest`, and requests input from Jupyter.
-	// It's used by `gonbui.RequestInput`.
+	// It's used by `gonbui.RequestInput` and `gonbui.ReadInput`.
 	//
 	// It's a GoNB specific mime type.
 	MIMEJupyterInput MIMEType = "gonb/jupyter_input"
 
+	// MIMEJupyterError maps to an `ErrorReport` (a value, not a pointer), and displays it as an error output in the notebook.
+	// It's used by `gonbui.DisplayError`.
+	//
+	// It's a GoNB specific mime type.
+	MIMEJupyterError MIMEType = "gonb/jupyter_error"
+
 	// MIMECommValue maps to a `*CommValue`. It can be used to send or request a value to/from
 	// the front-end (notebook).
 	// It's used by `comms.UpdateValue` and `comms.ReadValue`, used by widgets implementations.
@@ -128,6 +144,38 @@ type InputRequest struct {
 
 	// Password input, in which case the contents are not displayed.
 	Password bool
+
+	// Reply indicates the value entered should be sent back to the program as an InputReply, to the address
+	// GonbuiInputReplyAddress, as opposed to being written to the program's stdin.
+	Reply bool
+
+	// ReplyId is included in the InputReply, to identify the request. Only used if Reply is set.
+	ReplyId int
+}
+
+// InputReply is sent back to the program, with the value entered for an InputRequest, if it had Reply set.
+type InputReply struct {
+	// Id of the request, see InputRequest.ReplyId.
+	Id int
+
+	// Value entered by the user.
+	Value string
+
+	// Error is set if the input couldn't be requested, e.g.: the front-end doesn't support input.
+	Error string
+}
+
+// ErrorReport is displayed as an error output in the front-end, mimicking the contents of the
+// "error" message used by Jupyter.
+type ErrorReport struct {
+	// Name of the error, usually its type.
+	Name string
+
+	// Value is the error message.
+	Value string
+
+	// Traceback lines, may contain ANSI escape sequences for colors.
+	Traceback []string
 }
 
 // CommValueTypes currently accepted for communication with front-end.
@@ -156,6 +204,8 @@ const (
 	GonbuiSyncAddress = "#gonbui/sync"
 	// GonbuiSyncAckAddress is for internal use -- used to implement `gonbui.Sync`.
 	GonbuiSyncAckAddress = "#gonbui/sync_ack"
+	// GonbuiInputReplyAddress is for internal use -- used to implement `gonbui.ReadInput`.
+	GonbuiInputReplyAddress = "#gonbui/input_reply"
 	// GonbuiStartAddress is for internal use -- used to implement `comms.Start`.
 	GonbuiStartAddress = "#comms/start"
 )
@@ -163,6 +213,8 @@ const (
 func init() {
 	gob.Register(DisplayData{})
 	gob.Register(InputRequest{})
+	gob.Register(InputReply{})
+	gob.Register(ErrorReport{})
 	gob.Register(CommValue{})
 	gob.Register(CommSubscription{})
 
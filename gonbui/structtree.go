@@ -0,0 +1,232 @@
+package gonbui
+
+import (
+	"fmt"
+	"html"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file implements DisplayStruct, to display nested values as a collapsible tree.
+
+var (
+	// MaxDisplayStructDepth is the maximum depth of the tree displayed by DisplayStruct. Deeper values are elided.
+	MaxDisplayStructDepth = 10
+
+	// MaxDisplayStructItems is the maximum number of fields, elements or entries of each node of the tree displayed
+	// by DisplayStruct. The remaining ones are elided.
+	MaxDisplayStructItems = 100
+)
+
+// DisplayStruct displays the value as a collapsible tree: structs, maps, slices, arrays and the pointers to them are
+// nodes that can be expanded to show their fields, entries or elements, annotated with their types.
+//
+// Unexported fields are displayed as well, and pointers, maps and slices already being displayed in the path from
+// the root (cycles) are not expanded again. The size of the tree is limited by MaxDisplayStructDepth and
+// MaxDisplayStructItems.
+//
+// It also includes a plain text alternative (an indented tree, with the same limits), for front-ends
+// (or converters) that don't render HTML.
+func DisplayStruct(value any) {
+	if !IsNotebook {
+		return
+	}
+	DisplayMIMEMap(map[string]any{
+		"text/html":  structTreeHtml(value),
+		"text/plain": structTreeText(value),
+	})
+}
+
+// structTreeHtml returns the HTML used by DisplayStruct.
+func structTreeHtml(value any) string {
+	var sb strings.Builder
+	sb.WriteString(`<div style="font-family: monospace">`)
+	tree := &structTree{sb: &sb, visiting: make(map[visitKey]bool)}
+	tree.node("", reflect.ValueOf(value), 0)
+	sb.WriteString("</div>")
+	return sb.String()
+}
+
+// structTreeText returns the plain text alternative used by DisplayStruct.
+func structTreeText(value any) string {
+	var sb strings.Builder
+	tree := &structTree{sb: &sb, visiting: make(map[visitKey]bool), text: true}
+	tree.node("", reflect.ValueOf(value), 0)
+	return sb.String()
+}
+
+// structTree renders a value as a tree of nested `<details>` blocks, or as an indented plain text tree.
+type structTree struct {
+	sb *strings.Builder
+
+	// text renders plain text, instead of HTML.
+	text bool
+
+	// visiting holds the pointers, maps and slices being rendered in the path from the root, to detect cycles.
+	visiting map[visitKey]bool
+}
+
+// visitKey identifies a pointer, map or slice for the cycle detection: the address alone is not enough, since
+// the first field of a struct (or element of an array) has the same address as the struct.
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// escape the text for the output format.
+func (t *structTree) escape(text string) string {
+	if t.text {
+		return text
+	}
+	return html.EscapeString(text)
+}
+
+// line writes one line (a leaf, or a node that is not expanded) of the tree, at the given depth.
+func (t *structTree) line(depth int, text string) {
+	if t.text {
+		fmt.Fprintf(t.sb, "%s%s\n", strings.Repeat("  ", depth), text)
+	} else {
+		fmt.Fprintf(t.sb, "<div>%s</div>\n", text)
+	}
+}
+
+// enter starts tracking v, if it is a pointer, map or slice, in the path from the root. It returns false
+// if it is already being displayed (a cycle).
+func (t *structTree) enter(v reflect.Value) (key visitKey, ok bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return key, true
+		}
+		key = visitKey{ptr: v.Pointer(), typ: v.Type()}
+		if t.visiting[key] {
+			return key, false
+		}
+		t.visiting[key] = true
+	}
+	return key, true
+}
+
+// node renders the value v, labeled with label (the field name, key or index), at the given depth.
+func (t *structTree) node(label string, v reflect.Value, depth int) {
+	if label != "" {
+		if t.text {
+			label += ": "
+		} else {
+			label = fmt.Sprintf("<b>%s</b>: ", html.EscapeString(label))
+		}
+	}
+	if !v.IsValid() {
+		t.line(depth, label+"nil")
+		return
+	}
+	typeName := t.escape(v.Type().String())
+	if !t.text {
+		typeName = fmt.Sprintf(`<span style="color: gray">%s</span>`, typeName)
+	}
+	for {
+		key, ok := t.enter(v)
+		if !ok {
+			t.line(depth, fmt.Sprintf("%s%s (cycle, already displayed)", label, typeName))
+			return
+		}
+		if key.ptr != 0 {
+			defer delete(t.visiting, key)
+		}
+		if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+			break
+		}
+		if v.IsNil() {
+			t.line(depth, fmt.Sprintf("%snil %s", label, typeName))
+			return
+		}
+		v = v.Elem()
+	}
+
+	var length int
+	switch v.Kind() {
+	case reflect.Struct:
+		length = v.NumField()
+	case reflect.Slice, reflect.Array, reflect.Map:
+		length = v.Len()
+	default:
+		t.line(depth, fmt.Sprintf("%s%s %s", label, t.escape(formatLeaf(v)), typeName))
+		return
+	}
+	summary := fmt.Sprintf("%s%s", label, typeName)
+	if v.Kind() != reflect.Struct {
+		summary += fmt.Sprintf(" (%d items)", length)
+	}
+	if length == 0 {
+		t.line(depth, summary+" {}")
+		return
+	}
+	if depth >= MaxDisplayStructDepth {
+		t.line(depth, summary+" ... (max depth reached)")
+		return
+	}
+	if t.text {
+		t.line(depth, summary)
+	} else {
+		open := ""
+		if depth == 0 {
+			open = " open"
+		}
+		fmt.Fprintf(t.sb, "<details%s><summary>%s</summary>\n<div style=\"margin-left: 1.5em\">\n", open, summary)
+	}
+	count := min(length, MaxDisplayStructItems)
+	switch v.Kind() {
+	case reflect.Struct:
+		for ii := 0; ii < count; ii++ {
+			t.node(v.Type().Field(ii).Name, v.Field(ii), depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		for ii := 0; ii < count; ii++ {
+			t.node(fmt.Sprintf("%d", ii), v.Index(ii), depth+1)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		labels := make([]string, len(keys))
+		for ii, key := range keys {
+			labels[ii] = formatLeaf(key)
+		}
+		order := make([]int, len(keys))
+		for ii := range order {
+			order[ii] = ii
+		}
+		sort.Slice(order, func(i, j int) bool { return labels[order[i]] < labels[order[j]] })
+		for _, ii := range order[:count] {
+			t.node(labels[ii], v.MapIndex(keys[ii]), depth+1)
+		}
+	}
+	if length > count {
+		t.line(depth+1, fmt.Sprintf("... %d more", length-count))
+	}
+	if !t.text {
+		t.sb.WriteString("</div>\n</details>\n")
+	}
+}
+
+// formatLeaf formats a value that is not expanded in the tree. It doesn't use v.Interface(), so it also works
+// for values read from unexported fields.
+func formatLeaf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("%v", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprintf("%d", v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%g", v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("%g", v.Complex())
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	}
+	if v.CanInterface() {
+		return fmt.Sprintf("%v", v.Interface())
+	}
+	return fmt.Sprintf("<%s>", v.Type())
+}
@@ -0,0 +1,146 @@
+package gonbui
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file implements DisplayValue, used to display the value of the last expression of a cell (see `%autoprint`),
+// and DisplayTable.
+
+// MaxDisplayValueRows is the maximum number of rows of the tables displayed by DisplayValue. The remaining
+// rows are elided.
+var MaxDisplayValueRows = 100
+
+// DisplayValue displays any value in a representation chosen by its type:
+//
+//   - Slices and arrays are displayed as a table: one column per exported field, if the elements are structs,
+//     otherwise the index and the value.
+//   - Maps are displayed as a table of keys and values, sorted by key.
+//   - Structs (and pointers to structs) are displayed as indented JSON.
+//   - Anything else is displayed as text, formatted with `fmt.Sprintf("%v")`.
+//
+// It also includes a plain text alternative, for front-ends (or converters) that don't render HTML.
+func DisplayValue(value any) {
+	DisplayMIMEMap(map[string]any{
+		"text/html":  valueToHtml(value),
+		"text/plain": fmt.Sprintf("%+v", value),
+	})
+}
+
+// DisplayTable displays the rows as a table, with the given header. Only the first MaxDisplayValueRows rows are
+// displayed, the remaining ones are elided.
+//
+// It also includes a plain text alternative, with the values separated by tabs.
+func DisplayTable(header []string, rows [][]string) {
+	total := len(rows)
+	rows = rows[:min(total, MaxDisplayValueRows)]
+	var sb strings.Builder
+	for _, row := range append([][]string{header}, rows...) {
+		sb.WriteString(strings.Join(row, "\t"))
+		sb.WriteString("\n")
+	}
+	if total > len(rows) {
+		fmt.Fprintf(&sb, "... %d more\n", total-len(rows))
+	}
+	DisplayMIMEMap(map[string]any{
+		"text/html":  htmlTable(header, rows, total),
+		"text/plain": sb.String(),
+	})
+}
+
+// valueToHtml returns the HTML representation of the value used by DisplayValue.
+func valueToHtml(value any) string {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			break
+		}
+		return sliceToHtml(v)
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		return mapToHtml(v)
+	case reflect.Struct:
+		encoded, err := json.MarshalIndent(v.Interface(), "", "  ")
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(string(encoded)))
+	}
+	return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(fmt.Sprintf("%v", value)))
+}
+
+// sliceToHtml renders a slice or array as an HTML table.
+func sliceToHtml(v reflect.Value) string {
+	var header []string
+	var rows [][]string
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Struct {
+		var fields []int
+		for ii := 0; ii < elemType.NumField(); ii++ {
+			if elemType.Field(ii).IsExported() {
+				fields = append(fields, ii)
+				header = append(header, elemType.Field(ii).Name)
+			}
+		}
+		for ii := 0; ii < min(v.Len(), MaxDisplayValueRows); ii++ {
+			row := make([]string, 0, len(fields))
+			for _, field := range fields {
+				row = append(row, fmt.Sprintf("%v", v.Index(ii).Field(field).Interface()))
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		header = []string{"", "Value"}
+		for ii := 0; ii < min(v.Len(), MaxDisplayValueRows); ii++ {
+			rows = append(rows, []string{fmt.Sprintf("%d", ii), fmt.Sprintf("%v", v.Index(ii).Interface())})
+		}
+	}
+	return htmlTable(header, rows, v.Len())
+}
+
+// mapToHtml renders a map as an HTML table of keys and values, sorted by the formatted keys.
+func mapToHtml(v reflect.Value) string {
+	rows := make([][]string, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		rows = append(rows, []string{fmt.Sprintf("%v", iter.Key().Interface()), fmt.Sprintf("%v", iter.Value().Interface())})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	total := len(rows)
+	rows = rows[:min(total, MaxDisplayValueRows)]
+	return htmlTable([]string{"Key", "Value"}, rows, total)
+}
+
+// htmlTable renders the header and rows as an HTML table. If total is larger than the number of rows, a final
+// row notes how many were elided.
+func htmlTable(header []string, rows [][]string, total int) string {
+	var sb strings.Builder
+	sb.WriteString("<table>\n<tr>")
+	for _, name := range header {
+		fmt.Fprintf(&sb, "<th>%s</th>", html.EscapeString(name))
+	}
+	sb.WriteString("</tr>\n")
+	for _, row := range rows {
+		sb.WriteString("<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(&sb, "<td>%s</td>", html.EscapeString(cell))
+		}
+		sb.WriteString("</tr>\n")
+	}
+	if total > len(rows) {
+		fmt.Fprintf(&sb, "<tr><td colspan=\"%d\">... %d more</td></tr>\n", max(len(header), 1), total-len(rows))
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
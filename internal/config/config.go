@@ -0,0 +1,79 @@
+// Package config handles the kernel configuration persisted in a per-user file, so the preferred
+// settings (see `%config`) don't need to be set again in every notebook.
+//
+// The file is a JSON object mapping the configuration keys to their values, as they are given to
+// `%config <key>=<value>`. E.g.:
+//
+//	{
+//	  "autoget": "off",
+//	  "goflags": "-race"
+//	}
+//
+// It is loaded when the kernel starts, so commands executed in the notebook take precedence.
+package config
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path"
+)
+
+const (
+	// PathEnvName is the name of the environment variable that, if set, overrides the path of the
+	// configuration file.
+	PathEnvName = "GONB_CONFIG"
+
+	// FileName is the name of the configuration file, in the GoNB subdirectory of the user's
+	// configuration directory (see os.UserConfigDir). E.g.: `~/.config/gonb/config.json`.
+	FileName = "config.json"
+)
+
+// Config maps configuration keys to their values.
+type Config map[string]string
+
+// DefaultPath returns the path of the configuration file: the value of the environment variable
+// GONB_CONFIG if set, or `<user_config_dir>/gonb/config.json` otherwise.
+func DefaultPath() (string, error) {
+	if filePath := os.Getenv(PathEnvName); filePath != "" {
+		return filePath, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrapf(err, "can't find the user's configuration directory, set %s to the configuration file path", PathEnvName)
+	}
+	return path.Join(configDir, "gonb", FileName), nil
+}
+
+// Load reads the configuration from filePath. If the file doesn't exist, it returns an empty
+// configuration.
+func Load(filePath string) (Config, error) {
+	cfg := make(Config)
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read configuration from %q", filePath)
+	}
+	if err = json.Unmarshal(contents, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse configuration in %q", filePath)
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration to filePath, creating its directory if needed.
+func (cfg Config) Save(filePath string) error {
+	contents, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode configuration")
+	}
+	if err = os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for configuration file %q", filePath)
+	}
+	contents = append(contents, '\n')
+	if err = os.WriteFile(filePath, contents, 0644); err != nil {
+		return errors.Wrapf(err, "failed to save configuration to %q", filePath)
+	}
+	return nil
+}
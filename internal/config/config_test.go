@@ -0,0 +1,36 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestLoadAndSave(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "gonb", FileName)
+
+	// Missing file: empty configuration.
+	cfg, err := Load(filePath)
+	require.NoError(t, err)
+	assert.Empty(t, cfg)
+
+	cfg = Config{"autoget": "off", "goflags": "-race"}
+	require.NoError(t, cfg.Save(filePath))
+	loaded, err := Load(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, loaded)
+
+	// Invalid file.
+	require.NoError(t, os.WriteFile(filePath, []byte("autoget=off"), 0644))
+	_, err = Load(filePath)
+	assert.Error(t, err)
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv(PathEnvName, "/some/where/config.json")
+	filePath, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, "/some/where/config.json", filePath)
+}
@@ -15,6 +15,7 @@ import (
 	"k8s.io/klog/v2"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -140,7 +141,7 @@ func handleShellMsg(msg kernel.Message, goExec *goexec.State) (err error) {
 		case "interrupt_request":
 			// Interrupt current cell being executed if any.
 			klog.V(2).Infof("Received interrupt_request.")
-			msg.Kernel().CallInterruptSubscribers()
+			msg.Kernel().Interrupt()
 			replyContent := make(map[string]any)
 			replyContent["status"] = "ok"
 			err = msg.Reply("interrupt_reply", replyContent)
@@ -235,7 +236,7 @@ func handleBusyMessage(msg kernel.Message, goExec *goexec.State) (err error) {
 // handleShutdownRequest sends a "shutdown" message.
 func handleShutdownRequest(msg kernel.Message, goExec *goexec.State) error {
 	klog.Info("Shutting down in response to shutdown_request")
-	msg.Kernel().CallInterruptSubscribers() // Interrupt current runs.
+	msg.Kernel().Interrupt() // Interrupt current runs.
 
 	content := msg.ComposedMsg().Content.(map[string]any)
 	replyContent := make(map[string]any)
@@ -292,20 +293,14 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 	// Dispatch to various executors.
 	msg.Kernel().Interrupted.Store(false)
 	lines := strings.Split(code, "\n")
-	specialLines := MakeSet[int]() // lines that are special commands and not Go.
-	var executionErr error
-
-	if specialCell, err := specialcmd.ExecuteSpecialCell(msg, goExec, lines); specialCell {
-		executionErr = err // err may be nil here, if magic cell command was executed correctly.
-
-	} else {
-		if err := specialcmd.Parse(msg, goExec, true, lines, specialLines); err != nil {
-			executionErr = errors.WithMessagef(err, "executing special commands in cell")
-		}
-		hasMoreToRun := !goexec.IsEmptyLines(lines, specialLines) || goExec.CellIsTest
-		if executionErr == nil && !msg.Kernel().Interrupted.Load() && hasMoreToRun {
-			executionErr = goExec.ExecuteCell(msg, msg.Kernel().ExecCounter, lines, specialLines)
-		}
+	if !silent {
+		specialcmd.RecordMacroCell(goExec, msg.Kernel().ExecCounter, lines)
+	}
+	start := time.Now()
+	executionErr := specialcmd.ExecuteTaggedCell(msg, goExec, msg.Kernel().ExecCounter, lines)
+	logCellExecution(msg.Kernel().ExecCounter, time.Since(start), executionErr)
+	if executionErr != nil {
+		goExec.RecordCellError(msg.Kernel().ExecCounter, executionErr)
 	}
 
 	// Final execution result.
@@ -336,6 +331,18 @@ func handleExecuteRequest(msg kernel.Message, goExec *goexec.State) error {
 	return nil
 }
 
+// logCellExecution logs the execution of a cell with structured fields (see `--log_json` flag), so
+// it can be reliably processed by log scrapers.
+func logCellExecution(execCount int, elapsed time.Duration, executionErr error) {
+	if executionErr == nil {
+		klog.InfoS("Cell executed", "execution_count", execCount, "duration_ms", elapsed.Milliseconds(), "status", "ok")
+		return
+	}
+	name, value, _ := goexec.JupyterErrorSplit(executionErr)
+	klog.InfoS("Cell executed", "execution_count", execCount, "duration_ms", elapsed.Milliseconds(), "status", "error",
+		"error_name", name, "error", value)
+}
+
 // HandleInspectRequest presents rich data (HTML?) with contextual information for the
 // contents under the cursor.
 func HandleInspectRequest(msg kernel.Message, goExec *goexec.State) error {
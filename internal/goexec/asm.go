@@ -0,0 +1,173 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"html"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file implements `%asm`, which displays the assembly generated by the compiler for a function.
+
+var (
+	// regexpAsmSymbol matches the line that starts the assembly of a function in the output of
+	// `-gcflags=-S`, e.g.: "main.(*T).Inc STEXT nosplit size=4 args=0x8 locals=0x0".
+	regexpAsmSymbol = regexp.MustCompile(`^main\.(\S+) STEXT`)
+
+	// regexpAsmInstruction matches an instruction in the output of `-gcflags=-S`, e.g.:
+	// "\t0x0004 00004 (/tmp/gonb_1234/main.go:4)\tIMULQ\tAX, AX".
+	regexpAsmInstruction = regexp.MustCompile(`^\t(0x[0-9a-f]+) \d+ \(([^)]*)\)\t(.*)$`)
+
+	// regexpTypeParameters matches the type parameters in the name of the symbols of generic functions.
+	regexpTypeParameters = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+// asmPseudoInstructions are left out of the display, since they only carry information for the garbage
+// collector and the stack unwinding.
+var asmPseudoInstructions = common.SetWithValues("TEXT", "FUNCDATA", "PCDATA")
+
+// normalizeAsmName converts a function or method name as written by the user (e.g.: "Norm", "T.Inc" or
+// "(*T).Inc") or as a symbol of the assembly (e.g.: "(*T[go.shape.int]).Inc") to a common form, e.g.: "T.Inc".
+func normalizeAsmName(name string) string {
+	name = regexpTypeParameters.ReplaceAllString(name, "")
+	name = strings.NewReplacer("(*", "", "(", "", ")", "", "~", ".").Replace(name)
+	return name
+}
+
+// displayAssembly compiles the program of the current cell with `-gcflags=-S` and displays the assembly
+// generated for the function (or method) s.CellAsmFunction, including its closures, with the instructions
+// grouped by the lines of the cells they come from.
+func (s *State) displayAssembly(msg kernel.Message, decls *Declarations, fileToCellIdAndLine []CellIdAndLine) error {
+	target := normalizeAsmName(s.CellAsmFunction)
+	if _, found := decls.Functions[strings.Replace(target, ".", "~", 1)]; !found {
+		return errors.Errorf("`%%asm %s`: function not found, it must be a function or method memorized or "+
+			"defined in the cell, e.g.: `%%asm MyFunc` or `%%asm MyType.MyMethod`", s.CellAsmFunction)
+	}
+
+	output, err := s.compileWithGcFlags(msg, fileToCellIdAndLine, "-S")
+	if err != nil {
+		return err
+	}
+	src, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	htmlAsm := formatAssembly(output, target, s.CodePath(), strings.Split(src, "\n"), fileToCellIdAndLine)
+	if htmlAsm == "" {
+		return errors.Errorf("`%%asm %s`: no assembly generated for the function, it may have been "+
+			"inlined in all its uses, or be unused generic code", s.CellAsmFunction)
+	}
+	return kernel.PublishHtml(msg, htmlAsm)
+}
+
+// formatAssembly extracts from output of `-gcflags=-S` the assembly of the function target (normalized with
+// normalizeAsmName) and of its closures, and formats it as HTML. Each group of instructions is preceded by
+// the source line it comes from, with its cell line, if it maps to one.
+//
+// It returns an empty string if the function is not found in the output.
+func formatAssembly(output, target, codePath string, srcLines []string, fileToCellIdAndLine []CellIdAndLine) string {
+	var sb strings.Builder
+	var inTarget bool
+	var header, lastReference string
+	for _, line := range strings.Split(output, "\n") {
+		if matches := regexpAsmSymbol.FindStringSubmatch(line); matches != nil {
+			name := normalizeAsmName(matches[1])
+			inTarget = name == target || strings.HasPrefix(name, target+".func")
+			if inTarget {
+				// The header is only written with the first instruction: inlined closures have none.
+				header = fmt.Sprintf("<b>main.%s</b>\n<pre style=\"margin: 0\">", html.EscapeString(matches[1]))
+				lastReference = ""
+			}
+			continue
+		}
+		if !inTarget {
+			continue
+		}
+		matches := regexpAsmInstruction.FindStringSubmatch(line)
+		if matches == nil {
+			// Data dumps, relocations and the start of other symbols.
+			continue
+		}
+		offset, reference, instruction := matches[1], matches[2], matches[3]
+		if op, _, _ := strings.Cut(instruction, "\t"); asmPseudoInstructions.Has(op) {
+			continue
+		}
+		if header != "" {
+			if sb.Len() > 0 {
+				sb.WriteString("</pre>\n")
+			}
+			sb.WriteString(header)
+			header = ""
+		}
+		if reference != lastReference {
+			lastReference = reference
+			sb.WriteString(fmt.Sprintf("<span style=\"color: #408080\">%s</span>\n",
+				html.EscapeString(describeSourceLine(reference, codePath, srcLines, fileToCellIdAndLine))))
+		}
+		sb.WriteString(fmt.Sprintf("    %s  %s\n", offset, html.EscapeString(strings.Replace(instruction, "\t", " ", 1))))
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("</pre>\n")
+	}
+	return sb.String()
+}
+
+// describeSourceLine describes a line of the generated program, given its reference (e.g.:
+// "/tmp/gonb_1234/main.go:4"): the cell line, if it maps to one, and the contents of the line.
+func describeSourceLine(reference, codePath string, srcLines []string, fileToCellIdAndLine []CellIdAndLine) string {
+	sep := strings.LastIndex(reference, ":")
+	if sep < 0 || reference[:sep] != codePath {
+		return "// " + reference
+	}
+	lineNum, err := strconv.Atoi(reference[sep+1:])
+	if err != nil || lineNum < 1 || lineNum > len(srcLines) {
+		return "// " + reference
+	}
+	source := strings.TrimSpace(srcLines[lineNum-1])
+	if cellLine, found := CellLine(fileToCellIdAndLine, lineNum); found {
+		return fmt.Sprintf("// %s: %s", cellLine, source)
+	}
+	return fmt.Sprintf("// %s: %s", reference, source)
+}
+
+// compileWithGcFlags compiles the program of the current cell with the given `-gcflags` (after the ones set
+// with `%goflags`), and returns the output of the compiler. Compilation errors are displayed.
+//
+// Like Compile, unused variables and imports are silenced if configured, see State.Lenient.
+func (s *State) compileWithGcFlags(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine, gcFlags string) (string, error) {
+	var args []string
+	if s.CellIsTest {
+		args = []string{"test", "-c", "-o", s.BinaryPath()}
+	} else {
+		args = []string{"build", "-o", s.BinaryPath()}
+	}
+	if s.Vendored {
+		args = append(args, "-mod=vendor")
+	}
+	pgoFlag, err := s.pgoFlag()
+	if err != nil {
+		return "", err
+	}
+	args = append(args, pgoFlag)
+	args = append(args, s.GoBuildFlags...)
+	args = append(args, "-gcflags="+gcFlags)
+	compileCmd := func() *exec.Cmd {
+		cmd := s.GoCommand(args...)
+		cmd.Dir = s.TempDir
+		if s.GoOS != "" || s.GoArch != "" {
+			cmd.Env = withTarget(cmd.Environ(), s.GoOS, s.GoArch)
+		}
+		return cmd
+	}
+	cmd, output, err := s.runCompile(msg, fileToCellIdAndLine, compileCmd)
+	if err != nil {
+		err := s.DisplayErrorWithContext(msg, fileToCellIdAndLine, string(output), err)
+		return "", errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	return string(output), nil
+}
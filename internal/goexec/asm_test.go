@@ -0,0 +1,48 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAsmName(t *testing.T) {
+	assert.Equal(t, "Norm", normalizeAsmName("Norm"))
+	assert.Equal(t, "T.Inc", normalizeAsmName("(*T).Inc"))
+	assert.Equal(t, "T.Inc", normalizeAsmName("T~Inc"))
+	assert.Equal(t, "T.Inc", normalizeAsmName("(*T[go.shape.int]).Inc"))
+	assert.Equal(t, "Sum", normalizeAsmName("Sum[go.shape.float64]"))
+}
+
+func TestFormatAssembly(t *testing.T) {
+	output := strings.Join([]string{
+		"# gonb_1234",
+		"main.Norm.func1 STEXT size=0 align=0x0 args=0x0 locals=0x0 funcid=0x0",
+		"main.Norm STEXT nosplit size=12 align=0x0 args=0x10 locals=0x0 funcid=0x0",
+		"\t0x0000 00000 (/tmp/gonb_1234/main.go:4)\tTEXT\tmain.Norm(SB), NOSPLIT|NOFRAME|ABIInternal, $0-16",
+		"\t0x0000 00000 (/tmp/gonb_1234/main.go:4)\tFUNCDATA\t$0, gclocals(SB)",
+		"\t0x0000 00000 (/tmp/gonb_1234/main.go:4)\tIMULQ\tBX, BX",
+		"\t0x0004 00004 (/tmp/gonb_1234/main.go:5)\tADDQ\tBX, AX",
+		"\t0x000b 00011 (/tmp/gonb_1234/main.go:5)\tRET",
+		"\t0x0000 48 0f af db 48 0f af c0 48 01 d8 c3              H...H...H...",
+		"main.main STEXT size=71 align=0x0 args=0x0 locals=0x10 funcid=0x0",
+		"\t0x0000 00000 (/tmp/gonb_1234/main.go:8)\tCALL\tmain.Norm(SB)",
+	}, "\n")
+	srcLines := []string{"package main", "", "", "func Norm(a, b int) int {", "\treturn a*a + b*b", "}", "", "func main() {"}
+	fileToCellIdAndLine := make([]CellIdAndLine, len(srcLines))
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: 3, Line: NoCursorLine}
+	}
+	fileToCellIdAndLine[4] = CellIdAndLine{Id: 3, Line: 1}
+
+	got := formatAssembly(output, "Norm", "/tmp/gonb_1234/main.go", srcLines, fileToCellIdAndLine)
+	assert.Equal(t, 1, strings.Count(got, "<pre"), "inlined closure without instructions should be omitted")
+	assert.Contains(t, got, "<b>main.Norm</b>")
+	assert.Contains(t, got, "// /tmp/gonb_1234/main.go:4: func Norm(a, b int) int {")
+	assert.Contains(t, got, "// [cell 3] line 2: return a*a + b*b")
+	assert.Contains(t, got, "0x0000  IMULQ BX, BX")
+	assert.Contains(t, got, "0x000b  RET")
+	assert.NotContains(t, got, "FUNCDATA")
+	assert.NotContains(t, got, "CALL")
+	assert.Equal(t, "", formatAssembly(output, "Missing", "/tmp/gonb_1234/main.go", srcLines, fileToCellIdAndLine))
+}
@@ -0,0 +1,51 @@
+package goexec
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// This file implements the automatic display of the last expression of `func main()`, see State.AutoPrint.
+
+// GonbuiPackage is the import path of the package used by the programs to display rich content.
+const GonbuiPackage = "github.com/janpfeifer/gonb/gonbui"
+
+// autoPrintLastExpression rewrites the definition of `func main()` such that, if its last statement is a bare
+// expression, its value is displayed with `gonbui.DisplayValue`.
+//
+// Function calls and receive operations are valid statements by themselves, so they are left as is:
+// only expressions that would otherwise not compile (e.g.: a variable name) are displayed.
+//
+// It returns the rewritten definition and true, or the unchanged definition and false if there was nothing to display.
+func autoPrintLastExpression(definition string) (string, bool) {
+	const prefix = "package main\n"
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", prefix+definition, 0)
+	if err != nil || len(file.Decls) != 1 {
+		return definition, false
+	}
+	funcDecl, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok || funcDecl.Body == nil || len(funcDecl.Body.List) == 0 {
+		return definition, false
+	}
+	exprStmt, ok := funcDecl.Body.List[len(funcDecl.Body.List)-1].(*ast.ExprStmt)
+	if !ok {
+		return definition, false
+	}
+	expr := exprStmt.X
+	for paren, isParen := expr.(*ast.ParenExpr); isParen; paren, isParen = expr.(*ast.ParenExpr) {
+		expr = paren.X
+	}
+	switch expr := expr.(type) {
+	case *ast.CallExpr:
+		return definition, false
+	case *ast.UnaryExpr:
+		if expr.Op == token.ARROW {
+			return definition, false
+		}
+	}
+	start := fileSet.Position(exprStmt.Pos()).Offset - len(prefix)
+	end := fileSet.Position(exprStmt.End()).Offset - len(prefix)
+	return definition[:start] + "gonbui.DisplayValue(" + definition[start:end] + ")" + definition[end:], true
+}
@@ -0,0 +1,25 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAutoPrintLastExpression(t *testing.T) {
+	definition, changed := autoPrintLastExpression("func main() {\n\tflag.Parse()\n\tx := 3\n\tx * 2\n}")
+	assert.True(t, changed)
+	assert.Equal(t, "func main() {\n\tflag.Parse()\n\tx := 3\n\tgonbui.DisplayValue(x * 2)\n}", definition)
+
+	for _, definition := range []string{
+		"func main() {\n\tfmt.Println(3)\n}",
+		"func main() {\n\t(fmt.Println(3))\n}",
+		"func main() {\n\t<-ch\n}",
+		"func main() {\n\tx := 3\n}",
+		"func main() {}",
+		"func main() { not valid Go",
+	} {
+		got, changed := autoPrintLastExpression(definition)
+		assert.Falsef(t, changed, "Definition %q should not be changed", definition)
+		assert.Equal(t, definition, got)
+	}
+}
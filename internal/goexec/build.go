@@ -0,0 +1,83 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"io"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+)
+
+// This file implements `%build`, which compiles the cell without executing it.
+
+// compiledPath returns the path of the binary compiled for the current cell.
+func (s *State) compiledPath() string {
+	if s.CellIsWasm {
+		return path.Join(s.WasmDir, CompiledWasmName)
+	}
+	return s.BinaryPath()
+}
+
+// reportBuild reports the size of the binary compiled for the current cell, and copies it to
+// s.CellBuildOutput, if set.
+func (s *State) reportBuild(msg kernel.Message) error {
+	binaryPath := s.compiledPath()
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect compiled binary %q", binaryPath)
+	}
+	report := fmt.Sprintf("Build succeeded: binary size %s.\n", formatBinarySize(info.Size()))
+	if s.IsCrossCompiling() {
+		report = fmt.Sprintf("Build for %s succeeded (cross-compiled, not executed): binary size %s.\n",
+			s.Target(), formatBinarySize(info.Size()))
+	}
+	if s.CellBuildOutput != "" {
+		if err = copyExecutable(s.CellBuildOutput, binaryPath); err != nil {
+			return err
+		}
+		report += fmt.Sprintf("Binary written to %q.\n", s.CellBuildOutput)
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+// formatBinarySize formats a size in bytes in a human-readable form, e.g.: "1.9 MiB (2003456 bytes)".
+func formatBinarySize(size int64) string {
+	value, unit := float64(size), ""
+	for _, u := range []string{"KiB", "MiB", "GiB"} {
+		if value < 1024 {
+			break
+		}
+		value, unit = value/1024, u
+	}
+	if unit == "" {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	return fmt.Sprintf("%.1f %s (%d bytes)", value, unit, size)
+}
+
+// copyExecutable copies the executable file src to dst, creating dst's directory if needed.
+func copyExecutable(dst, src string) error {
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory for %q", dst)
+	}
+	from, err := os.Open(src)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %q", src)
+	}
+	defer func() { _ = from.Close() }()
+	to, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %q", dst)
+	}
+	if _, err = io.Copy(to, from); err != nil {
+		_ = to.Close()
+		return errors.Wrapf(err, "failed to copy binary to %q", dst)
+	}
+	return errors.Wrapf(to.Close(), "failed to write %q", dst)
+}
@@ -0,0 +1,33 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReportBuild(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	require.NoError(t, os.WriteFile(s.BinaryPath(), []byte("fake binary"), 0755))
+	require.NoError(t, s.reportBuild(nil))
+
+	s.CellBuildOutput = path.Join(t.TempDir(), "bin", "prototype")
+	require.NoError(t, s.reportBuild(nil))
+	content, err := os.ReadFile(s.CellBuildOutput)
+	require.NoError(t, err)
+	assert.Equal(t, "fake binary", string(content))
+	info, err := os.Stat(s.CellBuildOutput)
+	require.NoError(t, err)
+	assert.NotEqual(t, os.FileMode(0), info.Mode()&0100, "binary should be executable")
+}
+
+func TestFormatBinarySize(t *testing.T) {
+	assert.Equal(t, "100 bytes", formatBinarySize(100))
+	assert.Equal(t, "2.0 KiB (2048 bytes)", formatBinarySize(2048))
+	assert.Equal(t, "1.5 MiB (1572864 bytes)", formatBinarySize(1572864))
+}
@@ -0,0 +1,137 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"html"
+	"io"
+	"k8s.io/klog/v2"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// This file implements the display of the progress of slow `go build` and `go get` commands, so the
+// user knows the kernel is working and not frozen.
+
+var (
+	// BuildProgressDelay is how long a build runs before its progress is displayed, so fast builds
+	// don't flicker.
+	BuildProgressDelay = 2 * time.Second
+
+	// BuildProgressInterval is how often the progress display is updated.
+	BuildProgressInterval = 250 * time.Millisecond
+)
+
+// spinnerFrames are the frames of the spinner displayed while building.
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// runWithProgress runs cmd and returns its combined output (stdout and stderr), like
+// exec.Cmd.CombinedOutput.
+//
+// If it takes longer than BuildProgressDelay, it displays a transient progress with a spinner, the
+// elapsed time and the last line output by cmd, which is cleared once cmd finishes.
+//
+// The description is displayed in the progress, e.g.: "Compiling". It supports msg == nil, for
+// testing, in which case no progress is displayed.
+func runWithProgress(msg kernel.Message, cmd *exec.Cmd, description string) ([]byte, error) {
+	progress := &buildProgress{
+		msg:         msg,
+		description: description,
+		displayId:   "gonb_build_progress_" + UniqueId(),
+		startTime:   time.Now(),
+		done:        make(chan struct{}),
+	}
+	lineWriter := jpyexec.NewLineWriter(progress.setLastLine)
+	var output bytes.Buffer
+	w := io.MultiWriter(&output, lineWriter)
+	cmd.Stdout = w // Since both are the same writer, they are not written concurrently.
+	cmd.Stderr = w
+	if msg != nil {
+		progress.wg.Add(1)
+		go progress.loop()
+	}
+	err := cmd.Run()
+	_ = lineWriter.Close()
+	progress.stop()
+	return output.Bytes(), err
+}
+
+// buildProgress holds the state of the progress display, see runWithProgress.
+type buildProgress struct {
+	msg                    kernel.Message
+	description, displayId string
+	startTime              time.Time
+
+	mu       sync.Mutex
+	lastLine string
+	shown    bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// setLastLine is called for each line output by the command.
+func (p *buildProgress) setLastLine(line string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if line != "" {
+		p.lastLine = line
+	}
+}
+
+// loop updates the progress display until the command finishes.
+func (p *buildProgress) loop() {
+	defer p.wg.Done()
+	select {
+	case <-p.done:
+		return
+	case <-time.After(BuildProgressDelay):
+	}
+	ticker := time.NewTicker(BuildProgressInterval)
+	defer ticker.Stop()
+	for frame := 0; ; frame++ {
+		p.publish(p.html(frame))
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// html returns the progress display for the given spinner frame.
+func (p *buildProgress) html(frame int) string {
+	p.mu.Lock()
+	lastLine := p.lastLine
+	p.mu.Unlock()
+	return fmt.Sprintf(`<div style="font-family: monospace">%c %s ... %.1fs<br/><span style="opacity: 0.6">%s</span></div>`,
+		spinnerFrames[frame%len(spinnerFrames)], html.EscapeString(p.description),
+		time.Since(p.startTime).Seconds(), html.EscapeString(lastLine))
+}
+
+// publish updates the progress display with the given HTML content.
+func (p *buildProgress) publish(htmlContent string) {
+	p.shown = true
+	err := kernel.PublishUpdateDisplayData(p.msg, kernel.Data{
+		Data:      kernel.MIMEMap{string(protocol.MIMETextHTML): htmlContent},
+		Metadata:  make(kernel.MIMEMap),
+		Transient: kernel.MIMEMap{"display_id": p.displayId},
+	})
+	if err != nil {
+		klog.Errorf("Failed to publish build progress: %+v", err)
+	}
+}
+
+// stop stops the progress display, and clears it if it was shown.
+func (p *buildProgress) stop() {
+	close(p.done)
+	p.wg.Wait()
+	if p.shown {
+		p.publish("")
+	}
+}
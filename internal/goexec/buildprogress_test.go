@@ -0,0 +1,25 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRunWithProgress(t *testing.T) {
+	output, err := runWithProgress(nil, exec.Command("/bin/sh", "-c", "echo out; echo err >&2"), "Testing")
+	require.NoError(t, err)
+	assert.Equal(t, "out\nerr\n", string(output))
+
+	_, err = runWithProgress(nil, exec.Command("/bin/sh", "-c", "exit 1"), "Testing")
+	assert.Error(t, err)
+
+	p := &buildProgress{description: "Compiling"}
+	p.setLastLine("go: downloading <pkg>")
+	p.setLastLine("")
+	progressHtml := p.html(1)
+	assert.True(t, strings.HasPrefix(progressHtml, `<div style="font-family: monospace">⠙ Compiling ... `), progressHtml)
+	assert.Contains(t, progressHtml, "go: downloading &lt;pkg&gt;")
+}
@@ -1,6 +1,7 @@
 package goexec
 
 import (
+	"bytes"
 	"fmt"
 	. "github.com/janpfeifer/gonb/common"
 	"github.com/pkg/errors"
@@ -116,26 +117,82 @@ func (d *Declarations) RenderImports(w *WriterWithCursor, fileToCellIdAndLine []
 	}
 
 	w.Write("import (\n")
+	for _, key := range SortedKeys(d.Imports) {
+		fileToCellIdAndLine = d.Imports[key].render(w, fileToCellIdAndLine, &cursor)
+	}
+	w.Write(")\n\n")
+	return cursor, fileToCellIdAndLine
+}
+
+// RenderGroupedImports writes out `import ( ... )` for all imports in Declarations, grouped like `goimports`
+// does: standard library packages first, then third-party packages and finally local packages (the ones
+// under one of the localModules), with the groups separated by a blank line and sorted by path.
+// See State.GroupImports.
+func (d *Declarations) RenderGroupedImports(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine,
+	localModules []string) (Cursor, []CellIdAndLine) {
+	cursor := NoCursor
+	if len(d.Imports) == 0 {
+		return cursor, fileToCellIdAndLine
+	}
+
+	var groups [3][]*Import
 	for _, key := range SortedKeys(d.Imports) {
 		importDecl := d.Imports[key]
-		fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
-		fileToCellIdAndLine = importDecl.CellLines.Append(fileToCellIdAndLine)
-		w.Write("\t")
-		if importDecl.Alias != "" {
-			if importDecl.CursorInAlias {
-				cursor = w.CursorPlusDelta(importDecl.Cursor)
-			}
-			w.Writef("%s ", importDecl.Alias)
+		group := importGroup(importDecl.Path, localModules)
+		groups[group] = append(groups[group], importDecl)
+	}
+	w.Write("import (\n")
+	isFirst := true
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if !isFirst {
+			w.Write("\n")
 		}
-		if importDecl.CursorInPath {
-			cursor = w.CursorPlusDelta(importDecl.Cursor)
+		isFirst = false
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Path < group[j].Path })
+		for _, importDecl := range group {
+			fileToCellIdAndLine = importDecl.render(w, fileToCellIdAndLine, &cursor)
 		}
-		w.Writef("%q\n", importDecl.Path)
 	}
 	w.Write(")\n\n")
 	return cursor, fileToCellIdAndLine
 }
 
+// importGroup returns the group of an import path, in the order they are rendered by RenderGroupedImports:
+// 0 for the standard library (the first element of the path has no dot, as in `goimports`), 2 for packages
+// under one of the localModules and 1 for the other (third-party) packages.
+func importGroup(importPath string, localModules []string) int {
+	for _, module := range localModules {
+		if importPath == module || strings.HasPrefix(importPath, module+"/") {
+			return 2
+		}
+	}
+	if first, _, _ := strings.Cut(importPath, "/"); !strings.Contains(first, ".") {
+		return 0
+	}
+	return 1
+}
+
+// render writes out the import line, and updates the cursor if it is in the import.
+func (importDecl *Import) render(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine, cursor *Cursor) []CellIdAndLine {
+	fileToCellIdAndLine = w.FillLinesGap(fileToCellIdAndLine)
+	fileToCellIdAndLine = importDecl.CellLines.Append(fileToCellIdAndLine)
+	w.Write("\t")
+	if importDecl.Alias != "" {
+		if importDecl.CursorInAlias {
+			*cursor = w.CursorPlusDelta(importDecl.Cursor)
+		}
+		w.Writef("%s ", importDecl.Alias)
+	}
+	if importDecl.CursorInPath {
+		*cursor = w.CursorPlusDelta(importDecl.Cursor)
+	}
+	w.Writef("%q\n", importDecl.Path)
+	return fileToCellIdAndLine
+}
+
 // RenderVariables writes out `var ( ... )` for all variables in Declarations.
 func (d *Declarations) RenderVariables(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine) (Cursor, []CellIdAndLine) {
 	cursor := NoCursor
@@ -287,12 +344,43 @@ func (c *Constant) Render(w *WriterWithCursor, cursor *Cursor, fileToCellIdAndLi
 	return fileToCellIdAndLine
 }
 
+// IsEscapedSpecialCmd returns whether the line starts with an escaped special command
+// character, that is `\%` or `\!`.
+//
+// These lines are not interpreted as special commands, and are instead passed on to the
+// Go code without the leading backslash. This is useful for instance for lines within
+// a multi-line raw string that happen to start with '%' or '!'.
+func IsEscapedSpecialCmd(line string) bool {
+	return len(line) > 1 && line[0] == '\\' && (line[1] == '%' || line[1] == '!')
+}
+
+// GoFence is the line that marks the start of the Go code in a cell: every line after it is taken as
+// Go code as is, even if it starts with a special command character (`%` or `!`).
+const GoFence = "%%go"
+
+// IsGoFence returns whether the line is the Go fence, see GoFence.
+func IsGoFence(line string) bool {
+	return strings.TrimSpace(line) == GoFence
+}
+
+// CellNameDirective labels a cell with a name, as in `%%cell <name>`, so it can be executed again
+// later with `%run <name>`. It is not Go code, and it doesn't start a `func main()` like `%%`.
+const CellNameDirective = "%%cell"
+
+// IsCellNameDirective returns whether the line is a CellNameDirective.
+func IsCellNameDirective(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 0 && fields[0] == CellNameDirective
+}
+
 // createGoFileFromLines creates a Go file from the cell contents.
 // It doesn't yet include previous declarations.
 //
 // Among the things it handles:
 //   - Adding an initial `package main` line.
 //   - Handle the special `%%` line, a shortcut to create a `func main()`.
+//   - Remove the escape of lines starting with `\%` or `\!`, see IsEscapedSpecialCmd.
+//   - Skip the `%%go` fence line, and take every line after it as is, see GoFence.
 //
 // Parameters:
 //   - filePath is the path where to write the Go code.
@@ -333,10 +421,14 @@ func (s *State) createGoFileFromLines(filePath string, cellId int, lines []strin
 	}()
 
 	w.Write("package main\n\n")
-	var createdFuncMain bool
+	var createdFuncMain, afterFence bool
 	isFirstLine := true
 	for ii, line := range lines {
-		if strings.HasPrefix(line, "%main") || strings.HasPrefix(line, "%%") {
+		if !afterFence && IsGoFence(line) {
+			afterFence = true
+			continue
+		}
+		if !afterFence && (strings.HasPrefix(line, "%main") || strings.HasPrefix(line, "%%")) && !IsCellNameDirective(line) {
 			// Write preamble of func main() and associate to the "%%" line:
 			fileToCellLines[w.Line] = ii
 			fileToCellLines[w.Line+1] = ii
@@ -348,9 +440,18 @@ func (s *State) createGoFileFromLines(filePath string, cellId int, lines []strin
 		if _, found := skipLines[ii]; found {
 			continue
 		}
+		escaped := !afterFence && IsEscapedSpecialCmd(line)
+		if escaped {
+			// Drop the escape character: the rest of the line is taken as is.
+			line = line[1:]
+		}
 		if ii == cursorInCell.Line {
 			// Use current line for cursor, but add column.
-			cursorInFile = w.CursorPlusDelta(Cursor{Col: cursorInCell.Col})
+			col := cursorInCell.Col
+			if escaped && col > 0 {
+				col--
+			}
+			cursorInFile = w.CursorPlusDelta(Cursor{Col: col})
 		}
 		if isFirstLine && strings.HasPrefix(line, "package") {
 			err = errors.Errorf("Please don't set a `package` in any of your cells: GoNB will set a `package main` automatically for you when compiling your cells. Cell #%d Line %d: %q",
@@ -458,7 +559,14 @@ func (s *State) createCodeFromDecls(writer io.Writer, decls *Declarations, mainD
 		return false
 	}
 
-	if mergeCursorAndReportError(w, decls.RenderImports, "imports") {
+	renderImports := decls.RenderImports
+	if s.GroupImports {
+		localModules := s.localModules()
+		renderImports = func(w *WriterWithCursor, fileToCellIdAndLine []CellIdAndLine) (Cursor, []CellIdAndLine) {
+			return decls.RenderGroupedImports(w, fileToCellIdAndLine, localModules)
+		}
+	}
+	if mergeCursorAndReportError(w, renderImports, "imports") {
 		return
 	}
 	if mergeCursorAndReportError(w, decls.RenderTypes, "types") {
@@ -486,6 +594,17 @@ func (s *State) createCodeFromDecls(writer io.Writer, decls *Declarations, mainD
 	return
 }
 
+// DeclarationsSource returns the Go source of all the memorized declarations, as they are rendered
+// in the program of the next cell, but without a `main` function.
+func (s *State) DeclarationsSource() (string, error) {
+	var buf bytes.Buffer
+	_, _, err := s.createCodeFromDecls(&buf, s.Definitions, nil)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 var (
 	ParseError = fmt.Errorf("failed to parse cell contents")
 	CursorLost = fmt.Errorf("cursor position not rendered in main.go")
@@ -72,3 +72,115 @@ func TestCreateGoFileFromLines(t *testing.T) {
 	require.Errorf(t, err, "Expected error for unnecessary setting of `package`.")
 	assert.Contains(t, err.Error(), "Please don't set a `package`")
 }
+
+func TestCreateGoFileFromLinesEscaped(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellLines := strings.Split("var x = `\n\\%d items\n\\!important\n`\n", "\n")
+	skipLines := MakeSet[int]()
+	_, _, err := s.createGoFileFromLines(s.CodePath(), 1, cellLines, skipLines, NoCursor)
+	require.NoErrorf(t, err, "Failed createGoFileFromLines(%q)", s.CodePath())
+
+	contentBytes, err := os.ReadFile(s.CodePath())
+	require.NoErrorf(t, err, "Failed os.ReadFile(%q)", s.CodePath())
+	content := string(contentBytes)
+	assert.Contains(t, content, "\n%d items\n")
+	assert.Contains(t, content, "\n!important\n")
+	assert.NotContains(t, content, "\\%")
+	assert.NotContains(t, content, "\\!")
+}
+
+func TestCreateGoFileFromLinesGoFence(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellLines := strings.Split("%env X=1\n%%go\nvar x = `\n%d items\n%%\n\\!kept\n`\n", "\n")
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	skipLines.Insert(1)
+	_, fileToCellLines, err := s.createGoFileFromLines(s.CodePath(), 1, cellLines, skipLines, NoCursor)
+	require.NoErrorf(t, err, "Failed createGoFileFromLines(%q)", s.CodePath())
+
+	contentBytes, err := os.ReadFile(s.CodePath())
+	require.NoErrorf(t, err, "Failed os.ReadFile(%q)", s.CodePath())
+	content := string(contentBytes)
+	assert.Equal(t, "package main\n\nvar x = `\n%d items\n%%\n\\!kept\n`\n\n", content)
+	assert.NotContains(t, content, "func main()", "%% after the fence is Go code")
+	assert.Equal(t, []int{NoCursorLine, NoCursorLine, 2, 3, 4, 5, 6, 7}, fileToCellLines)
+}
+
+func TestCreateGoFileFromLinesCellName(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	cellLines := strings.Split("%%cell setup\nvar x = 1", "\n")
+	skipLines := MakeSet[int]()
+	skipLines.Insert(0)
+	_, _, err := s.createGoFileFromLines(s.CodePath(), 1, cellLines, skipLines, NoCursor)
+	require.NoErrorf(t, err, "Failed createGoFileFromLines(%q)", s.CodePath())
+
+	contentBytes, err := os.ReadFile(s.CodePath())
+	require.NoErrorf(t, err, "Failed os.ReadFile(%q)", s.CodePath())
+	assert.Equal(t, "package main\n\nvar x = 1\n", string(contentBytes))
+	assert.True(t, IsCellNameDirective("%%cell setup"))
+	assert.False(t, IsCellNameDirective("%%cells"))
+	assert.False(t, IsCellNameDirective("%% -x"))
+}
+
+func TestRenderGroupedImports(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	goMod := "module gonb_test\n\ngo 1.21\n\nreplace example.com/mylib => ../mylib\n"
+	require.NoError(t, os.WriteFile(s.TempDir+"/go.mod", []byte(goMod), 0666))
+	for _, importDecl := range []*Import{
+		{Key: "strings", Path: "strings"},
+		{Key: "mylib", Path: "example.com/mylib/sub", Alias: "mylib"},
+		{Key: "errors", Path: "github.com/pkg/errors"},
+		{Key: "fmt", Path: "fmt"},
+		{Key: "util", Path: "gonb_test/util"},
+		{Key: "klog", Path: "k8s.io/klog/v2"},
+	} {
+		s.Definitions.Imports[importDecl.Key] = importDecl
+	}
+
+	// Default: one block, sorted by name.
+	src, err := s.ImportsSource()
+	require.NoError(t, err)
+	assert.Equal(t, `import (
+	"github.com/pkg/errors"
+	"fmt"
+	"k8s.io/klog/v2"
+	mylib "example.com/mylib/sub"
+	"strings"
+	"gonb_test/util"
+)
+
+`, src)
+
+	// Grouped: standard library, third-party and local (notebook module and local replaces), sorted by path.
+	s.GroupImports = true
+	src, err = s.ImportsSource()
+	require.NoError(t, err)
+	assert.Equal(t, `import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	mylib "example.com/mylib/sub"
+	"gonb_test/util"
+)
+
+`, src)
+}
@@ -0,0 +1,48 @@
+package goexec
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// This file implements the capture of coverage across cell executions, see `%capture_coverage`.
+
+// CoverageDir is the directory where the coverage data of the cells executed is accumulated, when
+// State.CaptureCoverage is enabled.
+func (s *State) CoverageDir() string {
+	return path.Join(s.TempDir, "coverage")
+}
+
+// ResetCoverage removes the coverage data accumulated so far.
+func (s *State) ResetCoverage() error {
+	if err := os.RemoveAll(s.CoverageDir()); err != nil {
+		return errors.Wrapf(err, "failed to remove coverage data in %q", s.CoverageDir())
+	}
+	return nil
+}
+
+// CoverageReport returns the report of the coverage accumulated so far: the coverage per function and the total,
+// generated with `go tool covdata`.
+func (s *State) CoverageReport() (string, error) {
+	entries, err := os.ReadDir(s.CoverageDir())
+	if err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrapf(err, "failed to read coverage data in %q", s.CoverageDir())
+	}
+	if len(entries) == 0 {
+		return "", errors.Errorf("no coverage data captured, enable it with `%%capture_coverage on` and execute " +
+			"some cells (programs or `%%test`)")
+	}
+	var report strings.Builder
+	for _, mode := range []string{"func", "percent"} {
+		cmd := s.GoCommand("tool", "covdata", mode, "-i="+s.CoverageDir())
+		cmd.Dir = s.TempDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to run %q:\n%s", cmd.String(), output)
+		}
+		report.Write(output)
+	}
+	return report.String(), nil
+}
@@ -0,0 +1,86 @@
+package goexec
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+// This file implements the cross-compilation of the cells for other platforms, see `%goos` and `%goarch`.
+
+// HostTarget returns the platform the programs are compiled for by default: the GOOS and GOARCH of the Go
+// toolchain, ignoring the overrides of SetTarget.
+func (s *State) HostTarget() (goos, goarch string, err error) {
+	cmd := s.GoCommand("env", "GOOS", "GOARCH")
+	cmd.Dir = s.TempDir
+	cmd.Env = slices.DeleteFunc(cmd.Environ(), func(s string) bool {
+		return strings.HasPrefix(s, "GOOS=") || strings.HasPrefix(s, "GOARCH=")
+	})
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to run %q", cmd.String())
+	}
+	values := strings.Fields(string(output))
+	if len(values) != 2 {
+		return "", "", errors.Errorf("unexpected output of %q: %q", cmd.String(), output)
+	}
+	return values[0], values[1], nil
+}
+
+// SetTarget sets the platform for which the programs are compiled. Empty values mean the default of the
+// Go toolchain (see HostTarget). It returns an error if the combination is not supported by the toolchain,
+// as listed by `go tool dist list`.
+//
+// While the target differs from the host, the cells are compiled but not executed, see IsCrossCompiling.
+func (s *State) SetTarget(goos, goarch string) error {
+	hostOS, hostArch, err := s.HostTarget()
+	if err != nil {
+		return err
+	}
+	targetOS, targetArch := goos, goarch
+	if targetOS == "" {
+		targetOS = hostOS
+	}
+	if targetArch == "" {
+		targetArch = hostArch
+	}
+	cmd := s.GoCommand("tool", "dist", "list")
+	cmd.Dir = s.TempDir
+	output, err := cmd.Output()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %q", cmd.String())
+	}
+	if !slices.Contains(strings.Fields(string(output)), targetOS+"/"+targetArch) {
+		return errors.Errorf("platform %s/%s is not supported by the Go toolchain, see `go tool dist list`",
+			targetOS, targetArch)
+	}
+	s.GoOS, s.GoArch = goos, goarch
+	s.crossCompiling = targetOS != hostOS || targetArch != hostArch
+	s.target = targetOS + "/" + targetArch
+	return nil
+}
+
+// Target returns the platform ("GOOS/GOARCH") set with SetTarget, or an empty string if it was never called.
+func (s *State) Target() string {
+	return s.target
+}
+
+// IsCrossCompiling returns whether the programs are being compiled for a platform other than the host's (see
+// SetTarget), in which case they are not executed.
+func (s *State) IsCrossCompiling() bool {
+	return s.crossCompiling
+}
+
+// withTarget returns env with GOOS and GOARCH replaced by the given values, if they are not empty.
+func withTarget(env []string, goos, goarch string) []string {
+	env = slices.DeleteFunc(env, func(s string) bool {
+		return (goos != "" && strings.HasPrefix(s, "GOOS=")) || (goarch != "" && strings.HasPrefix(s, "GOARCH="))
+	})
+	if goos != "" {
+		env = append(env, "GOOS="+goos)
+	}
+	if goarch != "" {
+		env = append(env, "GOARCH="+goarch)
+	}
+	return env
+}
@@ -0,0 +1,38 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestSetTarget(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	hostOS, hostArch, err := s.HostTarget()
+	require.NoError(t, err)
+
+	require.NoError(t, s.SetTarget("", ""))
+	assert.False(t, s.IsCrossCompiling())
+	assert.Equal(t, hostOS+"/"+hostArch, s.Target())
+
+	target := "windows/arm64"
+	if hostOS == "windows" {
+		target = "linux/arm64"
+	}
+	goos, goarch, _ := strings.Cut(target, "/")
+	require.NoError(t, s.SetTarget(goos, goarch))
+	assert.True(t, s.IsCrossCompiling())
+	assert.Equal(t, target, s.Target())
+
+	assert.Error(t, s.SetTarget("plan10", "amd64"))
+	assert.Equal(t, target, s.Target(), "invalid platforms should not change the target")
+
+	assert.Equal(t, []string{"HOME=/root", "GOOS=js", "GOARCH=wasm"},
+		withTarget([]string{"GOOS=linux", "HOME=/root", "GOARCH=amd64"}, "js", "wasm"))
+	assert.Equal(t, []string{"GOOS=linux", "HOME=/root", "GOARCH=arm64"},
+		withTarget([]string{"GOOS=linux", "HOME=/root", "GOARCH=amd64"}, "", "arm64"))
+}
@@ -0,0 +1,76 @@
+package goexec
+
+import (
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"os"
+	"path"
+	"strings"
+)
+
+// This file implements the inspection of the dependencies of the notebook module, see `%deps`.
+
+// ModuleGraph holds the requirement graph of the notebook module, as reported by `go mod graph`.
+type ModuleGraph struct {
+	// Root is the path of the notebook module.
+	Root string
+
+	// Requires maps a module ("path@version", or only the path for Root) to the modules it requires,
+	// in the order reported.
+	Requires map[string][]string
+
+	// Direct holds the modules ("path@version") directly required by the notebook module's `go.mod`,
+	// typically added by `go get` (see `%autoget`) -- as opposed to the ones marked as `// indirect`.
+	Direct Set[string]
+}
+
+// DependencyGraph returns the requirement graph of the notebook module in its current state.
+func (s *State) DependencyGraph() (*ModuleGraph, error) {
+	goModPath := path.Join(s.TempDir, "go.mod")
+	content, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	goMod, err := modfile.ParseLax(goModPath, content, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	graph := &ModuleGraph{Requires: make(map[string][]string), Direct: MakeSet[string]()}
+	if goMod.Module != nil {
+		graph.Root = goMod.Module.Mod.Path
+	}
+	for _, require := range goMod.Require {
+		if !require.Indirect {
+			graph.Direct.Insert(require.Mod.String())
+		}
+	}
+
+	cmd := s.GoCommand("mod", "graph")
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %q:\n%s", cmd.String(), output)
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(fields[1], "go@") || strings.HasPrefix(fields[1], "toolchain@") {
+			// The requirements of Go and toolchain versions are not modules.
+			continue
+		}
+		graph.Requires[fields[0]] = append(graph.Requires[fields[0]], fields[1])
+	}
+	return graph, nil
+}
+
+// WhyModule returns the output of `go mod why -m <module>`: the shortest path from the notebook code to a
+// package of the module.
+func (s *State) WhyModule(module string) (string, error) {
+	cmd := s.GoCommand("mod", "why", "-m", module)
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %q:\n%s", cmd.String(), output)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,19 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	graph, err := s.DependencyGraph()
+	require.NoError(t, err)
+	assert.Equal(t, s.Package, graph.Root)
+	assert.Empty(t, graph.Requires[graph.Root])
+	assert.Empty(t, graph.Direct)
+}
@@ -0,0 +1,143 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file implements `%doc`, which displays the documentation of a Go symbol.
+
+// Documentation returns the documentation of symbol (e.g.: "fmt.Println", "strings.Builder.WriteString" or
+// "MyType") formatted as markdown.
+//
+// Symbols memorized in the notebook are documented by their declaration and doc comment. Other symbols are
+// documented with `go doc`, executed in the notebook module, so it covers the standard library and the
+// modules required in `go.mod`.
+func (s *State) Documentation(msg kernel.Message, symbol string) (string, error) {
+	if markdown, found := s.memorizedDocumentation(symbol); found {
+		return markdown, nil
+	}
+	cmd := s.GoCommand("doc", symbol)
+	cmd.Dir = s.TempDir
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Errorf("`go doc %s` failed: %s", symbol, strings.TrimSpace(string(output)))
+	}
+	return goDocToMarkdown(string(output)), nil
+}
+
+// memorizedDocumentation returns the documentation of a memorized symbol: its declaration (only the signature,
+// for functions and methods, and with the methods for types) followed by its doc comment.
+func (s *State) memorizedDocumentation(symbol string) (markdown string, found bool) {
+	decls := s.Definitions
+	key := strings.Replace(symbol, ".", "~", 1)
+	var declaration, doc string
+	if f, ok := decls.Functions[key]; ok {
+		declaration, doc = funcSignature(f.Definition), f.Doc
+	} else if t, ok := decls.Types[key]; ok {
+		declaration, doc = "type "+t.TypeDefinition, t.Doc
+		var methods []string
+		for _, methodKey := range decls.Methods(key) {
+			methods = append(methods, funcSignature(decls.Functions[methodKey].Definition))
+		}
+		if len(methods) > 0 {
+			declaration += "\n\n" + strings.Join(methods, "\n")
+		}
+	} else if v, ok := decls.Variables[key]; ok {
+		declaration, doc = "var "+v.Name, v.Doc
+		if v.TypeDefinition != "" {
+			declaration += " " + v.TypeDefinition
+		}
+		if v.ValueDefinition != "" {
+			declaration += " = " + v.ValueDefinition
+		}
+	} else if c, ok := decls.Constants[key]; ok {
+		declaration, doc = "const "+c.Key, c.Doc
+		if c.TypeDefinition != "" {
+			declaration += " " + c.TypeDefinition
+		}
+		if c.ValueDefinition != "" {
+			declaration += " = " + c.ValueDefinition
+		}
+	} else {
+		return "", false
+	}
+	if doc == "" {
+		doc = "_No doc comment, declared in the notebook._\n"
+	}
+	return fmt.Sprintf("```go\n%s\n```\n\n%s", declaration, doc), true
+}
+
+// funcSignature returns the signature of the function (or method) definition, without its body.
+// If it fails to parse it, it returns the definition as is.
+func funcSignature(definition string) string {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", "package main\n"+definition, parser.SkipObjectResolution)
+	if err != nil || len(file.Decls) != 1 {
+		return definition
+	}
+	funcDecl, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok {
+		return definition
+	}
+	funcDecl.Body = nil
+	var buf bytes.Buffer
+	if err = printer.Fprint(&buf, fileSet, funcDecl); err != nil {
+		return definition
+	}
+	return buf.String()
+}
+
+// goDocToMarkdown converts the output of `go doc` to markdown: the declarations are formatted as Go code blocks,
+// and the documentation (indented for symbols) as text, since the syntax of Go doc comments is close to markdown.
+func goDocToMarkdown(output string) string {
+	var sb strings.Builder
+	inCode := false
+	blankLines := 0
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			// Blank lines are written with the following line, after closing the current block if needed.
+			blankLines++
+			continue
+		}
+		isCode := false
+		if !strings.HasPrefix(line, "    ") {
+			for _, prefix := range []string{"package ", "func ", "type ", "var ", "const ", "\t", ")", "}"} {
+				if strings.HasPrefix(line, prefix) {
+					isCode = true
+					break
+				}
+			}
+		}
+		if isCode != inCode {
+			if inCode {
+				sb.WriteString("```\n")
+			}
+			sb.WriteString(strings.Repeat("\n", max(blankLines, 1)))
+			if isCode {
+				sb.WriteString("```go\n")
+			}
+			inCode = isCode
+		} else {
+			sb.WriteString(strings.Repeat("\n", blankLines))
+		}
+		blankLines = 0
+		if !isCode {
+			line = strings.TrimPrefix(line, "    ")
+		}
+		sb.WriteString(line + "\n")
+	}
+	if inCode {
+		sb.WriteString("```\n")
+	}
+	return strings.TrimSpace(sb.String()) + "\n"
+}
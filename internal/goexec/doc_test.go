@@ -0,0 +1,78 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGoDocToMarkdown(t *testing.T) {
+	output := `package fmt // import "fmt"
+
+func Println(a ...any) (n int, err error)
+    Println formats using the default formats for its operands and writes to
+    standard output.
+
+`
+	assert.Equal(t, "```go\npackage fmt // import \"fmt\"\n\nfunc Println(a ...any) (n int, err error)\n```\n\n"+
+		"Println formats using the default formats for its operands and writes to\nstandard output.\n",
+		goDocToMarkdown(output))
+
+	output = `package strings // import "strings"
+
+type Builder struct {
+	// Has unexported fields.
+}
+    A Builder is used to efficiently build a string.
+
+func (b *Builder) Len() int
+`
+	assert.Equal(t, "```go\npackage strings // import \"strings\"\n\ntype Builder struct {\n\t// Has unexported fields.\n}\n```\n\n"+
+		"A Builder is used to efficiently build a string.\n\n```go\nfunc (b *Builder) Len() int\n```\n",
+		goDocToMarkdown(output))
+}
+
+func TestMemorizedDocumentation(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	cellLines := []string{
+		"// Point in the plane.",
+		"type Point struct{ X, Y int }",
+		"",
+		"// Norm returns the squared norm.",
+		"func (p Point) Norm() int { return p.X*p.X + p.Y*p.Y }",
+		"",
+		"const (",
+		"	// Scale of the plot.",
+		"	Scale = 2",
+		")",
+	}
+	decls, _, _, _, err := s.parseLinesAndComposeMain(nil, 1, cellLines, nil, NoCursor)
+	require.NoError(t, err)
+	s.Definitions = decls
+
+	markdown, found := s.memorizedDocumentation("Point.Norm")
+	require.True(t, found)
+	assert.Equal(t, "```go\nfunc (p Point) Norm() int\n```\n\nNorm returns the squared norm.\n", markdown)
+
+	markdown, found = s.memorizedDocumentation("Point")
+	require.True(t, found)
+	assert.Equal(t, "```go\ntype Point struct{ X, Y int }\n\nfunc (p Point) Norm() int\n```\n\nPoint in the plane.\n", markdown)
+
+	markdown, found = s.memorizedDocumentation("Scale")
+	require.True(t, found)
+	assert.Equal(t, "```go\nconst Scale = 2\n```\n\nScale of the plot.\n", markdown)
+
+	_, found = s.memorizedDocumentation("fmt.Println")
+	assert.False(t, found)
+
+	// Other symbols are documented with `go doc`.
+	markdown, err = s.Documentation(nil, "strings.ToUpper")
+	require.NoError(t, err)
+	assert.Contains(t, markdown, "```go\npackage strings")
+	assert.Contains(t, markdown, "func ToUpper(s string) string\n```")
+	_, err = s.Documentation(nil, "strings.NoSuchFunction")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package goexec
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"regexp"
+	"strings"
+)
+
+// This file implements named entry points, selected with `%main --gonb_entry <name>`.
+
+// regexpEntryPoint matches the definition of a function that can be used as an entry point: one without
+// parameters, results or type parameters, e.g.: "func Scenario1() {...}".
+var regexpEntryPoint = regexp.MustCompile(`^func\s+(\w+)\s*\(\s*\)\s*\{`)
+
+// EntryPoints returns the sorted names of the functions in decls that can be used as entry points with
+// `%main --gonb_entry <name>`: functions without parameters, results or type parameters, other than `main`
+// and `init` functions.
+func EntryPoints(decls *Declarations) (entries []string) {
+	for _, key := range SortedKeys(decls.Functions) {
+		if key == "main" || strings.HasPrefix(key, "init") || strings.Contains(key, "~") {
+			continue
+		}
+		if matches := regexpEntryPoint.FindStringSubmatch(decls.Functions[key].Definition); matches != nil &&
+			matches[1] == key {
+			entries = append(entries, key)
+		}
+	}
+	return
+}
+
+// callEntryPoint changes mainDecl to call the entry point s.CellEntryPoint at its end, after any statements
+// of the cell's `func main()`.
+// It returns an error listing the available entry points if s.CellEntryPoint is not one of them.
+func (s *State) callEntryPoint(decls *Declarations, mainDecl *Function) error {
+	entries := EntryPoints(decls)
+	if !slices.Contains(entries, s.CellEntryPoint) {
+		if len(entries) == 0 {
+			return errors.Errorf("`%%main --gonb_entry %s`: entry point not found, and no entry points are defined: "+
+				"define them as functions without parameters, e.g.: `func %s() {...}`", s.CellEntryPoint, s.CellEntryPoint)
+		}
+		return errors.Errorf("`%%main --gonb_entry %s`: entry point not found, the available ones are: %s",
+			s.CellEntryPoint, strings.Join(entries, ", "))
+	}
+	definition := strings.TrimRight(mainDecl.Definition, " \t\n")
+	if !strings.HasSuffix(definition, "}") {
+		return errors.Errorf("`%%main --gonb_entry %s`: failed to parse the definition of `func main()`", s.CellEntryPoint)
+	}
+	mainDecl.Definition = fmt.Sprintf("%s\n\t%s()\n}", strings.TrimSuffix(definition, "}"), s.CellEntryPoint)
+	if len(mainDecl.CellLines.Lines) > 0 {
+		// The call to the entry point and the closing brace are in new lines, not in the cell.
+		mainDecl.CellLines.Lines = append(slices.Clone(mainDecl.CellLines.Lines), NoCursorLine, NoCursorLine)
+	}
+	return nil
+}
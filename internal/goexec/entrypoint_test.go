@@ -0,0 +1,34 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEntryPoints(t *testing.T) {
+	decls := NewDeclarations()
+	for key, definition := range map[string]string{
+		"Fast":     "func Fast() {\n\tfmt.Println(\"fast\")\n}",
+		"Slow":     "func Slow (  ) { time.Sleep(time.Second) }",
+		"Sum":      "func Sum(a, b int) int { return a + b }",
+		"Generic":  "func Generic[T any]() {}",
+		"T~Run":    "func (t T) Run() {}",
+		"init_0":   "func init_0() {}",
+		"Returner": "func Returner() int { return 1 }",
+	} {
+		decls.Functions[key] = &Function{Cursor: NoCursor, Key: key, Definition: definition}
+	}
+	assert.Equal(t, []string{"Fast", "Slow"}, EntryPoints(decls))
+
+	s := &State{CellState: CellState{CellEntryPoint: "Fast"}}
+	mainDecl := &Function{Cursor: NoCursor, Key: "main", Definition: "func main() {\n\tflag.Parse()\n\tsetup()\n}",
+		CellLines: CellLines{Id: 1, Lines: []int{0, NoCursorLine, 1, 1}}}
+	require.NoError(t, s.callEntryPoint(decls, mainDecl))
+	assert.Equal(t, "func main() {\n\tflag.Parse()\n\tsetup()\n\n\tFast()\n}", mainDecl.Definition)
+	assert.Equal(t, []int{0, NoCursorLine, 1, 1, NoCursorLine, NoCursorLine}, mainDecl.CellLines.Lines)
+
+	s.CellEntryPoint = "Sum"
+	assert.ErrorContains(t, s.callEntryPoint(decls, mainDecl), "the available ones are: Fast, Slow")
+	assert.ErrorContains(t, s.callEntryPoint(NewDeclarations(), mainDecl), "no entry points are defined")
+}
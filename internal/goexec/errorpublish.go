@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/pkg/errors"
 	"text/template"
+	"time"
 
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"k8s.io/klog/v2"
@@ -134,3 +135,26 @@ func JupyterErrorSplit(err error) (string, string, []string) {
 		return "ERROR", err.Error(), []string{err.Error()}
 	}
 }
+
+// MaxRecentErrors is the maximum number of errors of cell executions kept in State.RecentErrors.
+const MaxRecentErrors = 5
+
+// CellError is the error of the execution of a cell, see State.RecordCellError.
+type CellError struct {
+	// ExecCount is the execution count of the cell that failed.
+	ExecCount int
+	Time      time.Time
+
+	// Name and Value of the error, as published to Jupyter, see JupyterErrorSplit.
+	Name, Value string
+}
+
+// RecordCellError records the error of the execution of a cell in State.RecentErrors, dropping the oldest
+// error if there are already MaxRecentErrors. They are included in `%bugreport`.
+func (s *State) RecordCellError(execCount int, err error) {
+	name, value, _ := JupyterErrorSplit(err)
+	s.RecentErrors = append(s.RecentErrors, CellError{ExecCount: execCount, Time: time.Now(), Name: name, Value: value})
+	if len(s.RecentErrors) > MaxRecentErrors {
+		s.RecentErrors = s.RecentErrors[len(s.RecentErrors)-MaxRecentErrors:]
+	}
+}
@@ -0,0 +1,172 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"html"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements `%escape`, which displays the results of the escape analysis of the compiler.
+
+// regexpCompilerDiagnostic matches a diagnostic of the compiler about a Go file, as printed with
+// `-gcflags=-m`, e.g.: "./main.go:5:10: leaking param: a".
+var regexpCompilerDiagnostic = regexp.MustCompile(`^(?:\./)?([^\s:]+\.go):(\d+):(\d+): (.*)$`)
+
+// escapeDiagnosticColors are the colors used for the diagnostics of `-gcflags=-m`, by their content:
+// allocations on the heap are highlighted, while the non-escaping and the inlining decisions are dimmed.
+var escapeDiagnosticColors = []struct{ substring, color string }{
+	{"escapes to heap", "#BA2121"},
+	{"moved to heap", "#BA2121"},
+	{"leaking param", "#BA5021"},
+	{"does not escape", "#008800"},
+	{"inlin", "#808080"},
+}
+
+// escapeDiagnostic is a diagnostic of the escape analysis.
+type escapeDiagnostic struct {
+	line, column int
+	text         string
+}
+
+// displayEscapeAnalysis compiles the program of the current cell with `-gcflags=-m` and displays the
+// results of the escape analysis (and the inlining decisions) for s.CellEscapeFunction, or for all the
+// code if it is empty, grouped by the lines of the cells they refer to.
+func (s *State) displayEscapeAnalysis(msg kernel.Message, decls *Declarations, fileToCellIdAndLine []CellIdAndLine) error {
+	var target string
+	if s.CellEscapeFunction != "" {
+		target = normalizeAsmName(s.CellEscapeFunction)
+		if _, found := decls.Functions[strings.Replace(target, ".", "~", 1)]; !found {
+			return errors.Errorf("`%%escape %s`: function not found, it must be a function or method memorized or "+
+				"defined in the cell, e.g.: `%%escape MyFunc` or `%%escape MyType.MyMethod`", s.CellEscapeFunction)
+		}
+	}
+	output, err := s.compileWithGcFlags(msg, fileToCellIdAndLine, "-m")
+	if err != nil {
+		return err
+	}
+	src, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	fromLine, toLine := 1, strings.Count(src, "\n")+1
+	if target != "" {
+		var found bool
+		fromLine, toLine, found = funcLineRange(src, target)
+		if !found {
+			return errors.Errorf("`%%escape %s`: function not found in the generated program", s.CellEscapeFunction)
+		}
+	}
+	diagnostics := parseEscapeDiagnostics(output, path.Base(s.CodePath()), fromLine, toLine)
+	if len(diagnostics) == 0 {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, "No escape analysis results.\n")
+	}
+	return kernel.PublishHtml(msg, formatEscapeDiagnostics(diagnostics, s.CodePath(), strings.Split(src, "\n"),
+		fileToCellIdAndLine))
+}
+
+// parseEscapeDiagnostics returns the diagnostics of `-gcflags=-m` in output about the lines [fromLine, toLine]
+// of the file fileName, sorted by position and without duplicates.
+func parseEscapeDiagnostics(output, fileName string, fromLine, toLine int) []escapeDiagnostic {
+	var diagnostics []escapeDiagnostic
+	seen := make(map[escapeDiagnostic]bool)
+	for _, line := range strings.Split(output, "\n") {
+		matches := regexpCompilerDiagnostic.FindStringSubmatch(line)
+		if matches == nil || matches[1] != fileName {
+			continue
+		}
+		lineNum, _ := strconv.Atoi(matches[2])
+		column, _ := strconv.Atoi(matches[3])
+		if lineNum < fromLine || lineNum > toLine {
+			continue
+		}
+		d := escapeDiagnostic{line: lineNum, column: column, text: matches[4]}
+		if !seen[d] {
+			seen[d] = true
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].line != diagnostics[j].line {
+			return diagnostics[i].line < diagnostics[j].line
+		}
+		return diagnostics[i].column < diagnostics[j].column
+	})
+	return diagnostics
+}
+
+// formatEscapeDiagnostics formats the diagnostics as HTML, each group preceded by the source line they refer
+// to, with its cell line, if it maps to one.
+func formatEscapeDiagnostics(diagnostics []escapeDiagnostic, codePath string, srcLines []string,
+	fileToCellIdAndLine []CellIdAndLine) string {
+	var sb strings.Builder
+	sb.WriteString("<pre style=\"margin: 0\">")
+	lastLine := -1
+	for _, d := range diagnostics {
+		if d.line != lastLine {
+			lastLine = d.line
+			reference := fmt.Sprintf("%s:%d", codePath, d.line)
+			sb.WriteString(fmt.Sprintf("<span style=\"color: #408080\">%s</span>\n",
+				html.EscapeString(describeSourceLine(reference, codePath, srcLines, fileToCellIdAndLine))))
+		}
+		text := html.EscapeString(fmt.Sprintf("col %d: %s", d.column, d.text))
+		for _, c := range escapeDiagnosticColors {
+			if strings.Contains(d.text, c.substring) {
+				text = fmt.Sprintf("<span style=\"color: %s\">%s</span>", c.color, text)
+				break
+			}
+		}
+		sb.WriteString("    " + text + "\n")
+	}
+	sb.WriteString("</pre>\n")
+	return sb.String()
+}
+
+// funcLineRange returns the first and last lines of the declaration of the function (or method) target,
+// normalized with normalizeAsmName, in the Go source src.
+func funcLineRange(src, target string) (fromLine, toLine int, found bool) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return
+	}
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		name := funcDecl.Name.Name
+		if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+			name = receiverTypeName(funcDecl.Recv.List[0].Type) + "." + name
+		}
+		if name == target {
+			return fileSet.Position(funcDecl.Pos()).Line, fileSet.Position(funcDecl.End()).Line, true
+		}
+	}
+	return
+}
+
+// receiverTypeName returns the name of the type of a method receiver, without pointer or type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(e.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(e.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(e.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEscapeAnalysis(t *testing.T) {
+	src := "package main\n\ntype T struct{ x int }\n\nfunc (t *T) Inc() { t.x++ }\n\n" +
+		"func NewT() *T {\n\treturn &T{}\n}\n\nfunc main() { NewT().Inc() }\n"
+	from, to, found := funcLineRange(src, "NewT")
+	assert.True(t, found)
+	assert.Equal(t, []int{7, 9}, []int{from, to})
+	from, to, found = funcLineRange(src, "T.Inc")
+	assert.True(t, found)
+	assert.Equal(t, []int{5, 5}, []int{from, to})
+	_, _, found = funcLineRange(src, "Missing")
+	assert.False(t, found)
+
+	output := strings.Join([]string{
+		"# gonb_1234",
+		"./main.go:5:7: t does not escape",
+		"./main.go:8:9: &T{} escapes to heap",
+		"./main.go:7:6: can inline NewT",
+		"./main.go:8:9: &T{} escapes to heap",
+		"./other.go:8:1: unrelated",
+		"./main.go:11:18: inlining call to NewT",
+	}, "\n")
+	diagnostics := parseEscapeDiagnostics(output, "main.go", 7, 9)
+	assert.Equal(t, []escapeDiagnostic{{7, 6, "can inline NewT"}, {8, 9, "&T{} escapes to heap"}}, diagnostics)
+	assert.Len(t, parseEscapeDiagnostics(output, "main.go", 1, 100), 4)
+
+	fileToCellIdAndLine := make([]CellIdAndLine, 11)
+	for ii := range fileToCellIdAndLine {
+		fileToCellIdAndLine[ii] = CellIdAndLine{Id: 1, Line: ii}
+	}
+	got := formatEscapeDiagnostics(diagnostics, "/tmp/gonb_1234/main.go", strings.Split(src, "\n"), fileToCellIdAndLine)
+	assert.Contains(t, got, "// [cell 1] line 8: return &amp;T{}")
+	assert.Contains(t, got, `<span style="color: #BA2121">col 9: &amp;T{} escapes to heap</span>`)
+}
@@ -13,9 +13,8 @@ import (
 	"os"
 	"os/exec"
 	"path"
-	"regexp"
-	"strconv"
 	"strings"
+	"time"
 )
 
 // cellExecParams are the parameters of ExecuteCell, packaged so they
@@ -73,7 +72,7 @@ func (s *State) serializeExecuteCell() {
 // See documentation of parameters in `State.ExecuteCell`.
 // It is not reentrant, and calls to it should be serialized.
 // ExecuteCell serializes the calls to this method.
-func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string, skipLines Set[int]) error {
+func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string, skipLines Set[int]) (err error) {
 	klog.V(1).Infof("ExecuteCell: %q", lines)
 
 	defer s.PostExecuteCell()
@@ -82,8 +81,15 @@ func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string,
 		return errors.Errorf("Cannot execute test in a %%wasm cell. Please, choose either `%%wasm` or `%%test`.")
 	}
 
+	s.LastCellTimings = nil
+	phaseStart := time.Now()
+
 	// Runs AutoTrack: makes sure redirects in go.mod and use clauses in go.work are tracked.
-	err := s.AutoTrack()
+	err = s.AutoTrack()
+	if err != nil {
+		return err
+	}
+	err = s.goWorkAutoUse(msg)
 	if err != nil {
 		return err
 	}
@@ -96,18 +102,50 @@ func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string,
 		return err
 	}
 	klog.V(2).Infof("ExecuteCell: after s.parseLinesAndComposeMain()")
+	phaseStart = timePhase(&s.LastCellTimings, "parse", phaseStart)
 
 	// ProgramExecutor `goimports` (or the code that implements it) -- it updates `updatedDecls` with
 	// the new imports, if there are any.
 	_, fileToCellIdAndLine, err = s.GoImports(msg, updatedDecls, mainDecl, fileToCellIdAndLine)
 
 	klog.V(2).Infof("ExecuteCell: after s.GoImports()")
+	phaseStart = timePhase(&s.LastCellTimings, "goimports and go get", phaseStart)
+	s.fileToCellIdAndLine = fileToCellIdAndLine
 
 	if err != nil {
 		klog.Infof("goexec.ExecuteCell() failed to run `go imports` and `go get`: %+v", err)
 		return err
 	}
 
+	if s.CellKeepDecls {
+		// Declarations are memorized also when the cell is only displayed, built or exported, if successful.
+		defer func() {
+			if err == nil {
+				s.Definitions = updatedDecls
+			}
+		}()
+	}
+	if s.CellIsDryRun {
+		// Only display the generated program.
+		return s.publishProgram(msg)
+	}
+	if s.CellAsmFunction != "" {
+		// Only display the assembly of the function.
+		return s.displayAssembly(msg, updatedDecls, fileToCellIdAndLine)
+	}
+	if s.CellIsEscapeAnalysis {
+		// Only display the results of the escape analysis.
+		return s.displayEscapeAnalysis(msg, updatedDecls, fileToCellIdAndLine)
+	}
+	if s.CellExportPath != "" {
+		// Only export the generated program.
+		return s.exportProgram(msg, s.CellExportPath)
+	}
+	if s.CellExportModuleDir != "" {
+		// Only export the generated program as a module.
+		return s.exportModule(msg, updatedDecls, mainDecl, s.CellExportModuleDir)
+	}
+
 	// And then compile it.
 	if err := s.Compile(msg, fileToCellIdAndLine); err != nil {
 		klog.Infof("goexec.ExecuteCell() failed to compile cell: %+v", err)
@@ -115,15 +153,31 @@ func (s *State) executeCellImpl(msg kernel.Message, cellId int, lines []string,
 	}
 
 	klog.V(2).Infof("ExecuteCell: after s.Compile()")
+	phaseStart = timePhase(&s.LastCellTimings, "compile", phaseStart)
+	if s.CellIsBuildOnly {
+		// Only report the build.
+		return s.reportBuild(msg)
+	}
 
-	// Compilation successful: save merged declarations into current State.
-	s.Definitions = updatedDecls
+	// Compilation successful: save merged declarations into current State, unless discarded with `%discard`.
+	if !s.CellDiscardDecls {
+		s.Definitions = updatedDecls
+		if s.Repl {
+			s.memorizeReplVariables(mainDecl)
+		}
+	}
+	if s.IsCrossCompiling() {
+		// The program can't be executed in this platform.
+		return s.reportBuild(msg)
+	}
 
 	// Execute compiled code.
-	return s.Execute(msg, fileToCellIdAndLine)
+	err = s.Execute(msg, fileToCellIdAndLine)
+	timePhase(&s.LastCellTimings, "run", phaseStart)
+	return err
 }
 
-// PostExecuteCell reset state that is valid only for the duration of a cell.
+// PostExecuteCell reset state that is valid only for the duration of a cell, the CellState.
 // This includes s.CellIsTest and s.Args.
 func (s *State) PostExecuteCell() {
 	klog.V(2).Infof("PostExecuteCell(): CellIsTest=%v", s.CellIsTest)
@@ -132,12 +186,7 @@ func (s *State) PostExecuteCell() {
 		s.RemoveWasmConstants(s.Definitions)
 	}
 
-	s.Args = nil
-	s.CellIsTest = false
-	s.CellTests = nil
-	s.CellHasBenchmarks = false
-	s.CellIsWasm = false
-	s.WasmDivId = ""
+	s.CellState = CellState{}
 }
 
 // BinaryPath is the path to the generated binary file.
@@ -183,16 +232,64 @@ func (s *State) Execute(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine)
 		return s.ExecuteWasm(msg)
 	}
 	args := s.Args
-	if len(args) == 0 && s.CellIsTest {
+	if s.CellFuzzTarget != "" {
+		args = append(s.fuzzArgs(), args...)
+	} else if len(args) == 0 && s.CellIsTest {
 		args = s.DefaultCellTestArgs()
 	}
-	err := jpyexec.New(msg, s.BinaryPath(), args...).
+	if s.CellPgoCapture {
+		// Makes sure a profile from a previous capture is not reported as the new one.
+		if err := os.Remove(s.PgoProfilePath()); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove previous CPU profile")
+		}
+		if s.CellIsTest {
+			args = append(slices.Clone(args), "-test.cpuprofile="+s.PgoProfilePath())
+		}
+	}
+	env := slices.Clone(s.CellEnv)
+	if s.CaptureCoverage {
+		if err := os.MkdirAll(s.CoverageDir(), 0755); err != nil {
+			return errors.Wrapf(err, "failed to create directory for coverage data")
+		}
+		if s.CellIsTest {
+			args = append(slices.Clone(args), "-test.gocoverdir="+s.CoverageDir())
+		} else {
+			env = append(env, "GOCOVERDIR="+s.CoverageDir())
+		}
+	}
+	var stderr io.Writer = newJupyterStackTraceMapperWriter(msg, "stderr", s.CodePath(), fileToCellIdAndLine, s.rawError)
+	var races *dataRaceCounter
+	if s.CellIsRace {
+		races = &dataRaceCounter{Writer: stderr}
+		stderr = races
+	}
+	executor := jpyexec.New(msg, s.BinaryPath(), args...).
 		UseNamedPipes(s.Comms).
 		ExecutionCount(msg.Kernel().ExecCounter).
-		WithStderr(newJupyterStackTraceMapperWriter(msg, "stderr", s.CodePath(), fileToCellIdAndLine)).
-		Exec()
+		WithTimeout(s.ExecTimeout).
+		WithEnv(env...).
+		WithStderr(stderr)
+	if s.CellIsTest {
+		// Test failures are reported in the stdout, with references to `main_test.go`.
+		executor = executor.WithStdout(s.NewCellLinesWriter(msg, "stdout"))
+	}
+	if s.CellFuzzTarget != "" {
+		// Failing inputs are saved in the corpus under `testdata/fuzz`, relative to the current directory.
+		executor = executor.InDir(s.TempDir)
+	}
+	err := executor.Exec()
 	if err != nil {
 		klog.Infof("goexec.Execute(): failed to run the compiled cell: %+v", msg)
+		if s.CellFuzzTarget != "" {
+			s.publishFailingFuzzInput(msg)
+		}
+	}
+	if err == nil && s.CellPgoCapture {
+		err = s.reportPgoCapture(msg)
+	}
+	if races != nil && races.count > 0 {
+		err = errors.Errorf("%d data race(s) detected, see the reports above: the references to the cells "+
+			"are highlighted", races.count)
 	}
 	return err
 }
@@ -203,31 +300,55 @@ func (s *State) Execute(msg kernel.Message, fileToCellIdAndLine []CellIdAndLine)
 // current cell.
 func (s *State) Compile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine) error {
 	var args []string
+	if s.CellFuzzTarget != "" {
+		if err := s.checkFuzzTarget(); err != nil {
+			return err
+		}
+	}
 	if s.CellIsTest {
 		args = []string{"test", "-c", "-o", s.BinaryPath()}
+		if s.CellFuzzTarget != "" {
+			// Instruments the binary for coverage guided fuzzing.
+			args = append(args, fmt.Sprintf("-fuzz=^%s$", s.CellFuzzTarget))
+		}
 	} else if s.CellIsWasm {
 		args = []string{"build", "-o", path.Join(s.WasmDir, CompiledWasmName)}
 	} else {
 		args = []string{"build", "-o", s.BinaryPath()}
 	}
+	if !s.BuildCache {
+		args = append(args, "-a")
+	}
+	if s.CaptureCoverage && !s.CellIsWasm {
+		args = append(args, "-cover")
+	}
+	if s.CellIsTest && s.CellIsRace {
+		args = append(args, "-race")
+	}
+	if s.Vendored {
+		args = append(args, "-mod=vendor")
+	}
+	if !s.CellIsWasm {
+		pgoFlag, err := s.pgoFlag()
+		if err != nil {
+			return err
+		}
+		args = append(args, pgoFlag)
+	}
 	args = append(args, s.GoBuildFlags...)
-	cmd := exec.Command("go", args...)
-	cmd.Dir = s.TempDir
-	if s.CellIsWasm {
-		// Set GOARCH and GOOS in cmd.Env.
-		cmd.Env = append(
-			slices.DeleteFunc(cmd.Environ(), func(s string) bool {
-				return strings.HasPrefix(s, "GOARCH=") ||
-					strings.HasPrefix(s, "GOOS=")
-			}),
-			"GOARCH=wasm",
-			"GOOS=js",
-		)
+	compileCmd := func() *exec.Cmd {
+		cmd := s.GoCommand(args...)
+		cmd.Dir = s.TempDir
+		if s.CellIsWasm {
+			// Set GOARCH and GOOS in cmd.Env.
+			cmd.Env = withTarget(cmd.Environ(), "js", "wasm")
+		} else if s.GoOS != "" || s.GoArch != "" {
+			cmd.Env = withTarget(cmd.Environ(), s.GoOS, s.GoArch)
+		}
+		return cmd
 	}
 
-	var output []byte
-	klog.V(2).Infof("Executing %s", cmd)
-	output, err := cmd.CombinedOutput()
+	cmd, output, err := s.runCompile(msg, fileToCellIdAndLines, compileCmd)
 	if err != nil {
 		klog.Errorf("Failed %q:\n%s\n", cmd, output)
 		err := s.DisplayErrorWithContext(msg, fileToCellIdAndLines, string(output), err)
@@ -236,6 +357,44 @@ func (s *State) Compile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine
 	return nil
 }
 
+// runCompile runs the compilation command returned by compileCmd. If it fails only because of unused variables
+// or imports, and these are silenced (see State.Lenient and State.SilenceUnusedVariables), they are fixed and the
+// program is compiled again. It returns the last command run, with its output.
+func (s *State) runCompile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine, compileCmd func() *exec.Cmd) (
+	cmd *exec.Cmd, output []byte, err error) {
+	cmd = compileCmd()
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err = runWithProgress(msg, cmd, "Compiling")
+	for attempt := 0; err != nil && (s.Lenient || s.SilenceUnusedVariables) && attempt < MaxLenientAttempts; attempt++ {
+		// Unused variables and imports are fixed, and the program compiled again.
+		if !s.silenceUnusedInCode(msg, fileToCellIdAndLines, string(output)) {
+			break
+		}
+		cmd = compileCmd()
+		klog.V(2).Infof("Executing %s", cmd)
+		output, err = runWithProgress(msg, cmd, "Compiling")
+	}
+	return
+}
+
+// goImportsPath returns the path to the `goimports` program. If it is not installed, it publishes how to
+// install it, and returns an error.
+func (s *State) goImportsPath(msg kernel.Message) (string, error) {
+	goimportsPath, found := s.ToolPath("goimports")
+	if !found {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, `
+Program goimports is not installed. It is used to automatically import
+missing standard packages, and is a standard Go toolkit package. You
+can install it from the notebook with:
+
+%install_tool goimports
+
+`)
+		return "", errors.Errorf("goimports not found in PATH, while trying to run goimports\n")
+	}
+	return goimportsPath, nil
+}
+
 // GoImports execute `goimports` which adds imports to non-declared imports automatically.
 // It also runs "go get" to download any missing dependencies.
 //
@@ -243,17 +402,8 @@ func (s *State) Compile(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine
 func (s *State) GoImports(msg kernel.Message, decls *Declarations, mainDecl *Function, fileToCellIdAndLine []CellIdAndLine) (cursorInFile Cursor, updatedFileToCellIdAndLine []CellIdAndLine, err error) {
 	klog.V(2).Infof("GoImports():")
 	cursorInFile = NoCursor
-	goimportsPath, err := exec.LookPath("goimports")
+	goimportsPath, err := s.goImportsPath(msg)
 	if err != nil {
-		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, `
-Program goimports is not installed. It is used to automatically import
-missing standard packages, and is a standard Go toolkit package. You
-can install it from the notebook with:
-
-!go install golang.org/x/tools/cmd/goimports@latest
-
-`)
-		err = errors.WithMessagef(err, "while trying to run goimports\n")
 		return
 	}
 	cmd := exec.Command(goimportsPath, "-w", s.CodePath())
@@ -304,15 +454,22 @@ can install it from the notebook with:
 	if !s.AutoGet {
 		return
 	}
+	if s.Vendored {
+		// `go get` would make go.mod inconsistent with the vendored dependencies.
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			"Dependencies are vendored (see `%vendor`), `go get` skipped: use `%vendor off` to fetch new dependencies.\n")
+		return
+	}
 
 	args := []string{"get"}
 	if s.CellIsTest {
 		args = append(args, "-t")
 	}
-	cmd = exec.Command("go", args...)
-	cmd.Dir = s.TempDir
-	klog.V(2).Infof("Executing %s", cmd)
-	output, err = cmd.CombinedOutput()
+	output, err = s.runGoGetWithRetries(msg, func() *exec.Cmd {
+		cmd = s.GoCommand(args...)
+		cmd.Dir = s.TempDir
+		return cmd
+	})
 	if err != nil {
 		err = errors.Wrapf(err, "failed to run %q", cmd.String())
 		strOutput := fmt.Sprintf("%v\n\n%s", err, output)
@@ -320,72 +477,193 @@ can install it from the notebook with:
 		err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, strOutput, err)
 		return
 	}
+	if err = s.pinGonbuiVersion(msg); err != nil {
+		klog.Warningf("%+v", err)
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("warning: %v\n", err))
+		err = nil
+	}
 	return
 }
 
 // jupyterStackTraceMapperWriter implements an io.Writer that maps stack traces to their corresponding
 // cell Lines, to facilitate debugging.
+//
+// If the program panics, the panic output is held until the writer is closed, and then it is
+// published as a cleaned up report, see parsePanic. The output is only held while it looks like a
+// panic: if no goroutine stack trace follows the start of the panic (e.g.: the program logged a recovered
+// panic and carried on), or if it grows too large, it is written out as is.
 type jupyterStackTraceMapperWriter struct {
+	msg                 kernel.Message
 	jupyterWriter       io.Writer
 	mainPath            string
 	fileToCellIdAndLine []CellIdAndLine
-	regexpMainPath      *regexp.Regexp
+
+	// rawError indicates the panic report should be written as text, as opposed to HTML.
+	rawError bool
+
+	// atLineStart indicates whether the next byte written is at the start of a line.
+	atLineStart bool
+
+	// lineStart holds an incomplete line that may be the start of a panic, split across writes.
+	lineStart []byte
+
+	// panicOutput holds the output from the start of a panic, if one happened, and panicConfirmed
+	// whether a goroutine stack trace was found in it.
+	panicOutput    *bytes.Buffer
+	panicConfirmed bool
 }
 
+const (
+	// maxPanicOutput is the maximum size of the panic output held by jupyterStackTraceMapperWriter.
+	// Beyond that, the output is written as is.
+	maxPanicOutput = 1 << 20
+
+	// maxPanicHeaderLines is the number of lines after the start of a panic within which a goroutine
+	// stack trace is expected. If none is found, the output held is written as is.
+	maxPanicHeaderLines = 10
+)
+
 // newJupyterStackTraceMapperWriter creates an io.Writer that allows for mapping of references to the `main.go`
 // to its corresponding position in a cell.
-func newJupyterStackTraceMapperWriter(msg kernel.Message, stream string, mainPath string, fileToCellIdAndLine []CellIdAndLine) io.Writer {
-	r, err := regexp.Compile(fmt.Sprintf("%s:(\\d+)", regexp.QuoteMeta(mainPath)))
-	if err != nil {
-		klog.Errorf("Failed to compile expression to match %q: won't be able to map stack traces with cell Lines", mainPath)
-	}
-
+func newJupyterStackTraceMapperWriter(msg kernel.Message, stream string, mainPath string, fileToCellIdAndLine []CellIdAndLine, rawError bool) io.Writer {
 	return &jupyterStackTraceMapperWriter{
+		msg:                 msg,
 		jupyterWriter:       kernel.NewJupyterStreamWriter(msg, stream),
 		mainPath:            mainPath,
-		regexpMainPath:      r,
 		fileToCellIdAndLine: fileToCellIdAndLine,
+		rawError:            rawError,
+		atLineStart:         true,
 	}
 }
 
 // Write implements io.Writer, and maps references to the `main.go` file to their corresponding Lines in cells.
 func (w *jupyterStackTraceMapperWriter) Write(p []byte) (int, error) {
-	n := len(p) // Save original number of bytes.
-	if w.regexpMainPath == nil {
-		return w.jupyterWriter.Write(p)
-	}
-	p = w.regexpMainPath.ReplaceAllFunc(p, func(match []byte) []byte {
-		klog.V(2).Infof("\tFiltering stderr: %s", match)
-		lineNumStr := strings.Split(string(match), ":")[1]
-		lineNum, err := strconv.Atoi(lineNumStr)
-		if err != nil {
-			klog.Warningf("Can't parse line number in err output %q, skipping", match)
-			return match
+	if err := w.write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write implements Write: it holds the output from the start of a panic, and writes the rest mapped.
+func (w *jupyterStackTraceMapperWriter) write(p []byte) error {
+	if w.panicOutput != nil {
+		w.panicOutput.Write(p)
+		return w.checkPanicOutput()
+	}
+	data := append(w.lineStart, p...)
+	w.lineStart = nil
+
+	// Search for the start of a panic, at the start of a line.
+	for _, loc := range regexpPanicStart.FindAllIndex(data, -1) {
+		if loc[0] == 0 && !w.atLineStart {
+			continue
 		}
-		lineNum -= 1 // Since line reporting starts with 1, but our indices start with 0.
-		if lineNum < 0 || lineNum >= len(w.fileToCellIdAndLine) {
-			klog.Warningf("Can't find line number %d in %q: skipping", lineNum, w.mainPath)
-			return match
+		if err := w.writeMapped(data[:loc[0]]); err != nil {
+			return err
 		}
-		cellId, cellLineNum := w.fileToCellIdAndLine[lineNum].Id, w.fileToCellIdAndLine[lineNum].Line
-		var cellText []byte
+		w.panicOutput = bytes.NewBuffer(nil)
+		w.panicOutput.Write(data[loc[0]:])
+		return w.checkPanicOutput()
+	}
+
+	// Hold an incomplete last line that may be the start of a panic.
+	lastLineStart := bytes.LastIndexByte(data, '\n') + 1
+	if (lastLineStart > 0 || w.atLineStart) && isPanicStartPrefix(data[lastLineStart:]) {
+		w.lineStart = data[lastLineStart:]
+		data = data[:lastLineStart]
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	w.atLineStart = data[len(data)-1] == '\n'
+	return w.writeMapped(data)
+}
+
+// isPanicStartPrefix returns whether the incomplete line may be the beginning of the start of a panic.
+func isPanicStartPrefix(line []byte) bool {
+	if len(line) == 0 {
+		return false
+	}
+	for _, start := range []string{"panic: ", "fatal error: "} {
+		if len(line) < len(start) && strings.HasPrefix(start, string(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPanicOutput checks whether the panic output held is followed by a goroutine stack trace, and
+// otherwise writes it out. It also writes it out if it grows beyond maxPanicOutput.
+func (w *jupyterStackTraceMapperWriter) checkPanicOutput() error {
+	output := w.panicOutput.Bytes()
+	if !w.panicConfirmed {
+		if regexpGoroutineStart.Match(output) {
+			w.panicConfirmed = true
+		} else if bytes.Count(output, []byte("\n")) > maxPanicHeaderLines {
+			// Not a panic of the program: write out the first line, and check the rest again.
+			w.panicOutput = nil
+			firstLineEnd := bytes.IndexByte(output, '\n') + 1
+			if err := w.writeMapped(output[:firstLineEnd]); err != nil {
+				return err
+			}
+			w.atLineStart = true
+			return w.write(output[firstLineEnd:])
+		}
+	}
+	if len(output) > maxPanicOutput {
+		w.panicOutput, w.panicConfirmed = nil, false
+		w.atLineStart = output[len(output)-1] == '\n'
+		return w.writeMapped(output)
+	}
+	return nil
+}
+
+// writeMapped writes p to the Jupyter writer, with the references to `main.go` mapped to their corresponding Lines in cells.
+func (w *jupyterStackTraceMapperWriter) writeMapped(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	p = []byte(MapFileReferences(string(p), w.mainPath, w.fileToCellIdAndLine, func(reference string, cellLine CellIdAndLine) string {
+		klog.V(2).Infof("\tFiltering stderr: %s", reference)
 		const invertColor = "\033[7m"
 		const resetColor = "\033[0m"
-		// Since line reports usually start with 1, we report cellLineNum+1
-		if cellId == -1 {
-			cellText = []byte(fmt.Sprintf(" %s[[ Cell Line %d ]]%s ", invertColor, cellLineNum+1, resetColor))
+		return fmt.Sprintf("%s%s%s %s", invertColor, cellLine, resetColor, reference)
+	}))
+	_, err := w.jupyterWriter.Write(p)
+	return err
+}
+
+// Close implements io.Closer: it publishes the panic report, if the program panicked, and closes the underlying
+// Jupyter writer, if it implements io.Closer.
+func (w *jupyterStackTraceMapperWriter) Close() error {
+	if len(w.lineStart) > 0 {
+		if err := w.writeMapped(w.lineStart); err != nil {
+			klog.Errorf("Failed to write output: %+v", err)
+		}
+		w.lineStart = nil
+	}
+	if w.panicOutput != nil {
+		output := w.panicOutput.Bytes()
+		w.panicOutput = nil
+		report := parsePanic(string(output), w.mainPath, w.fileToCellIdAndLine)
+		var err error
+		if report == nil {
+			// Not a stack trace we can parse: output as is.
+			err = w.writeMapped(output)
+		} else if w.rawError {
+			// report.Text() already includes the cell lines.
+			_, err = io.WriteString(w.jupyterWriter, report.Text())
 		} else {
-			cellText = []byte(fmt.Sprintf(" %s[[ Cell [%d] Line %d ]]%s ", invertColor, cellId, cellLineNum+1, resetColor))
+			err = report.Publish(w.msg)
 		}
-		res := bytes.Join([][]byte{cellText, match}, nil)
-		return res
-	})
-	_, err := w.jupyterWriter.Write(p)
-	if err != nil {
-		return 0, err
+		if err != nil {
+			klog.Errorf("Failed to publish panic report: %+v", err)
+		}
+	}
+	if closer, ok := w.jupyterWriter.(io.Closer); ok {
+		return closer.Close()
 	}
-	// Return the original number of bytes: since we change what is written, we actually write more bytes.
-	return n, nil
+	return nil
 }
 
 const (
@@ -0,0 +1,162 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// This file implements the export of the generated program to a standalone Go program, used by `%export`,
+// or to a module directory, used by `%export_module`.
+
+// exportProgram writes the program generated for the current cell (already formatted by `goimports`) to
+// filePath, along with the `go.mod` and `go.sum` files in the same directory.
+//
+// An existing `go.mod` in the target directory is not overwritten, since it likely belongs to another project.
+func (s *State) exportProgram(msg kernel.Message, filePath string) error {
+	src, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	dir := path.Dir(filePath)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q to export the program", dir)
+	}
+	if err = os.WriteFile(filePath, []byte(src), 0644); err != nil {
+		return errors.Wrapf(err, "failed to export the program to %q", filePath)
+	}
+	report := fmt.Sprintf("Program exported to %q.\n", filePath)
+	if _, err = os.Stat(path.Join(dir, "go.mod")); err == nil {
+		report += fmt.Sprintf("Directory %q already has a go.mod, it was not overwritten.\n", dir)
+	} else {
+		for _, name := range []string{"go.mod", "go.sum"} {
+			content, err := os.ReadFile(path.Join(s.TempDir, name))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return errors.Wrapf(err, "failed to read %q to export", name)
+			}
+			if err = os.WriteFile(path.Join(dir, name), content, 0644); err != nil {
+				return errors.Wrapf(err, "failed to export %q to %q", name, dir)
+			}
+			report += fmt.Sprintf("%s exported to %q.\n", name, dir)
+		}
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+// exportModule writes the program generated for the current cell to the directory dir as a module that builds
+// with `go build`: the declarations are split into `types.go` (constants and types), `funcs.go` (variables and
+// functions) and `main.go`, along with the `go.mod` and `go.sum` files.
+//
+// Local directories in "replace" rules of `go.mod` are made absolute, so they still resolve from the new
+// location, and they are reported since the module depends on them. It fails if dir already has a `go.mod`.
+func (s *State) exportModule(msg kernel.Message, decls *Declarations, mainDecl *Function, dir string) error {
+	if _, err := os.Stat(path.Join(dir, "go.mod")); err == nil {
+		return errors.Errorf("directory %q already has a go.mod, `%%export_module` won't overwrite it", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create directory %q to export the module", dir)
+	}
+
+	// Source files: each one gets all imports, and `goimports` removes the unused ones.
+	files := []struct {
+		name     string
+		decls    *Declarations
+		mainDecl *Function
+	}{
+		{"types.go", &Declarations{Types: decls.Types, Constants: decls.Constants}, nil},
+		{"funcs.go", &Declarations{Variables: decls.Variables, Functions: decls.Functions}, nil},
+		{MainGo, &Declarations{}, mainDecl},
+	}
+	var names, filePaths []string
+	for _, file := range files {
+		if len(file.decls.Types)+len(file.decls.Constants)+len(file.decls.Variables)+len(file.decls.Functions) == 0 &&
+			file.mainDecl == nil {
+			continue
+		}
+		file.decls.Imports = decls.Imports
+		var sb strings.Builder
+		if _, _, err := s.createCodeFromDecls(&sb, file.decls, file.mainDecl); err != nil {
+			return errors.WithMessagef(err, "failed to render %q", file.name)
+		}
+		filePath := path.Join(dir, file.name)
+		if err := os.WriteFile(filePath, []byte(sb.String()), 0644); err != nil {
+			return errors.Wrapf(err, "failed to export %q", filePath)
+		}
+		names = append(names, file.name)
+		filePaths = append(filePaths, filePath)
+	}
+	report := fmt.Sprintf("Module exported to %q: %s.\n", dir, strings.Join(names, ", "))
+	if goimportsPath, found := s.ToolPath("goimports"); found {
+		cmd := exec.Command(goimportsPath, append([]string{"-w"}, filePaths...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "failed to run %q on the exported module:\n%s", cmd.String(), output)
+		}
+	} else {
+		report += "\t- WARNING: `goimports` not found, unused imports were not removed from the exported files.\n"
+	}
+
+	// go.mod, with local replace rules made absolute, and go.sum.
+	goModPath := path.Join(s.TempDir, "go.mod")
+	goModContents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, goModContents, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	for _, replace := range modFile.Replace {
+		if replace.New.Version != "" {
+			continue // Not a local directory.
+		}
+		localPath := replace.New.Path
+		if !filepath.IsAbs(localPath) {
+			localPath = path.Join(s.TempDir, localPath)
+			if err = modFile.AddReplace(replace.Old.Path, replace.Old.Version, localPath, ""); err != nil {
+				return errors.Wrapf(err, "failed to update replace rule of %q", replace.Old.Path)
+			}
+		}
+		report += fmt.Sprintf("\t- WARNING: module %q is replaced by the local directory %q, the exported module "+
+			"depends on it.\n", replace.Old.Path, localPath)
+	}
+	goModContents, err = modFile.Format()
+	if err != nil {
+		return errors.Wrapf(err, "failed to format the exported `go.mod`")
+	}
+	if err = os.WriteFile(path.Join(dir, "go.mod"), goModContents, 0644); err != nil {
+		return errors.Wrapf(err, "failed to export go.mod to %q", dir)
+	}
+	goSum, err := os.ReadFile(path.Join(s.TempDir, "go.sum"))
+	if err == nil {
+		err = os.WriteFile(path.Join(dir, "go.sum"), goSum, 0644)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to export go.sum to %q", dir)
+	}
+	if _, err = os.Stat(path.Join(s.TempDir, "go.work")); err == nil {
+		report += "\t- WARNING: `go.work` is not exported, use `%goworkfix` first to convert its \"use\" clauses " +
+			"to \"replace\" rules in `go.mod`.\n"
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
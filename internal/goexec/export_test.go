@@ -0,0 +1,62 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestExportProgram(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	src := "package main\n\nfunc main() {}\n"
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(src), 0644))
+
+	// Exported along with the go.mod.
+	filePath := path.Join(t.TempDir(), "prototype", "main.go")
+	require.NoError(t, s.exportProgram(nil, filePath))
+	content, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, src, string(content))
+	goMod, err := os.ReadFile(path.Join(path.Dir(filePath), "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(goMod), "module "+s.Package)
+
+	// An existing go.mod is not overwritten.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "go.mod"), []byte("module other\n"), 0644))
+	require.NoError(t, s.exportProgram(nil, path.Join(dir, "main.go")))
+	goMod, err = os.ReadFile(path.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, "module other\n", string(goMod))
+}
+
+func TestExportModule(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	decls := NewDeclarations()
+	decls.Types["Point"] = &TypeDecl{Cursor: NoCursor, Key: "Point", TypeDefinition: "Point struct{ X, Y int }"}
+	decls.Functions["Norm"] = &Function{Cursor: NoCursor, Key: "Norm", Name: "Norm",
+		Definition: "func Norm(p Point) int { return p.X*p.X + p.Y*p.Y }"}
+	mainDecl := &Function{Cursor: NoCursor, Key: "main", Name: "main",
+		Definition: "func main() { _ = Norm(Point{1, 2}) }"}
+
+	dir := path.Join(t.TempDir(), "module")
+	require.NoError(t, s.exportModule(nil, decls, mainDecl, dir))
+	for _, name := range []string{"types.go", "funcs.go", MainGo, "go.mod"} {
+		assert.FileExists(t, path.Join(dir, name))
+	}
+	content, err := os.ReadFile(path.Join(dir, "funcs.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "func Norm(p Point) int")
+	assert.NotContains(t, string(content), "type Point")
+
+	// It won't overwrite an existing module.
+	assert.Error(t, s.exportModule(nil, decls, mainDecl, dir))
+}
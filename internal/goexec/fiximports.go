@@ -0,0 +1,73 @@
+package goexec
+
+import (
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os/exec"
+	"strings"
+)
+
+// FixImports runs `goimports` over the memorized declarations, and updates the memorized imports accordingly:
+// missing imports (typically from the standard library) are added, and unused ones are removed.
+// It implements `%goimports`.
+//
+// Only the memorized declarations are considered, so an import may look unused because only `%%` (main)
+// cells use it: unused imports are only removed if `goimports` can restore them later, see
+// removableImport.
+//
+// It returns the keys of the imports added and removed.
+func (s *State) FixImports(msg kernel.Message) (added, removed []string, err error) {
+	goimportsPath, err := s.goImportsPath(msg)
+	if err != nil {
+		return
+	}
+	// Empty main function, just so the program is complete: it doesn't use any package.
+	mainDecl := &Function{Cursor: NoCursor, Key: "main", Name: "main", Definition: "func main() {}"}
+	decls := s.Definitions.Copy()
+	decls.ClearCursor()
+	var fileToCellIdAndLine []CellIdAndLine
+	if _, fileToCellIdAndLine, err = s.createCodeFileFromDecls(decls, mainDecl); err != nil {
+		err = errors.WithMessagef(err, "while composing main.go with all declarations")
+		return
+	}
+	cmd := exec.Command(goimportsPath, "-w", s.CodePath())
+	cmd.Dir = s.TempDir
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, string(output)+"\n"+err.Error(), err)
+		err = errors.Wrapf(err, "failed to run %q", cmd.String())
+		return
+	}
+	newDecls, err := s.parseFromGoCode(msg, -1, NoCursor, nil)
+	if err != nil {
+		return
+	}
+	for _, key := range SortedKeys(newDecls.Imports) {
+		if _, found := s.Definitions.Imports[key]; !found {
+			importDecl := newDecls.Imports[key]
+			importDecl.Cursor, importDecl.CellLines = NoCursor, CellLines{Id: -1}
+			s.Definitions.Imports[key] = importDecl
+			added = append(added, key)
+		}
+	}
+	for _, key := range SortedKeys(s.Definitions.Imports) {
+		if _, found := newDecls.Imports[key]; !found && removableImport(s.Definitions.Imports[key]) {
+			delete(s.Definitions.Imports, key)
+			removed = append(removed, key)
+		}
+	}
+	return
+}
+
+// removableImport returns whether an import unused by the memorized declarations can be removed:
+// only non-aliased imports from the standard library, which `goimports` can add back, are removed.
+func removableImport(importDecl *Import) bool {
+	if importDecl.Alias != "" {
+		return false
+	}
+	firstElem, _, _ := strings.Cut(importDecl.Path, "/")
+	return !strings.Contains(firstElem, ".")
+}
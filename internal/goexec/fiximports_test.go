@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFixImports(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	if _, found := s.ToolPath("goimports"); !found {
+		t.Skip("goimports not installed")
+	}
+	unused := NewImport("strings", "")
+	unused.Cursor = NoCursor
+	s.Definitions.Imports[unused.Key] = unused
+	s.Definitions.Functions["Hello"] = &Function{Cursor: NoCursor, Key: "Hello", Name: "Hello",
+		Definition: "func Hello() { fmt.Println(\"hello\") }"}
+
+	added, removed, err := s.FixImports(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fmt"}, added)
+	assert.Equal(t, []string{"strings"}, removed)
+	assert.Contains(t, s.Definitions.Imports, "fmt")
+	assert.NotContains(t, s.Definitions.Imports, "strings")
+
+	// Nothing else to fix.
+	added, removed, err = s.FixImports(nil)
+	require.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+}
+
+func TestRemovableImport(t *testing.T) {
+	assert.True(t, removableImport(NewImport("strings", "")))
+	assert.True(t, removableImport(NewImport("encoding/json", "")))
+	assert.False(t, removableImport(NewImport("encoding/json", "js")))
+	assert.False(t, removableImport(NewImport("github.com/janpfeifer/gonb/gonbui", "")))
+	assert.False(t, removableImport(NewImport("github.com/janpfeifer/gonb/gonbui", "ui")))
+	assert.False(t, removableImport(NewImport("golang.org/x/exp/maps", "")))
+}
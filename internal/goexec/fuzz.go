@@ -0,0 +1,97 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+	"strings"
+)
+
+// This file implements the support for Go's native fuzzing, see `%fuzz`.
+
+// DefaultFuzzTime is the value of `-test.fuzztime` used by `%fuzz` if none is given.
+const DefaultFuzzTime = "10s"
+
+// FuzzCorpusDir returns the directory where the corpus of the given fuzz target is saved, including
+// any failing input.
+func (s *State) FuzzCorpusDir(target string) string {
+	return path.Join(s.TempDir, "testdata", "fuzz", target)
+}
+
+// FuzzCacheDir returns the directory where the fuzzing engine caches the inputs it generated
+// that expanded coverage.
+func (s *State) FuzzCacheDir() string {
+	return path.Join(s.TempDir, "fuzzcache")
+}
+
+// checkFuzzTarget returns an error if the fuzz target of the cell is not a memorized function.
+func (s *State) checkFuzzTarget() error {
+	if _, found := s.Definitions.Functions[s.CellFuzzTarget]; found {
+		return nil
+	}
+	var targets []string
+	for _, key := range common.SortedKeys(s.Definitions.Functions) {
+		if strings.HasPrefix(key, "Fuzz") && !strings.Contains(key, "~") {
+			targets = append(targets, key)
+		}
+	}
+	if len(targets) == 0 {
+		return errors.Errorf("`%%fuzz %s`: no fuzz target memorized, define it with "+
+			"`func %s(f *testing.F) {...}`", s.CellFuzzTarget, s.CellFuzzTarget)
+	}
+	return errors.Errorf("`%%fuzz %s`: fuzz target not memorized, the memorized ones are: %s",
+		s.CellFuzzTarget, strings.Join(targets, ", "))
+}
+
+// fuzzArgs returns the flags for the test binary to fuzz the cell's fuzz target.
+func (s *State) fuzzArgs() []string {
+	fuzzTime := s.CellFuzzTime
+	if fuzzTime == "" {
+		fuzzTime = DefaultFuzzTime
+	}
+	return []string{
+		fmt.Sprintf("-test.run=^%s$", s.CellFuzzTarget),
+		fmt.Sprintf("-test.fuzz=^%s$", s.CellFuzzTarget),
+		"-test.fuzztime=" + fuzzTime,
+		"-test.fuzzcachedir=" + s.FuzzCacheDir(),
+	}
+}
+
+// publishFailingFuzzInput publishes the contents of the latest failing input saved in the corpus of
+// the cell's fuzz target, if any.
+func (s *State) publishFailingFuzzInput(msg kernel.Message) {
+	corpusDir := s.FuzzCorpusDir(s.CellFuzzTarget)
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			klog.Warningf("Failed to read fuzz corpus in %q: %+v", corpusDir, err)
+		}
+		return
+	}
+	var latestName string
+	var latestInfo os.FileInfo
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if latestInfo == nil || info.ModTime().After(latestInfo.ModTime()) {
+			latestName, latestInfo = entry.Name(), info
+		}
+	}
+	if latestInfo == nil {
+		return
+	}
+	content, err := os.ReadFile(path.Join(corpusDir, latestName))
+	if err != nil {
+		klog.Warningf("Failed to read failing fuzz input: %+v", err)
+		return
+	}
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+		"\nFailing input %s (saved in %s, it is replayed first by the next `%%fuzz %s`):\n%s\n",
+		latestName, corpusDir, s.CellFuzzTarget, content))
+}
@@ -0,0 +1,25 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFuzzTarget(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	s.CellFuzzTarget = "FuzzParse"
+	assert.ErrorContains(t, s.checkFuzzTarget(), "no fuzz target memorized")
+	s.Definitions.Functions["FuzzReverse"] = &Function{Cursor: NoCursor, Key: "FuzzReverse", Name: "FuzzReverse"}
+	assert.ErrorContains(t, s.checkFuzzTarget(), "FuzzReverse")
+	s.CellFuzzTarget = "FuzzReverse"
+	assert.NoError(t, s.checkFuzzTarget())
+
+	args := s.fuzzArgs()
+	assert.Contains(t, args, "-test.fuzz=^FuzzReverse$")
+	assert.Contains(t, args, "-test.fuzztime="+DefaultFuzzTime)
+	s.CellFuzzTime = "100x"
+	assert.Contains(t, s.fuzzArgs(), "-test.fuzztime=100x")
+}
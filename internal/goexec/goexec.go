@@ -12,11 +12,14 @@ import (
 	"github.com/janpfeifer/gonb/internal/goexec/goplsclient"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
 	"k8s.io/klog/v2"
+	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"regexp"
+	"strings"
+	"time"
 )
 
 const (
@@ -30,6 +33,10 @@ const (
 	InitFunctionPrefix = "init_"
 )
 
+// DefaultSecretEnvPatterns are the default patterns of names of environment variables holding secrets, see
+// State.SecretEnvPatterns.
+var DefaultSecretEnvPatterns = []string{"*TOKEN*", "*SECRET*", "*PASSWORD*"}
+
 // State holds information about Go code execution for this kernel. It's a singleton (for now).
 // It hols the directory, ids, configuration, command line arguments to use and currently
 // defined Go code.
@@ -45,16 +52,124 @@ type State struct {
 	UniqueID, Package, TempDir string
 
 	// Building and executing go code configuration:
-	Args         []string // Args to be passed to the program, after being executed.
 	GoBuildFlags []string // Flags to be passed to `go build`, in State.Compile.
 	AutoGet      bool     // Whether to do a "go get" before compiling, to fetch missing external modules.
 
+	// BuildCache indicates whether the Go build cache is used when compiling. If false, all packages
+	// are rebuilt (`go build -a`).
+	BuildCache bool
+
+	// GoOS and GoArch, if set, override the platform the programs are compiled for (see `%goos` and `%goarch`).
+	// Set them with SetTarget.
+	GoOS, GoArch   string
+	crossCompiling bool
+	target         string
+
+	// PGO indicates the programs are compiled with profile-guided optimization, using the CPU profile captured
+	// with `%pgo capture` (see PgoProfilePath).
+	PGO bool
+
+	// Vendored indicates the dependencies of the notebook module are vendored (see `%vendor`), and programs are
+	// compiled with `-mod=vendor`.
+	Vendored bool
+
+	// Lenient downgrades to warnings the compile errors about unused variables and imports, fixing the generated
+	// program (see `%lenient`).
+	Lenient bool
+
+	// SilenceUnusedVariables downgrades to warnings the compile errors about unused variables only, the most
+	// common friction when experimenting. It is off by default, see `%config silence_unused_vars`.
+	SilenceUnusedVariables bool
+
+	// GoWorkAutoUse adds `use` rules to `go.work` (if there is one) for the tracked directories with Go modules
+	// that are not yet used. It is on by default, see `%config gowork_auto_use`.
+	GoWorkAutoUse bool
+
+	// AutoPrint indicates whether the value of a bare expression at the end of `func main()` (e.g.: the last line
+	// after `%%`) is displayed, see `%autoprint`.
+	AutoPrint bool
+
+	// Repl indicates the variables declared at the top level of `func main()` (e.g.: `x := 5` after `%%`) are
+	// memorized as package level variables, so they are visible in the following cells, see `%repl`.
+	Repl bool
+
+	// GroupImports renders the import block of the generated program grouped like `goimports`: standard library,
+	// third-party and local packages, separated by blank lines. Otherwise, the imports are rendered in one block
+	// sorted by name. See `%imports --group` and `%imports --flat`.
+	GroupImports bool
+
+	// GoGetAttempts is the number of attempts to run `go get` (see AutoGet), when it fails due to transient
+	// network errors. GoGetBackoff is the wait before the first retry, doubled for each subsequent one.
+	GoGetAttempts int
+	GoGetBackoff  time.Duration
+
+	// ExecTimeout is the maximum time the program of a cell can run before it is interrupted.
+	// If 0 there is no limit.
+	ExecTimeout time.Duration
+
+	// Shell is the interpreter used to execute shell commands (lines starting with `!`), invoked with the
+	// arguments returned by ShellArgs. It defaults to DefaultShell, or to the first available interpreter
+	// of the platform (e.g.: FallbackShell if bash is not installed).
+	Shell string
+
+	// CaptureCoverage enables the capture of the coverage of the cells executed (programs and tests), accumulated
+	// in CoverageDir across executions. See `%capture_coverage` and CoverageReport.
+	CaptureCoverage bool
+
+	// SecretEnvPatterns are the patterns (see path.Match) of the names of environment variables holding secrets:
+	// their values are masked when set with `%env`. The names are matched in upper case. It defaults to
+	// DefaultSecretEnvPatterns.
+	SecretEnvPatterns []string
+
+	// goBinary is the `go` command used to compile, and goRootOverride the GOROOT to use with it, if
+	// not empty. See SetGoRoot and SetGoVersion.
+	goBinary, goRootOverride string
+
+	// toolPaths caches the paths to the tools installed with InstallTool.
+	toolPaths map[string]string
+
 	// Global elements defined mapped by their keys.
 	Definitions *Declarations
 
+	// fileToCellIdAndLine maps the lines of the generated Go file of the last cell executed to the cell lines.
+	// See FileToCellIdAndLine.
+	fileToCellIdAndLine []CellIdAndLine
+
+	// Aliases maps user defined special command names (set with `%alias`) to their expansion.
+	Aliases map[string]string
+
+	// Macros maps macro names (recorded with `%macro`) to the cells recorded.
+	Macros map[string][]RecordedCell
+
+	// MacroRecording is the name of the macro being recorded, or empty if none is being recorded.
+	MacroRecording string
+
+	// MacroRunning is the name of the macro being replayed, or empty if none is running.
+	MacroRunning string
+
+	// NamedCells maps cell names (set with `%%cell <name>`) to their source, so they can be executed
+	// again with `%run <name>`.
+	NamedCells map[string]RecordedCell
+
+	// NamedCellsRunning holds the names of the cells being executed with `%run`, to prevent recursion.
+	NamedCellsRunning common.Set[string]
+
+	// Snapshots maps snapshot names (saved with `%snapshot save <name>`) to the source of the memorized
+	// declarations at the time, so they can be compared with `%diff <name>`.
+	Snapshots map[string]string
+
+	// SqlConnection is the database connection configured with `%sql_connect`, used by `%%sql` cells.
+	SqlConnection SqlConnection
+
+	// HttpHeaders are the headers set with `%http_header`, sent with every `%http` request.
+	HttpHeaders http.Header
+
 	// gopls client
 	gopls *goplsclient.Client
 
+	// completion settings of gopls, kept here also when gopls is not available. See SetCompletionSettings.
+	completion goplsclient.CompletionSettings
+
 	// trackingInfo is everything related to tracking.
 	trackingInfo *trackingInfo
 
@@ -82,6 +197,43 @@ type State struct {
 	// Jupyter before previous cell execution finishes, and we want to keep the order.
 	cellExecChan chan *cellExecParams
 
+	// CellState holds the configuration specific to the cell being executed, reset by PostExecuteCell.
+	CellState
+
+	// CellWatchPaths, if set, are the files or directories watched by the current cell (see `%watch`): the cell is
+	// executed again whenever they change. Unlike the other cell fields, it is not reset by PostExecuteCell, but by
+	// the caller that handles the re-execution.
+	CellWatchPaths []string
+
+	// CellRefreshInterval, if > 0, is the interval at which the current cell is executed again (see `%every`). Like
+	// CellWatchPaths, it is reset by the caller that handles the re-execution.
+	CellRefreshInterval time.Duration
+
+	// WasmDir and WasmUrl are where the WebAssembly (wasm) programs are written to, and served from, see
+	// CellState.CellIsWasm.
+	WasmDir, WasmUrl string
+
+	// FilesDir is the directory where files served by Jupyter are stored, see `gonbui.ServeFile`.
+	// It is only set if the Jupyter root directory is known, and it is removed when the kernel stops.
+	FilesDir string
+
+	// Comms represents the communication with the front-end.
+	Comms *comms.State
+
+	// StartupTimings holds the time spent in each phase of the creation of the State, and
+	// LastCellTimings the time spent in each phase of the last Go cell executed. See `%profile_startup`.
+	StartupTimings, LastCellTimings []Timing
+
+	// RecentErrors are the errors of the last cells that failed, oldest first, see RecordCellError.
+	RecentErrors []CellError
+}
+
+// CellState holds the configuration of State that is specific to the cell being executed, usually set by
+// special commands (e.g.: `%test`, `%args`). It is reset by PostExecuteCell, and it can be saved and restored
+// to execute other cells from within the current one (e.g.: `%run`).
+type CellState struct {
+	Args []string // Args to be passed to the program, after being executed.
+
 	// CellIsTest indicates whether the current cell is to be compiled with `go test` (as opposed to `go build`).
 	// This also triggers writing the code to `main_test.go` as opposed to `main.go`.
 	// Usually this is set and reset after the execution -- the default being the normal build.
@@ -89,12 +241,92 @@ type State struct {
 	CellTests         []string // Tests defined in this cell. Only used if CellIsTest==true.
 	CellHasBenchmarks bool
 
-	// CellIsWasm indicates whether the current cell is to be compiled for WebAssembly (wasm).
-	CellIsWasm                  bool
-	WasmDir, WasmUrl, WasmDivId string
+	// CellIsRace indicates the test of the current cell is to be compiled with the race detector (see `%test --race`).
+	CellIsRace bool
 
-	// Comms represents the communication with the front-end.
-	Comms *comms.State
+	// CellFuzzTarget is the name of the `Fuzz*` function to fuzz in the current cell, and CellFuzzTime
+	// is the value of `-test.fuzztime` to use (see `%fuzz`).
+	CellFuzzTarget, CellFuzzTime string
+
+	// CellIsDryRun indicates the program generated for the current cell should be displayed (see `%show`),
+	// instead of compiled and executed. Declarations of the cell are not memorized.
+	CellIsDryRun bool
+
+	// CellPgoCapture indicates a CPU profile of the execution of the current cell is saved to PgoProfilePath,
+	// see `%pgo capture`.
+	CellPgoCapture bool
+
+	// CellDiscardDecls indicates the declarations of the current cell are not memorized, even if it is
+	// executed successfully (see `%discard`).
+	CellDiscardDecls bool
+
+	// CellKeepDecls indicates the declarations of the current cell are memorized even in the modes that
+	// don't memorize them, like `%show` or `%build` (see `%keep`).
+	CellKeepDecls bool
+
+	// CellEntryPoint, if set, is the memorized function called at the end of `func main()` (see
+	// `%main --gonb_entry <name>`).
+	CellEntryPoint string
+
+	// CellAsmFunction, if set, is the function (or method) whose assembly is displayed (see `%asm`), instead
+	// of executing the cell. Declarations of the cell are not memorized.
+	CellAsmFunction string
+
+	// CellIsEscapeAnalysis indicates the results of the escape analysis are displayed (see `%escape`), for the
+	// function (or method) CellEscapeFunction, or for all the code if empty, instead of executing the cell.
+	// Declarations of the cell are not memorized.
+	CellIsEscapeAnalysis bool
+	CellEscapeFunction   string
+
+	// CellIsBuildOnly indicates the current cell should only be compiled, and not executed (see `%build`).
+	// Declarations of the cell are not memorized. If CellBuildOutput is set, the binary is copied to it.
+	CellIsBuildOnly bool
+	CellBuildOutput string
+
+	// CellExportPath, if set, is the path where to export the program generated for the current cell as a
+	// standalone Go program (see `%export`), instead of compiling and executing it.
+	CellExportPath string
+
+	// CellExportModuleDir, if set, is the directory where to export the program generated for the current cell
+	// as a Go module (see `%export_module`), instead of compiling and executing it.
+	CellExportModuleDir string
+
+	// CellParameters, if set, maps variable names to the values (Go literals) injected in the current cell, the one
+	// tagged "parameters" (papermill convention). See ParametersToGo.
+	CellParameters map[string]string
+
+	// CellEnv holds extra environment variables ("KEY=value") for the execution of the program of the current
+	// cell, for values that shouldn't be written in the generated code (e.g.: the data source name of `%%sql`).
+	CellEnv []string
+
+	// CellIsGenerated indicates the lines of the current cell were generated by GoNB (e.g.: `%%sql`), and don't
+	// correspond to the lines of the cell: they are not mapped back to cell lines when reporting errors.
+	CellIsGenerated bool
+
+	// CellIsWasm indicates whether the current cell is to be compiled for WebAssembly (wasm), to be
+	// displayed in the HTML element WasmDivId.
+	CellIsWasm bool
+	WasmDivId  string
+}
+
+// RequiresExecution returns whether the cell configuration requires executing the cell even if it has no
+// Go code: e.g.: to run the memorized tests (`%test`) or to export the program (`%export`).
+func (c *CellState) RequiresExecution() bool {
+	return c.CellIsTest || c.CellIsDryRun || c.CellIsBuildOnly || c.CellAsmFunction != "" ||
+		c.CellIsEscapeAnalysis || c.CellEntryPoint != "" || c.CellExportPath != "" || c.CellExportModuleDir != ""
+}
+
+// RecordedCell is the source of a cell recorded to be executed again later, see `%macro` and `%%cell`.
+type RecordedCell struct {
+	// CellId is the execution number of the cell when it was recorded, used to map errors to the cell lines.
+	CellId int
+	Lines  []string
+}
+
+// SqlConnection configures the database connection used by `%%sql` cells: the driver name and the data source name
+// passed to `sql.Open`, and the import path of the package that registers the driver.
+type SqlConnection struct {
+	Driver, DriverImport, DSN string
 }
 
 // Declarations is a collection of declarations that we carry over from one cell to another.
@@ -118,16 +350,31 @@ type Declarations struct {
 // goroutines, that stop when the kernel stops.
 func New(k *kernel.Kernel, uniqueID string, preserveTempDir, rawError bool) (*State, error) {
 	s := &State{
-		Kernel:          k,
-		UniqueID:        uniqueID,
-		Package:         "gonb_" + uniqueID,
-		Definitions:     NewDeclarations(),
-		AutoGet:         true,
-		trackingInfo:    newTrackingInfo(),
-		preserveTempDir: preserveTempDir,
-		rawError:        rawError,
-		Comms:           comms.New(),
-		cellExecChan:    make(chan *cellExecParams),
+		Kernel:            k,
+		UniqueID:          uniqueID,
+		Package:           "gonb_" + uniqueID,
+		Definitions:       NewDeclarations(),
+		Aliases:           make(map[string]string),
+		Macros:            make(map[string][]RecordedCell),
+		NamedCells:        make(map[string]RecordedCell),
+		NamedCellsRunning: common.MakeSet[string](),
+		Snapshots:         make(map[string]string),
+		HttpHeaders:       make(http.Header),
+		AutoGet:           true,
+		BuildCache:        true,
+		GoWorkAutoUse:     true,
+		GoGetAttempts:     DefaultGoGetAttempts,
+		GoGetBackoff:      DefaultGoGetBackoff,
+		Shell:             defaultShell(),
+		SecretEnvPatterns: slices.Clone(DefaultSecretEnvPatterns),
+		goBinary:          DefaultGoBinary,
+		toolPaths:         make(map[string]string),
+		completion:        goplsclient.DefaultCompletionSettings,
+		trackingInfo:      newTrackingInfo(),
+		preserveTempDir:   preserveTempDir,
+		rawError:          rawError,
+		Comms:             comms.New(),
+		cellExecChan:      make(chan *cellExecParams),
 	}
 
 	// Goroutine that processes incoming ExecuteCell requests.
@@ -135,6 +382,7 @@ func New(k *kernel.Kernel, uniqueID string, preserveTempDir, rawError bool) (*St
 	go s.serializeExecuteCell()
 
 	// Create directory.
+	phaseStart := time.Now()
 	s.TempDir = path.Join(os.TempDir(), s.Package)
 	err := os.Mkdir(s.TempDir, 0700)
 	if err != nil {
@@ -162,18 +410,13 @@ func New(k *kernel.Kernel, uniqueID string, preserveTempDir, rawError bool) (*St
 		err = nil
 	}
 
+	phaseStart = timePhase(&s.StartupTimings, "temporary directory setup", phaseStart)
 	if err = s.GoModInit(); err != nil {
 		return nil, err
 	}
+	phaseStart = timePhase(&s.StartupTimings, "go.mod init", phaseStart)
 
-	if _, err = exec.LookPath("gopls"); err == nil {
-		s.gopls = goplsclient.New(s.TempDir)
-		err = s.gopls.Start()
-		if err != nil {
-			klog.Errorf("Failed to start `gopls`: %v", err)
-		}
-		klog.V(1).Infof("Started `gopls`.")
-	} else {
+	if !s.StartGopls() {
 		msg := `
 Program gopls is not installed. It is used to inspect into code
 and provide contextual information and autocompletion. It is a 
@@ -181,11 +424,13 @@ standard Go toolkit package. You can install it from the notebook
 with:
 
 ` + "```" + `
-!go install golang.org/x/tools/gopls@latest
+%install_tool gopls
 ` + "```\n"
-		klog.Errorf(msg)
+		klog.Errorf("%s", msg)
 	}
 
+	phaseStart = timePhase(&s.StartupTimings, "gopls launch", phaseStart)
+
 	// Try to find out Jupyter root's directory.
 	jupyterRoot, err := JupyterRootDirectory()
 	if err != nil {
@@ -196,8 +441,14 @@ with:
 			klog.Errorf("Failed to set environment variable %q: %v", protocol.GONB_JUPYTER_ROOT_ENV, err)
 			err = nil
 		}
+		if err = s.setFilesDir(jupyterRoot); err != nil {
+			klog.Errorf("Failed to configure directory of served files: %v", err)
+			err = nil
+		}
 	}
 
+	timePhase(&s.StartupTimings, "Jupyter root directory", phaseStart)
+
 	klog.Infof("GoNB: jupyter root in %q, tmp Go code in %q", jupyterRoot, s.TempDir)
 	return s, nil
 }
@@ -209,8 +460,14 @@ func (s *State) GoModInit() error {
 		klog.Errorf("Failed to remove go.mod: %+v", err)
 		return errors.Wrapf(err, "failed to remove go.mod")
 	}
+	if s.Vendored {
+		// The vendored dependencies no longer match the new go.mod.
+		if err = s.Unvendor(); err != nil {
+			return err
+		}
+	}
 	// ProgramExecutor `go mod init` on given directory.
-	cmd := exec.Command("go", "mod", "init", s.Package)
+	cmd := s.GoCommand("mod", "init", s.Package)
 	cmd.Dir = s.TempDir
 	var output []byte
 	output, err = cmd.CombinedOutput()
@@ -234,6 +491,14 @@ func (s *State) Stop() error {
 		}
 		s.TempDir = "/"
 	}
+	if s.FilesDir != "" {
+		err := os.RemoveAll(s.FilesDir)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to remove directory of served files %s", s.FilesDir)
+		}
+		_ = os.Remove(path.Dir(s.FilesDir)) // Only removed if empty, that is, if %wasm was not used.
+		s.FilesDir = ""
+	}
 	if s.Comms != nil {
 		// Close without a message (no sending back a comm_close message),
 		// if not yet closed.
@@ -303,6 +568,29 @@ func (d *Declarations) DropFuncInit() {
 	}
 }
 
+// Methods returns the sorted keys of the memorized methods of typeName: the functions keyed `<typeName>~<method>`.
+func (d *Declarations) Methods(typeName string) []string {
+	var keys []string
+	for _, key := range common.SortedKeys(d.Functions) {
+		if receiver, _, isMethod := strings.Cut(key, "~"); isMethod && receiver == typeName {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// RemoveType removes the type typeName along with its methods. It returns the keys of the removed methods,
+// and whether the type was found.
+func (d *Declarations) RemoveType(typeName string) (methods []string, found bool) {
+	_, found = d.Types[typeName]
+	delete(d.Types, typeName)
+	methods = d.Methods(typeName)
+	for _, key := range methods {
+		delete(d.Functions, key)
+	}
+	return
+}
+
 //go:generate stringer -type=ElementType goexec.go
 
 type ElementType int
@@ -383,6 +671,7 @@ type Function struct {
 	Key            string
 	Name, Receiver string
 	Definition     string // Multi-line definition, includes comments preceding definition.
+	Doc            string // Doc comment of the function, without the comment markers, see `%doc`.
 
 }
 
@@ -394,6 +683,7 @@ type Variable struct {
 	CursorInName, CursorInType, CursorInValue bool
 	Key, Name                                 string
 	TypeDefinition, ValueDefinition           string // Type definition may be empty.
+	Doc                                       string // Doc comment, without the comment markers.
 }
 
 // TypeDecl definition, parsed from a notebook cell.
@@ -403,6 +693,7 @@ type TypeDecl struct {
 
 	Key            string // Same as the name here.
 	TypeDefinition string // Type definition which includes the name.
+	Doc            string // Doc comment, without the comment markers.
 	CursorInType   bool
 }
 
@@ -415,6 +706,7 @@ type Constant struct {
 
 	Key                                      string
 	TypeDefinition, ValueDefinition          string // Can be empty, if used as iota.
+	Doc                                      string // Doc comment, without the comment markers.
 	CursorInKey, CursorInType, CursorInValue bool
 	Next, Prev                               *Constant // Next and previous declaration in same Const block.
 }
@@ -5,7 +5,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path"
 	"testing"
+	"time"
 )
 
 func TestDirEnv(t *testing.T) {
@@ -21,3 +23,34 @@ func TestDirEnv(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, pwd, os.Getenv(protocol.GONB_DIR_ENV))
 }
+
+func TestFilesDirCleanup(t *testing.T) {
+	s := newEmptyState(t)
+	jupyterRoot := t.TempDir()
+	require.NoError(t, s.setFilesDir(jupyterRoot))
+	assert.Equal(t, s.FilesDir, os.Getenv(protocol.GONB_FILES_DIR_ENV))
+	assert.Equal(t, path.Join("/files", JupyterFilesSubdir, s.UniqueID, ServedFilesSubdir),
+		os.Getenv(protocol.GONB_FILES_URL_ENV))
+
+	// Served files are removed when the kernel stops.
+	filesDir := s.FilesDir
+	require.NoError(t, os.MkdirAll(filesDir, 0755))
+	require.NoError(t, os.WriteFile(path.Join(filesDir, "plot.svg"), []byte("<svg/>"), 0644))
+	require.NoError(t, s.Stop())
+	_, err := os.Stat(path.Join(jupyterRoot, JupyterFilesSubdir, s.UniqueID))
+	assert.True(t, os.IsNotExist(err), "Expected served files directory to be removed")
+}
+
+func TestStartupTimings(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	var phases []string
+	for _, timing := range s.StartupTimings {
+		phases = append(phases, timing.Phase)
+		assert.GreaterOrEqual(t, timing.Duration, time.Duration(0))
+	}
+	assert.Equal(t, []string{"temporary directory setup", "go.mod init", "gopls launch", "Jupyter root directory"}, phases)
+	assert.Empty(t, s.LastCellTimings)
+}
@@ -0,0 +1,61 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"k8s.io/klog/v2"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// This file implements the retries of `go get` when it fails due to transient network errors.
+
+const (
+	// DefaultGoGetAttempts is the default number of attempts to run `go get`, see State.GoGetAttempts.
+	DefaultGoGetAttempts = 3
+
+	// DefaultGoGetBackoff is the default wait before retrying `go get` the first time, see State.GoGetBackoff.
+	DefaultGoGetBackoff = time.Second
+)
+
+// regexpTransientNetworkError matches the errors reported by `go get` that are likely due to transient
+// network failures, and are worth retrying. Other errors (e.g.: nonexistent module or version) are not.
+var regexpTransientNetworkError = regexp.MustCompile(
+	`(?i)(dial tcp|i/o timeout|connection reset|connection refused|TLS handshake timeout|` +
+		`temporary failure in name resolution|unexpected EOF|` +
+		`\b(502 Bad Gateway|503 Service Unavailable|504 Gateway Timeout)\b)`)
+
+// isTransientNetworkError returns whether the output of a failed `go get` indicates a transient network error.
+func isTransientNetworkError(output []byte) bool {
+	return regexpTransientNetworkError.Match(output)
+}
+
+// runGoGetWithRetries runs the command created by newCmd (`go get`), displaying its progress (see
+// runWithProgress), and retries it if it fails with a transient network error.
+//
+// It makes up to State.GoGetAttempts attempts, waiting State.GoGetBackoff before the first retry, and
+// doubling the wait for each subsequent retry. Each retry is reported to the cell's stderr.
+//
+// It returns the output and error of the last attempt.
+func (s *State) runGoGetWithRetries(msg kernel.Message, newCmd func() *exec.Cmd) (output []byte, err error) {
+	backoff := s.GoGetBackoff
+	for attempt := 1; ; attempt++ {
+		cmd := newCmd()
+		klog.V(2).Infof("Executing %s", cmd)
+		output, err = runWithProgress(msg, cmd, "Fetching dependencies (go get)")
+		if err == nil || attempt >= s.GoGetAttempts || !isTransientNetworkError(output) {
+			return
+		}
+		if msg != nil && msg.Kernel().Interrupted.Load() {
+			return
+		}
+		klog.Warningf("%q failed (attempt %d of %d), retrying in %s: %s", cmd, attempt, s.GoGetAttempts, backoff, output)
+		if msg != nil {
+			_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+				"`go get` failed with a network error (attempt %d of %d), retrying in %s ...\n", attempt, s.GoGetAttempts, backoff))
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,41 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunGoGetWithRetries(t *testing.T) {
+	s := &State{GoGetAttempts: 3, GoGetBackoff: time.Millisecond}
+	var attempts int
+	newCmd := func(script string) func() *exec.Cmd {
+		return func() *exec.Cmd {
+			attempts++
+			return exec.Command("/bin/sh", "-c", script)
+		}
+	}
+
+	// Transient network errors are retried.
+	attempts = 0
+	output, err := s.runGoGetWithRetries(nil, newCmd(
+		"echo 'go: example.com/pkg: Get \"https://proxy.golang.org/...\": dial tcp: i/o timeout'; exit 1"))
+	require.Error(t, err)
+	assert.Contains(t, string(output), "i/o timeout")
+	assert.Equal(t, 3, attempts)
+
+	// Other errors are not.
+	attempts = 0
+	_, err = s.runGoGetWithRetries(nil, newCmd(
+		"echo 'go: module example.com/pkg: reading https://proxy.golang.org/...: 404 Not Found'; exit 1"))
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	// Success.
+	attempts = 0
+	_, err = s.runGoGetWithRetries(nil, newCmd("true"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -181,14 +181,12 @@ func (s *State) parseErrorLine(lineStr string, codeLines []string, fileToCellIdA
 	l.RawContext = strings.Join(partsRaw, "")
 
 	// Gather CellInfo
-	if lineNum > 0 && lineNum < len(fileToCellIdAndLine) && fileToCellIdAndLine[lineNum].Line != NoCursorLine {
-		cell := fileToCellIdAndLine[lineNum]
+	if cellLine, found := CellLine(fileToCellIdAndLine, lineNum+1); found {
 		l.HasCellInfo = true
-		// Notice GoNB store Lines starting at 0, but Jupyter display Lines starting at 1, so we add 1 here.
-		if cell.Id != -1 {
-			l.CellInfo = fmt.Sprintf("Cell[%d]: Line %d", cell.Id, cell.Line+1)
-		} else {
-			l.CellInfo = fmt.Sprintf("Cell Line %d", cell.Line+1)
+		l.CellInfo = cellLine.String()
+		// Errors in declarations memorized from previous cells also report the declaration.
+		if name, found := s.Definitions.DeclarationAt(cellLine); found {
+			l.CellInfo += ", in " + name
 		}
 	}
 	return
@@ -41,3 +41,31 @@ func TestRawError(t *testing.T) {
 	assert.True(t, errors.As(err, &gonbError))
 
 }
+
+func TestParseErrorLineCellInfo(t *testing.T) {
+	s := &State{}
+	codeLines := []string{"package main", "func f() {", "\tx := 1", "}"}
+	fileToCellIdAndLine := []CellIdAndLine{{NoCursorLine, NoCursorLine}, {3, 0}, {3, 1}, {3, 2}}
+	l := s.parseErrorLine("./main.go:3:2: declared and not used: x", codeLines, fileToCellIdAndLine)
+	assert.True(t, l.HasContext)
+	assert.True(t, l.HasCellInfo)
+	assert.Equal(t, "[cell 3] line 2", l.CellInfo)
+	assert.Equal(t, "declared and not used: x", l.Message)
+
+	// Line generated by GoNB.
+	l = s.parseErrorLine("./main.go:1:1: some error", codeLines, fileToCellIdAndLine)
+	assert.True(t, l.HasContext)
+	assert.False(t, l.HasCellInfo)
+}
+
+func TestParseErrorLineDeclaration(t *testing.T) {
+	s := &State{Definitions: NewDeclarations()}
+	s.Definitions.Functions["T~Inc"] = &Function{Key: "T~Inc", CellLines: CellLines{Id: 4, Lines: []int{1, 2, 3}}}
+	s.Definitions.Variables["x"] = &Variable{Key: "x", CellLines: CellLines{Id: 3, Lines: []int{0}}}
+	codeLines := []string{"package main", "var x = 1", "func (t *T) Inc() {", "\ty := 1", "}"}
+	fileToCellIdAndLine := []CellIdAndLine{{NoCursorLine, NoCursorLine}, {3, 0}, {4, 1}, {4, 2}, {4, 3}}
+	l := s.parseErrorLine("./main.go:4:2: declared and not used: y", codeLines, fileToCellIdAndLine)
+	assert.Equal(t, "[cell 4] line 3, in T.Inc", l.CellInfo)
+	l = s.parseErrorLine("./main.go:2:5: some error", codeLines, fileToCellIdAndLine)
+	assert.Equal(t, "[cell 3] line 1, in x", l.CellInfo)
+}
@@ -0,0 +1,88 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"k8s.io/klog/v2"
+	"os"
+	"os/exec"
+	"path"
+	"runtime/debug"
+)
+
+// This file implements the pinning of the version of `gonbui` used by the notebook to the version of the kernel,
+// since they communicate with a protocol that may change across versions.
+
+// GonbModule is the module of GoNB, which includes the `gonbui` package used by the notebooks.
+const GonbModule = "github.com/janpfeifer/gonb"
+
+// KernelVersion returns the version of the GoNB module the kernel was built from (e.g.: "v0.10.2"), or empty if it
+// is not known, e.g.: for development builds.
+func KernelVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Path != GonbModule {
+		return ""
+	}
+	version := info.Main.Version
+	if !semver.IsValid(version) || semver.Build(version) != "" {
+		// E.g.: "(devel)", or "+dirty" builds.
+		return ""
+	}
+	return version
+}
+
+// GonbuiVersion returns the version of the GoNB module (with `gonbui`) required by the notebook's `go.mod`, empty
+// if it is not required, and its replacement, if there is a `replace` rule for it (e.g.: a local checkout).
+func (s *State) GonbuiVersion() (required, replacement string, err error) {
+	goModPath := path.Join(s.TempDir, "go.mod")
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	for _, req := range modFile.Require {
+		if req.Mod.Path == GonbModule {
+			required = req.Mod.Version
+		}
+	}
+	for _, replace := range modFile.Replace {
+		if replace.Old.Path == GonbModule {
+			replacement = replace.New.Path
+			if replace.New.Version != "" {
+				replacement += " " + replace.New.Version
+			}
+		}
+	}
+	return
+}
+
+// pinGonbuiVersion makes the notebook use the version of `gonbui` matching the kernel (see KernelVersion), if the
+// notebook requires a different one -- usually the latest, added by `go get`. Nothing is done if the kernel
+// version is unknown, or if there is a `replace` rule for the GoNB module.
+func (s *State) pinGonbuiVersion(msg kernel.Message) error {
+	kernelVersion := KernelVersion()
+	if kernelVersion == "" {
+		return nil
+	}
+	required, replacement, err := s.GonbuiVersion()
+	if err != nil || required == "" || replacement != "" || required == kernelVersion {
+		return err
+	}
+	output, err := s.runGoGetWithRetries(msg, func() *exec.Cmd {
+		cmd := s.GoCommand("get", GonbModule+"@"+kernelVersion)
+		cmd.Dir = s.TempDir
+		return cmd
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to pin %s to the kernel version %s: %s", GonbModule, kernelVersion, output)
+	}
+	klog.Infof("Pinned %s from %s to the kernel version %s", GonbModule, required, kernelVersion)
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf(
+		"Using `gonbui` version %s (instead of %s), matching the kernel, see `%%gonbui_version`.\n", kernelVersion, required))
+}
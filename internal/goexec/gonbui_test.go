@@ -0,0 +1,30 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGonbuiVersion(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	// Test binaries are development builds.
+	assert.Equal(t, "", KernelVersion())
+	require.NoError(t, s.pinGonbuiVersion(nil))
+
+	required, replacement, err := s.GonbuiVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "", required)
+	assert.Equal(t, "", replacement)
+
+	_, err = s.runGoInTempDir("mod", "edit", "-require="+GonbModule+"@v0.10.0", "-replace="+GonbModule+"=/tmp/gonb")
+	require.NoError(t, err)
+	required, replacement, err = s.GonbuiVersion()
+	require.NoError(t, err)
+	assert.Equal(t, "v0.10.0", required)
+	assert.Equal(t, "/tmp/gonb", replacement)
+}
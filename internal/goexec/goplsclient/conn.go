@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/janpfeifer/gonb/common"
 	"k8s.io/klog/v2"
 	"net"
 	"strings"
@@ -89,9 +90,13 @@ func (c *Client) Connect(ctx context.Context) error {
 	}(c.conn)
 
 	callId, err := c.jsonConn.Call(ctx, lsp.MethodInitialize, &lsp.InitializeParams{
-		ProcessID: 0,
-		RootURI:   uri.File(c.dir),
-		// Capabilities:          lsp.ClientCapabilities{},
+		ProcessID:             0,
+		RootURI:               uri.File(c.dir),
+		InitializationOptions: c.CompletionSettings().goplsSettings(),
+		Capabilities: lsp.ClientCapabilities{
+			// Needed for `gopls` to fetch the settings changed by SetCompletionSettings.
+			Workspace: &lsp.WorkspaceClientCapabilities{Configuration: true},
+		},
 	}, &c.lspCapabilities)
 	_ = callId // Not used now.
 	if err != nil {
@@ -212,6 +217,60 @@ func (c *Client) notifyDidOpenOrChangeLocked(ctx context.Context, filePath strin
 	return
 }
 
+// NumOpenedUnder returns the number of files under the given file or directory that were opened (sent)
+// to `gopls`.
+func (c *Client) NumOpenedUnder(fileOrDirPath string) (numOpened int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filePath := range c.fileVersions {
+		if filePath == fileOrDirPath || strings.HasPrefix(filePath, strings.TrimSuffix(fileOrDirPath, "/")+"/") {
+			numOpened++
+		}
+	}
+	return
+}
+
+// NotifyDidCloseUnder notifies `gopls` that the files opened (sent to it) under any of the given files or
+// directories were closed, so it reads their contents from disk again. It returns the number of files closed.
+func (c *Client) NotifyDidCloseUnder(ctx context.Context, fileOrDirPaths []string) (numClosed int, err error) {
+	if !c.WaitConnection(ctx) {
+		// Silently do nothing, if no connection available.
+		return
+	}
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	for _, filePath := range common.SortedKeys(c.fileVersions) {
+		under := false
+		for _, p := range fileOrDirPaths {
+			if filePath == p || strings.HasPrefix(filePath, strings.TrimSuffix(p, "/")+"/") {
+				under = true
+				break
+			}
+		}
+		if !under {
+			continue
+		}
+		delete(c.fileVersions, filePath)
+		delete(c.fileCache, filePath)
+		params := &lsp.DidCloseTextDocumentParams{
+			TextDocument: lsp.TextDocumentIdentifier{
+				URI: uri.File(filePath),
+			},
+		}
+		err = c.jsonConn.Notify(ctx, lsp.MethodTextDocumentDidClose, params)
+		if err != nil {
+			err = errors.Wrapf(err, "Failed Client.MethodTextDocumentDidClose notification for %q", filePath)
+			return
+		}
+		numClosed++
+	}
+	return
+}
+
 // CallDefinition service in `gopls`. This returns just the range of where a symbol, under
 // the cursor, is defined. See `Definition()` for the full definition service.
 //
@@ -370,7 +429,6 @@ func trimString(s string, maxLen int) string {
 
 // Handler implements jsonrpc2.Handler, and receives messages initiated by gopls.
 func (c *Client) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
-	_ = ctx
 	switch req.Method() {
 	case lsp.MethodWindowShowMessage:
 		var params lsp.ShowMessageParams
@@ -406,6 +464,9 @@ func (c *Client) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 			klog.V(2).Infof("received gopls diagnostics: %+v",
 				trimString(fmt.Sprintf("%+v", params), 100))
 		}
+	case lsp.MethodWorkspaceConfiguration:
+		return c.handleConfiguration(ctx, reply, req)
+
 	default:
 		klog.Errorf("gopls jsonrpc2 message delivered to GoNB but not handled: %q", req.Method())
 	}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
@@ -34,9 +33,13 @@ func (c *Client) Start() error {
 
 	c.stop = make(chan struct{})
 	c.removeUnixSocketFile()
-	goplsPath, err := exec.LookPath("gopls")
-	if err != nil {
-		return errors.Wrapf(err, "cannot file `gopls` binary in path")
+	goplsPath := c.binPath
+	if goplsPath == "" {
+		var err error
+		goplsPath, err = exec.LookPath("gopls")
+		if err != nil {
+			return errors.Wrapf(err, "cannot file `gopls` binary in path")
+		}
 	}
 
 	addr := c.Address()
@@ -49,10 +52,10 @@ func (c *Client) Start() error {
 	// the kernel receives from Jupyter and dying.
 	// Not sure on the status of MacOS:
 	// https://stackoverflow.com/questions/43364958/start-command-with-new-process-group-id-golang
-	c.goplsExec.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: 0}
+	setProcessGroup(c.goplsExec)
 	c.goplsExec.Dir = c.dir
 	klog.Infof("Executing %q", c.goplsExec)
-	err = c.goplsExec.Start()
+	err := c.goplsExec.Start()
 	if err != nil {
 		err = errors.Wrapf(err, "failed to start %s", c.goplsExec)
 		close(c.stop)
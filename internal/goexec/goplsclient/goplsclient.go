@@ -37,6 +37,7 @@ import (
 type Client struct {
 	dir     string // directory with contents.
 	address string // where to connect to `gopls`.
+	binPath string // `gopls` binary to start, if empty it is searched in the PATH.
 
 	// Guard server state.
 	mu sync.Mutex
@@ -57,6 +58,10 @@ type Client struct {
 
 	// Messages: they should be reset whenever they have been consumed.
 	messages []string
+
+	// Settings sent to `gopls`, guarded by their own mutex.
+	settingsMu sync.Mutex
+	completion CompletionSettings
 }
 
 // New returns a new Client in the directory. The returned Client does not yet start
@@ -72,6 +77,7 @@ func New(dir string) *Client {
 		address:      path.Join(dir, "gopls_socket"),
 		fileVersions: make(map[string]int),
 		fileCache:    make(map[string]*FileData),
+		completion:   DefaultCompletionSettings,
 
 		stop: nil, // gopls starts stopped.
 	}
@@ -90,6 +96,14 @@ func (c *Client) SetAddress(address string) {
 	c.address = address
 }
 
+// SetBinaryPath sets the path to the `gopls` binary to be started, e.g.: when it is installed outside the PATH.
+// If empty, `gopls` is searched in the PATH.
+//
+// This has no effect if `gopls` is already started.
+func (c *Client) SetBinaryPath(binPath string) {
+	c.binPath = binPath
+}
+
 // Shutdown closes connection and stops `gopls` (if connectingLatch/started).
 func (c *Client) Shutdown() {
 	c.mu.Lock()
@@ -187,6 +201,9 @@ func (c *Client) Complete(ctx context.Context, filePath string, line, col int) (
 	if len(items.Items) != len(matches) {
 		klog.Infof("Complete found %d items, used only %d", len(items.Items), len(matches))
 	}
+	if maxResults := c.CompletionSettings().MaxResults; maxResults > 0 && len(matches) > maxResults {
+		matches = matches[:maxResults]
+	}
 	return
 }
 
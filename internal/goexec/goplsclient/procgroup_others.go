@@ -0,0 +1,13 @@
+//go:build !windows
+
+package goplsclient
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures cmd to start in its own process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: 0}
+}
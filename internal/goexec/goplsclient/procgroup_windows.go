@@ -0,0 +1,8 @@
+//go:build windows
+
+package goplsclient
+
+import "os/exec"
+
+// setProcessGroup is a no-op in Windows, where process groups (as in POSIX) are not supported.
+func setProcessGroup(cmd *exec.Cmd) {}
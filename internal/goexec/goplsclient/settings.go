@@ -0,0 +1,84 @@
+package goplsclient
+
+import (
+	"context"
+	"encoding/json"
+	lsp "github.com/go-language-server/protocol"
+	"github.com/pkg/errors"
+	"go.lsp.dev/jsonrpc2"
+)
+
+// CompletionSettings control the auto-complete suggestions of `gopls`.
+type CompletionSettings struct {
+	// Deep enables deep completion: candidates are also searched in the fields and methods of the
+	// values in scope (e.g.: `x.Field.Method`). Disabling it makes the completion faster and shallower.
+	Deep bool
+
+	// Unimported enables the completion of packages not imported yet, and their members.
+	Unimported bool
+
+	// MaxResults limits the number of suggestions returned. If 0, all of them are returned.
+	MaxResults int
+}
+
+// DefaultCompletionSettings are the same as the defaults of `gopls`, with no limit on the number of results.
+var DefaultCompletionSettings = CompletionSettings{
+	Deep:       true,
+	Unimported: true,
+}
+
+// goplsSettings returns the `gopls` settings (as in its documentation) corresponding to the completion settings.
+func (s CompletionSettings) goplsSettings() map[string]any {
+	return map[string]any{
+		"deepCompletion":     s.Deep,
+		"completeUnimported": s.Unimported,
+	}
+}
+
+// CompletionSettings returns the current completion settings.
+func (c *Client) CompletionSettings() CompletionSettings {
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+	return c.completion
+}
+
+// SetCompletionSettings changes the completion settings. If connected, `gopls` is notified of the change,
+// otherwise they are sent when connecting.
+func (c *Client) SetCompletionSettings(ctx context.Context, settings CompletionSettings) error {
+	c.settingsMu.Lock()
+	c.completion = settings
+	c.settingsMu.Unlock()
+
+	ctx = minTimeout(ctx, CommunicationTimeout)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	// `gopls` fetches the new settings with a "workspace/configuration" request, see handleConfiguration.
+	err := c.jsonConn.Notify(ctx, lsp.MethodWorkspaceDidChangeConfiguration, &lsp.DidChangeConfigurationParams{
+		Settings: settings.goplsSettings(),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to notify `gopls` of the new completion settings")
+	}
+	return nil
+}
+
+// handleConfiguration replies to a "workspace/configuration" request from `gopls`, with the current settings
+// for each of the items requested.
+//
+// It must not acquire Client.mu, since it is called while other calls to `gopls` wait for their responses.
+func (c *Client) handleConfiguration(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+	var params lsp.ConfigurationParams
+	err := json.Unmarshal(req.Params(), &params)
+	if err != nil {
+		return reply(ctx, nil, errors.Wrapf(err, "failed to parse ConfigurationParams"))
+	}
+	settings := c.CompletionSettings().goplsSettings()
+	results := make([]any, len(params.Items))
+	for ii := range results {
+		results[ii] = settings
+	}
+	return reply(ctx, results, nil)
+}
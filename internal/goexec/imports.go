@@ -0,0 +1,54 @@
+package goexec
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+)
+
+// This file implements the options of the rendering of the import block of the generated program, see `%imports`.
+
+// localModules returns the modules whose packages are rendered as local imports when State.GroupImports is set:
+// the notebook module itself and the modules replaced by local directories in `go.mod` (e.g.: with
+// `%replace_local` or `%goworkfix`).
+func (s *State) localModules() (modules []string) {
+	goModPath := path.Join(s.TempDir, "go.mod")
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		klog.V(1).Infof("Failed to read %q, no local modules for the grouping of imports: %+v", goModPath, err)
+		return
+	}
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		klog.V(1).Infof("Failed to parse %q, no local modules for the grouping of imports: %+v", goModPath, err)
+		return
+	}
+	if modFile.Module != nil {
+		modules = append(modules, modFile.Module.Mod.Path)
+	}
+	for _, replace := range modFile.Replace {
+		if replace.New.Version == "" {
+			modules = append(modules, replace.Old.Path)
+		}
+	}
+	return
+}
+
+// ImportsSource returns the import block of the memorized declarations, as it is rendered in the program of
+// the next cell, or an empty string if there are no memorized imports.
+func (s *State) ImportsSource() (string, error) {
+	var buf bytes.Buffer
+	w := NewWriterWithCursor(&buf)
+	if s.GroupImports {
+		s.Definitions.RenderGroupedImports(w, nil, s.localModules())
+	} else {
+		s.Definitions.RenderImports(w, nil)
+	}
+	if err := w.Error(); err != nil {
+		return "", errors.WithMessagef(err, "failed to render imports")
+	}
+	return buf.String(), nil
+}
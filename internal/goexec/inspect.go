@@ -4,8 +4,10 @@ import (
 	"context"
 	"github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/goexec/goplsclient"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/pkg/errors"
+	"go/token"
 	"k8s.io/klog/v2"
 	"os"
 	"path"
@@ -123,6 +125,47 @@ func (s *State) InspectIdentifierInCell(lines []string, skipLines map[int]struct
 	return
 }
 
+// Hover returns the `gopls` hover information (the same as the contextual help) of symbol, e.g.: "fmt.Println",
+// "strings.Builder" or "MyType.MyMethod", formatted as markdown.
+//
+// It returns an error if `gopls` is not available, or if it has no information about the symbol.
+func (s *State) Hover(symbol string) (string, error) {
+	if s.gopls == nil {
+		return "", errors.Errorf("`gopls` is not available: install it with `%%install_tool gopls`")
+	}
+	if !isQualifiedIdentifier(symbol) {
+		return "", errors.Errorf("invalid symbol %q, it must be an identifier optionally qualified by a package "+
+			"or type, e.g.: `fmt.Println` or `MyType.MyMethod`", symbol)
+	}
+
+	// The symbol is inspected in a cell that only refers to it, with the cursor on its last identifier.
+	const prefix = "var _ = "
+	lines := []string{prefix + symbol}
+	mimeMap, err := s.InspectIdentifierInCell(lines, common.MakeSet[int](), 0, len(lines[0])-1)
+	if err != nil {
+		return "", err
+	}
+	if text, found := mimeMap[string(protocol.MIMETextPlain)]; found {
+		return "", errors.Errorf("`gopls` failed for %q: %v", symbol, text)
+	}
+	markdown, _ := mimeMap[string(protocol.MIMETextMarkdown)].(string)
+	if strings.TrimSpace(markdown) == "" {
+		return "", errors.Errorf("no information found for %q by `gopls`", symbol)
+	}
+	return markdown, nil
+}
+
+// isQualifiedIdentifier returns whether symbol is a Go identifier, optionally qualified by a package and/or
+// a type, e.g.: "x", "fmt.Println" or "strings.Builder.WriteString".
+func isQualifiedIdentifier(symbol string) bool {
+	for _, part := range strings.Split(symbol, ".") {
+		if !token.IsIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
+
 // AutoCompleteOptionsInCell implements a `complete_request` from Jupyter, using `gopls`.
 // It updates `main.go` with the cell contents (given as Lines)
 func (s *State) AutoCompleteOptionsInCell(cellLines []string, skipLines map[int]struct{},
@@ -203,6 +246,51 @@ func (s *State) AutoCompleteOptionsInCell(cellLines []string, skipLines map[int]
 	return
 }
 
+// HasGopls returns whether `gopls` is available, used for contextual help and auto-complete.
+func (s *State) HasGopls() bool {
+	return s.gopls != nil
+}
+
+// StartGopls starts `gopls`, if it is not started yet, with the binary found by ToolPath: either installed
+// with `%install_tool gopls` or in the PATH. It returns false if `gopls` is not available, or if it failed to start.
+func (s *State) StartGopls() bool {
+	if s.gopls != nil {
+		return true
+	}
+	goplsPath, found := s.ToolPath("gopls")
+	if !found {
+		return false
+	}
+	s.gopls = goplsclient.New(s.TempDir)
+	s.gopls.SetBinaryPath(goplsPath)
+	_ = s.gopls.SetCompletionSettings(context.Background(), s.completion)
+	if err := s.gopls.Start(); err != nil {
+		klog.Errorf("Failed to start `gopls`: %v", err)
+		s.gopls = nil
+		return false
+	}
+	klog.V(1).Infof("Started `gopls` (%s).", goplsPath)
+	return true
+}
+
+// CompletionSettings returns the settings of the auto-complete, see SetCompletionSettings.
+func (s *State) CompletionSettings() goplsclient.CompletionSettings {
+	return s.completion
+}
+
+// SetCompletionSettings changes the settings of the auto-complete, used by `gopls`. They are kept also if `gopls`
+// is not available, so they can be reported.
+func (s *State) SetCompletionSettings(settings goplsclient.CompletionSettings) error {
+	if settings.MaxResults < 0 {
+		return errors.Errorf("invalid maximum number of completion results %d, it must be >= 0", settings.MaxResults)
+	}
+	s.completion = settings
+	if s.gopls == nil {
+		return nil
+	}
+	return s.gopls.SetCompletionSettings(context.Background(), settings)
+}
+
 // runeIndicesForLine returns the start of each rune in the line (encoded as UTF-8).
 func runeIndicesForLine(line string, col int) (runeIndices []int, colIdx int) {
 	runeIndices = make([]int, 0, len(line))
@@ -0,0 +1,57 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestIsQualifiedIdentifier(t *testing.T) {
+	for _, symbol := range []string{"x", "fmt.Println", "strings.Builder.WriteString", "MyType.MyMethod"} {
+		assert.True(t, isQualifiedIdentifier(symbol), symbol)
+	}
+	for _, symbol := range []string{"", "fmt.", ".Println", "f()", "a b", "x[0]", "func"} {
+		assert.False(t, isQualifiedIdentifier(symbol), symbol)
+	}
+}
+
+func TestHoverWithoutGopls(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	if s.gopls != nil {
+		s.gopls.Shutdown()
+		s.gopls = nil
+	}
+	_, err := s.Hover("fmt.Println")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "install_tool gopls")
+}
+
+func TestStartGoplsInstalled(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	if s.gopls != nil {
+		s.gopls.Shutdown()
+		s.gopls = nil
+	}
+
+	// The path of a tool installed with InstallTool is used, even if it is not in the PATH.
+	t.Setenv("PATH", "")
+	assert.False(t, s.StartGopls())
+	assert.False(t, s.HasGopls())
+	s.toolPaths["gopls"] = "/nonexistent/bin/gopls"
+	assert.False(t, s.StartGopls(), "gopls binary doesn't exist, it should fail to start")
+	assert.False(t, s.HasGopls())
+
+	goplsPath := path.Join(t.TempDir(), "gopls")
+	require.NoError(t, os.WriteFile(goplsPath, []byte("#!/bin/sh\nexec sleep 60\n"), 0755))
+	s.toolPaths["gopls"] = goplsPath
+	assert.True(t, s.StartGopls())
+	assert.True(t, s.HasGopls())
+}
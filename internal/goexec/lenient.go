@@ -0,0 +1,241 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements `%lenient`, which downgrades to warnings the compile errors about unused variables
+// and imports, common when exploring in a notebook: the generated program is fixed, and compiled again.
+// State.SilenceUnusedVariables does the same for the unused variables only.
+
+// MaxLenientAttempts is the maximum number of times the program is fixed and compiled again in lenient
+// mode, since the compiler stops reporting errors after a few of them.
+const MaxLenientAttempts = 5
+
+var (
+	// regexpUnusedVariable matches the message of the compiler about an unused variable, e.g.:
+	// "declared and not used: x", or "x declared and not used" (for type switches and older Go versions).
+	regexpUnusedVariable = regexp.MustCompile(`^(?:declared and not used: (\w+)|(\w+) declared and not used)$`)
+
+	// regexpUnusedImport matches the message of the compiler about an unused import, e.g.:
+	// `"os" imported and not used` or `"os" imported as o and not used`.
+	regexpUnusedImport = regexp.MustCompile(`^"([^"]+)" imported (?:as \S+ )?and not used$`)
+)
+
+// unusedDiagnostic is a compile error about an unused variable or import, in the generated program.
+type unusedDiagnostic struct {
+	line, column int
+
+	// name of the variable, or path of the import.
+	name     string
+	isImport bool
+}
+
+// sourceEdit replaces the bytes [start, end) of the source by text.
+type sourceEdit struct {
+	start, end int
+	text       string
+}
+
+// parseUnusedDiagnostics parses the output of a failed compilation, and returns the errors about unused
+// variables and imports in the file fileName.
+//
+// It returns ok=false if there are any other errors, in which case nothing should be silenced.
+func parseUnusedDiagnostics(output, fileName string) (diagnostics []unusedDiagnostic, ok bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || line == "too many errors" {
+			continue
+		}
+		matches := regexpCompilerDiagnostic.FindStringSubmatch(line)
+		if matches == nil || matches[1] != fileName {
+			return nil, false
+		}
+		d := unusedDiagnostic{}
+		d.line, _ = strconv.Atoi(matches[2])
+		d.column, _ = strconv.Atoi(matches[3])
+		if m := regexpUnusedVariable.FindStringSubmatch(matches[4]); m != nil {
+			d.name = m[1] + m[2]
+		} else if m := regexpUnusedImport.FindStringSubmatch(matches[4]); m != nil {
+			d.name, d.isImport = m[1], true
+		} else {
+			return nil, false
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics, len(diagnostics) > 0
+}
+
+// silenceUnused fixes the Go source src so the unused variables and imports in diagnostics are no longer
+// errors: a blank assignment (`_ = x`) is inserted after the declaration of the variables, and the unused
+// imports are made blank imports (`import _ "os"`).
+//
+// The edits never add new lines, so the mapping of the lines of the program to the cell lines is preserved.
+// It returns an error if any of the diagnostics can't be fixed.
+func silenceUnused(src string, diagnostics []unusedDiagnostic) (string, error) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse generated program")
+	}
+	tokFile := fileSet.File(file.Pos())
+	offset := func(pos token.Pos) int { return tokFile.Offset(pos) }
+
+	var edits []sourceEdit
+	for _, d := range diagnostics {
+		if d.line < 1 || d.line > tokFile.LineCount() {
+			return "", errors.Errorf("line %d of unused %q out of range", d.line, d.name)
+		}
+		var found bool
+		if d.isImport {
+			for _, spec := range file.Imports {
+				if tokFile.Line(spec.Pos()) != d.line {
+					continue
+				}
+				found = true
+				if spec.Name != nil {
+					edits = append(edits, sourceEdit{offset(spec.Name.Pos()), offset(spec.Name.End()), "_"})
+				} else {
+					edits = append(edits, sourceEdit{offset(spec.Path.Pos()), offset(spec.Path.Pos()), "_ "})
+				}
+				break
+			}
+		} else {
+			pos := tokFile.LineStart(d.line) + token.Pos(d.column-1)
+			var varEdits []sourceEdit
+			varEdits, found = blankAssignmentEdits(file, pos, d.name, offset)
+			edits = append(edits, varEdits...)
+		}
+		if !found {
+			return "", errors.Errorf("can't silence unused %q in line %d", d.name, d.line)
+		}
+	}
+
+	// Apply edits from the end, so the offsets of the previous ones are still valid.
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, edit := range edits {
+		src = src[:edit.start] + edit.text + src[edit.end:]
+	}
+	return src, nil
+}
+
+// blankAssignmentEdits returns the edits to insert a blank assignment (`_ = name`) using the variable
+// declared at pos, in the scope of its declaration.
+func blankAssignmentEdits(file *ast.File, pos token.Pos, name string, offset func(token.Pos) int) (edits []sourceEdit, found bool) {
+	// Find the path of nodes from the file to the identifier being declared.
+	var stack, path []ast.Node
+	ast.Inspect(file, func(node ast.Node) bool {
+		if path != nil {
+			return false
+		}
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if pos < node.Pos() || pos >= node.End() {
+			return false
+		}
+		stack = append(stack, node)
+		if ident, ok := node.(*ast.Ident); ok && ident.Pos() == pos && ident.Name == name {
+			path = append([]ast.Node{}, stack...)
+		}
+		return true
+	})
+	if path == nil {
+		return nil, false
+	}
+
+	assignment := "_ = " + name + "; "
+	insertAt := func(p token.Pos) sourceEdit { return sourceEdit{offset(p), offset(p), assignment} }
+	for ii := len(path) - 2; ii >= 0; ii-- {
+		child := path[ii+1]
+		switch node := path[ii].(type) {
+		case *ast.BlockStmt:
+			// Statement in a block: the assignment follows it, in the same line.
+			return []sourceEdit{{offset(child.End()), offset(child.End()), "; _ = " + name}}, true
+		case *ast.CaseClause:
+			if child.Pos() > node.Colon {
+				return []sourceEdit{{offset(child.End()), offset(child.End()), "; _ = " + name}}, true
+			}
+		case *ast.CommClause:
+			if child.Pos() > node.Colon {
+				return []sourceEdit{{offset(child.End()), offset(child.End()), "; _ = " + name}}, true
+			}
+			// Variable received in the case (`case v := <-ch:`).
+			return []sourceEdit{insertAt(node.Colon + 1)}, true
+		case *ast.ForStmt:
+			return []sourceEdit{insertAt(node.Body.Lbrace + 1)}, true
+		case *ast.RangeStmt:
+			return []sourceEdit{insertAt(node.Body.Lbrace + 1)}, true
+		case *ast.IfStmt:
+			return []sourceEdit{insertAt(node.Body.Lbrace + 1)}, true
+		case *ast.TypeSwitchStmt:
+			// The variable is declared in each of the clauses.
+			for _, stmt := range node.Body.List {
+				edits = append(edits, insertAt(stmt.(*ast.CaseClause).Colon+1))
+			}
+			return edits, len(edits) > 0
+		case *ast.FuncLit, *ast.FuncDecl, *ast.SwitchStmt, *ast.SelectStmt:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// silenceUnusedInCode is called when the compilation fails in lenient mode (or with SilenceUnusedVariables):
+// if the only errors are about unused variables (or imports, in lenient mode) in the generated program, it
+// fixes them (see silenceUnused), and publishes them as warnings.
+//
+// It returns whether the program was fixed, in which case it should be compiled again.
+func (s *State) silenceUnusedInCode(msg kernel.Message, fileToCellIdAndLines []CellIdAndLine, output string) bool {
+	if !s.Lenient && !s.SilenceUnusedVariables {
+		return false
+	}
+	diagnostics, ok := parseUnusedDiagnostics(output, path.Base(s.CodePath()))
+	if !ok {
+		return false
+	}
+	if !s.Lenient {
+		for _, d := range diagnostics {
+			if d.isImport {
+				return false
+			}
+		}
+	}
+	src, err := s.readMainGo()
+	if err != nil {
+		return false
+	}
+	src, err = silenceUnused(src, diagnostics)
+	if err != nil {
+		return false
+	}
+	if err = os.WriteFile(s.CodePath(), []byte(src), 0644); err != nil {
+		return false
+	}
+	var sb strings.Builder
+	for _, d := range diagnostics {
+		location := fmt.Sprintf("%s:%d", path.Base(s.CodePath()), d.line)
+		if cellLine, found := CellLine(fileToCellIdAndLines, d.line); found {
+			location = cellLine.String()
+		}
+		if d.isImport {
+			sb.WriteString(fmt.Sprintf("warning: %s: %q imported and not used\n", location, d.name))
+		} else {
+			sb.WriteString(fmt.Sprintf("warning: %s: declared and not used: %s\n", location, d.name))
+		}
+	}
+	_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, sb.String())
+	return true
+}
@@ -0,0 +1,152 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+const unusedSource = `package main
+
+import (
+	"fmt"
+	o "os"
+	"strings"
+)
+
+func main() {
+	x := 1 // Comment.
+	for i, v := range []int{1} {
+	}
+	var ch chan int
+	select {
+	case y := <-ch:
+	}
+	var a any
+	switch t := a.(type) {
+	case int:
+	default:
+	}
+	fmt.Println(strings.ToUpper("done"))
+}
+`
+
+const unusedOutput = `# gonb_12345678
+./main.go:5:2: "os" imported as o and not used
+./main.go:10:2: declared and not used: x
+./main.go:11:6: declared and not used: i
+./main.go:11:9: declared and not used: v
+./main.go:15:7: declared and not used: y
+./main.go:18:9: t declared and not used
+`
+
+func TestParseUnusedDiagnostics(t *testing.T) {
+	diagnostics, ok := parseUnusedDiagnostics(unusedOutput, "main.go")
+	require.True(t, ok)
+	require.Len(t, diagnostics, 6)
+	assert.Equal(t, unusedDiagnostic{line: 5, column: 2, name: "os", isImport: true}, diagnostics[0])
+	assert.Equal(t, unusedDiagnostic{line: 10, column: 2, name: "x"}, diagnostics[1])
+	assert.Equal(t, unusedDiagnostic{line: 18, column: 9, name: "t"}, diagnostics[5])
+
+	// Any other error prevents silencing.
+	_, ok = parseUnusedDiagnostics(unusedOutput+"./main.go:20:2: undefined: y\n", "main.go")
+	assert.False(t, ok)
+	_, ok = parseUnusedDiagnostics("./other.go:3:2: declared and not used: x\n", "main.go")
+	assert.False(t, ok)
+	_, ok = parseUnusedDiagnostics("", "main.go")
+	assert.False(t, ok)
+}
+
+func TestSilenceUnused(t *testing.T) {
+	diagnostics, ok := parseUnusedDiagnostics(unusedOutput, "main.go")
+	require.True(t, ok)
+	fixed, err := silenceUnused(unusedSource, diagnostics)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Count(unusedSource, "\n"), strings.Count(fixed, "\n"),
+		"fixes should not change the line mapping")
+	lines := strings.Split(fixed, "\n")
+	assert.Equal(t, "\t_ \"os\"", lines[4])
+	assert.Equal(t, "\t\"strings\"", lines[5])
+	assert.Equal(t, "\tx := 1; _ = x // Comment.", lines[9])
+	assert.Equal(t, "\tfor i, v := range []int{1} {_ = v; _ = i; ", lines[10])
+	assert.Equal(t, "\tcase y := <-ch:_ = y; ", lines[14])
+	assert.Equal(t, "\tcase int:_ = t; ", lines[18])
+	assert.Equal(t, "\tdefault:_ = t; ", lines[19])
+
+	// Variables declared in the init of a switch are not handled.
+	src := "package main\n\nfunc main() {\n\tswitch x := 1; {\n\t}\n}\n"
+	_, err = silenceUnused(src, []unusedDiagnostic{{line: 4, column: 9, name: "x"}})
+	require.Error(t, err)
+}
+
+func TestSilenceUnusedInCode(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	require.False(t, s.SilenceUnusedVariables, "unused variables should not be silenced by default")
+	s.SilenceUnusedVariables = true
+	src := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tx := 1\n\tfmt.Println(\"ok\")\n}\n"
+	output := "# gonb_12345678\n./main.go:6:2: declared and not used: x\n"
+	fileToCellIdAndLines := make([]CellIdAndLine, 8)
+	for ii := range fileToCellIdAndLines {
+		fileToCellIdAndLines[ii] = CellIdAndLine{Id: NoCursorLine, Line: NoCursorLine}
+	}
+	writeCode := func(src string) {
+		require.NoError(t, os.WriteFile(s.CodePath(), []byte(src), 0644))
+	}
+
+	// Unused variable: fixed, the rest of the program unaffected.
+	writeCode(src)
+	require.True(t, s.silenceUnusedInCode(nil, fileToCellIdAndLines, output))
+	fixed, err := s.readMainGo()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(src, "x := 1", "x := 1; _ = x", 1), fixed)
+
+	// Disabled.
+	writeCode(src)
+	s.SilenceUnusedVariables = false
+	assert.False(t, s.silenceUnusedInCode(nil, fileToCellIdAndLines, output))
+	s.SilenceUnusedVariables = true
+
+	// Other errors: the program is left untouched, so the errors are reported.
+	otherOutput := output + "./main.go:7:2: undefined: y\n"
+	assert.False(t, s.silenceUnusedInCode(nil, fileToCellIdAndLines, otherOutput))
+	fixed, err = s.readMainGo()
+	require.NoError(t, err)
+	assert.Equal(t, src, fixed)
+
+	// Unused imports are only silenced in lenient mode.
+	importSrc := "package main\n\nimport \"os\"\n\nfunc main() {\n}\n"
+	importOutput := "./main.go:3:8: \"os\" imported and not used\n"
+	writeCode(importSrc)
+	assert.False(t, s.silenceUnusedInCode(nil, fileToCellIdAndLines, importOutput))
+	s.Lenient = true
+	assert.True(t, s.silenceUnusedInCode(nil, fileToCellIdAndLines, importOutput))
+	fixed, err = s.readMainGo()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(importSrc, "\"os\"", "_ \"os\"", 1), fixed)
+}
+
+func TestCompileWithGcFlagsSilenceUnused(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	src := "package main\n\nfunc main() {\n\tx := 1\n}\n"
+	require.NoError(t, os.WriteFile(s.CodePath(), []byte(src), 0644))
+	fileToCellIdAndLines := make([]CellIdAndLine, 6)
+	for ii := range fileToCellIdAndLines {
+		fileToCellIdAndLines[ii] = CellIdAndLine{Id: NoCursorLine, Line: NoCursorLine}
+	}
+
+	// `%asm` and `%escape` fail, like the normal execution, if unused variables are not silenced.
+	_, err := s.compileWithGcFlags(nil, fileToCellIdAndLines, "-m")
+	require.Error(t, err)
+
+	s.SilenceUnusedVariables = true
+	_, err = s.compileWithGcFlags(nil, fileToCellIdAndLines, "-m")
+	require.NoError(t, err)
+}
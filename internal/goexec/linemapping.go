@@ -0,0 +1,118 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+// This file holds the mapping of the lines of the generated Go file (`main.go` or `main_test.go`) back
+// to the lines of the cells, used to report compiler errors, vet/test output and panics with references
+// the user can relate to.
+
+// regexpGoFileReference matches references to a line of the generated Go files, as reported by the Go
+// tools, e.g.: "/tmp/gonb_1234/main.go:12" or "./main_test.go:7".
+var regexpGoFileReference = regexp.MustCompile(`([^\s:"'(]*)\b(main(?:_test)?\.go):(\d+)`)
+
+// String returns a description of the cell line, e.g.: "[cell 3] line 2".
+// If the cell id is not known, it is omitted: "[cell] line 2".
+func (c CellIdAndLine) String() string {
+	// Since line reports usually start with 1, we report Line+1
+	if c.Id == NoCursorLine {
+		return fmt.Sprintf("[cell] line %d", c.Line+1)
+	}
+	return fmt.Sprintf("[cell %d] line %d", c.Id, c.Line+1)
+}
+
+// CellLine returns the cell line corresponding to the line fileLineNum of the generated Go file.
+//
+// fileLineNum starts from 1, as reported by the Go tools. It returns false if the line doesn't map
+// to a cell line -- e.g. if it was generated by GoNB.
+func CellLine(fileToCellIdAndLine []CellIdAndLine, fileLineNum int) (CellIdAndLine, bool) {
+	idx := fileLineNum - 1 // Since line reporting starts with 1, but our indices start with 0.
+	if idx < 0 || idx >= len(fileToCellIdAndLine) || fileToCellIdAndLine[idx].Line == NoCursorLine {
+		return CellIdAndLine{}, false
+	}
+	return fileToCellIdAndLine[idx], true
+}
+
+// MapFileReferences rewrites the references to lines of the generated Go file filePath in text
+// (e.g.: "/tmp/gonb_1234/main.go:12" or "./main.go:12") using replaceFn, which is given the
+// matched reference and its corresponding cell line.
+//
+// References to lines that don't map to a cell line, or to files with the same name in other
+// directories, are left untouched.
+func MapFileReferences(text, filePath string, fileToCellIdAndLine []CellIdAndLine,
+	replaceFn func(reference string, cellLine CellIdAndLine) string) string {
+	dir, name := path.Split(filePath)
+	return regexpGoFileReference.ReplaceAllStringFunc(text, func(reference string) string {
+		matches := regexpGoFileReference.FindStringSubmatch(reference)
+		if matches[2] != name || (matches[1] != "" && matches[1] != "./" && matches[1] != dir) {
+			return reference
+		}
+		lineNum, err := strconv.Atoi(matches[3])
+		if err != nil {
+			return reference
+		}
+		cellLine, found := CellLine(fileToCellIdAndLine, lineNum)
+		if !found {
+			return reference
+		}
+		return replaceFn(reference, cellLine)
+	})
+}
+
+// AnnotateFileReferences prefixes the references to lines of the generated Go file filePath in text
+// with their corresponding cell lines, e.g.: "[cell 3] line 2 ./main.go:12".
+//
+// See MapFileReferences.
+func AnnotateFileReferences(text, filePath string, fileToCellIdAndLine []CellIdAndLine) string {
+	return MapFileReferences(text, filePath, fileToCellIdAndLine, func(reference string, cellLine CellIdAndLine) string {
+		return fmt.Sprintf("%s %s", cellLine, reference)
+	})
+}
+
+// FileToCellIdAndLine returns the mapping of the lines of the generated Go file (see CodePath) to
+// the cell lines, for the last cell executed. It is nil if no cell was executed yet.
+func (s *State) FileToCellIdAndLine() []CellIdAndLine {
+	return s.fileToCellIdAndLine
+}
+
+// NewCellLinesWriter returns an io.Writer to the Jupyter stream ("stdout" or "stderr"), that annotates
+// the references to lines of the generated Go file with their corresponding cell lines, using the mapping
+// of the last cell executed.
+//
+// It is used for the output of tools run on the generated code, e.g.: `!*go vet`.
+func (s *State) NewCellLinesWriter(msg kernel.Message, stream string) io.Writer {
+	return &cellLinesWriter{
+		jupyterWriter:       kernel.NewJupyterStreamWriter(msg, stream),
+		filePath:            s.CodePath(),
+		fileToCellIdAndLine: s.fileToCellIdAndLine,
+	}
+}
+
+// cellLinesWriter implements State.NewCellLinesWriter.
+type cellLinesWriter struct {
+	jupyterWriter       io.Writer
+	filePath            string
+	fileToCellIdAndLine []CellIdAndLine
+}
+
+// Write implements io.Writer.
+func (w *cellLinesWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.jupyterWriter, AnnotateFileReferences(string(p), w.filePath, w.fileToCellIdAndLine)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, and closes the underlying Jupyter writer, if it implements io.Closer.
+func (w *cellLinesWriter) Close() error {
+	if closer, ok := w.jupyterWriter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
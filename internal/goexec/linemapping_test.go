@@ -0,0 +1,60 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCellIdAndLineString(t *testing.T) {
+	assert.Equal(t, "[cell 3] line 2", CellIdAndLine{3, 1}.String())
+	assert.Equal(t, "[cell] line 5", CellIdAndLine{NoCursorLine, 4}.String())
+}
+
+func TestMapFileReferences(t *testing.T) {
+	filePath := "/tmp/gonb_12345678/main.go"
+	fileToCellIdAndLine := []CellIdAndLine{
+		{NoCursorLine, NoCursorLine}, // package main
+		{3, 0},                       // func f() {
+		{3, 1},                       //   x := 1
+		{3, 2},                       // }
+		{NoCursorLine, NoCursorLine}, // func main() {
+	}
+	replaceFn := func(reference string, cellLine CellIdAndLine) string {
+		return fmt.Sprintf("<%s|%s>", reference, cellLine)
+	}
+
+	// Absolute path.
+	assert.Equal(t, "\t<"+filePath+":3|[cell 3] line 2> +0x1d",
+		MapFileReferences("\t"+filePath+":3 +0x1d", filePath, fileToCellIdAndLine, replaceFn))
+
+	// Relative paths, as reported by the compiler, `go vet` and `go test`.
+	assert.Equal(t, "<./main.go:3|[cell 3] line 2>:5: declared and not used: x",
+		MapFileReferences("./main.go:3:5: declared and not used: x", filePath, fileToCellIdAndLine, replaceFn))
+	assert.Equal(t, "    <main.go:4|[cell 3] line 3>: failed",
+		MapFileReferences("    main.go:4: failed", filePath, fileToCellIdAndLine, replaceFn))
+
+	// Same-named file in another directory, or another file in the same directory are not mapped.
+	for _, text := range []string{
+		"/home/user/project/main.go:3",
+		"/tmp/gonb_12345678/main_test.go:3",
+		"./other_main.go:3",
+	} {
+		assert.Equal(t, text, MapFileReferences(text, filePath, fileToCellIdAndLine, replaceFn))
+	}
+
+	// Lines generated by GoNB, or out of range, are not mapped.
+	for _, text := range []string{"./main.go:1", "./main.go:5", "./main.go:0", "./main.go:100"} {
+		assert.Equal(t, text, MapFileReferences(text, filePath, fileToCellIdAndLine, replaceFn))
+	}
+
+	// Multiple references.
+	assert.Equal(t, "[cell 3] line 1 ./main.go:2:1: x\n[cell 3] line 3 ./main.go:4:1: y\n",
+		AnnotateFileReferences("./main.go:2:1: x\n./main.go:4:1: y\n", filePath, fileToCellIdAndLine))
+}
+
+func TestCellLinesOrigin(t *testing.T) {
+	assert.Equal(t, "cell 4, line 2", CellLines{Id: 4, Lines: []int{NoCursorLine, 1, 2}}.Origin())
+	assert.Equal(t, "cell 4", CellLines{Id: 4}.Origin())
+	assert.Equal(t, "", CellLines{Id: -1}.Origin())
+}
@@ -0,0 +1,58 @@
+package goexec
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"golang.org/x/exp/slices"
+	"strings"
+)
+
+// This file implements the reporting of where (cell and line) each memorized declaration was defined, see
+// CellLines. It is displayed by `%ls` and in the compiler errors in memorized declarations.
+
+// Origin returns where the declaration was defined, e.g.: "cell 4, line 2", with the execution count of the
+// cell and the line of the start of the declaration. It returns an empty string if the declaration was
+// created automatically (e.g.: imports added by `goimports`).
+func (c CellLines) Origin() string {
+	if c.Id < 0 {
+		return ""
+	}
+	for _, line := range c.Lines {
+		if line != NoCursorLine {
+			return fmt.Sprintf("cell %d, line %d", c.Id, line+1)
+		}
+	}
+	return fmt.Sprintf("cell %d", c.Id)
+}
+
+// hasCellLine returns whether the declaration spans the given cell line.
+func (c CellLines) hasCellLine(cellLine CellIdAndLine) bool {
+	return c.Id == cellLine.Id && cellLine.Line != NoCursorLine && slices.Contains(c.Lines, cellLine.Line)
+}
+
+// DeclarationAt returns the name of the memorized declaration that spans the given cell line, e.g.: "MyFunc",
+// or "MyType.MyMethod" for methods. It returns false if there is none.
+func (d *Declarations) DeclarationAt(cellLine CellIdAndLine) (name string, found bool) {
+	if d == nil {
+		return "", false
+	}
+	if name, found = declarationAt(d.Functions, cellLine); found {
+		return strings.Replace(name, "~", ".", 1), true
+	}
+	if name, found = declarationAt(d.Types, cellLine); found {
+		return
+	}
+	if name, found = declarationAt(d.Variables, cellLine); found {
+		return
+	}
+	return declarationAt(d.Constants, cellLine)
+}
+
+func declarationAt[T interface{ hasCellLine(CellIdAndLine) bool }](m map[string]T, cellLine CellIdAndLine) (string, bool) {
+	for _, key := range SortedKeys(m) {
+		if m[key].hasCellLine(cellLine) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,167 @@
+package goexec
+
+import (
+	"bytes"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"html/template"
+	"k8s.io/klog/v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file handles the parsing of the panic output (stack traces) of the programs executed, so they
+// can be reported back to the user with the references to the generated `main.go` mapped to the cell lines,
+// and without the frames that are internal to Go or generated by GoNB.
+
+// regexpPanicStart matches the start of a panic (or fatal error) output of a Go program.
+var regexpPanicStart = regexp.MustCompile(`(?m)^(panic: |fatal error: )`)
+
+// regexpGoroutineStart matches the start of a goroutine stack trace, e.g.: "goroutine 1 [running]:".
+var regexpGoroutineStart = regexp.MustCompile(`(?m)^goroutine \d+ \[`)
+
+// regexpFrameLocation matches the location line of a stack frame, e.g.: "\t/tmp/gonb_1234/main.go:12 +0x1d".
+var regexpFrameLocation = regexp.MustCompile(`^\s+(.+):(\d+)(\s+\+0x[0-9a-f]+)?$`)
+
+// panicReport holds a parsed panic output.
+type panicReport struct {
+	// Header lines, e.g.: "panic: runtime error: index out of range [3] with length 2".
+	Header []string
+
+	// Goroutines with their stack traces.
+	Goroutines []*panicGoroutine
+}
+
+// panicGoroutine is one goroutine stack trace of the panic output.
+type panicGoroutine struct {
+	Header string // E.g.: "goroutine 1 [running]:"
+	Frames []*panicFrame
+}
+
+// panicFrame is one stack frame of the panic output.
+type panicFrame struct {
+	Function string // E.g.: "main.f(...)"
+	Location string // E.g.: "/tmp/gonb_1234/main.go:12"
+	CellInfo string // E.g.: "[cell 3] line 2", only set for locations in the cell code.
+}
+
+// isInternalFrame returns whether the function of the frame is internal to Go (the call to panic, the runtime
+// or the testing packages), and hence not of interest to the user.
+func isInternalFrame(function string) bool {
+	return strings.HasPrefix(function, "panic(") || strings.HasPrefix(function, "runtime.") ||
+		strings.HasPrefix(function, "testing.")
+}
+
+// parsePanic parses the panic output, mapping the locations in `mainPath` to the corresponding cell lines,
+// using fileToCellIdAndLine.
+//
+// Frames internal to Go (see isInternalFrame) or on lines of `mainPath` that were generated by GoNB
+// (that is, don't map to a cell line) are dropped.
+//
+// It returns nil if no goroutine stack traces were found.
+func parsePanic(output, mainPath string, fileToCellIdAndLine []CellIdAndLine) *panicReport {
+	report := &panicReport{}
+	var goroutine *panicGoroutine
+	var function string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "goroutine ") {
+			goroutine = &panicGoroutine{Header: line}
+			report.Goroutines = append(report.Goroutines, goroutine)
+			function = ""
+			continue
+		}
+		if goroutine == nil {
+			report.Header = append(report.Header, line)
+			continue
+		}
+		matches := regexpFrameLocation.FindStringSubmatch(line)
+		if matches == nil {
+			// Function line, the location comes in the next line.
+			function = line
+			continue
+		}
+		frame := &panicFrame{
+			Function: function,
+			Location: matches[1] + ":" + matches[2],
+		}
+		function = ""
+		if isInternalFrame(frame.Function) {
+			continue
+		}
+		if matches[1] == mainPath {
+			lineNum, _ := strconv.Atoi(matches[2])
+			cellLine, found := CellLine(fileToCellIdAndLine, lineNum)
+			if !found {
+				// Line generated by GoNB.
+				continue
+			}
+			frame.CellInfo = cellLine.String()
+		}
+		goroutine.Frames = append(goroutine.Frames, frame)
+	}
+	if len(report.Goroutines) == 0 {
+		return nil
+	}
+	return report
+}
+
+// Text renders the panic report as plain text.
+func (r *panicReport) Text() string {
+	var sb strings.Builder
+	for _, line := range r.Header {
+		sb.WriteString(line + "\n")
+	}
+	for _, goroutine := range r.Goroutines {
+		sb.WriteString("\n" + goroutine.Header + "\n")
+		for _, frame := range goroutine.Frames {
+			if frame.CellInfo != "" {
+				sb.WriteString(frame.CellInfo + " ")
+			}
+			sb.WriteString(frame.Function + "\n\t" + frame.Location + "\n")
+		}
+	}
+	return sb.String()
+}
+
+var templatePanicReport = template.Must(template.New("panic_report").Parse(`
+<style>
+.gonb-cell-line-info {
+	background: var(--jp-layout-color2);
+	color: #999;
+	margin: 0.1em;
+	border: 1px solid var(--jp-border-color1);
+	padding-left: 0.2em;
+	padding-right: 0.2em;
+}
+</style>
+<div class="lm-Widget p-Widget jp-RenderedText jp-mod-trusted jp-OutputArea-output" data-mime-type="application/vnd.jupyter.stderr" style="font-family: monospace;">
+{{range .Header}}<b>{{.}}</b><br/>
+{{end}}{{range .Goroutines}}<br/>{{.Header}}<br/>
+{{range .Frames}}{{if .CellInfo}}<span class="gonb-cell-line-info">{{.CellInfo}}</span> {{end}}{{.Function}}<br/>
+<span style="white-space: pre;">	{{.Location}}</span><br/>
+{{end}}{{end}}</div>
+`))
+
+// Publish the panic report as HTML, with a plain text alternative.
+func (r *panicReport) Publish(msg kernel.Message) error {
+	if msg == nil {
+		return kernel.PublishWriteStream(msg, kernel.StreamStderr, r.Text())
+	}
+	buf := &bytes.Buffer{}
+	if err := templatePanicReport.Execute(buf, r); err != nil {
+		klog.Errorf("Failed to execute template for panic report: %+v", err)
+		return kernel.PublishWriteStream(msg, kernel.StreamStderr, r.Text())
+	}
+	return kernel.PublishData(msg, kernel.Data{
+		Data: kernel.MIMEMap{
+			string(protocol.MIMETextHTML):  buf.String(),
+			string(protocol.MIMETextPlain): r.Text(),
+		},
+		Metadata:  make(kernel.MIMEMap),
+		Transient: make(kernel.MIMEMap),
+	})
+}
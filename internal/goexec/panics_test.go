@@ -0,0 +1,114 @@
+package goexec
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParsePanic(t *testing.T) {
+	mainPath := "/tmp/gonb_12345678/main.go"
+	fileToCellIdAndLine := []CellIdAndLine{
+		{NoCursorLine, NoCursorLine}, // package main
+		{NoCursorLine, NoCursorLine}, //
+		{3, 0},                       // func f(x []int) int {
+		{3, 1},                       //   return x[3]
+		{3, 2},                       // }
+		{NoCursorLine, NoCursorLine}, // func main() {
+		{NoCursorLine, NoCursorLine}, //   flag.Parse()
+		{4, 1},                       //   f(nil)
+		{NoCursorLine, NoCursorLine}, // }
+	}
+	output := `panic: runtime error: index out of range [3] with length 0
+
+goroutine 1 [running]:
+main.f(...)
+	/tmp/gonb_12345678/main.go:4
+main.main()
+	/tmp/gonb_12345678/main.go:8 +0x1d
+runtime.main()
+	/usr/local/go/src/runtime/proc.go:271 +0x29d
+exit status 2
+`
+	report := parsePanic(output, mainPath, fileToCellIdAndLine)
+	require.NotNil(t, report)
+	assert.Equal(t, []string{"panic: runtime error: index out of range [3] with length 0"}, report.Header)
+	require.Len(t, report.Goroutines, 1)
+	frames := report.Goroutines[0].Frames
+	require.Len(t, frames, 2, "runtime.main frame should have been dropped")
+	assert.Equal(t, "main.f(...)", frames[0].Function)
+	assert.Equal(t, "[cell 3] line 2", frames[0].CellInfo)
+	assert.Equal(t, "main.main()", frames[1].Function)
+	assert.Equal(t, "/tmp/gonb_12345678/main.go:8", frames[1].Location)
+	assert.Equal(t, "[cell 4] line 2", frames[1].CellInfo)
+
+	// Frames on lines generated by GoNB are dropped.
+	fileToCellIdAndLine[7] = CellIdAndLine{NoCursorLine, NoCursorLine}
+	report = parsePanic(output, mainPath, fileToCellIdAndLine)
+	require.NotNil(t, report)
+	require.Len(t, report.Goroutines[0].Frames, 1)
+
+	// Not a stack trace.
+	assert.Nil(t, parsePanic("panic: something\n", mainPath, fileToCellIdAndLine))
+}
+
+func TestJupyterStackTraceMapperWriter(t *testing.T) {
+	mainPath := "/tmp/gonb_12345678/main.go"
+	fileToCellIdAndLine := []CellIdAndLine{
+		{NoCursorLine, NoCursorLine}, // package main
+		{3, 0},                       // func f() {
+		{3, 1},                       //   panic("bad")
+		{3, 2},                       // }
+	}
+	newWriter := func() (*jupyterStackTraceMapperWriter, *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		return &jupyterStackTraceMapperWriter{
+			jupyterWriter:       buf,
+			mainPath:            mainPath,
+			fileToCellIdAndLine: fileToCellIdAndLine,
+			rawError:            true,
+			atLineStart:         true,
+		}, buf
+	}
+	write := func(w *jupyterStackTraceMapperWriter, chunks ...string) {
+		for _, chunk := range chunks {
+			n, err := w.Write([]byte(chunk))
+			require.NoError(t, err)
+			require.Equal(t, len(chunk), n)
+		}
+	}
+
+	// Panic split across writes: held until Close, and reported only once with the cell lines.
+	w, buf := newWriter()
+	write(w, "some output\npa", "nic: bad\n\ngoroutine 1 [running]:\nmain.f()\n\t", mainPath+":3 +0x1d\n")
+	assert.Equal(t, "some output\n", buf.String())
+	require.NoError(t, w.Close())
+	assert.Equal(t, "some output\npanic: bad\n\ngoroutine 1 [running]:\n[cell 3] line 2 main.f()\n\t"+mainPath+":3\n",
+		buf.String())
+
+	// A logged (recovered) panic, not followed by a stack trace, is not held.
+	w, buf = newWriter()
+	write(w, "panic: recovered\n")
+	for ii := 0; ii < maxPanicHeaderLines+1; ii++ {
+		write(w, "still running\n")
+	}
+	assert.True(t, strings.HasPrefix(buf.String(), "panic: recovered\nstill running\n"))
+	assert.Nil(t, w.panicOutput)
+	require.NoError(t, w.Close())
+	assert.Equal(t, 1+maxPanicHeaderLines+1, strings.Count(buf.String(), "\n"))
+
+	// "panic: " not at the start of a line is not a panic.
+	w, buf = newWriter()
+	write(w, "no ", "panic: here\n")
+	assert.Equal(t, "no panic: here\n", buf.String())
+	require.NoError(t, w.Close())
+
+	// Too large panic output is written as is.
+	w, buf = newWriter()
+	write(w, "panic: bad\n\ngoroutine 1 [running]:\n", strings.Repeat("x", maxPanicOutput))
+	assert.Nil(t, w.panicOutput)
+	assert.Equal(t, 35+maxPanicOutput, buf.Len())
+	require.NoError(t, w.Close())
+}
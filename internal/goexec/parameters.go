@@ -0,0 +1,80 @@
+package goexec
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// This file implements the injection of parameters in the cell tagged "parameters", following papermill's
+// convention, so notebooks can be executed in batch with different values.
+
+// ParametersEnv is the name of the environment variable that can hold the parameters (a JSON object mapping
+// variable names to their values) to inject in the cell tagged "parameters".
+const ParametersEnv = "GONB_PARAMETERS"
+
+// ParametersFromEnv returns the parameters in the environment variable ParametersEnv, converted with
+// ParametersToGo, or nil if it is not set.
+func ParametersFromEnv() (map[string]string, error) {
+	value := os.Getenv(ParametersEnv)
+	if value == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber() // Preserves the numbers as written, e.g.: "1.0" remains a float.
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse $%s, it should be a JSON object mapping variable names to values",
+			ParametersEnv)
+	}
+	return ParametersToGo(values)
+}
+
+// ParametersToGo converts the values of the parameters (as decoded from JSON) to Go literals.
+// Only strings, numbers and booleans are accepted.
+func ParametersToGo(values map[string]any) (map[string]string, error) {
+	params := make(map[string]string, len(values))
+	for name, value := range values {
+		if !token.IsIdentifier(name) {
+			return nil, errors.Errorf("invalid parameter name %q, it must be a Go identifier", name)
+		}
+		switch v := value.(type) {
+		case string:
+			params[name] = strconv.Quote(v)
+		case json.Number:
+			params[name] = v.String()
+		case float64:
+			params[name] = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			params[name] = strconv.FormatBool(v)
+		default:
+			return nil, errors.Errorf("parameter %q has a value of type %T, only strings, numbers and booleans are "+
+				"accepted", name, value)
+		}
+	}
+	return params, nil
+}
+
+// injectParameters replaces the values of the variables in decls by the ones in s.CellParameters.
+// Parameters not declared in decls are declared as new variables.
+func (s *State) injectParameters(decls *Declarations) {
+	for name, value := range s.CellParameters {
+		v, found := decls.Variables[name]
+		if !found {
+			decls.Variables[name] = &Variable{
+				Cursor:          NoCursor,
+				CellLines:       CellLines{Id: -1},
+				Key:             name,
+				Name:            name,
+				ValueDefinition: value,
+			}
+			continue
+		}
+		injected := *v
+		injected.ValueDefinition = value
+		decls.Variables[name] = &injected
+	}
+}
@@ -0,0 +1,38 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParametersFromEnv(t *testing.T) {
+	t.Setenv(ParametersEnv, `{"name": "a \"b\"", "ratio": 1.0, "count": 3, "verbose": true}`)
+	params, err := ParametersFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": `"a \"b\""`, "ratio": "1.0", "count": "3", "verbose": "true"}, params)
+
+	t.Setenv(ParametersEnv, `{"list": [1, 2]}`)
+	_, err = ParametersFromEnv()
+	assert.Error(t, err)
+	t.Setenv(ParametersEnv, `{"not-a-name": 1}`)
+	_, err = ParametersFromEnv()
+	assert.Error(t, err)
+	t.Setenv(ParametersEnv, "")
+	params, err = ParametersFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, params)
+}
+
+func TestInjectParameters(t *testing.T) {
+	s := &State{CellState: CellState{CellParameters: map[string]string{"rate": "0.5", "extra": `"x"`}}}
+	decls := NewDeclarations()
+	original := &Variable{Key: "rate", Name: "rate", TypeDefinition: "float64", ValueDefinition: "0.1"}
+	decls.Variables["rate"] = original
+	s.injectParameters(decls)
+	assert.Equal(t, "0.5", decls.Variables["rate"].ValueDefinition)
+	assert.Equal(t, "float64", decls.Variables["rate"].TypeDefinition)
+	assert.Equal(t, "0.1", original.ValueDefinition, "Original declaration should not be modified")
+	require.Contains(t, decls.Variables, "extra")
+	assert.Equal(t, `"x"`, decls.Variables["extra"].ValueDefinition)
+}
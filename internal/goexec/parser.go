@@ -103,6 +103,12 @@ func (pi *parseInfo) extractContentOfNode(node ast.Node) string {
 	return contents[from:to]
 }
 
+// BareStatementsHint is appended to the parsing errors caused by statements outside of a function, a common
+// mistake when one expects the cell to be executed as a script.
+const BareStatementsHint = "Statements (e.g.: `fmt.Println(x)` or `x := 1`) must be inside a function: " +
+	"use `%%` in a line before them, to wrap the lines that follow in a `func main() {...}`, " +
+	"or define the `func main()` yourself."
+
 // parseFromGoCode reads the Go code written in `s.TempDir` and parses its declarations.
 // See object Declarations.
 //
@@ -139,8 +145,11 @@ func (s *State) parseFromGoCode(msg kernel.Message,
 		keep := name == "main.go" || name == "main_test.go"
 		klog.V(2).Infof("parser.ParseDir().filter(%q) -> keep=%v", name, keep)
 		return keep
-	}, parser.SkipObjectResolution) // |parser.AllErrors
+	}, parser.SkipObjectResolution|parser.ParseComments) // |parser.AllErrors
 	if err != nil {
+		if strings.Contains(err.Error(), "expected declaration, found") {
+			err = errors.Errorf("%v\n%s", err, BareStatementsHint)
+		}
 		if msg != nil {
 			err = s.DisplayErrorWithContext(msg, fileToCellIdAndLine, err.Error(), err)
 		}
@@ -245,16 +254,15 @@ func (pi *parseInfo) ParseFuncEntry(decls *Declarations, funcDecl *ast.FuncDecl)
 	// Incorporate functions.
 	key := funcDecl.Name.Name
 	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
-		typeName := "unknown"
-		switch fieldType := funcDecl.Recv.List[0].Type.(type) {
-		case *ast.Ident:
-			typeName = fieldType.Name
-		case *ast.StarExpr:
-			typeName = pi.extractContentOfNode(fieldType.X)
+		// Methods are keyed by the name of the type, without the type parameters, so they are associated
+		// with it regardless of how the type parameters are named in each method.
+		typeName := receiverTypeName(funcDecl.Recv.List[0].Type)
+		if typeName == "" {
+			typeName = "unknown"
 		}
 		key = fmt.Sprintf("%s~%s", typeName, key)
 	}
-	f := &Function{Key: key, Definition: pi.extractContentOfNode(funcDecl)}
+	f := &Function{Key: key, Definition: pi.extractContentOfNode(funcDecl), Doc: funcDecl.Doc.Text()}
 	f.CellLines = pi.calculateCellLines(funcDecl)
 	f.Cursor = pi.getCursor(funcDecl)
 	decls.Functions[f.Key] = f
@@ -276,7 +284,7 @@ func (pi *parseInfo) ParseVarEntry(decls *Declarations, genDecl *ast.GenDecl) {
 		}
 		// Each spec may be a list of variables (comma separated).
 		for nameIdx, name := range vSpec.Names {
-			v := &Variable{Name: name.Name, TypeDefinition: typeDefinition}
+			v := &Variable{Name: name.Name, TypeDefinition: typeDefinition, Doc: specDoc(genDecl, vSpec.Doc)}
 			if !cursorFound {
 				if c := pi.getCursor(name); c.HasCursor() {
 					v.CursorInName = true
@@ -328,7 +336,8 @@ func (pi *parseInfo) ParseConstEntry(decls *Declarations, typedDecl *ast.GenDecl
 		}
 		// Each spec may be a list of variables (comma separated).
 		for nameIdx, name := range vSpec.Names {
-			c := &Constant{Cursor: NoCursor, Key: name.Name, TypeDefinition: typeDefinition}
+			c := &Constant{Cursor: NoCursor, Key: name.Name, TypeDefinition: typeDefinition,
+				Doc: specDoc(typedDecl, vSpec.Doc)}
 			c.Prev = prevConstDecl
 			if c.Prev != nil {
 				c.Prev.Next = c
@@ -369,7 +378,7 @@ func (pi *parseInfo) ParseTypeEntry(decls *Declarations, typedDecl *ast.GenDecl)
 		tSpec := spec.(*ast.TypeSpec)
 		name := tSpec.Name.Name
 		tDef := pi.extractContentOfNode(tSpec)
-		tDecl := &TypeDecl{Key: name, TypeDefinition: tDef}
+		tDecl := &TypeDecl{Key: name, TypeDefinition: tDef, Doc: specDoc(typedDecl, tSpec.Doc)}
 		if c := pi.getCursor(tSpec); c.HasCursor() {
 			tDecl.Cursor = c
 			tDecl.CursorInType = true
@@ -379,6 +388,15 @@ func (pi *parseInfo) ParseTypeEntry(decls *Declarations, typedDecl *ast.GenDecl)
 	}
 }
 
+// specDoc returns the doc comment of a spec of a declaration: its own, or the one of the declaration if it
+// has no parentheses (e.g.: `// Doc.\ntype T int`).
+func specDoc(genDecl *ast.GenDecl, doc *ast.CommentGroup) string {
+	if doc == nil && !genDecl.Lparen.IsValid() {
+		doc = genDecl.Doc
+	}
+	return doc.Text()
+}
+
 // parseLinesAndComposeMain parses the cell (given in Lines and skipLines), merges with
 // memorized declarations in the State (presumably from previous Cell runs) and compose a `main.go`.
 //
@@ -409,6 +427,11 @@ func (s *State) parseLinesAndComposeMain(
 	if err != nil {
 		return
 	}
+	if s.CellIsGenerated {
+		for ii := range fileToCellLine {
+			fileToCellLine[ii] = NoCursorLine
+		}
+	}
 	fileToCellIdAndLine = MakeFileToCellIdAndLine(cellId, fileToCellLine)
 
 	data, _ := os.ReadFile(s.CodePath())
@@ -443,11 +466,45 @@ func (s *State) parseLinesAndComposeMain(
 		}
 	}
 
+	// Display the value of the last expression of main, if it is a bare expression. Not done if there is a cursor
+	// (completion or inspection requests), since it would change its position.
+	if s.AutoPrint && hasMain && !cursorInCell.HasCursor() {
+		if definition, changed := autoPrintLastExpression(mainDecl.Definition); changed {
+			mainDecl.Definition = definition
+			gonbuiImport := NewImport(GonbuiPackage, "")
+			gonbuiImport.Cursor, gonbuiImport.CellLines = NoCursor, CellLines{Id: -1}
+			_, inCell := newDecls.Imports[gonbuiImport.Key]
+			if _, found := s.Definitions.Imports[gonbuiImport.Key]; !found && !inCell {
+				newDecls.Imports[gonbuiImport.Key] = gonbuiImport
+			}
+		}
+	}
+
+	// In REPL mode, the variables of main to be memorized are used, so they are not reported as unused.
+	if s.Repl && hasMain && !cursorInCell.HasCursor() {
+		s.useReplVariables(msg, mainDecl)
+	}
+
+	// Values injected in the cell tagged "parameters" replace the ones declared in the cell.
+	if len(s.CellParameters) > 0 {
+		s.injectParameters(newDecls)
+	}
+
 	// Merge cell declarations with a copy of the current state: we don't want to commit the new
 	// declarations until they compile successfully.
 	updatedDecls = s.Definitions.Copy()
 	updatedDecls.ClearCursor()
 	updatedDecls.MergeFrom(newDecls)
+	if s.CellPgoCapture && !s.CellIsTest {
+		if err = s.injectCpuProfile(mainDecl); err != nil {
+			return
+		}
+	}
+	if s.CellEntryPoint != "" {
+		if err = s.callEntryPoint(updatedDecls, mainDecl); err != nil {
+			return
+		}
+	}
 	if s.CellIsWasm {
 		s.ExportWasmConstants(updatedDecls)
 	}
@@ -3,6 +3,7 @@ package goexec
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
@@ -401,3 +402,140 @@ func TestAdjustCursorForFunctionIdentifier(t *testing.T) {
 	assert.Equal(t, 0, cursor.Line) // "‸f(x,)"
 	assert.Equal(t, 0, cursor.Col)  // "‸f(x,)"
 }
+
+func TestBareStatementsHint(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	// Statements outside a function: a common mistake.
+	lines := []string{"import \"fmt\"", "", "x := 1", "fmt.Println(x)"}
+	_, _, err := s.createGoFileFromLines(s.CodePath(), 1, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	_, err = s.parseFromGoCode(nil, 1, NoCursor, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected declaration")
+	assert.Contains(t, err.Error(), BareStatementsHint)
+
+	// Other syntax errors have no hint.
+	lines = []string{"func f() {", "\tx := ", "}"}
+	_, _, err = s.createGoFileFromLines(s.CodePath(), 2, lines, MakeSet[int](), NoCursor)
+	require.NoError(t, err)
+	_, err = s.parseFromGoCode(nil, 2, NoCursor, nil)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), BareStatementsHint)
+}
+
+func TestIncrementalMethods(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	// Each cell is memorized after it is parsed, as in State.ExecuteCell.
+	parseCell := func(cellId int, cell string) {
+		updatedDecls, _, _, _, err := s.parseLinesAndComposeMain(nil, cellId, strings.Split(cell, "\n"), nil, NoCursor)
+		require.NoError(t, err)
+		updatedDecls.ClearCursor()
+		s.Definitions = updatedDecls
+	}
+	parseCell(1, "type Counter struct { n int }")
+	parseCell(2, "func (c *Counter) Inc() { c.n++ }")
+	parseCell(3, "func (c Counter) Value() int { return c.n }")
+	assert.Equal(t, []string{"Counter~Inc", "Counter~Value"}, s.Definitions.Methods("Counter"))
+
+	// Redefining the type keeps its methods, and redefining a method replaces it.
+	parseCell(4, "type Counter struct { n, step int }")
+	parseCell(5, "func (c *Counter) Inc() { c.n += c.step }")
+	assert.Equal(t, []string{"Counter~Inc", "Counter~Value"}, s.Definitions.Methods("Counter"))
+	assert.Equal(t, "func (c *Counter) Inc() { c.n += c.step }", s.Definitions.Functions["Counter~Inc"].Definition)
+	assert.Equal(t, "Counter struct { n, step int }", s.Definitions.Types["Counter"].TypeDefinition)
+
+	// Methods of generic types are keyed by the name of the type, regardless of the type parameters.
+	parseCell(6, "type Pair[K comparable, V any] struct { Key K; Value V }")
+	parseCell(7, "func (p *Pair[K, V]) Get() V { return p.Value }")
+	parseCell(8, "func (p Pair[A, B]) Get() B { return p.Value }")
+	assert.Equal(t, []string{"Pair~Get"}, s.Definitions.Methods("Pair"))
+	assert.Equal(t, "func (p Pair[A, B]) Get() B { return p.Value }", s.Definitions.Functions["Pair~Get"].Definition)
+
+	// Removing a type removes its methods, but not the ones of other types.
+	methods, found := s.Definitions.RemoveType("Counter")
+	assert.True(t, found)
+	assert.Equal(t, []string{"Counter~Inc", "Counter~Value"}, methods)
+	assert.NotContains(t, s.Definitions.Types, "Counter")
+	assert.Empty(t, s.Definitions.Methods("Counter"))
+	assert.Equal(t, []string{"Pair~Get"}, s.Definitions.Methods("Pair"))
+}
+
+func TestGenericDeclarations(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	// Each cell is memorized after it is parsed, as in State.ExecuteCell, and returns the generated `main.go`.
+	parseCell := func(cellId int, cell string) string {
+		updatedDecls, _, _, _, err := s.parseLinesAndComposeMain(nil, cellId, strings.Split(cell, "\n"), nil, NoCursor)
+		require.NoError(t, err)
+		updatedDecls.ClearCursor()
+		s.Definitions = updatedDecls
+		src, err := os.ReadFile(s.CodePath())
+		require.NoError(t, err)
+		return string(src)
+	}
+	parseCell(1, `type Number interface {
+	~int | ~float64
+}
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+type Set[T comparable] map[T]struct{}
+
+var evens = Map[int, int]([]int{1, 2}, func(x int) int { return 2 * x })`)
+	require.Contains(t, s.Definitions.Functions, "Map")
+	require.Contains(t, s.Definitions.Types, "Set")
+	assert.Equal(t, "Set[T comparable] map[T]struct{}", s.Definitions.Types["Set"].TypeDefinition)
+	assert.Equal(t, "Number interface {\n\t~int | ~float64\n}", s.Definitions.Types["Number"].TypeDefinition)
+	assert.Equal(t, "Map[int, int]([]int{1, 2}, func(x int) int { return 2 * x })",
+		s.Definitions.Variables["evens"].ValueDefinition)
+
+	// Used in the following cell.
+	src := parseCell(2, "%%\nfmt.Println(Map([]int{1, 2}, strconv.Itoa), evens, Set[string]{})")
+	assert.Contains(t, src, "func Map[T, U any](s []T, f func(T) U) []U {\n")
+	assert.Contains(t, src, "type Set[T comparable] map[T]struct{}\n")
+	assert.Contains(t, src, "fmt.Println(Map([]int{1, 2}, strconv.Itoa), evens, Set[string]{})\n")
+
+	// Redefined with a constraint in a later cell: the previous definition is replaced.
+	parseCell(3, `func Map[T any, U Number](s []T, f func(T) U) (r []U) {
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return
+}`)
+	src = parseCell(4, "%%\nfmt.Println(Map([]string{\"a\"}, func(s string) int { return len(s) }))")
+	assert.Equal(t, 1, strings.Count(src, "func Map["))
+	assert.Contains(t, src, "func Map[T any, U Number](s []T, f func(T) U) (r []U) {\n")
+}
+
+func TestGeneratedCellLines(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	lines := []string{"func main() {", "\tx := 1", "}"}
+	_, _, _, fileToCellIdAndLine, err := s.parseLinesAndComposeMain(nil, 1, lines, nil, NoCursor)
+	require.NoError(t, err)
+	_, found := CellLine(fileToCellIdAndLine, 4)
+	assert.True(t, found)
+
+	// Lines generated by GoNB don't map back to cell lines.
+	s.CellIsGenerated = true
+	_, _, _, fileToCellIdAndLine, err = s.parseLinesAndComposeMain(nil, 2, lines, nil, NoCursor)
+	require.NoError(t, err)
+	for lineNum := 1; lineNum <= len(fileToCellIdAndLine); lineNum++ {
+		_, found = CellLine(fileToCellIdAndLine, lineNum)
+		assert.Falsef(t, found, "line %d of main.go mapped to a cell line", lineNum)
+	}
+}
@@ -0,0 +1,71 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+	"strings"
+)
+
+// This file implements the profile-guided optimization (PGO) workflow, see `%pgo`.
+
+// PgoProfilePath is the path of the CPU profile captured with `%pgo capture`, and used to compile with
+// `-pgo=<path>` when State.PGO is enabled.
+func (s *State) PgoProfilePath() string {
+	return path.Join(s.TempDir, "default.pgo")
+}
+
+// pgoFlag returns the `-pgo` flag for the builds: the captured profile if State.PGO is on, otherwise
+// `-pgo=off`. The latter is needed because PgoProfilePath is in the main package directory, where the default
+// `-pgo=auto` would otherwise pick it up.
+func (s *State) pgoFlag() (string, error) {
+	if !s.PGO {
+		return "-pgo=off", nil
+	}
+	if _, err := os.Stat(s.PgoProfilePath()); err != nil {
+		return "", errors.Errorf("profile-guided optimization is on, but there is no CPU profile in %q: "+
+			"capture one with `%%pgo capture`, or disable it with `%%pgo off`", s.PgoProfilePath())
+	}
+	return "-pgo=" + s.PgoProfilePath(), nil
+}
+
+// injectCpuProfile changes mainDecl to write a CPU profile to PgoProfilePath, while it runs.
+//
+// The code is injected in the line of the opening brace of `func main()`, so the mapping of the lines of
+// the cell is not changed. The profile is not written if the program exits with `os.Exit`. The imports
+// are added by `goimports`.
+func (s *State) injectCpuProfile(mainDecl *Function) error {
+	idx := strings.Index(mainDecl.Definition, "{")
+	if idx < 0 {
+		return errors.Errorf("`%%pgo capture`: failed to parse the definition of `func main()`")
+	}
+	profiling := fmt.Sprintf("if f, err := os.Create(%q); err == nil { "+
+		"if err := pprof.StartCPUProfile(f); err == nil { defer func() { pprof.StopCPUProfile(); _ = f.Close() }() } }; ",
+		s.PgoProfilePath())
+	mainDecl.Definition = mainDecl.Definition[:idx+1] + " " + profiling + mainDecl.Definition[idx+1:]
+	return nil
+}
+
+// reportPgoCapture reports the CPU profile captured by the execution of the cell, with the hints to use it.
+func (s *State) reportPgoCapture(msg kernel.Message) error {
+	info, err := os.Stat(s.PgoProfilePath())
+	if err != nil || info.Size() == 0 {
+		return errors.Errorf("`%%pgo capture`: no CPU profile was written to %q -- notice programs that exit with "+
+			"`os.Exit` don't write it", s.PgoProfilePath())
+	}
+	report := fmt.Sprintf("\nCPU profile captured in %q (%s).\n", s.PgoProfilePath(), formatBinarySize(info.Size()))
+	if s.PGO {
+		report += "Profile-guided optimization is on: the next builds use the new profile, " +
+			"rerun the cell to compare the results.\n"
+	} else {
+		report += "Take note of the results above, and use `%pgo on` to enable profile-guided optimization in the " +
+			"next builds: then rerun the cell to compare.\n"
+	}
+	if err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report); err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestInjectCpuProfile(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	definition := "func main() {\n\tflag.Parse()\n\tfmt.Println(fib(30))\n}"
+	mainDecl := &Function{Cursor: NoCursor, Key: "main", Definition: definition}
+	require.NoError(t, s.injectCpuProfile(mainDecl))
+	assert.Equal(t, strings.Count(definition, "\n"), strings.Count(mainDecl.Definition, "\n"),
+		"injected code should not change the line mapping")
+	firstLine, rest, _ := strings.Cut(mainDecl.Definition, "\n")
+	assert.Contains(t, firstLine, "pprof.StartCPUProfile(f)")
+	assert.Contains(t, firstLine, s.PgoProfilePath())
+	assert.Equal(t, "\tflag.Parse()\n\tfmt.Println(fib(30))\n}", rest)
+}
+
+func TestPgoFlag(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	// PGO is explicitly disabled, otherwise `-pgo=auto` would use the captured default.pgo.
+	flag, err := s.pgoFlag()
+	require.NoError(t, err)
+	assert.Equal(t, "-pgo=off", flag)
+
+	s.PGO = true
+	_, err = s.pgoFlag()
+	require.Error(t, err, "no profile captured yet")
+	require.NoError(t, os.WriteFile(s.PgoProfilePath(), []byte("profile"), 0644))
+	flag, err = s.pgoFlag()
+	require.NoError(t, err)
+	assert.Equal(t, "-pgo="+s.PgoProfilePath(), flag)
+}
@@ -0,0 +1,74 @@
+package goexec
+
+import (
+	"bytes"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// This file implements the support for the race detector in tests, see `%test --race`.
+
+// raceSupportedPlatforms are the platforms ("GOOS/GOARCH") supported by the race detector.
+var raceSupportedPlatforms = common.SetWithValues(
+	"linux/amd64", "linux/arm64", "linux/ppc64le", "linux/s390x",
+	"darwin/amd64", "darwin/arm64", "freebsd/amd64", "netbsd/amd64", "windows/amd64")
+
+// CheckRaceSupport returns an error if the toolchain used can't build with the race detector (`-race`):
+// it must target a supported platform, and, except on macOS, it requires cgo.
+func (s *State) CheckRaceSupport() error {
+	cmd := s.GoCommand("env", "GOOS", "GOARCH", "CGO_ENABLED")
+	cmd.Dir = s.TempDir
+	output, err := cmd.Output()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %q", cmd.String())
+	}
+	values := strings.Fields(string(output))
+	if len(values) != 3 {
+		return errors.Errorf("unexpected output of %q: %q", cmd.String(), output)
+	}
+	goos, goarch, cgoEnabled := values[0], values[1], values[2]
+	if !raceSupportedPlatforms.Has(goos + "/" + goarch) {
+		return errors.Errorf("the race detector is not supported on %s/%s", goos, goarch)
+	}
+	if cgoEnabled != "1" && goos != "darwin" {
+		return errors.Errorf("the race detector requires cgo, but CGO_ENABLED=%s -- is a C compiler installed?",
+			cgoEnabled)
+	}
+	return nil
+}
+
+// dataRaceReport starts each report of a data race by the race detector.
+var dataRaceReport = []byte("WARNING: DATA RACE")
+
+// dataRaceCounter is an io.Writer that counts the reports of data races written through it.
+type dataRaceCounter struct {
+	io.Writer
+	count int
+
+	// tail holds the end of the last write that may be the start of a report.
+	tail []byte
+}
+
+// Write implements io.Writer.
+func (w *dataRaceCounter) Write(p []byte) (int, error) {
+	data := append(w.tail, p...)
+	w.count += bytes.Count(data, dataRaceReport)
+	w.tail = nil
+	if keep := min(len(dataRaceReport)-1, len(data)); keep > 0 {
+		// A report split across writes can only start at its first character.
+		if start := bytes.LastIndexByte(data[len(data)-keep:], dataRaceReport[0]); start >= 0 {
+			w.tail = append([]byte(nil), data[len(data)-keep+start:]...)
+		}
+	}
+	return w.Writer.Write(p)
+}
+
+// Close implements io.Closer, closing the underlying writer if it implements it.
+func (w *dataRaceCounter) Close() error {
+	if closer, ok := w.Writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
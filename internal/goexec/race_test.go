@@ -0,0 +1,19 @@
+package goexec
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDataRaceCounter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &dataRaceCounter{Writer: &buf}
+	report := "==================\nWARNING: DATA RACE\nWrite at 0x00c000012345 by goroutine 7:\n"
+	for _, chunk := range []string{report, report[:22], report[22:], "WARN", "ING: DATA", " RACE\n", "W", "W\n"} {
+		_, err := w.Write([]byte(chunk))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, w.count)
+	assert.Equal(t, report+report+"WARNING: DATA RACE\nWW\n", buf.String())
+}
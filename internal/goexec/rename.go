@@ -0,0 +1,212 @@
+package goexec
+
+import (
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// This file implements `%rename`, which renames a memorized declaration and its references.
+
+// Rename renames the memorized function, type, variable or constant oldName to newName, and updates the
+// references to it in all the memorized declarations. If oldName is a type, its methods are also updated.
+//
+// References are found in the AST of each declaration: identifiers shadowed by local declarations, selectors
+// (e.g.: `x.oldName`) and struct fields are left untouched.
+//
+// It returns the number of declarations changed, and an error if oldName is not memorized, or if newName
+// is already declared, in which case nothing is changed.
+func (s *State) Rename(oldName, newName string) (numChanged int, err error) {
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return 0, errors.Errorf("invalid new name %q, it must be a Go identifier", newName)
+	}
+	if oldName == "main" || oldName == "init" || newName == "main" || newName == "init" {
+		return 0, errors.Errorf("functions `main` and `init` can't be renamed")
+	}
+	decls := s.Definitions
+	_, isFunction := decls.Functions[oldName]
+	_, isType := decls.Types[oldName]
+	_, isVariable := decls.Variables[oldName]
+	_, isConstant := decls.Constants[oldName]
+	if !isFunction && !isType && !isVariable && !isConstant {
+		return 0, errors.Errorf("%q is not a memorized function, type, variable or constant, see `%%ls`", oldName)
+	}
+	_, found := decls.Functions[newName]
+	if !found {
+		_, found = decls.Types[newName]
+	}
+	if !found {
+		_, found = decls.Variables[newName]
+	}
+	if !found {
+		_, found = decls.Constants[newName]
+	}
+	if !found {
+		_, found = decls.Imports[newName]
+	}
+	if found {
+		return 0, errors.Errorf("can't rename %q: %q is already declared", oldName, newName)
+	}
+
+	// Updates are first collected, and only applied if all declarations could be renamed. Notice they
+	// don't refer to the loop variables, which are shared across iterations.
+	var updates []func()
+	changed := func(update func()) {
+		updates = append(updates, update)
+		numChanged++
+	}
+	for _, key := range SortedKeys(decls.Functions) {
+		f, oldKey := decls.Functions[key], key
+		definition, err := renameInSource("", f.Definition, oldName, newName, key == oldName)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in function %q", key)
+		}
+		newKey := key
+		if isType {
+			// Methods of the renamed type.
+			if typeName, method, isMethod := strings.Cut(key, "~"); isMethod {
+				if typeName == oldName {
+					newKey = newName + "~" + method
+				}
+			}
+		} else if key == oldName {
+			newKey = newName
+		}
+		if definition != f.Definition || newKey != key {
+			changed(func() {
+				f.Definition = definition
+				if newKey != oldKey {
+					f.Key = newKey
+					delete(decls.Functions, oldKey)
+					decls.Functions[newKey] = f
+				}
+			})
+		}
+	}
+	for _, key := range SortedKeys(decls.Types) {
+		t, isRenamed := decls.Types[key], key == oldName
+		definition, err := renameInSource("type ", t.TypeDefinition, oldName, newName, isRenamed)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in type %q", key)
+		}
+		if definition != t.TypeDefinition {
+			changed(func() {
+				t.TypeDefinition = definition
+				if isRenamed {
+					t.Key = newName
+					delete(decls.Types, oldName)
+					decls.Types[newName] = t
+				}
+			})
+		}
+	}
+	for _, key := range SortedKeys(decls.Variables) {
+		v, isRenamed := decls.Variables[key], key == oldName
+		typeDefinition, err := renameInSource("var _ ", v.TypeDefinition, oldName, newName, false)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in variable %q", key)
+		}
+		valueDefinition, err := renameInSource("var _ = ", v.ValueDefinition, oldName, newName, false)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in variable %q", key)
+		}
+		if typeDefinition != v.TypeDefinition || valueDefinition != v.ValueDefinition || isRenamed {
+			changed(func() {
+				v.TypeDefinition, v.ValueDefinition = typeDefinition, valueDefinition
+				if isRenamed {
+					v.Key, v.Name = newName, newName
+					delete(decls.Variables, oldName)
+					decls.Variables[newName] = v
+				}
+			})
+		}
+	}
+	for _, key := range SortedKeys(decls.Constants) {
+		c, isRenamed := decls.Constants[key], key == oldName
+		typeDefinition, err := renameInSource("var _ ", c.TypeDefinition, oldName, newName, false)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in constant %q", key)
+		}
+		valueDefinition, err := renameInSource("var _ = ", c.ValueDefinition, oldName, newName, false)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "in constant %q", key)
+		}
+		if typeDefinition != c.TypeDefinition || valueDefinition != c.ValueDefinition || isRenamed {
+			changed(func() {
+				c.TypeDefinition, c.ValueDefinition = typeDefinition, valueDefinition
+				if isRenamed {
+					c.Key = newName
+					delete(decls.Constants, oldName)
+					decls.Constants[newName] = c
+				}
+			})
+		}
+	}
+	for _, update := range updates {
+		update()
+	}
+	return numChanged, nil
+}
+
+// renameInSource renames the references to the package level identifier oldName in the Go source fragment
+// src, parsed as a file with the given prefix (e.g.: "var _ = " for the value of a variable).
+// If isDeclaration, the function or type declared by src, named oldName, is also renamed.
+func renameInSource(prefix, src, oldName, newName string, isDeclaration bool) (string, error) {
+	if src == "" || !strings.Contains(src, oldName) {
+		return src, nil
+	}
+	header := "package main\n" + prefix
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", header+src, 0)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse memorized declaration")
+	}
+	tokFile := fileSet.File(file.Pos())
+
+	// Identifiers not resolved within the declaration refer to package level declarations (or to
+	// predeclared ones).
+	var offsets []int
+	for _, ident := range file.Unresolved {
+		if ident.Name == oldName {
+			offsets = append(offsets, tokFile.Offset(ident.Pos()))
+		}
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			if isDeclaration && n.Recv == nil && n.Name.Name == oldName {
+				offsets = append(offsets, tokFile.Offset(n.Name.Pos()))
+			}
+		case *ast.TypeSpec:
+			if isDeclaration && n.Name.Name == oldName {
+				offsets = append(offsets, tokFile.Offset(n.Name.Pos()))
+			}
+		case *ast.CompositeLit:
+			// The parser doesn't resolve the keys of composite literals, since they could be struct fields: but
+			// the keys of maps, arrays and slices are expressions.
+			switch n.Type.(type) {
+			case *ast.MapType, *ast.ArrayType:
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if ident, ok := kv.Key.(*ast.Ident); ok && ident.Name == oldName && ident.Obj == nil {
+							offsets = append(offsets, tokFile.Offset(ident.Pos()))
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	// Replace from the end, so the offsets of the previous ones are still valid.
+	sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+	for _, offset := range offsets {
+		offset -= len(header)
+		src = src[:offset] + newName + src[offset+len(oldName):]
+	}
+	return src, nil
+}
@@ -0,0 +1,63 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	decls := s.Definitions
+	decls.Types["Point"] = &TypeDecl{Key: "Point", TypeDefinition: "Point struct{ Point, Y int }"}
+	decls.Functions["Point~Norm"] = &Function{Key: "Point~Norm",
+		Definition: "func (p *Point) Norm() int { return p.Point*p.Point + p.Y*p.Y }"}
+	decls.Functions["origin"] = &Function{Key: "origin",
+		Definition: "func origin() Point { return Point{Point: 0} }"}
+	decls.Functions["shadow"] = &Function{Key: "shadow",
+		Definition: "func shadow(Point int) int { return Point }"}
+	decls.Variables["points"] = &Variable{Key: "points", Name: "points",
+		TypeDefinition: "map[Point]Point", ValueDefinition: "map[Point]Point{Point{}: {}}"}
+	decls.Constants["scale"] = &Constant{Key: "scale", ValueDefinition: "2"}
+
+	numChanged, err := s.Rename("Point", "Vec")
+	require.NoError(t, err)
+	assert.Equal(t, 4, numChanged)
+	require.Contains(t, decls.Types, "Vec")
+	assert.NotContains(t, decls.Types, "Point")
+	assert.Equal(t, "Vec", decls.Types["Vec"].Key)
+	assert.Equal(t, "Vec struct{ Point, Y int }", decls.Types["Vec"].TypeDefinition)
+	require.Contains(t, decls.Functions, "Vec~Norm")
+	assert.Equal(t, "func (p *Vec) Norm() int { return p.Point*p.Point + p.Y*p.Y }",
+		decls.Functions["Vec~Norm"].Definition)
+	assert.Equal(t, "func origin() Vec { return Vec{Point: 0} }", decls.Functions["origin"].Definition)
+	assert.Equal(t, "func shadow(Point int) int { return Point }", decls.Functions["shadow"].Definition)
+	assert.Equal(t, "map[Vec]Vec", decls.Variables["points"].TypeDefinition)
+	assert.Equal(t, "map[Vec]Vec{Vec{}: {}}", decls.Variables["points"].ValueDefinition)
+
+	// Functions, constants and map keys.
+	decls.Functions["double"] = &Function{Key: "double", Definition: "func double(x int) int { return scale * x }"}
+	decls.Variables["table"] = &Variable{Key: "table", Name: "table", ValueDefinition: "map[int]string{scale: \"two\"}"}
+	numChanged, err = s.Rename("scale", "factor")
+	require.NoError(t, err)
+	assert.Equal(t, 3, numChanged)
+	assert.Contains(t, decls.Constants, "factor")
+	assert.Equal(t, "func double(x int) int { return factor * x }", decls.Functions["double"].Definition)
+	assert.Equal(t, "map[int]string{factor: \"two\"}", decls.Variables["table"].ValueDefinition)
+	numChanged, err = s.Rename("double", "twice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, numChanged)
+	assert.Equal(t, "func twice(x int) int { return factor * x }", decls.Functions["twice"].Definition)
+
+	// Conflicts and unknown names: nothing is changed.
+	_, err = s.Rename("twice", "origin")
+	assert.ErrorContains(t, err, "already declared")
+	assert.Contains(t, decls.Functions, "twice")
+	_, err = s.Rename("unknown", "other")
+	assert.Error(t, err)
+	_, err = s.Rename("twice", "1invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package goexec
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// This file implements the REPL mode (see State.Repl and `%repl`): the variables declared at the top level of
+// `func main()` (e.g.: `x := 5` after `%%`) are memorized as package level variables, so they are visible in the
+// following cells.
+//
+// Since each cell is executed by a new process, the memorized variables are initialized again (with the value
+// they were declared with) in every execution: changes made by other statements are not carried over.
+
+// replVariables parses the definition of mainDecl and returns the variables declared at the top level of its
+// body, with `x := <value>` or `var x [<type>] [= <value>]`, as package level variables.
+//
+// Variables that can't be memorized are returned in skipped: the ones declared with a multi-valued expression
+// (e.g.: `a, b := f()`) and the ones whose type or value refer to other local declarations (not memorized) or
+// to themselves (e.g.: `x := x + 1`, which would be an initialization cycle).
+//
+// It also returns the edits to main that use (`_ = x`) the memorized variables, right after their declaration,
+// so they are not reported as unused in the cell that declares them.
+func replVariables(mainDecl *Function) (vars []*Variable, skipped []string, edits []sourceEdit) {
+	const prefix = "package main\n"
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", prefix+mainDecl.Definition, parser.SkipObjectResolution)
+	if err != nil || len(file.Decls) != 1 {
+		return
+	}
+	funcDecl, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok || funcDecl.Body == nil {
+		return
+	}
+	offset := func(pos token.Pos) int { return fileSet.Position(pos).Offset - len(prefix) }
+	source := func(node ast.Node) string { return mainDecl.Definition[offset(node.Pos()):offset(node.End())] }
+	cellLines := func(from, to token.Pos) CellLines {
+		// Line 1 of the parsed file is the prefix.
+		c := CellLines{Id: mainDecl.Id}
+		for line := fileSet.Position(from).Line - 2; line <= fileSet.Position(to).Line-2; line++ {
+			if line >= 0 && line < len(mainDecl.Lines) {
+				c.Lines = append(c.Lines, mainDecl.Lines[line])
+			} else {
+				c.Lines = append(c.Lines, NoCursorLine)
+			}
+		}
+		return c
+	}
+
+	// local holds the names declared in main that are not memorized: memorized variables can't refer to them.
+	local := MakeSet[string]()
+	var usesLocal func(node ast.Node) bool
+	usesLocal = func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		found := false
+		ast.Inspect(node, func(n ast.Node) bool {
+			if found {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				// The selected field or method is not a reference to a local declaration.
+				found = usesLocal(n.X)
+				return false
+			case *ast.Ident:
+				found = local.Has(n.Name)
+			}
+			return true
+		})
+		return found
+	}
+	skip := func(name string) {
+		if name != "_" && !local.Has(name) {
+			local.Insert(name)
+			skipped = append(skipped, name)
+		}
+	}
+	memorize := func(stmt ast.Stmt, v *Variable, typeExpr, valueExpr ast.Expr) {
+		if v.Name == "_" {
+			return
+		}
+		local.Insert(v.Name) // Temporarily, to detect references to itself.
+		if usesLocal(typeExpr) || usesLocal(valueExpr) {
+			local.Delete(v.Name)
+			skip(v.Name)
+			return
+		}
+		local.Delete(v.Name)
+		v.Key, v.Cursor = v.Name, NoCursor
+		vars = append(vars, v)
+		edits = append(edits, sourceEdit{offset(stmt.End()), offset(stmt.End()), "; _ = " + v.Name})
+	}
+
+	for _, stmt := range funcDecl.Body.List {
+		switch stmt := stmt.(type) {
+		case *ast.AssignStmt:
+			if stmt.Tok != token.DEFINE {
+				continue
+			}
+			multiValued := len(stmt.Lhs) != len(stmt.Rhs)
+			for ii, lhs := range stmt.Lhs {
+				ident, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if multiValued {
+					skip(ident.Name)
+					continue
+				}
+				memorize(stmt, &Variable{
+					Name:            ident.Name,
+					ValueDefinition: source(stmt.Rhs[ii]),
+					CellLines:       cellLines(ident.Pos(), stmt.Rhs[ii].End()),
+				}, nil, stmt.Rhs[ii])
+			}
+		case *ast.DeclStmt:
+			genDecl, ok := stmt.Decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					multiValued := len(spec.Values) > 0 && len(spec.Values) != len(spec.Names)
+					for ii, name := range spec.Names {
+						if genDecl.Tok != token.VAR || multiValued {
+							skip(name.Name)
+							continue
+						}
+						v := &Variable{Name: name.Name}
+						end := name.End()
+						if spec.Type != nil {
+							v.TypeDefinition, end = source(spec.Type), spec.Type.End()
+						}
+						var valueExpr ast.Expr
+						if len(spec.Values) > 0 {
+							valueExpr = spec.Values[ii]
+							v.ValueDefinition, end = source(valueExpr), valueExpr.End()
+						}
+						v.CellLines = cellLines(name.Pos(), end)
+						memorize(stmt, v, spec.Type, valueExpr)
+					}
+				case *ast.TypeSpec:
+					skip(spec.Name.Name)
+				}
+			}
+		}
+	}
+	return
+}
+
+// useReplVariables changes the definition of mainDecl so that the variables memorized in REPL mode (see
+// replVariables) are used right after they are declared, and warns about the ones that can't be memorized.
+//
+// The edits don't add new lines, so the mapping of the lines of main to the cell lines is preserved.
+func (s *State) useReplVariables(msg kernel.Message, mainDecl *Function) {
+	_, skipped, edits := replVariables(mainDecl)
+	// Apply edits from the end, so the offsets of the previous ones are still valid.
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, edit := range edits {
+		mainDecl.Definition = mainDecl.Definition[:edit.start] + edit.text + mainDecl.Definition[edit.end:]
+	}
+	if len(skipped) > 0 {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf(
+			"warning: `%%repl`: not memorizing %s: variables declared with multiple values (e.g.: `a, b := f()`), "+
+				"or that refer to themselves or to other local declarations can't be memorized\n",
+			strings.Join(skipped, ", ")))
+	}
+}
+
+// memorizeReplVariables memorizes the variables declared at the top level of mainDecl (see replVariables) as
+// package level variables in s.Definitions. Variables whose names are already used by other memorized
+// declarations (functions, types, constants or imports) are not memorized.
+func (s *State) memorizeReplVariables(mainDecl *Function) {
+	vars, _, _ := replVariables(mainDecl)
+	decls := s.Definitions
+	for _, v := range vars {
+		_, found := decls.Functions[v.Name]
+		if !found {
+			_, found = decls.Types[v.Name]
+		}
+		if !found {
+			_, found = decls.Constants[v.Name]
+		}
+		if !found {
+			_, found = decls.Imports[v.Name]
+		}
+		if !found {
+			decls.Variables[v.Key] = v
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestReplVariables(t *testing.T) {
+	mainDecl := &Function{
+		Key: "main",
+		Definition: "func main() {\n\tflag.Parse()\n\tx := 5\n\tvar (\n\t\ty float64 = float64(x) / 2\n\t\tz []int\n\t)\n" +
+			"\ta, err := f()\n\tb := a + 1\n\ttype T struct{}\n\tt := T{}\n\tp := Point{X: x}.X\n\tx2 := x2 + 1\n" +
+			"\tfor i := 0; i < 3; i++ { c := i }\n}",
+		CellLines: CellLines{Id: 3, Lines: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}},
+	}
+	vars, skipped, edits := replVariables(mainDecl)
+	names := make([]string, 0, len(vars))
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	assert.Equal(t, []string{"x", "y", "z", "p"}, names)
+	assert.Equal(t, []string{"a", "err", "b", "T", "t", "x2"}, skipped)
+	assert.Len(t, edits, 4)
+
+	x, y, z := vars[0], vars[1], vars[2]
+	assert.Equal(t, "5", x.ValueDefinition)
+	assert.Equal(t, CellLines{Id: 3, Lines: []int{2}}, x.CellLines)
+	assert.Equal(t, "float64", y.TypeDefinition)
+	assert.Equal(t, "float64(x) / 2", y.ValueDefinition)
+	assert.Equal(t, CellLines{Id: 3, Lines: []int{4}}, y.CellLines)
+	assert.Equal(t, "[]int", z.TypeDefinition)
+	assert.Empty(t, z.ValueDefinition)
+
+	// Variables are used right after they are declared.
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.useReplVariables(nil, mainDecl)
+	assert.Contains(t, mainDecl.Definition, "\tx := 5; _ = x\n")
+	assert.Contains(t, mainDecl.Definition, "\t); _ = z; _ = y\n")
+}
+
+func TestRepl(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.Repl = true
+
+	// Variables declared in the first cell are memorized.
+	_, mainDecl, _, _, err := s.parseLinesAndComposeMain(nil, 1, []string{"%%", "x := 5", "msg := \"hello\""},
+		nil, NoCursor)
+	require.NoError(t, err)
+	s.memorizeReplVariables(mainDecl)
+	require.Contains(t, s.Definitions.Variables, "x")
+	require.Contains(t, s.Definitions.Variables, "msg")
+	assert.Equal(t, "5", s.Definitions.Variables["x"].ValueDefinition)
+
+	// And they are declared in the program of the following cells.
+	updatedDecls, _, _, _, err := s.parseLinesAndComposeMain(nil, 2, []string{"%%", "fmt.Println(msg, x)"},
+		nil, NoCursor)
+	require.NoError(t, err)
+	assert.Contains(t, updatedDecls.Variables, "x")
+	src, err := os.ReadFile(s.CodePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "\tx = 5\n")
+
+	// Names already used by other memorized declarations are not memorized.
+	s.Definitions.Functions["f"] = &Function{Key: "f", Name: "f", Definition: "func f() {}"}
+	s.memorizeReplVariables(&Function{Key: "main", Definition: "func main() {\n\tf := 1\n}"})
+	assert.NotContains(t, s.Definitions.Variables, "f")
+}
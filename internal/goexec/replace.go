@@ -0,0 +1,133 @@
+package goexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/mod/modfile"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// This file implements `%replace` and `%replace_local`, which add `replace` rules to the notebook's `go.mod`.
+
+// ReplaceWithRef adds a `replace` rule to `go.mod`, replacing the module modulePath by target, given as
+// "<module_path>@<ref>", where ref is a version, a branch, a tag or a commit, e.g.: "github.com/fork/mod@main".
+// The ref is first resolved to a version (a pseudo-version for branches and commits), which validates that the
+// target exists, and then `go mod tidy` is executed.
+//
+// It returns the resulting `replace` line in `go.mod`.
+func (s *State) ReplaceWithRef(msg kernel.Message, modulePath, target string) (replaceLine string, err error) {
+	targetPath, ref, found := strings.Cut(target, "@")
+	if !found || targetPath == "" || ref == "" {
+		return "", errors.Errorf("invalid replacement %q, it must be of the form `<module_path>@<ref>`, "+
+			"e.g.: `github.com/fork/mod@main`", target)
+	}
+	if modulePath == "" {
+		return "", errors.New("missing the path of the module to replace")
+	}
+
+	output, err := s.runGoInTempDir("list", "-m", "-f", "{{.Version}}", target)
+	if err != nil {
+		return "", errors.Errorf("can't resolve %q: %s", target, output)
+	}
+	version := strings.TrimSpace(output)
+	output, err = s.runGoInTempDir("mod", "edit", fmt.Sprintf("-replace=%s=%s@%s", modulePath, targetPath, version))
+	if err != nil {
+		return "", errors.Errorf("failed to add replace rule for %q: %s", modulePath, output)
+	}
+	output, err = s.runGoInTempDir("mod", "tidy")
+	if err != nil {
+		// The replace rule is in place, `go mod tidy` may fail because of the program of the last cell.
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("warning: `go mod tidy` failed: %s\n", output))
+	}
+	return s.goModReplaceLine(modulePath)
+}
+
+// ReplaceLocal adds a `replace` rule to `go.mod`, replacing the module modulePath by the local directory dir
+// (e.g.: a checkout of the module being developed), and tracks the directory for `gopls`. Previous `replace`
+// rules for the module are dropped.
+//
+// It returns the absolute path of the directory, and an error if it doesn't hold the module modulePath.
+func (s *State) ReplaceLocal(modulePath, dir string) (absDir string, err error) {
+	absDir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find the absolute path of %q", dir)
+	}
+	if _, err = os.Stat(path.Join(absDir, "go.mod")); err != nil {
+		return "", errors.Errorf("directory %q has no `go.mod` file", absDir)
+	}
+	if module := moduleOfPath(absDir, true); module != modulePath {
+		return "", errors.Errorf("directory %q holds module %q, not %q", absDir, module, modulePath)
+	}
+
+	goModPath := path.Join(s.TempDir, "go.mod")
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	for _, replace := range modFile.Replace {
+		if replace.Old.Path == modulePath {
+			if err = modFile.DropReplace(replace.Old.Path, replace.Old.Version); err != nil {
+				return "", errors.Wrapf(err, "failed to remove previous replace rule for %q", modulePath)
+			}
+		}
+	}
+	if err = modFile.AddReplace(modulePath, "", absDir, ""); err != nil {
+		return "", errors.Wrapf(err, "failed to add replace rule from %q to %q", modulePath, absDir)
+	}
+	modFile.Cleanup()
+	if contents, err = modFile.Format(); err != nil {
+		return "", errors.Wrapf(err, "failed to format the updated `go.mod` file %q", goModPath)
+	}
+	if err = os.WriteFile(goModPath, contents, 0666); err != nil {
+		return "", errors.Wrapf(err, "failed to write the updated `go.mod` file to %q", goModPath)
+	}
+	s.trackDirAndSubdirs(absDir)
+	return absDir, nil
+}
+
+// goModReplaceLine returns the `replace` rule for modulePath in the notebook's `go.mod`, formatted as a line.
+func (s *State) goModReplaceLine(modulePath string) (string, error) {
+	goModPath := path.Join(s.TempDir, "go.mod")
+	contents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.Parse(goModPath, contents, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	for _, replace := range modFile.Replace {
+		if replace.Old.Path != modulePath {
+			continue
+		}
+		line := "replace " + replace.Old.Path
+		if replace.Old.Version != "" {
+			line += " " + replace.Old.Version
+		}
+		line += " => " + replace.New.Path
+		if replace.New.Version != "" {
+			line += " " + replace.New.Version
+		}
+		return line, nil
+	}
+	return "", errors.Errorf("no replace rule for %q found in %q", modulePath, goModPath)
+}
+
+// runGoInTempDir runs the `go` command with the given arguments in the notebook's module, and returns its
+// combined output, trimmed.
+func (s *State) runGoInTempDir(args ...string) (string, error) {
+	cmd := s.GoCommand(args...)
+	cmd.Dir = s.TempDir
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
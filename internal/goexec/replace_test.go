@@ -0,0 +1,86 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestReplaceWithRef(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	for _, target := range []string{"github.com/fork/mod", "github.com/fork/mod@", "@main"} {
+		_, err := s.ReplaceWithRef(nil, "example.com/mod", target)
+		assert.Error(t, err, target)
+	}
+
+	// Targets that can't be resolved are not added to go.mod.
+	goModPath := path.Join(s.TempDir, "go.mod")
+	goModBefore, err := os.ReadFile(goModPath)
+	require.NoError(t, err)
+	_, err = s.ReplaceWithRef(nil, "example.com/mod", "example.invalid/fork@main")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't resolve")
+	goModAfter, err := os.ReadFile(goModPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(goModBefore), string(goModAfter))
+}
+
+func TestGoModReplaceLine(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	_, err := s.runGoInTempDir("mod", "edit", "-replace=example.com/mod=github.com/fork/mod@v0.0.0-20240101000000-abcdefabcdef")
+	require.NoError(t, err)
+	line, err := s.goModReplaceLine("example.com/mod")
+	require.NoError(t, err)
+	assert.Equal(t, "replace example.com/mod => github.com/fork/mod v0.0.0-20240101000000-abcdefabcdef", line)
+
+	_, err = s.goModReplaceLine("example.com/other")
+	assert.Error(t, err)
+}
+
+func TestReplaceLocal(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	modDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(modDir, "go.mod"), []byte("module example.com/local\n\ngo 1.21\n"), 0644))
+	require.NoError(t, os.Mkdir(path.Join(modDir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(modDir, "sub", "sub.go"), []byte("package sub\n"), 0644))
+
+	// Wrong module or no go.mod.
+	_, err := s.ReplaceLocal("example.com/other", modDir)
+	assert.ErrorContains(t, err, `holds module "example.com/local"`)
+	_, err = s.ReplaceLocal("example.com/local", t.TempDir())
+	assert.ErrorContains(t, err, "no `go.mod`")
+
+	dir, err := s.ReplaceLocal("example.com/local", modDir)
+	require.NoError(t, err)
+	assert.Equal(t, modDir, dir)
+	line, err := s.goModReplaceLine("example.com/local")
+	require.NoError(t, err)
+	assert.Equal(t, "replace example.com/local => "+modDir, line)
+	assert.Equal(t, []string{modDir, path.Join(modDir, "sub")}, s.ListTracked())
+
+	// Subdirectories are not the root of the module.
+	_, err = s.ReplaceLocal("example.com/local", path.Join(modDir, "sub"))
+	assert.Error(t, err)
+
+	// Replacing again keeps only one rule.
+	_, err = s.ReplaceLocal("example.com/local", modDir)
+	require.NoError(t, err)
+	goMod, err := os.ReadFile(path.Join(s.TempDir, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(goMod), "example.com/local"))
+}
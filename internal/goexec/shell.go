@@ -0,0 +1,61 @@
+package goexec
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// This file implements the selection of the interpreter of shell commands (lines starting with `!`), see
+// State.Shell. The interpreters available in each platform are defined in shell_others.go and shell_windows.go.
+
+// lookPath is exec.LookPath, replaced in tests to simulate missing interpreters.
+var lookPath = exec.LookPath
+
+// defaultShell returns the interpreter to use by default for shell commands: the first available of the
+// shellCandidates.
+//
+// If none is available it returns DefaultShell, and the error is reported when a shell command is executed,
+// see State.CheckShell.
+func defaultShell() string {
+	candidates := shellCandidates()
+	for ii, candidate := range candidates {
+		shellPath, err := lookPath(candidate)
+		if err != nil {
+			continue
+		}
+		if ii == 0 {
+			return candidate
+		}
+		klog.Warningf("%q not found, using %q to execute shell commands", candidates[0], shellPath)
+		return shellPath
+	}
+	klog.Errorf("None of %q were found: shell commands (lines starting with `!`) will fail", candidates)
+	return DefaultShell
+}
+
+// CheckShell returns an actionable error if the interpreter of shell commands (State.Shell) is not available.
+func (s *State) CheckShell() error {
+	if _, err := lookPath(s.Shell); err != nil {
+		return errors.Errorf("the interpreter of shell commands (lines starting with `!`) %q was not found: "+
+			"install it, or select another one with `%%config shell=<path>` (e.g.: `%%config shell=%s`)",
+			s.Shell, FallbackShell)
+	}
+	return nil
+}
+
+// ShellArgs returns the arguments to execute cmdStr with the interpreter State.Shell: `/C <command>` for
+// `cmd.exe`, `-NoProfile -Command <command>` for PowerShell, and `-c <command>` for the others (bash, sh,
+// Git Bash, zsh, etc.).
+func (s *State) ShellArgs(cmdStr string) []string {
+	name := strings.TrimSuffix(strings.ToLower(filepath.Base(s.Shell)), ".exe")
+	switch name {
+	case "cmd":
+		return []string{"/C", cmdStr}
+	case "powershell", "pwsh":
+		return []string{"-NoProfile", "-Command", cmdStr}
+	}
+	return []string{"-c", cmdStr}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package goexec
+
+const (
+	// DefaultShell is the interpreter used by default to execute shell commands (lines starting with `!`).
+	DefaultShell = "/bin/bash"
+
+	// FallbackShell is the interpreter used to execute shell commands if bash is not available, e.g.: in
+	// minimal container images.
+	FallbackShell = "/bin/sh"
+)
+
+// shellCandidates returns the interpreters to execute shell commands, in order of preference.
+func shellCandidates() []string {
+	return []string{DefaultShell, "bash", FallbackShell}
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package goexec
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"testing"
+)
+
+// withMissingPaths replaces lookPath during the test, such that the given paths are not found.
+func withMissingPaths(t *testing.T, missing ...string) {
+	t.Cleanup(func() { lookPath = exec.LookPath })
+	lookPath = func(file string) (string, error) {
+		for _, m := range missing {
+			if file == m {
+				return "", errors.Errorf("%q not found", file)
+			}
+		}
+		if file[0] != '/' {
+			return "/usr/local/bin/" + file, nil
+		}
+		return file, nil
+	}
+}
+
+func TestDefaultShell(t *testing.T) {
+	withMissingPaths(t)
+	assert.Equal(t, DefaultShell, defaultShell())
+
+	// bash installed elsewhere in the PATH.
+	withMissingPaths(t, DefaultShell)
+	assert.Equal(t, "/usr/local/bin/bash", defaultShell())
+
+	// No bash at all.
+	withMissingPaths(t, DefaultShell, "bash")
+	assert.Equal(t, FallbackShell, defaultShell())
+}
+
+func TestCheckShell(t *testing.T) {
+	withMissingPaths(t, DefaultShell, "bash", FallbackShell)
+	s := &State{Shell: defaultShell()}
+	assert.Equal(t, DefaultShell, s.Shell)
+	err := s.CheckShell()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "%config shell=")
+
+	s.Shell = "/usr/bin/zsh"
+	assert.NoError(t, s.CheckShell())
+}
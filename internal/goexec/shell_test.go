@@ -0,0 +1,21 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShellArgs(t *testing.T) {
+	s := &State{}
+	for shell, want := range map[string][]string{
+		"/bin/bash":      {"-c", "echo hi"},
+		"/bin/sh":        {"-c", "echo hi"},
+		"cmd.exe":        {"/C", "echo hi"},
+		"CMD":            {"/C", "echo hi"},
+		"powershell.exe": {"-NoProfile", "-Command", "echo hi"},
+		"/usr/bin/pwsh":  {"-NoProfile", "-Command", "echo hi"},
+	} {
+		s.Shell = shell
+		assert.Equal(t, want, s.ShellArgs("echo hi"), "shell "+shell)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build windows
+
+package goexec
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultShell is the interpreter used by default to execute shell commands (lines starting with `!`),
+	// if Git Bash is not installed.
+	DefaultShell = "cmd.exe"
+
+	// FallbackShell is the interpreter used to execute shell commands if `cmd.exe` is not available.
+	FallbackShell = "powershell.exe"
+)
+
+// shellCandidates returns the interpreters to execute shell commands, in order of preference: Git Bash (installed
+// with Git for Windows), so the shell commands of notebooks written in Linux or macOS work unchanged, then
+// DefaultShell and FallbackShell.
+func shellCandidates() []string {
+	candidates := []string{DefaultShell, FallbackShell}
+	if gitPath, err := lookPath("git"); err == nil {
+		// Git for Windows installs `git.exe` in `<install dir>\cmd`, and Git Bash in `<install dir>\bin\bash.exe`.
+		bashPath := filepath.Join(filepath.Dir(filepath.Dir(gitPath)), "bin", "bash.exe")
+		if _, err := os.Stat(bashPath); err == nil {
+			candidates = append([]string{bashPath}, candidates...)
+		}
+	}
+	return candidates
+}
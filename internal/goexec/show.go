@@ -0,0 +1,126 @@
+package goexec
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/format"
+	"go/scanner"
+	"go/token"
+	"html"
+	"strings"
+)
+
+// This file implements the display of the generated Go program, used by `%show`, and of the memorized
+// declarations, used by `%cat`.
+
+// goSyntaxColors are the colors used to highlight the Go tokens.
+var goSyntaxColors = map[string]string{
+	"keyword": "#AA22FF",
+	"string":  "#BA2121",
+	"number":  "#008800",
+	"comment": "#408080",
+}
+
+// publishProgram publishes the Go program generated for the current cell (`main.go` or `main_test.go`),
+// with syntax highlighting, instead of compiling and executing it.
+func (s *State) publishProgram(msg kernel.Message) error {
+	src, err := s.readMainGo()
+	if err != nil {
+		return err
+	}
+	return kernel.PublishHtml(msg, fmt.Sprintf(
+		"<b>%s</b>\n<pre style=\"margin: 0\">%s</pre>\n", html.EscapeString(s.CodePath()), HighlightGo(src)))
+}
+
+// HighlightGo converts the Go source code to HTML, highlighting keywords, literals and comments.
+// The returned HTML is meant to be used inside a `<pre>` element.
+func HighlightGo(src string) string {
+	var sb strings.Builder
+	fileSet := token.NewFileSet()
+	file := fileSet.AddFile("", fileSet.Base(), len(src))
+	var sc scanner.Scanner
+	sc.Init(file, []byte(src), nil, scanner.ScanComments)
+	lastOffset := 0
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// Automatically inserted semicolon.
+			continue
+		}
+		var class string
+		switch {
+		case tok.IsKeyword():
+			class = "keyword"
+		case tok == token.STRING || tok == token.CHAR:
+			class = "string"
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			class = "number"
+		case tok == token.COMMENT:
+			class = "comment"
+		default:
+			continue
+		}
+		offset := file.Offset(pos)
+		text := lit
+		if tok.IsKeyword() {
+			text = tok.String()
+		}
+		sb.WriteString(html.EscapeString(src[lastOffset:offset]))
+		sb.WriteString(fmt.Sprintf(`<span style="color: %s">%s</span>`, goSyntaxColors[class], html.EscapeString(text)))
+		lastOffset = offset + len(text)
+	}
+	sb.WriteString(html.EscapeString(src[lastOffset:]))
+	return sb.String()
+}
+
+// DeclarationSource returns the Go source of the memorized declaration name, as rendered in the programs of the
+// cells: a function, a method (e.g.: "MyType.MyMethod"), a type (along with its methods), a variable, a constant
+// (along with the block it belongs to) or an import. If name is empty, it returns all memorized declarations.
+//
+// The source is formatted with `gofmt`, if possible.
+func (s *State) DeclarationSource(name string) (string, error) {
+	decls := s.Definitions
+	if name != "" {
+		decls = NewDeclarations()
+		key := strings.Replace(name, ".", "~", 1)
+		if f, found := s.Definitions.Functions[key]; found {
+			decls.Functions[key] = f
+		}
+		if t, found := s.Definitions.Types[key]; found {
+			decls.Types[key] = t
+			for _, methodKey := range s.Definitions.Methods(key) {
+				decls.Functions[methodKey] = s.Definitions.Functions[methodKey]
+			}
+		}
+		if v, found := s.Definitions.Variables[key]; found {
+			decls.Variables[key] = v
+		}
+		if c, found := s.Definitions.Constants[key]; found {
+			// Constants are rendered from the head of their block.
+			for c.Prev != nil {
+				c = c.Prev
+			}
+			decls.Constants[c.Key] = c
+		}
+		if i, found := s.Definitions.Imports[key]; found {
+			decls.Imports[key] = i
+		}
+		if len(decls.Functions)+len(decls.Types)+len(decls.Variables)+len(decls.Constants)+len(decls.Imports) == 0 {
+			return "", errors.Errorf("%q is not memorized, see `%%ls` for the memorized declarations", name)
+		}
+	}
+	var buf bytes.Buffer
+	if _, _, err := s.createCodeFromDecls(&buf, decls, nil); err != nil {
+		return "", err
+	}
+	src := buf.Bytes()
+	if formatted, err := format.Source(src); err == nil {
+		src = formatted
+	}
+	return strings.TrimPrefix(string(src), "package main\n\n"), nil
+}
@@ -0,0 +1,63 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHighlightGo(t *testing.T) {
+	got := HighlightGo("package main\n\n// Hi <there>\nvar x = \"a<b\" + 1\n")
+	assert.Equal(t,
+		`<span style="color: #AA22FF">package</span> main`+"\n\n"+
+			`<span style="color: #408080">// Hi &lt;there&gt;</span>`+"\n"+
+			`<span style="color: #AA22FF">var</span> x = <span style="color: #BA2121">&#34;a&lt;b&#34;</span> + `+
+			`<span style="color: #008800">1</span>`+"\n",
+		got)
+}
+
+func TestDeclarationSource(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	decls := s.Definitions
+	decls.Types["Point"] = &TypeDecl{Key: "Point", TypeDefinition: "Point struct{ X, Y int }"}
+	decls.Functions["Point~Norm"] = &Function{Key: "Point~Norm",
+		Definition: "func (p *Point) Norm() int { return p.X*p.X + p.Y*p.Y }"}
+	decls.Functions["origin"] = &Function{Key: "origin", Definition: "func origin() Point { return Point{} }"}
+	first := &Constant{Key: "A", ValueDefinition: "iota"}
+	second := &Constant{Key: "B", Prev: first}
+	first.Next = second
+	decls.Constants["A"], decls.Constants["B"] = first, second
+
+	src, err := s.DeclarationSource("origin")
+	require.NoError(t, err)
+	assert.Equal(t, "func origin() Point { return Point{} }\n", src)
+
+	// Types include their methods.
+	src, err = s.DeclarationSource("Point")
+	require.NoError(t, err)
+	assert.Contains(t, src, "type Point struct{ X, Y int }")
+	assert.Contains(t, src, "func (p *Point) Norm() int")
+	assert.NotContains(t, src, "origin")
+
+	src, err = s.DeclarationSource("Point.Norm")
+	require.NoError(t, err)
+	assert.NotContains(t, src, "type Point")
+	assert.Contains(t, src, "func (p *Point) Norm() int")
+
+	// Constants are displayed with their block.
+	src, err = s.DeclarationSource("B")
+	require.NoError(t, err)
+	assert.Equal(t, "const (\n\tA = iota\n\tB\n)\n", src)
+
+	// All declarations.
+	src, err = s.DeclarationSource("")
+	require.NoError(t, err)
+	assert.Contains(t, src, "func origin() Point")
+	assert.Contains(t, src, "type Point struct")
+
+	_, err = s.DeclarationSource("unknown")
+	assert.Error(t, err)
+}
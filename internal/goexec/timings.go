@@ -0,0 +1,22 @@
+package goexec
+
+import (
+	"time"
+)
+
+// This file implements the recording of the time spent in each phase of the kernel startup and of the
+// execution of the cells, see `%profile_startup`.
+
+// Timing of one phase of the kernel startup or of a cell execution.
+type Timing struct {
+	Phase    string
+	Duration time.Duration
+}
+
+// timePhase appends to timings the time elapsed since start for the given phase, and returns the current
+// time, to be used as the start of the next phase.
+func timePhase(timings *[]Timing, phase string, start time.Time) time.Time {
+	now := time.Now()
+	*timings = append(*timings, Timing{Phase: phase, Duration: now.Sub(start)})
+	return now
+}
@@ -0,0 +1,276 @@
+package goexec
+
+import (
+	"encoding/json"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"golang.org/x/mod/modfile"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// This file handles the selection of the Go toolchain used to compile the cells.
+
+// DefaultGoBinary is the `go` command used by default, searched in the PATH.
+const DefaultGoBinary = "go"
+
+// GoCommand returns an exec.Cmd that runs the selected `go` toolchain (see SetGoRoot and SetGoVersion)
+// with the given arguments.
+func (s *State) GoCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command(s.goBinary, args...)
+	if s.goRootOverride != "" {
+		cmd.Env = append(
+			slices.DeleteFunc(cmd.Environ(), func(s string) bool {
+				return strings.HasPrefix(s, "GOROOT=")
+			}),
+			"GOROOT="+s.goRootOverride,
+		)
+	}
+	return cmd
+}
+
+// GoBinary returns the `go` command currently used to compile the cells.
+func (s *State) GoBinary() string {
+	return s.goBinary
+}
+
+// GoVersion returns the output of `go version` for the selected toolchain.
+//
+// It runs in the notebook's temporary directory, where the cells are compiled, since the toolchain used
+// may depend on the `go.mod` file (see GOTOOLCHAIN).
+func (s *State) GoVersion() (string, error) {
+	cmd := s.GoCommand("version")
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %q: %s", cmd, output)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GoRoot returns the GOROOT of the selected toolchain.
+func (s *State) GoRoot() (string, error) {
+	cmd := s.GoCommand("env", "GOROOT")
+	cmd.Dir = s.TempDir
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find GOROOT")
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// GoEnv returns the values of the given `go env` variables (e.g.: "GOROOT", "GOPATH") for the selected toolchain.
+func (s *State) GoEnv(names ...string) (map[string]string, error) {
+	cmd := s.GoCommand(append([]string{"env", "-json"}, names...)...)
+	cmd.Dir = s.TempDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to run %q", cmd)
+	}
+	values := make(map[string]string, len(names))
+	if err = json.Unmarshal(output, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse output of %q", cmd)
+	}
+	return values, nil
+}
+
+// GoEnvWrite persists the `go env` variable key with the given value (`go env -w`), for the selected
+// toolchain, so it applies to future `go` commands, also after the kernel restarts. If value is empty,
+// the persisted value is removed instead (`go env -u`).
+//
+// It returns an error if key is not a variable known by `go env`.
+func (s *State) GoEnvWrite(key, value string) error {
+	known, err := s.GoEnv()
+	if err != nil {
+		return err
+	}
+	if _, found := known[key]; !found {
+		return errors.Errorf("%q is not a variable known by `go env`, use one of %q", key, common.SortedKeys(known))
+	}
+	args := []string{"env", "-u", key}
+	if value != "" {
+		args = []string{"env", "-w", key + "=" + value}
+	}
+	cmd := s.GoCommand(args...)
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "failed to run %q: %s", cmd, output)
+	}
+	return nil
+}
+
+// GoEnvFile returns the path to the file where `go env -w` persists the variables (GOENV) for the
+// selected toolchain, and its contents. The contents are empty if the file doesn't exist.
+func (s *State) GoEnvFile() (filePath, contents string, err error) {
+	env, err := s.GoEnv("GOENV")
+	if err != nil {
+		return "", "", err
+	}
+	filePath = env["GOENV"]
+	contentsBytes, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", errors.Wrapf(err, "failed to read %q", filePath)
+	}
+	return filePath, string(contentsBytes), nil
+}
+
+// GoModGoDirective returns the version in the `go` directive of the notebook's `go.mod` file, or
+// an empty string if it is not set.
+func (s *State) GoModGoDirective() (string, error) {
+	goModPath := path.Join(s.TempDir, "go.mod")
+	goModContents, err := os.ReadFile(goModPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read %q", goModPath)
+	}
+	modFile, err := modfile.ParseLax(goModPath, goModContents, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse %q", goModPath)
+	}
+	if modFile.Go == nil {
+		return "", nil
+	}
+	return modFile.Go.Version, nil
+}
+
+// SetGoRoot selects the Go toolchain installed in goRoot (that is, `<goRoot>/bin/go`) to compile the
+// cells from now on. If goRoot is empty, it reverts to the default toolchain.
+//
+// It returns an error, and keeps the current toolchain, if the toolchain is not valid.
+func (s *State) SetGoRoot(goRoot string) error {
+	if goRoot == "" {
+		return s.setGoToolchain(DefaultGoBinary, "")
+	}
+	goRoot = common.ReplaceTildeInDir(goRoot)
+	goBinary := path.Join(goRoot, "bin", "go")
+	if _, err := os.Stat(goBinary); err != nil {
+		return errors.Wrapf(err, "can't find Go toolchain in GOROOT %q", goRoot)
+	}
+	return s.setGoToolchain(goBinary, goRoot)
+}
+
+// SetGoVersion selects the Go toolchain for the given version (e.g.: "1.21.5" or "go1.21.5") to compile
+// the cells from now on. It uses the `go<version>` command found in the PATH, as installed by the
+// golang.org/dl download shims (e.g.: `go install golang.org/dl/go1.21.5@latest && go1.21.5 download`).
+// If version is empty, it reverts to the default toolchain.
+//
+// It returns an error, and keeps the current toolchain, if the toolchain is not valid.
+func (s *State) SetGoVersion(version string) error {
+	if version == "" {
+		return s.setGoToolchain(DefaultGoBinary, "")
+	}
+	if !strings.HasPrefix(version, "go") {
+		version = "go" + version
+	}
+	goBinary, err := exec.LookPath(version)
+	if err != nil {
+		return errors.Wrapf(err, "can't find %q in PATH, install it with `go install golang.org/dl/%s@latest && %s download`",
+			version, version, version)
+	}
+	return s.setGoToolchain(goBinary, "")
+}
+
+// setGoToolchain sets the toolchain, and checks that it works by running `go version`.
+// If it fails, it reverts to the previous toolchain.
+func (s *State) setGoToolchain(goBinary, goRootOverride string) error {
+	previousBinary, previousGoRoot := s.goBinary, s.goRootOverride
+	s.goBinary, s.goRootOverride = goBinary, goRootOverride
+	if _, err := s.GoVersion(); err != nil {
+		s.goBinary, s.goRootOverride = previousBinary, previousGoRoot
+		return errors.WithMessagef(err, "invalid Go toolchain %q", goBinary)
+	}
+	return nil
+}
+
+// KnownTools maps the names of common Go tools to the package installed by InstallTool.
+var KnownTools = map[string]string{
+	"dlv":           "github.com/go-delve/delve/cmd/dlv",
+	"goimports":     "golang.org/x/tools/cmd/goimports",
+	"golangci-lint": "github.com/golangci/golangci-lint/cmd/golangci-lint",
+	"gopls":         "golang.org/x/tools/gopls",
+	"govulncheck":   "golang.org/x/vuln/cmd/govulncheck",
+	"staticcheck":   "honnef.co/go/tools/cmd/staticcheck",
+}
+
+// reMajorVersionElem matches the major version suffix of module paths (e.g.: "v2"), which is not used as the name
+// of the binary installed by `go install`.
+var reMajorVersionElem = regexp.MustCompile(`^v[0-9]+$`)
+
+// ToolPackage returns the name of the binary and the package (with version) to `go install` for
+// the given tool: either one of the KnownTools, or a package path. An optional version can be
+// given with "@<version>" (e.g.: "dlv@v1.22.1"), and it defaults to "@latest".
+func ToolPackage(tool string) (name, pkg string, err error) {
+	pkg, version, found := strings.Cut(tool, "@")
+	if !found || version == "" {
+		version = "latest"
+	}
+	if strings.Contains(pkg, "/") {
+		dir, elem := path.Split(pkg)
+		if reMajorVersionElem.MatchString(elem) && dir != "" {
+			// As `go install`: e.g. "golang.org/x/tools/gopls/v2" installs "gopls".
+			elem = path.Base(dir)
+		}
+		name = elem
+	} else {
+		name = pkg
+		var known bool
+		pkg, known = KnownTools[name]
+		if !known {
+			return "", "", errors.Errorf("unknown tool %q, use one of %q or the full package path", name, common.SortedKeys(KnownTools))
+		}
+	}
+	return name, pkg + "@" + version, nil
+}
+
+// InstallTool runs `go install` for the given tool (see ToolPackage) with the selected toolchain,
+// and returns the path to the installed binary. The path is cached, see ToolPath.
+//
+// If the tool is `gopls`, and it was not available yet, it is started (see StartGopls).
+func (s *State) InstallTool(tool string) (string, error) {
+	name, pkg, err := ToolPackage(tool)
+	if err != nil {
+		return "", err
+	}
+	cmd := s.GoCommand("install", pkg)
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run %q: %s", cmd, output)
+	}
+	env, err := s.GoEnv("GOBIN", "GOPATH", "GOEXE")
+	if err != nil {
+		return "", err
+	}
+	binDir := env["GOBIN"]
+	if binDir == "" {
+		goPath, _, _ := strings.Cut(env["GOPATH"], string(os.PathListSeparator))
+		binDir = path.Join(goPath, "bin")
+	}
+	binPath := path.Join(binDir, name+env["GOEXE"])
+	if _, err = os.Stat(binPath); err != nil {
+		return "", errors.Wrapf(err, "installed %q, but can't find the binary", pkg)
+	}
+	s.toolPaths[name] = binPath
+	if name == "gopls" {
+		s.StartGopls()
+	}
+	return binPath, nil
+}
+
+// ToolPath returns the path to the binary of the given tool: either installed by InstallTool, or
+// found in the PATH. It returns false if it is not found.
+func (s *State) ToolPath(name string) (string, bool) {
+	if binPath, found := s.toolPaths[name]; found {
+		return binPath, true
+	}
+	binPath, err := exec.LookPath(name)
+	if err != nil {
+		return "", false
+	}
+	return binPath, true
+}
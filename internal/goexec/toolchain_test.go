@@ -0,0 +1,106 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+	"path"
+	"testing"
+)
+
+func TestGoToolchain(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	assert.Equal(t, DefaultGoBinary, s.GoBinary())
+	version, err := s.GoVersion()
+	require.NoError(t, err)
+	assert.Contains(t, version, "go version")
+
+	// Select explicitly the current GOROOT.
+	goRoot, err := s.GoRoot()
+	require.NoError(t, err)
+	require.NoError(t, s.SetGoRoot(goRoot))
+	assert.Equal(t, path.Join(goRoot, "bin", "go"), s.GoBinary())
+	assert.True(t, slices.Contains(s.GoCommand("version").Env, "GOROOT="+goRoot))
+
+	// Invalid toolchains keep the current one.
+	assert.Error(t, s.SetGoRoot(path.Join(s.TempDir, "no_such_goroot")))
+	assert.Error(t, s.SetGoVersion("0.0.0"))
+	assert.Equal(t, path.Join(goRoot, "bin", "go"), s.GoBinary())
+
+	// Revert to default.
+	require.NoError(t, s.SetGoVersion(""))
+	assert.Equal(t, DefaultGoBinary, s.GoBinary())
+	assert.Nil(t, s.GoCommand("version").Env)
+}
+
+func TestGoEnvAndGoDirective(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+	env, err := s.GoEnv("GOROOT", "GOPATH")
+	require.NoError(t, err)
+	goRoot, err := s.GoRoot()
+	require.NoError(t, err)
+	assert.Equal(t, goRoot, env["GOROOT"])
+	assert.Contains(t, env, "GOPATH")
+
+	goDirective, err := s.GoModGoDirective()
+	require.NoError(t, err)
+	assert.NotEmpty(t, goDirective, "`go mod init` should have set the go directive")
+}
+
+func TestToolPackage(t *testing.T) {
+	name, pkg, err := ToolPackage("dlv")
+	require.NoError(t, err)
+	assert.Equal(t, "dlv", name)
+	assert.Equal(t, "github.com/go-delve/delve/cmd/dlv@latest", pkg)
+
+	name, pkg, err = ToolPackage("staticcheck@2023.1.7")
+	require.NoError(t, err)
+	assert.Equal(t, "staticcheck", name)
+	assert.Equal(t, "honnef.co/go/tools/cmd/staticcheck@2023.1.7", pkg)
+
+	name, pkg, err = ToolPackage("example.com/tools/cmd/mytool")
+	require.NoError(t, err)
+	assert.Equal(t, "mytool", name)
+	assert.Equal(t, "example.com/tools/cmd/mytool@latest", pkg)
+
+	// Major version suffixes are not part of the name of the binary.
+	name, pkg, err = ToolPackage("golang.org/x/tools/gopls/v2@v2.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "gopls", name)
+	assert.Equal(t, "golang.org/x/tools/gopls/v2@v2.0.1", pkg)
+
+	_, _, err = ToolPackage("unknown")
+	assert.Error(t, err)
+}
+
+func TestGoEnvWrite(t *testing.T) {
+	goEnvPath := path.Join(t.TempDir(), "go", "env")
+	t.Setenv("GOENV", goEnvPath)
+	t.Setenv("GOPRIVATE", "")
+	s := newEmptyState(t)
+	defer func() {
+		err := s.Stop()
+		require.NoError(t, err, "Failed to finalized state")
+	}()
+
+	require.NoError(t, s.GoEnvWrite("GOPRIVATE", "example.com/private"))
+	filePath, contents, err := s.GoEnvFile()
+	require.NoError(t, err)
+	assert.Equal(t, goEnvPath, filePath)
+	assert.Contains(t, contents, "GOPRIVATE=example.com/private")
+
+	require.NoError(t, s.GoEnvWrite("GOPRIVATE", ""))
+	_, contents, err = s.GoEnvFile()
+	require.NoError(t, err)
+	assert.NotContains(t, contents, "GOPRIVATE")
+
+	assert.Error(t, s.GoEnvWrite("NOT_A_GO_VARIABLE", "1"))
+}
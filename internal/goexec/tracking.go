@@ -1,6 +1,7 @@
 package goexec
 
 import (
+	"context"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/janpfeifer/gonb/common"
@@ -44,6 +45,9 @@ type trackEntry struct {
 	IsDir          bool
 	UpdatedModTime time.Time
 	resolvedName   string // Final file name, after resolving symbolic links.
+
+	// isDependency is set for the source of modules tracked with TrackModule, usually in the module cache.
+	isDependency bool
 }
 
 func newTrackingInfo() *trackingInfo {
@@ -177,6 +181,46 @@ func (s *State) lockedTrack(root, fileOrDirPath string, visited common.Set[strin
 	return
 }
 
+// ModuleDir returns the directory with the source of the module modulePath (e.g.: "github.com/pkg/errors"),
+// as used by the notebook program: usually in the module cache, or the local directory of a `replace` rule.
+//
+// The module must be a dependency of the notebook (e.g.: after `go get` or running a cell that imports it).
+func (s *State) ModuleDir(modulePath string) (string, error) {
+	cmd := s.GoCommand("list", "-m", "-f", "{{.Dir}}", modulePath)
+	cmd.Dir = s.TempDir
+	klog.V(2).Infof("Executing %s", cmd)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Errorf("can't find module %q, is it a dependency of the notebook? `go list -m` failed: %s",
+			modulePath, strings.TrimSpace(string(output)))
+	}
+	dir := strings.TrimSpace(string(output))
+	if dir == "" {
+		return "", errors.Errorf("the source of module %q is not downloaded, use `!*go mod download %s`",
+			modulePath, modulePath)
+	}
+	return dir, nil
+}
+
+// TrackModule tracks the source of the module modulePath (see ModuleDir), so `gopls` can provide contextual
+// help and auto-complete for it. It returns the directory tracked.
+func (s *State) TrackModule(modulePath string) (dir string, err error) {
+	dir, err = s.ModuleDir(modulePath)
+	if err != nil {
+		return "", err
+	}
+	if err = s.Track(dir); err != nil {
+		return "", err
+	}
+	ti := s.trackingInfo
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	if entry, found := ti.tracked[dir]; found {
+		entry.isDependency = true
+	}
+	return dir, nil
+}
+
 // Untrack removes file or dir from path of tracked files. If it ends with "...", it un-tracks
 // anything that has fileOrDirPath as prefix. If you set `fileOrDirPath == "..."`, it will
 // un-tracks everything.
@@ -204,6 +248,36 @@ func (s *State) Untrack(fileOrDirPath string) (err error) {
 	return
 }
 
+// UntrackAll removes all files and directories from the list of tracked files, and returns how many were
+// removed. `gopls` is notified that the files sent to it from the untracked paths are closed, so it goes
+// back to reading them from disk.
+//
+// Directories still in `replace` rules of `go.mod` or `use` rules of `go.work` are tracked again
+// automatically (see AutoTrack).
+func (s *State) UntrackAll() (numUntracked int, err error) {
+	ti := s.trackingInfo
+	ti.mu.Lock()
+	var untracked []string
+	for _, p := range common.SortedKeys(ti.tracked) {
+		untracked = append(untracked, p, ti.tracked[p].resolvedName)
+		err = s.lockedUntrackEntry(p)
+		if err != nil {
+			ti.mu.Unlock()
+			return
+		}
+		numUntracked++
+	}
+	// Drop pending updates of the untracked files, and re-parse go.mod and go.work in the next AutoTrack.
+	ti.updated = common.MakeSet[string]()
+	ti.goModModTime, ti.goWorkModTime = time.Time{}, time.Time{}
+	ti.mu.Unlock()
+
+	if s.gopls != nil && len(untracked) > 0 {
+		_, err = s.gopls.NotifyDidCloseUnder(context.Background(), untracked)
+	}
+	return
+}
+
 func (s *State) lockedUntrackEntry(fileOrDirPath string) (err error) {
 	ti := s.trackingInfo
 	entry, found := ti.tracked[fileOrDirPath]
@@ -240,6 +314,65 @@ func (s *State) ListTracked() []string {
 	return common.SortedKeys(s.trackingInfo.tracked)
 }
 
+// TrackedStatus describes a tracked file or directory, see ListTrackedStatus.
+type TrackedStatus struct {
+	// Path tracked, as given to Track.
+	Path  string
+	IsDir bool
+
+	// Module is the path of the module Path belongs to, from the nearest `go.mod` file, or empty if there is none.
+	Module string
+
+	// NumLoaded is the number of files under Path sent to `gopls`, or -1 if `gopls` is not available.
+	// Files are sent with the next contextual help or auto-complete request after they change.
+	NumLoaded int
+}
+
+// ListTrackedStatus returns the status of each tracked file or directory, sorted by path.
+func (s *State) ListTrackedStatus() []TrackedStatus {
+	ti := s.trackingInfo
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	statuses := make([]TrackedStatus, 0, len(ti.tracked))
+	for _, p := range common.SortedKeys(ti.tracked) {
+		entry := ti.tracked[p]
+		status := TrackedStatus{
+			Path:      p,
+			IsDir:     entry.IsDir,
+			Module:    moduleOfPath(entry.resolvedName, entry.IsDir),
+			NumLoaded: -1,
+		}
+		if s.gopls != nil {
+			status.NumLoaded = s.gopls.NumOpenedUnder(entry.resolvedName)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// moduleOfPath returns the name of the module the file or directory belongs to, from the nearest `go.mod` file
+// in it or its parent directories. It returns empty if none is found.
+func moduleOfPath(fileOrDirPath string, isDir bool) string {
+	dir := fileOrDirPath
+	if !isDir {
+		dir = path.Dir(dir)
+	}
+	for {
+		goModPath := path.Join(dir, "go.mod")
+		if contents, err := os.ReadFile(goModPath); err == nil {
+			if modFile, err := modfile.ParseLax(goModPath, contents, nil); err == nil && modFile.Module != nil {
+				return modFile.Module.Mod.Path
+			}
+			return ""
+		}
+		parent := path.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
 // isGoRelated checks whether a file is Go related.
 func isGoRelated(fileOrDirPath string) bool {
 	base := path.Base(fileOrDirPath)
@@ -326,30 +459,37 @@ func (s *State) autoTrackGoMod() (err error) {
 			continue
 		}
 		klog.V(2).Infof("- go.mod new replace: %s", replaceTarget)
-		err = s.Track(replaceTarget)
+		s.trackDirAndSubdirs(replaceTarget)
+	}
+	return
+}
 
-		// Because fsnotify doesn't support recursion in watching for changes in subdirectories,
-		// we need to add each subdirectory under the one defined.
-		err = common.WalkDirWithSymbolicLinks(replaceTarget, func(entryPath string, info fs.DirEntry, err error) error {
-			// Visit function for each file in the directory:
-			if err != nil {
-				return errors.Wrapf(err, "failed to auto-track file under directory %q", replaceTarget)
-			}
-			if !isGoRelated(entryPath) {
-				return nil
-			}
+// trackDirAndSubdirs tracks the directory dir, and each of its subdirectories with Go files. Errors are
+// logged and otherwise ignored, since it is used for automatic tracking.
+func (s *State) trackDirAndSubdirs(dir string) {
+	err := s.Track(dir)
+	if err != nil {
+		klog.Errorf("Failed to auto-track %q: %+v", dir, err)
+	}
 
-			// Only track directories that have go files. Notice repeated tracked directories
-			// are quickly ignored.
-			dir := path.Dir(entryPath)
-			return s.Track(dir)
-		})
+	// Because fsnotify doesn't support recursion in watching for changes in subdirectories,
+	// we need to add each subdirectory under the one defined.
+	err = common.WalkDirWithSymbolicLinks(dir, func(entryPath string, info fs.DirEntry, err error) error {
+		// Visit function for each file in the directory:
 		if err != nil {
-			klog.Errorf("Failed to auto-track subdirectories of %q: %+v", replaceTarget, err)
-			err = nil
+			return errors.Wrapf(err, "failed to auto-track file under directory %q", dir)
+		}
+		if !isGoRelated(entryPath) {
+			return nil
 		}
+
+		// Only track directories that have go files. Notice repeated tracked directories
+		// are quickly ignored.
+		return s.Track(path.Dir(entryPath))
+	})
+	if err != nil {
+		klog.Errorf("Failed to auto-track subdirectories of %q: %+v", dir, err)
 	}
-	return
 }
 
 var (
@@ -411,31 +551,81 @@ func (s *State) autoTrackGoWork() (err error) {
 			continue
 		}
 		klog.V(2).Infof("- go.work new replace: %s", p)
-		err = s.Track(p)
+		s.trackDirAndSubdirs(p)
+	}
+	klog.V(2).Infof("autoTrackGoWork(): go.work re-parsed, %d tracked files in total", len(ti.tracked))
+	return
+}
 
-		// Because fsnotify doesn't support recursion in watching for changes in subdirectories,
-		// we need to add each subdirectory under the one defined.
-		err = common.WalkDirWithSymbolicLinks(p, func(entryPath string, info fs.DirEntry, err error) error {
-			// Visit function for each file in the directory:
-			if err != nil {
-				return errors.Wrapf(err, "failed to auto-track file under directory %q", p)
-			}
-			if !isGoRelated(entryPath) {
-				return nil
-			}
+// goWorkAutoUse adds `use` rules to `go.work` for the tracked directories that are the root of a Go module
+// (they have a `go.mod`), and are not yet used, reporting the ones added. See State.GoWorkAutoUse.
+//
+// Directories in `replace` rules of `go.mod` and the source of dependencies (see TrackModule) are not added.
+// It is a no-op if there is no `go.work`.
+func (s *State) goWorkAutoUse(msg kernel.Message) (err error) {
+	if !s.GoWorkAutoUse || !s.hasGoWork {
+		return
+	}
+	goWorkPath := path.Join(s.TempDir, "go.work")
+	contents, err := os.ReadFile(goWorkPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %q", goWorkPath)
+	}
+	workFile, err := modfile.ParseWork(goWorkPath, contents, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q", goWorkPath)
+	}
+	used := common.MakeSet[string]()
+	for _, useRule := range workFile.Use {
+		p := useRule.Path
+		if !path.IsAbs(p) {
+			p = path.Join(s.TempDir, p)
+		}
+		used.Insert(path.Clean(p))
+	}
+	replaced := common.MakeSet[string]()
+	if goModContents, err := os.ReadFile(path.Join(s.TempDir, "go.mod")); err == nil {
+		for _, match := range regexpGoModReplace.FindAllSubmatch(goModContents, -1) {
+			replaced.Insert(path.Clean(strings.TrimSpace(string(match[1]))))
+		}
+	}
 
-			// Only track directories that have go files. Notice repeated tracked directories
-			// are quickly ignored.
-			dir := path.Dir(entryPath)
-			return s.Track(dir)
-		})
-		if err != nil {
-			klog.Errorf("Failed to auto-track subdirectories of %q: %+v", p, err)
-			err = nil
+	type moduleDir struct{ dir, module string }
+	var toUse []moduleDir
+	ti := s.trackingInfo
+	ti.mu.Lock()
+	for _, p := range common.SortedKeys(ti.tracked) {
+		entry := ti.tracked[p]
+		dir := path.Clean(entry.resolvedName)
+		if !entry.IsDir || entry.isDependency || dir == path.Clean(s.TempDir) || used.Has(dir) || replaced.Has(dir) {
+			continue
+		}
+		if _, err := os.Stat(path.Join(dir, "go.mod")); err != nil {
+			continue
 		}
+		used.Insert(dir)
+		toUse = append(toUse, moduleDir{dir, moduleOfPath(dir, true)})
 	}
-	klog.V(2).Infof("autoTrackGoWork(): go.work re-parsed, %d tracked files in total", len(ti.tracked))
-	return
+	ti.mu.Unlock()
+	if len(toUse) == 0 {
+		return
+	}
+
+	for _, use := range toUse {
+		if err = workFile.AddUse(use.dir, use.module); err != nil {
+			return errors.Wrapf(err, "failed to add `use %s` to %q", use.dir, goWorkPath)
+		}
+	}
+	workFile.Cleanup()
+	if err = os.WriteFile(goWorkPath, modfile.Format(workFile.Syntax), 0666); err != nil {
+		return errors.Wrapf(err, "failed to write %q", goWorkPath)
+	}
+	var sb strings.Builder
+	for _, use := range toUse {
+		sb.WriteString(fmt.Sprintf("\t- Added `use` rule to `go.work` for tracked module %q in %q.\n", use.module, use.dir))
+	}
+	sb.WriteString("\t  (Disable it with `%config gowork_auto_use=off`)\n")
+	return kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
 }
 
 // findGoWorkModules will go over each of the known `go.work` "use" clauses, and find the
@@ -480,7 +670,9 @@ func (s *State) findGoWorkModules() (modToPath map[string]string, err error) {
 
 // GoWorkFix takes all modules in `go.work` "use" clauses, and add them as "replace" clauses in
 // `go.mod`. This is needed for `go get` to work.
-func (s *State) GoWorkFix(msg kernel.Message) (err error) {
+//
+// If dryRun, it only reports the changes it would make, without applying them.
+func (s *State) GoWorkFix(msg kernel.Message, dryRun bool) (err error) {
 	err = s.AutoTrack()
 	if err != nil {
 		return
@@ -515,7 +707,14 @@ func (s *State) GoWorkFix(msg kernel.Message) (err error) {
 
 	// Add missing replace rules.
 	var goModModified bool
-	for mod, p := range modToPath {
+	action := func(done, planned string) string {
+		if dryRun {
+			return planned
+		}
+		return done
+	}
+	for _, mod := range common.SortedKeys(modToPath) {
+		p := modToPath[mod]
 		if replace, found := replaceRules[mod]; found {
 			if replace.New.Path == p {
 				// The correct "replace" rule already exists.
@@ -531,8 +730,8 @@ func (s *State) GoWorkFix(msg kernel.Message) (err error) {
 			// Update previous "replace" rule.
 			err = kernel.PublishWriteStream(msg, kernel.StreamStderr,
 				fmt.Sprintf(
-					"\t- WARNING: replace rule for module %q mapping to %q, updated to `go.work` location %q\n",
-					mod, replace.New.Path, p))
+					"\t- WARNING: replace rule for module %q mapping to %q, %s to `go.work` location %q\n",
+					mod, replace.New.Path, action("updated", "would be updated"), p))
 			if err != nil {
 				return
 			}
@@ -543,31 +742,36 @@ func (s *State) GoWorkFix(msg kernel.Message) (err error) {
 			}
 		} else {
 			err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
-				fmt.Sprintf("\t- Added replace rule for module %q to local directory %q.\n",
-					mod, p))
+				fmt.Sprintf("\t- %s replace rule for module %q to local directory %q.\n",
+					action("Added", "Would add"), mod, p))
+			if err != nil {
+				return
+			}
 		}
 		err = modFile.AddReplace(mod, "", p, "")
 		if err != nil {
 			err = errors.Wrapf(err, "failed to add replace rule from %q to %q", mod, p)
 			return
 		}
-		if err != nil {
-			return
-		}
 		goModModified = true
 	}
-	if goModModified {
-		// Update go.mod file.
-		goModContents, err = modFile.Format()
-		if err != nil {
-			err = errors.Wrapf(err, "failed to format the updated `go.mod` file %q", goModPath)
-			return
-		}
-		err = os.WriteFile(goModPath, goModContents, 0666)
-		if err != nil {
-			err = errors.Wrapf(err, "failed to write the updated `go.mod` file to %q", goModPath)
-			return
-		}
+	if !goModModified {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, "\tNo changes needed to `go.mod`.\n")
+	}
+	if dryRun {
+		return kernel.PublishWriteStream(msg, kernel.StreamStdout, "\tDry run: `go.mod` was not changed.\n")
+	}
+
+	// Update go.mod file.
+	goModContents, err = modFile.Format()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to format the updated `go.mod` file %q", goModPath)
+		return
+	}
+	err = os.WriteFile(goModPath, goModContents, 0666)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to write the updated `go.mod` file to %q", goModPath)
+		return
 	}
 	return
 }
@@ -0,0 +1,136 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTrackModule(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	// The notebook module itself is always known, even offline.
+	dir, err := s.TrackModule(s.Package)
+	require.NoError(t, err)
+	assert.Equal(t, s.TempDir, dir)
+	assert.Contains(t, s.ListTracked(), s.TempDir)
+
+	_, err = s.TrackModule("example.com/not/a/dependency")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is it a dependency of the notebook")
+}
+
+func TestUntrackAll(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	require.NoError(t, s.Track(dir1))
+	require.NoError(t, s.Track(dir2))
+	require.Len(t, s.ListTracked(), 2)
+
+	numUntracked, err := s.UntrackAll()
+	require.NoError(t, err)
+	assert.Equal(t, 2, numUntracked)
+	assert.Empty(t, s.ListTracked())
+
+	numUntracked, err = s.UntrackAll()
+	require.NoError(t, err)
+	assert.Equal(t, 0, numUntracked)
+
+	// Single paths can still be tracked and untracked.
+	require.NoError(t, s.Track(dir1))
+	require.NoError(t, s.Untrack(dir1))
+	assert.Empty(t, s.ListTracked())
+}
+
+func TestListTrackedStatus(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	modDir, noModDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(modDir, "go.mod"), []byte("module example.com/tracked\n\ngo 1.21\n"), 0644))
+	require.NoError(t, os.Mkdir(path.Join(modDir, "pkg"), 0755))
+	require.NoError(t, s.Track(path.Join(modDir, "pkg")))
+	require.NoError(t, s.Track(noModDir))
+
+	statuses := s.ListTrackedStatus()
+	require.Len(t, statuses, 2)
+	byPath := make(map[string]TrackedStatus)
+	for _, status := range statuses {
+		byPath[status.Path] = status
+	}
+	assert.Equal(t, "example.com/tracked", byPath[path.Join(modDir, "pkg")].Module)
+	assert.True(t, byPath[path.Join(modDir, "pkg")].IsDir)
+	assert.Equal(t, "", byPath[noModDir].Module)
+	if s.gopls == nil {
+		assert.Equal(t, -1, byPath[noModDir].NumLoaded)
+	}
+}
+
+func TestGoWorkFixDryRun(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	modDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(modDir, "go.mod"), []byte("module example.com/local\n\ngo 1.21\n"), 0644))
+	goWork := "go 1.21\n\nuse (\n\t.\n\t" + modDir + "\n)\n"
+	require.NoError(t, os.WriteFile(path.Join(s.TempDir, "go.work"), []byte(goWork), 0644))
+	goModPath := path.Join(s.TempDir, "go.mod")
+	goModBefore, err := os.ReadFile(goModPath)
+	require.NoError(t, err)
+
+	require.NoError(t, s.GoWorkFix(nil, true))
+	goModAfter, err := os.ReadFile(goModPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(goModBefore), string(goModAfter), "dry-run must not change go.mod")
+
+	require.NoError(t, s.GoWorkFix(nil, false))
+	goModAfter, err = os.ReadFile(goModPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(goModAfter), "replace example.com/local => "+modDir)
+}
+
+func TestGoWorkAutoUse(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	modDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(modDir, "go.mod"), []byte("module example.com/local\n\ngo 1.21\n"), 0644))
+	goWorkPath := path.Join(s.TempDir, "go.work")
+	require.NoError(t, os.WriteFile(goWorkPath, []byte("go 1.21\n\nuse .\n"), 0644))
+	require.NoError(t, s.AutoTrack())
+	require.NoError(t, s.Track(modDir))
+
+	// Disabled: go.work is not changed.
+	s.GoWorkAutoUse = false
+	require.NoError(t, s.goWorkAutoUse(nil))
+	contents, err := os.ReadFile(goWorkPath)
+	require.NoError(t, err)
+	assert.NotContains(t, string(contents), modDir)
+
+	s.GoWorkAutoUse = true
+	require.NoError(t, s.goWorkAutoUse(nil))
+	contents, err = os.ReadFile(goWorkPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), modDir)
+
+	// Already used: no changes.
+	require.NoError(t, s.goWorkAutoUse(nil))
+	contentsAfter, err := os.ReadFile(goWorkPath)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), string(contentsAfter))
+}
@@ -0,0 +1,63 @@
+package goexec
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/pkg/errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// This file implements the vendoring of the dependencies of the notebook module, see `%vendor`.
+
+// VendorDir returns the directory where the dependencies of the notebook module are vendored.
+func (s *State) VendorDir() string {
+	return path.Join(s.TempDir, "vendor")
+}
+
+// Vendor copies the dependencies of the notebook module to VendorDir (with `go mod vendor`), and compiles
+// the following programs with `-mod=vendor`, so they don't require network access.
+//
+// It returns the number of modules vendored.
+func (s *State) Vendor() (numModules int, err error) {
+	cmd := s.GoCommand("mod", "vendor")
+	cmd.Dir = s.TempDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to run %q:\n%s", cmd.String(), output)
+	}
+	s.Vendored = true
+	modulesTxt, err := os.ReadFile(path.Join(s.VendorDir(), "modules.txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No dependencies to vendor.
+			return 0, nil
+		}
+		return 0, errors.Wrapf(err, "failed to read the list of vendored modules")
+	}
+	return countVendoredModules(modulesTxt), nil
+}
+
+// Unvendor removes VendorDir, and goes back to compiling with the module cache.
+func (s *State) Unvendor() error {
+	s.Vendored = false
+	if err := os.RemoveAll(s.VendorDir()); err != nil {
+		return errors.Wrapf(err, "failed to remove %q", s.VendorDir())
+	}
+	return nil
+}
+
+// countVendoredModules returns the number of modules listed in the contents of `vendor/modules.txt`: they are
+// the lines like "# <module> <version>". Replacements ("# <module> => <path>") are also modules, but not the
+// "## explicit" annotations.
+func countVendoredModules(modulesTxt []byte) (count int) {
+	scanner := bufio.NewScanner(bytes.NewReader(modulesTxt))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# ") {
+			count++
+		}
+	}
+	return
+}
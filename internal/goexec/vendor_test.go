@@ -0,0 +1,25 @@
+package goexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestVendor(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+	numModules, err := s.Vendor()
+	require.NoError(t, err)
+	assert.Equal(t, 0, numModules)
+	assert.True(t, s.Vendored)
+	require.NoError(t, s.Unvendor())
+	assert.False(t, s.Vendored)
+	assert.NoDirExists(t, s.VendorDir())
+
+	modulesTxt := "# github.com/pkg/errors v0.9.1\n## explicit\ngithub.com/pkg/errors\n" +
+		"# example.com/local v0.0.0 => ../local\n## explicit; go 1.21\nexample.com/local\n"
+	assert.Equal(t, 2, countVendoredModules([]byte(modulesTxt)))
+}
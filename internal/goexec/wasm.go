@@ -8,10 +8,8 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
 	"os"
-	"os/exec"
 	"path"
 	"strconv"
-	"strings"
 	"text/template"
 )
 
@@ -23,6 +21,10 @@ const (
 
 	JupyterFilesSubdir = "jupyter_files"
 	CompiledWasmName   = "gonb_cell.wasm"
+
+	// ServedFilesSubdir is the subdirectory of the kernel's `jupyter_files/<unique id>/` where
+	// `gonbui.ServeFile` stores the files to be served.
+	ServedFilesSubdir = "files"
 )
 
 // MakeWasmSubdir creates a subdirectory named `.wasm/<notebook name>/` in the
@@ -56,12 +58,12 @@ func (s *State) MakeWasmSubdir() (err error) {
 
 	// Copy over `wasm_exec.js` if needed.
 	var wasmExecSrc string
-	wasmExecSrc, err = GoRoot()
+	wasmExecSrc, err = s.GoRoot()
 	if err != nil {
 		err = errors.WithMessage(err, "failed to find GOROOT, needed to copy wasm_exec.js for WASM programs")
 		return
 	}
-	klog.Infof("GOROOT=%q", goRoot)
+	klog.Infof("GOROOT=%q", wasmExecSrc)
 	wasmExecSrc = path.Join(wasmExecSrc, "misc", "wasm", "wasm_exec.js")
 	wasmExecDst := path.Join(s.WasmDir, "wasm_exec.js")
 
@@ -89,6 +91,22 @@ func (s *State) MakeWasmSubdir() (err error) {
 	return
 }
 
+// setFilesDir sets s.FilesDir and the environment variables used by `gonbui.ServeFile` to
+// store files to be served by Jupyter.
+//
+// The directory itself is only created by `gonbui.ServeFile`, when first used.
+func (s *State) setFilesDir(jupyterRoot string) error {
+	s.FilesDir = path.Join(jupyterRoot, JupyterFilesSubdir, s.UniqueID, ServedFilesSubdir)
+	filesUrl := path.Join("/files", JupyterFilesSubdir, s.UniqueID, ServedFilesSubdir)
+	if err := os.Setenv(protocol.GONB_FILES_DIR_ENV, s.FilesDir); err != nil {
+		return errors.Wrapf(err, "failed to set environment variable %q", protocol.GONB_FILES_DIR_ENV)
+	}
+	if err := os.Setenv(protocol.GONB_FILES_URL_ENV, filesUrl); err != nil {
+		return errors.Wrapf(err, "failed to set environment variable %q", protocol.GONB_FILES_URL_ENV)
+	}
+	return nil
+}
+
 var jupyterRootDirectory string
 
 // JupyterRootDirectory returns Jupyter's root directory.
@@ -126,25 +144,6 @@ func JupyterRootDirectory() (string, error) {
 	return jupyterRootDirectory, nil
 }
 
-var goRoot string
-
-func GoRoot() (string, error) {
-	if goRoot != "" {
-		return goRoot, nil
-	}
-
-	cmd := exec.Command("go", "env", "GOROOT")
-	klog.Infof("Executing %q", cmd)
-	cmd.Stderr = os.Stderr
-	output, err := cmd.Output()
-	if err != nil {
-		return "", errors.Wrapf(err, "failed to find GOROOT")
-	}
-	goRoot = string(output)
-	goRoot = strings.TrimSuffix(goRoot, "\n")
-	return goRoot, nil
-}
-
 var (
 	runWasmHtml = template.Must(template.New("wasm_exec_html").Parse(
 		`<div id="{{.WasmDivId}}"></div><script src="{{.WasmExecJsUrl}}"></script>`))
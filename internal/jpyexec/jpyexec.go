@@ -9,14 +9,15 @@
 package jpyexec
 
 import (
+	"fmt"
 	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/pkg/errors"
 	"io"
 	"k8s.io/klog/v2"
-	"os"
 	osexec "os/exec"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -30,12 +31,14 @@ type Executor struct {
 	command                    string
 	args                       []string
 	dir                        string
+	env                        []string
 	useNamedPipes              bool
 	commsHandler               CommsHandler
 	stdoutWriter, stderrWriter io.Writer
 	stdinContent               []byte
 	millisecondsToInput        int
 	inputPassword              bool
+	timeout                    time.Duration
 
 	// State when execution starts (after call to Exec)
 	cmd                                      *osexec.Cmd
@@ -56,9 +59,10 @@ type Executor struct {
 	// Currently, it is assumed that it will be used by the CommsHandler.
 	PipeWriterFifo chan *protocol.CommValue
 
-	isDone   bool
-	doneChan chan struct{}
-	muDone   sync.Mutex
+	isDone        bool
+	doneChan      chan struct{}
+	muDone        sync.Mutex
+	interruptOnce sync.Once
 }
 
 // New creates an executor for the given command plus arguments,
@@ -85,8 +89,15 @@ func New(msg kernel.Message, command string, args ...string) *Executor {
 // commsHandler is called to handle Comms request from the program. If left as nil,
 // comms requests are simply ignored.
 //
+// Named pipes are not supported in Windows, where this is a no-op: the program is executed without them
+// ($GONB_PIPE is not set), and `gonbui` reports it is not running in a notebook.
+//
 // See `gonb/gonbui` and `gonb/gonbui/widgets`.
 func (exec *Executor) UseNamedPipes(commsHandler CommsHandler) *Executor {
+	if !namedPipesSupported {
+		klog.V(1).Infof("Named pipes not supported in this platform, executing %q without them", exec.command)
+		return exec
+	}
 	exec.useNamedPipes = true
 	exec.commsHandler = commsHandler
 	return exec
@@ -109,13 +120,25 @@ func (exec *Executor) InDir(dir string) *Executor {
 	return exec
 }
 
+// WithEnv configures the Executor to add the given environment variables, in the format "KEY=VALUE", to the
+// environment of the program, on top of the kernel's environment (they take precedence).
+// The kernel's own environment is not changed.
+func (exec *Executor) WithEnv(env ...string) *Executor {
+	exec.env = append(exec.env, env...)
+	return exec
+}
+
 // WithStderr configures piping of stderr to the given `io.Writer`.
+//
+// If the writer also implements `io.Closer`, it is closed once the program finishes.
 func (exec *Executor) WithStderr(stderrWriter io.Writer) *Executor {
 	exec.stderrWriter = stderrWriter
 	return exec
 }
 
-// WithStdout configures piping of stderr to the given `io.Writer`.
+// WithStdout configures piping of stdout to the given `io.Writer`.
+//
+// If the writer also implements `io.Closer`, it is closed once the program finishes.
 func (exec *Executor) WithStdout(stdoutWriter io.Writer) *Executor {
 	exec.stdoutWriter = stdoutWriter
 	return exec
@@ -156,6 +179,13 @@ func (exec *Executor) WithStaticInput(stdinContent []byte) *Executor {
 	return exec
 }
 
+// WithTimeout configures the Executor to interrupt the program if it runs for longer than timeout,
+// as if the user had interrupted it. If timeout is 0, there is no limit.
+func (exec *Executor) WithTimeout(timeout time.Duration) *Executor {
+	exec.timeout = timeout
+	return exec
+}
+
 // WaitToKill is the to wait after an interrupt signal, before killing the process.
 var WaitToKill = 5 * time.Second
 
@@ -176,6 +206,10 @@ func (exec *Executor) Exec() error {
 	cmd := osexec.Command(exec.command, exec.args...)
 	exec.cmd = cmd
 	cmd.Dir = exec.dir
+	if len(exec.env) > 0 {
+		cmd.Env = append(cmd.Environ(), exec.env...)
+	}
+	setProcessGroup(cmd) // So interruptions reach also the processes spawned by the program.
 
 	var err error
 	exec.cmdStdout, err = cmd.StdoutPipe()
@@ -238,23 +272,28 @@ func (exec *Executor) Exec() error {
 
 	var interruptId kernel.SubscriptionId
 	interruptId = exec.Msg.Kernel().SubscribeInterrupt(func(id kernel.SubscriptionId) {
-		// Sent interrupt to process.
-		err := cmd.Process.Signal(os.Interrupt)
 		exec.Msg.Kernel().UnsubscribeInterrupt(interruptId)
-		if err != nil {
-			klog.Errorf("failed to interrupt process %s (%v): %+v", cmd, cmd.Process, err)
-		}
-		select {
-		case <-exec.doneChan:
-			// Normal stop, nothing to do.
-		case <-time.After(WaitToKill):
-			// If process hasn't yet died, kill it.
-			err = cmd.Process.Signal(syscall.SIGKILL)
-			if err != nil {
-				klog.Errorf("failed to kill process %s (%v): %+v", cmd, cmd.Process, err)
-			}
-		}
+		exec.interruptAndKill()
 	})
+	if exec.Msg.Kernel().Interrupted.Load() {
+		// Interrupted before we subscribed: the program should not continue.
+		exec.Msg.Kernel().UnsubscribeInterrupt(interruptId)
+		go exec.interruptAndKill()
+	}
+
+	// Interrupt the program if it exceeds the timeout.
+	var timedOut atomic.Bool
+	if exec.timeout > 0 {
+		go func() {
+			select {
+			case <-exec.doneChan:
+				// Normal stop, nothing to do.
+			case <-time.After(exec.timeout):
+				timedOut.Store(true)
+				exec.interruptAndKill()
+			}
+		}()
+	}
 
 	if exec.stdinContent != nil {
 		exec.handleStaticInput()
@@ -262,10 +301,19 @@ func (exec *Executor) Exec() error {
 
 	// Wait for output pipes to finish.
 	streamersWG.Wait()
+	for _, w := range []io.Writer{exec.stdoutWriter, exec.stderrWriter} {
+		if closer, ok := w.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				klog.Errorf("Failed closing output of execution: %+v", err)
+			}
+		}
+	}
 	if err := cmd.Wait(); err != nil {
 		errMsg := err.Error() + "\n"
 		if exec.Msg.Kernel().Interrupted.Load() {
 			errMsg = "^C\n" + errMsg
+		} else if timedOut.Load() {
+			errMsg = fmt.Sprintf("Timed out after %s\n", exec.timeout) + errMsg
 		}
 		_ = kernel.PublishWriteStream(exec.Msg, kernel.StreamStderr, errMsg)
 	}
@@ -279,6 +327,29 @@ func (exec *Executor) Exec() error {
 	return nil
 }
 
+// interruptAndKill sends an interrupt signal to the program, and kills it if it hasn't finished
+// after WaitToKill. The signals are sent to the program's process group, so the processes it
+// spawned are also stopped. Only the first call has any effect.
+func (exec *Executor) interruptAndKill() {
+	exec.interruptOnce.Do(func() {
+		cmd := exec.cmd
+		err := signalProcessGroup(cmd, syscall.SIGINT)
+		if err != nil {
+			klog.Errorf("failed to interrupt process %s (%v): %+v", cmd, cmd.Process, err)
+		}
+		select {
+		case <-exec.doneChan:
+			// Normal stop, nothing to do.
+		case <-time.After(WaitToKill):
+			// If processes haven't yet died, kill them.
+			err = signalProcessGroup(cmd, syscall.SIGKILL)
+			if err != nil {
+				klog.Errorf("failed to kill process %s (%v): %+v", cmd, cmd.Process, err)
+			}
+		}
+	})
+}
+
 // done signals program finished executing, and triggers the closing of everything.
 func (exec *Executor) done() {
 	exec.muDone.Lock()
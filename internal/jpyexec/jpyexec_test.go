@@ -0,0 +1,54 @@
+package jpyexec
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// execWithDeadline runs exec.Exec, and fails the test if it doesn't return within the deadline.
+func execWithDeadline(t *testing.T, exec *Executor, deadline time.Duration) {
+	errChan := make(chan error, 1)
+	go func() { errChan <- exec.Exec() }()
+	select {
+	case err := <-errChan:
+		require.NoError(t, err)
+	case <-time.After(deadline):
+		t.Fatalf("Execution of %q didn't finish after %s", exec.command, deadline)
+	}
+}
+
+// interruptAfter interrupts the kernel after the given delay.
+func interruptAfter(k *kernel.Kernel, delay time.Duration) {
+	go func() {
+		time.Sleep(delay)
+		k.Interrupt()
+	}()
+}
+
+func TestExecInterrupt(t *testing.T) {
+	// Program stopped by the interrupt signal.
+	k := &kernel.Kernel{}
+	msg := &fakeMessage{kernel: k}
+	interruptAfter(k, 200*time.Millisecond)
+	execWithDeadline(t, New(msg, "/bin/sh", "-c", "while :; do :; done"), 10*time.Second)
+	require.NotEmpty(t, msg.published)
+	assert.Contains(t, msg.published[len(msg.published)-1].content, "^C")
+
+	// Program that ignores the interrupt signal is killed after WaitToKill.
+	defer func(wait time.Duration) { WaitToKill = wait }(WaitToKill)
+	WaitToKill = 200 * time.Millisecond
+	k = &kernel.Kernel{}
+	msg = &fakeMessage{kernel: k}
+	interruptAfter(k, 200*time.Millisecond)
+	execWithDeadline(t, New(msg, "/bin/sh", "-c", "trap '' INT; while :; do :; done"), 10*time.Second)
+	assert.Contains(t, msg.published[len(msg.published)-1].content, "killed")
+
+	// Interrupted before the program started.
+	k = &kernel.Kernel{}
+	k.Interrupted.Store(true)
+	msg = &fakeMessage{kernel: k}
+	execWithDeadline(t, New(msg, "/bin/sh", "-c", "while :; do :; done"), 10*time.Second)
+}
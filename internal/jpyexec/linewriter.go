@@ -0,0 +1,53 @@
+package jpyexec
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// LineWriter is an io.WriteCloser that calls a function for each complete line written to it,
+// without the trailing new line. It can be used to stream the output of a program line by line,
+// e.g.: with WithStdout or as the output of an exec.Cmd.
+//
+// The last line, if not terminated by a new line, is only passed on when the writer is closed.
+// It is safe for concurrent use.
+type LineWriter struct {
+	lineFn  func(line string)
+	pending []byte
+	mu      sync.Mutex
+}
+
+var _ io.WriteCloser = (*LineWriter)(nil)
+
+// NewLineWriter returns a LineWriter that calls lineFn for each line written.
+func NewLineWriter(lineFn func(line string)) *LineWriter {
+	return &LineWriter{lineFn: lineFn}
+}
+
+// Write implements io.Writer.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		idx := bytes.IndexByte(w.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		w.lineFn(string(w.pending[:idx]))
+		w.pending = w.pending[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, and passes on the last line, if it was not terminated by a new line.
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		w.lineFn(string(w.pending))
+		w.pending = nil
+	}
+	return nil
+}
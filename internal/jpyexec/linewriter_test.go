@@ -0,0 +1,20 @@
+package jpyexec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	var lines []string
+	w := NewLineWriter(func(line string) { lines = append(lines, line) })
+	for _, part := range []string{"go: downloading", " example.com/a v1.0.0\n", "\n", "# example.com/b\nlast"} {
+		n, err := w.Write([]byte(part))
+		require.NoError(t, err)
+		assert.Equal(t, len(part), n)
+	}
+	assert.Equal(t, []string{"go: downloading example.com/a v1.0.0", "", "# example.com/b"}, lines)
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{"go: downloading example.com/a v1.0.0", "", "# example.com/b", "last"}, lines)
+}
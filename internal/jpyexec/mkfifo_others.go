@@ -0,0 +1,13 @@
+//go:build !windows
+
+package jpyexec
+
+import "syscall"
+
+// namedPipesSupported indicates whether the platform supports the named pipes used by Executor.UseNamedPipes.
+const namedPipesSupported = true
+
+// mkfifo creates a named pipe in pipePath.
+func mkfifo(pipePath string) error {
+	return syscall.Mkfifo(pipePath, 0600)
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package jpyexec
+
+import "github.com/pkg/errors"
+
+// namedPipesSupported is false in Windows: Executor.UseNamedPipes is a no-op, so programs are executed without
+// the named pipes, and can't use them to display rich content (see package `gonbui`) or for widgets.
+const namedPipesSupported = false
+
+// mkfifo is not supported in Windows, and it is never called, since namedPipesSupported is false.
+func mkfifo(pipePath string) error {
+	return errors.Errorf("named pipes (%q) are not supported in Windows", pipePath)
+}
@@ -16,7 +16,6 @@ import (
 	"k8s.io/klog/v2"
 	"os"
 	"sync"
-	"syscall"
 )
 
 func init() {
@@ -98,7 +97,7 @@ func (exec *Executor) createTmpFifo() (string, error) {
 	}
 
 	// Create pipe.
-	if err = syscall.Mkfifo(pipePath, 0600); err != nil {
+	if err = mkfifo(pipePath); err != nil {
 		return "", errors.Wrapf(err, "failed to create pipe (Mkfifo) for %q", pipePath)
 	}
 	return pipePath, nil
@@ -193,6 +192,22 @@ func (exec *Executor) pollNamedPipeReader() {
 			continue
 		}
 
+		// Error reports, displayed as an error output.
+		if reqAny, found := data.Data[protocol.MIMEJupyterError]; found {
+			report, ok := reqAny.(protocol.ErrorReport)
+			if !ok {
+				exec.reportCellError(errors.Errorf(
+					"A MIMEJupyterError sent to GONB_PIPE without an associated protocol.ErrorReport!? -- got (%T) %#v",
+					reqAny, reqAny))
+				continue
+			}
+			err := kernel.PublishExecutionError(exec.Msg, report.Value, report.Traceback, report.Name)
+			if err != nil {
+				klog.Errorf("Failed to display error report (ignoring): %v", err)
+			}
+			continue
+		}
+
 		// CommValue: update or read value in the front-end.
 		if reqAny, found := data.Data[protocol.MIMECommValue]; found {
 			req, ok := reqAny.(protocol.CommValue)
@@ -305,6 +320,10 @@ func (exec *Executor) dispatchDisplayData(data *protocol.DisplayData) {
 // so we suggest using the `gonb/gonbui/widgets` API instead.
 func (exec *Executor) dispatchInputRequest(req *protocol.InputRequest) {
 	klog.V(2).Infof("Received InputRequest %+v", req)
+	if req.Reply {
+		exec.dispatchInputRequestWithReply(req)
+		return
+	}
 	writeStdinFn := func(original, input *kernel.MessageImpl) error {
 		content := input.Composed.Content.(map[string]any)
 		value := content["value"].(string) + "\n"
@@ -333,6 +352,46 @@ func (exec *Executor) dispatchInputRequest(req *protocol.InputRequest) {
 	}
 }
 
+// dispatchInputRequestWithReply uses the standard Jupyter input mechanism, and sends the value entered back to
+// the program as a protocol.InputReply, see `gonbui.ReadInput`.
+//
+// If the front-end doesn't allow input, the reply carries an error instead.
+func (exec *Executor) dispatchInputRequestWithReply(req *protocol.InputRequest) {
+	reply := func(value, errMsg string) {
+		// The input may be entered after the program finished (or was interrupted), when PipeWriterFifo
+		// is already closed: muDone guarantees it is not closed while we send.
+		exec.muDone.Lock()
+		defer exec.muDone.Unlock()
+		if exec.isDone {
+			klog.V(2).Infof("InputReply(%d) dropped, program already finished", req.ReplyId)
+			return
+		}
+		select {
+		case exec.PipeWriterFifo <- &protocol.CommValue{
+			Address: protocol.GonbuiInputReplyAddress,
+			Value:   protocol.InputReply{Id: req.ReplyId, Value: value, Error: errMsg},
+		}:
+		default:
+			klog.Warningf("InputReply(%d) dropped, named pipe to the program is full", req.ReplyId)
+		}
+	}
+	if content, _ := exec.Msg.ComposedMsg().Content.(map[string]any); content["allow_stdin"] != true {
+		reply("", "the front-end doesn't allow input (\"allow_stdin\" is false), e.g. when executing "+
+			"the notebook non-interactively")
+		return
+	}
+	replyFn := func(original, input *kernel.MessageImpl) error {
+		content := input.Composed.Content.(map[string]any)
+		value, _ := content["value"].(string)
+		reply(value, "")
+		return nil
+	}
+	err := exec.Msg.PromptInput(req.Prompt, req.Password, replyFn)
+	if err != nil {
+		reply("", err.Error())
+	}
+}
+
 // openPipeWriter opens `exec.namedPipeWriterPath` and handles its proper closing, and removal of
 // the named pipe when program execution is finished.
 //
@@ -0,0 +1,65 @@
+package jpyexec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// fakeMessage implements kernel.Message, collecting the published messages.
+type fakeMessage struct {
+	kernel.Message // Not implemented, calls to unimplemented methods will panic.
+	kernel         *kernel.Kernel
+
+	// published collects the type and the JSON content of all messages published.
+	published []publishedMsg
+}
+
+type publishedMsg struct {
+	msgType, content string
+}
+
+func (m *fakeMessage) Kernel() *kernel.Kernel { return m.kernel }
+
+func (m *fakeMessage) Publish(msgType string, content interface{}) error {
+	contentJson, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	m.published = append(m.published, publishedMsg{msgType, string(contentJson)})
+	return nil
+}
+
+func TestPollNamedPipeReaderErrorReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := gob.NewEncoder(buf)
+	require.NoError(t, encoder.Encode(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{protocol.MIMEJupyterError: protocol.ErrorReport{
+			Name:      "*errors.fundamental",
+			Value:     "bad",
+			Traceback: []string{"*errors.fundamental: bad"},
+		}},
+	}))
+	// Invalid content for MIMEJupyterError.
+	require.NoError(t, encoder.Encode(&protocol.DisplayData{
+		Data: map[protocol.MIMEType]any{protocol.MIMEJupyterError: "bad"},
+	}))
+
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	exec := New(msg, "true")
+	exec.pipeReader = io.NopCloser(buf)
+	exec.pollNamedPipeReader()
+
+	require.Len(t, msg.published, 2)
+	assert.Equal(t, "error", msg.published[0].msgType)
+	assert.JSONEq(t, `{"ename": "*errors.fundamental", "evalue": "bad", "traceback": ["*errors.fundamental: bad"]}`,
+		msg.published[0].content)
+	assert.Equal(t, "stream", msg.published[1].msgType)
+	assert.Contains(t, msg.published[1].content, "without an associated protocol.ErrorReport")
+}
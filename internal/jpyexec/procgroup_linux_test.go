@@ -0,0 +1,71 @@
+package jpyexec
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isProcessRunning returns whether the process is running: zombie processes (that died but were not
+// reaped by their parent) are not considered running.
+func isProcessRunning(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// Format: "<pid> (<command>) <state> ...".
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z" && fields[0] != "X"
+}
+
+// readPid reads the pid written by the test program in pidPath, waiting for it to be written.
+func readPid(pidPath string) (int, error) {
+	for ii := 0; ii < 100; ii++ {
+		contents, err := os.ReadFile(pidPath)
+		if err == nil && strings.HasSuffix(string(contents), "\n") {
+			return strconv.Atoi(strings.TrimSpace(string(contents)))
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return 0, errors.Errorf("program didn't write its pid to %q", pidPath)
+}
+
+func TestExecProcessGroup(t *testing.T) {
+	defer func(wait time.Duration) { WaitToKill = wait }(WaitToKill)
+	WaitToKill = 200 * time.Millisecond
+
+	// The background process ignores the interrupt (as background processes in shell scripts do), and
+	// holds the output pipes open: it must be killed for the execution to finish.
+	script := "sleep 1000 & echo $! > %s; wait"
+
+	// Interrupt.
+	pidPath := path.Join(t.TempDir(), "pid")
+	k := &kernel.Kernel{}
+	msg := &fakeMessage{kernel: k}
+	go func() {
+		_, _ = readPid(pidPath) // Interrupt once the background process started.
+		k.Interrupt()
+	}()
+	execWithDeadline(t, New(msg, "/bin/sh", "-c", fmt.Sprintf(script, pidPath)), 10*time.Second)
+	pid, err := readPid(pidPath)
+	require.NoError(t, err)
+	require.Eventuallyf(t, func() bool { return !isProcessRunning(pid) }, time.Second, 50*time.Millisecond,
+		"Process %d spawned by the program is still running after interrupt", pid)
+
+	// Timeout.
+	pidPath = path.Join(t.TempDir(), "pid")
+	msg = &fakeMessage{kernel: &kernel.Kernel{}}
+	execWithDeadline(t, New(msg, "/bin/sh", "-c", fmt.Sprintf(script, pidPath)).WithTimeout(300*time.Millisecond), 10*time.Second)
+	pid, err = readPid(pidPath)
+	require.NoError(t, err)
+	require.Eventuallyf(t, func() bool { return !isProcessRunning(pid) }, time.Second, 50*time.Millisecond,
+		"Process %d spawned by the program is still running after timeout", pid)
+	require.Contains(t, msg.published[len(msg.published)-1].content, "Timed out after 300ms")
+}
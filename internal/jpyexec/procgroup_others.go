@@ -0,0 +1,17 @@
+//go:build !(linux || darwin)
+
+package jpyexec
+
+import (
+	osexec "os/exec"
+	"syscall"
+)
+
+// setProcessGroup is a no-op in this platform: process groups are not supported.
+func setProcessGroup(cmd *osexec.Cmd) {}
+
+// signalProcessGroup sends sig only to the program started by cmd, since process groups are not
+// supported in this platform.
+func signalProcessGroup(cmd *osexec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Signal(sig)
+}
@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package jpyexec
+
+import (
+	osexec "os/exec"
+	"syscall"
+)
+
+// setProcessGroup configures cmd to start the program in its own process group, so the kernel can
+// signal the whole tree of processes it spawns, see signalProcessGroup.
+func setProcessGroup(cmd *osexec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends sig to the process group of the program started by cmd: that is, to the
+// program and all the processes it spawned (unless they moved to another process group).
+func signalProcessGroup(cmd *osexec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,211 @@
+package kernel
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// This file implements a small converter of ANSI escape sequences (colors and styling) to HTML,
+// used to display the output of programs (e.g.: `go test`, linters) with colors.
+
+// ansiEscape is the prefix of the ANSI "Control Sequence Introducer" (CSI).
+const ansiEscape = "\033["
+
+// ansiColors is the palette used for the 16 basic ANSI colors: the 8 normal colors, followed
+// by the 8 bright ones. They are the same colors used by Jupyter.
+var ansiColors = [16]string{
+	"#3E424D", "#E75C58", "#00A250", "#DDB62B", "#208FFB", "#D160C4", "#60C6C8", "#C5C1B4",
+	"#282C36", "#B22B31", "#007427", "#B27D12", "#0065CA", "#A03196", "#258F8F", "#A1A6B2",
+}
+
+// ansiToHtml converts text with ANSI escape sequences to HTML. It keeps the styling state
+// across calls, so text can be converted in chunks.
+//
+// Only SGR ("Select Graphic Rendition") sequences are converted, other sequences (cursor
+// movement, erasing, etc.) are dropped.
+type ansiToHtml struct {
+	fg, bg                                  string
+	bold, faint, italic, underline, inverse bool
+
+	// pending holds an incomplete escape sequence at the end of the last chunk converted.
+	pending string
+}
+
+// IsActive returns whether any styling is set, or if there is a pending escape sequence.
+func (a *ansiToHtml) IsActive() bool {
+	return a.fg != "" || a.bg != "" || a.bold || a.faint || a.italic || a.underline || a.inverse || a.pending != ""
+}
+
+// Convert converts the text to HTML and to plain text (with escape sequences removed).
+//
+// An incomplete escape sequence at the end of the text is kept to be used in the next call.
+func (a *ansiToHtml) Convert(text string) (htmlText, plainText string) {
+	text = a.pending + text
+	a.pending = ""
+	var htmlParts, plainParts strings.Builder
+	for len(text) > 0 {
+		pos := strings.Index(text, ansiEscape)
+		if pos < 0 {
+			pos = len(text)
+			if strings.HasSuffix(text, "\033") {
+				// Escape sequence possibly split across chunks.
+				pos--
+				a.pending = text[pos:]
+			}
+		}
+		if pos > 0 {
+			a.writeHtml(&htmlParts, text[:pos])
+			plainParts.WriteString(text[:pos])
+		}
+		if pos+len(a.pending) >= len(text) {
+			break
+		}
+		text = text[pos+len(ansiEscape):]
+
+		// Find end of sequence: a byte in the range 0x40-0x7E.
+		end := strings.IndexFunc(text, func(r rune) bool { return r >= 0x40 && r <= 0x7E })
+		if end < 0 {
+			a.pending = ansiEscape + text
+			break
+		}
+		if text[end] == 'm' {
+			a.applySGR(text[:end])
+		}
+		text = text[end+1:]
+	}
+	return htmlParts.String(), plainParts.String()
+}
+
+// writeHtml writes the escaped text wrapped in a span with the current style, if any is set.
+func (a *ansiToHtml) writeHtml(sb *strings.Builder, text string) {
+	var styles []string
+	fg, bg := a.fg, a.bg
+	if a.inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "var(--jp-layout-color0, white)"
+		}
+		if bg == "" {
+			bg = "var(--jp-content-font-color0, black)"
+		}
+	}
+	if fg != "" {
+		styles = append(styles, "color: "+fg)
+	}
+	if bg != "" {
+		styles = append(styles, "background-color: "+bg)
+	}
+	if a.bold {
+		styles = append(styles, "font-weight: bold")
+	}
+	if a.faint {
+		styles = append(styles, "opacity: 0.7")
+	}
+	if a.italic {
+		styles = append(styles, "font-style: italic")
+	}
+	if a.underline {
+		styles = append(styles, "text-decoration: underline")
+	}
+	if len(styles) == 0 {
+		sb.WriteString(html.EscapeString(text))
+		return
+	}
+	sb.WriteString(fmt.Sprintf(`<span style="%s">%s</span>`, strings.Join(styles, "; "), html.EscapeString(text)))
+}
+
+// applySGR applies the "Select Graphic Rendition" parameters (separated by ";") to the current style.
+func (a *ansiToHtml) applySGR(params string) {
+	if params == "" {
+		params = "0"
+	}
+	codes := strings.Split(params, ";")
+	for ii := 0; ii < len(codes); ii++ {
+		code, err := strconv.Atoi(codes[ii])
+		if err != nil {
+			continue
+		}
+		switch {
+		case code == 0:
+			*a = ansiToHtml{pending: a.pending}
+		case code == 1:
+			a.bold = true
+		case code == 2:
+			a.faint = true
+		case code == 3:
+			a.italic = true
+		case code == 4:
+			a.underline = true
+		case code == 7:
+			a.inverse = true
+		case code == 22:
+			a.bold, a.faint = false, false
+		case code == 23:
+			a.italic = false
+		case code == 24:
+			a.underline = false
+		case code == 27:
+			a.inverse = false
+		case code >= 30 && code <= 37:
+			a.fg = ansiColors[code-30]
+		case code == 39:
+			a.fg = ""
+		case code >= 40 && code <= 47:
+			a.bg = ansiColors[code-40]
+		case code == 49:
+			a.bg = ""
+		case code >= 90 && code <= 97:
+			a.fg = ansiColors[code-90+8]
+		case code >= 100 && code <= 107:
+			a.bg = ansiColors[code-100+8]
+		case code == 38 || code == 48:
+			var color string
+			color, ii = parseExtendedColor(codes, ii+1)
+			if code == 38 {
+				a.fg = color
+			} else {
+				a.bg = color
+			}
+		}
+	}
+}
+
+// parseExtendedColor parses the 256 colors (`5;<n>`) or the true color (`2;<r>;<g>;<b>`) parameters,
+// starting at codes[start]. It returns the color (empty if invalid) and the index of the last
+// parameter used.
+func parseExtendedColor(codes []string, start int) (color string, last int) {
+	if start >= len(codes) {
+		return "", len(codes) - 1
+	}
+	values := make([]int, 0, 4)
+	for _, c := range codes[start:min(start+4, len(codes))] {
+		v, _ := strconv.Atoi(c)
+		values = append(values, v)
+	}
+	switch {
+	case values[0] == 5 && len(values) >= 2:
+		return ansi256Color(values[1]), start + 1
+	case values[0] == 2 && len(values) >= 4:
+		return fmt.Sprintf("rgb(%d, %d, %d)", values[1], values[2], values[3]), start + 3
+	}
+	return "", len(codes) - 1
+}
+
+// ansi256Color returns the CSS color for the n-th color of the 256 colors palette.
+func ansi256Color(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("rgb(%d, %d, %d)", levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("rgb(%d, %d, %d)", gray, gray, gray)
+	}
+}
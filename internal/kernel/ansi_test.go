@@ -0,0 +1,30 @@
+package kernel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAnsiToHtml(t *testing.T) {
+	a := &ansiToHtml{}
+	htmlText, plainText := a.Convert("ok \033[31mFAIL\033[0m <done>")
+	assert.Equal(t, `ok <span style="color: #E75C58">FAIL</span> &lt;done&gt;`, htmlText)
+	assert.Equal(t, "ok FAIL <done>", plainText)
+	assert.False(t, a.IsActive())
+
+	// Styles are kept across chunks, and escape sequences can be split.
+	htmlText, plainText = a.Convert("\033[1;32mPASS\033[")
+	assert.Equal(t, `<span style="color: #00A250; font-weight: bold">PASS</span>`, htmlText)
+	assert.Equal(t, "PASS", plainText)
+	assert.True(t, a.IsActive())
+	htmlText, _ = a.Convert("22m more\033")
+	assert.Equal(t, `<span style="color: #00A250"> more</span>`, htmlText)
+	htmlText, _ = a.Convert("[m end")
+	assert.Equal(t, " end", htmlText)
+	assert.False(t, a.IsActive())
+
+	// Extended colors, and non-SGR sequences are dropped.
+	htmlText, _ = a.Convert("\033[38;5;196mx\033[48;2;1;2;3my\033[2K\033[0m")
+	assert.Equal(t, `<span style="color: rgb(255, 0, 0)">x</span>`+
+		`<span style="color: rgb(255, 0, 0); background-color: rgb(1, 2, 3)">y</span>`, htmlText)
+}
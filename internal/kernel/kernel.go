@@ -107,6 +107,20 @@ type Kernel struct {
 	// Interrupted indicates whether cell/shell currently being executed was Interrupted.
 	Interrupted atomic.Bool
 
+	// OutputMaxLines and OutputMaxBytes limit the output (stdout and stderr) streamed to the front-end by each
+	// program executed (see NewJupyterStreamWriter). Output beyond the limit is truncated.
+	// A value <= 0 means no limit.
+	OutputMaxLines, OutputMaxBytes int
+
+	// AnsiHtmlOutput enables the conversion of ANSI escape sequences (colors, styling) in the output
+	// of programs to HTML (see NewJupyterStreamWriter). It is off by default, since the converted lines are
+	// published as display data, and not as stream output.
+	AnsiHtmlOutput bool
+
+	// secrets are redacted from the output published to the front-end, see AddSecret.
+	secrets   []string
+	muSecrets sync.Mutex
+
 	// InterruptCond gets signaled whenever an interruption happens.
 	interruptSubscriptions *list.List
 	muSubscriptions        sync.Mutex
@@ -193,8 +207,7 @@ func (k *Kernel) HandleInterrupt() {
 			for {
 				select {
 				case sig := <-k.signalsChan:
-					k.Interrupted.Store(true)
-					k.CallInterruptSubscribers()
+					k.Interrupt()
 					klog.Infof("Signal %s received.", sig)
 					if sig == os.Interrupt {
 						// Simply interrupt running cells.
@@ -222,6 +235,9 @@ type InterruptFn func(id SubscriptionId)
 func (k *Kernel) SubscribeInterrupt(fn InterruptFn) SubscriptionId {
 	k.muSubscriptions.Lock()
 	defer k.muSubscriptions.Unlock()
+	if k.interruptSubscriptions == nil {
+		k.interruptSubscriptions = list.New()
+	}
 	if klog.V(2).Enabled() {
 		klog.Infof("SubscribeInterrupt(): %d elements", k.interruptSubscriptions.Len()+1)
 	}
@@ -245,11 +261,21 @@ func (k *Kernel) UnsubscribeInterrupt(id SubscriptionId) {
 	}
 }
 
+// Interrupt marks the cell being executed as interrupted, and calls the interrupt subscribers (see
+// SubscribeInterrupt), which should stop any program or shell command running.
+func (k *Kernel) Interrupt() {
+	k.Interrupted.Store(true)
+	k.CallInterruptSubscribers()
+}
+
 // CallInterruptSubscribers in a separate goroutine each.
 // Meant to be called when JupyterServer sends a kernel interrupt (either a SIGINT, or a `interrupt_request` message to interrupt).
 func (k *Kernel) CallInterruptSubscribers() {
 	k.muSubscriptions.Lock()
 	defer k.muSubscriptions.Unlock()
+	if k.interruptSubscriptions == nil {
+		return
+	}
 
 	for e := k.interruptSubscriptions.Front(); e != nil; e = e.Next() {
 		if e.Value == nil {
@@ -1,13 +1,16 @@
 package kernel
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/pkg/errors"
 	"io"
 	"k8s.io/klog/v2"
 	"runtime"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-zeromq/zmq4"
 	"github.com/gofrs/uuid"
@@ -365,6 +368,12 @@ func EnsureMIMEMap(bundle MIMEMap) MIMEMap {
 
 // PublishExecutionError publishes a serialized error that was encountered during execution.
 func PublishExecutionError(msg Message, err string, trace []string, name string) error {
+	err = redact(msg, err)
+	redactedTrace := make([]string, len(trace))
+	for ii, line := range trace {
+		redactedTrace[ii] = redact(msg, line)
+	}
+	trace = redactedTrace
 	return msg.Publish("error",
 		struct {
 			Name  string   `json:"ename"`
@@ -388,7 +397,7 @@ func PublishExecuteResult(msg Message, data Data) error {
 		Transient MIMEMap `json:"transient"`
 	}{
 		ExecCount: msg.Kernel().ExecCounter,
-		Data:      data.Data,
+		Data:      redactMIMEMap(msg, data.Data),
 		Metadata:  EnsureMIMEMap(data.Metadata),
 		Transient: EnsureMIMEMap(data.Transient),
 	})
@@ -406,7 +415,7 @@ func PublishDisplayData(msg Message, data Data) error {
 		Metadata  MIMEMap `json:"metadata"`
 		Transient MIMEMap `json:"transient"`
 	}{
-		Data:      data.Data,
+		Data:      redactMIMEMap(msg, data.Data),
 		Metadata:  EnsureMIMEMap(data.Metadata),
 		Transient: EnsureMIMEMap(data.Transient),
 	})
@@ -457,7 +466,7 @@ func PublishUpdateDisplayData(msg Message, data Data) error {
 		Metadata  MIMEMap `json:"metadata"`
 		Transient MIMEMap `json:"transient"`
 	}{
-		Data:      data.Data,
+		Data:      redactMIMEMap(msg, data.Data),
 		Metadata:  EnsureMIMEMap(data.Metadata),
 		Transient: data.Transient,
 	})
@@ -510,6 +519,10 @@ func PublishWriteStream(msg Message, stream string, data string) error {
 		klog.Infof("PublishWriteStream(nil, %s): %q", stream, data)
 		return nil
 	}
+	if stream == StreamStdout && isQuiet(msg) {
+		return nil
+	}
+	data = redact(msg, data)
 	return msg.Publish("stream",
 		struct {
 			Stream string `json:"name"`
@@ -523,26 +536,241 @@ func PublishWriteStream(msg Message, stream string, data string) error {
 
 // jupyterStreamWriter is an `io.Writer` implementation that writes the data to the notebook
 // front-end.
+//
+// If limits on the output are configured (see Kernel.OutputMaxLines and Kernel.OutputMaxBytes), the output
+// beyond the limits is dropped, and a notice is published when the writer is closed.
+//
+// Lines with ANSI escape sequences are converted to HTML only if Kernel.AnsiHtmlOutput is set (see `%ansi on`):
+// an incomplete line is then only held back (until completed) if it has an escape sequence or follows an active
+// style. Otherwise, the output is streamed raw.
 type jupyterStreamWriter struct {
 	stream string
 	msg    Message
+
+	// ansi converts ANSI escape sequences to HTML, if not nil, and ansiLine holds the last incomplete line
+	// written, until it is completed.
+	ansi     *ansiToHtml
+	ansiLine []byte
+	ansiHtml strings.Builder
+
+	// lineBuffered publishes only complete lines, holding in pendingLine the last incomplete line written, until
+	// it is completed. It's used while there are secrets to redact (see Kernel.AddSecret), so they are not split
+	// across publications.
+	lineBuffered bool
+	pendingLine  []byte
+
+	// Limits, and the counters of what has been written (and dropped) so far.
+	maxLines, maxBytes       int
+	numLines, numBytes       int
+	limitReached             bool
+	droppedLines             int
+	droppedBytes             int
+	lastWritten, lastDropped byte
 }
 
 // NewJupyterStreamWriter returns an io.Writer that forwards what is written to the Jupyter client,
 // under the given stream name.
+//
+// The output is truncated according to the kernel's OutputMaxLines and OutputMaxBytes, at the
+// time of creation. The returned writer also implements io.Closer, and Close should be called
+// once the output is finished, so a notice of truncated output is published, if needed.
 func NewJupyterStreamWriter(msg Message, stream string) io.Writer {
-	return &jupyterStreamWriter{stream, msg}
+	w := &jupyterStreamWriter{stream: stream, msg: msg}
+	if msg != nil && msg.Kernel() != nil {
+		w.maxLines = msg.Kernel().OutputMaxLines
+		w.maxBytes = msg.Kernel().OutputMaxBytes
+		if msg.Kernel().AnsiHtmlOutput {
+			w.ansi = &ansiToHtml{}
+		}
+		w.lineBuffered = msg.Kernel().NumSecrets() > 0
+	}
+	return w
 }
 
 // Write implements `io.Writer.Write` by publishing the data via `PublishWriteStream`
 func (w *jupyterStreamWriter) Write(p []byte) (n int, err error) {
-	data := string(p)
-	if err := PublishWriteStream(w.msg, w.stream, data); err != nil {
-		klog.Errorf("Failed to stream %d bytes of data to stream %q: %+v", n, w.stream, err)
+	if len(p) == 0 {
+		return 0, nil
+	}
+	toWrite := p
+	if w.maxLines > 0 || w.maxBytes > 0 {
+		toWrite = w.applyLimits(p)
+	}
+	if len(toWrite) > 0 {
+		w.lastWritten = toWrite[len(toWrite)-1]
+		if w.ansi != nil {
+			w.writeAnsiLines(toWrite)
+		} else if w.lineBuffered {
+			w.writeLines(toWrite)
+		} else {
+			w.publish(string(toWrite))
+		}
 	}
 	return len(p), nil
 }
 
+// publish the text to the stream.
+func (w *jupyterStreamWriter) publish(text string) {
+	if err := PublishWriteStream(w.msg, w.stream, text); err != nil {
+		klog.Errorf("Failed to stream %d bytes of data to stream %q: %+v", len(text), w.stream, err)
+	}
+}
+
+// writeLines publishes the complete lines of p (along with the incomplete line held from previous writes).
+// The last incomplete line is held until completed, or until the writer is closed.
+func (w *jupyterStreamWriter) writeLines(p []byte) {
+	data := append(w.pendingLine, p...)
+	lastLineStart := bytes.LastIndexByte(data, '\n') + 1
+	w.pendingLine = data[lastLineStart:]
+	if lastLineStart > 0 {
+		w.publish(string(data[:lastLineStart]))
+	}
+}
+
+// writeAnsiLines publishes the complete lines of p (along with the incomplete line held from previous writes):
+// lines with ANSI escape sequences (or while a style is active) are published as HTML, the others to the stream.
+// The last incomplete line is held until completed (or until the writer is closed) only if it may need
+// conversion, or if the output is line buffered, so plain output (e.g.: progress indicators) is otherwise
+// streamed as it is written.
+func (w *jupyterStreamWriter) writeAnsiLines(p []byte) {
+	data := append(w.ansiLine, p...)
+	lastLineStart := bytes.LastIndexByte(data, '\n') + 1
+	w.ansiLine = data[lastLineStart:]
+	if lastLineStart > 0 {
+		w.publishAnsiLines(string(data[:lastLineStart]))
+	}
+	if len(w.ansiLine) > 0 && !w.lineBuffered && !w.ansi.IsActive() && !bytes.Contains(w.ansiLine, []byte{'\033'}) {
+		w.publish(string(w.ansiLine))
+		w.ansiLine = nil
+	}
+}
+
+// publishAnsiLines publishes the text, converting to HTML the lines with ANSI escape sequences.
+// Consecutive lines of the same kind are published together.
+func (w *jupyterStreamWriter) publishAnsiLines(text string) {
+	var block strings.Builder
+	blockIsAnsi := false
+	flush := func() {
+		if block.Len() == 0 {
+			return
+		}
+		if blockIsAnsi {
+			w.publishAnsi(block.String())
+		} else {
+			w.publish(block.String())
+		}
+		block.Reset()
+	}
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line == "" {
+			continue
+		}
+		isAnsi := w.ansi.IsActive() || strings.Contains(line, "\033")
+		if isAnsi != blockIsAnsi {
+			flush()
+			blockIsAnsi = isAnsi
+		}
+		if isAnsi {
+			// Convert line by line, so IsActive reflects the style at the end of each line. Secrets are redacted
+			// before the conversion, since it escapes the text.
+			htmlText, plainText := w.ansi.Convert(redact(w.msg, line))
+			w.ansiHtml.WriteString(htmlText)
+			block.WriteString(plainText)
+		} else {
+			block.WriteString(line)
+		}
+	}
+	flush()
+}
+
+// publishAnsi publishes the HTML converted so far (in ansiHtml) with the plain text (without the escape
+// sequences) alternative.
+func (w *jupyterStreamWriter) publishAnsi(plainText string) {
+	htmlText := w.ansiHtml.String()
+	w.ansiHtml.Reset()
+	err := PublishData(w.msg, Data{
+		Data: MIMEMap{
+			string(protocol.MIMETextHTML):  `<pre style="margin: 0">` + htmlText + "</pre>",
+			string(protocol.MIMETextPlain): plainText,
+		},
+		Metadata:  make(MIMEMap),
+		Transient: make(MIMEMap),
+	})
+	if err != nil {
+		klog.Errorf("Failed to publish %d bytes of data with ANSI sequences for stream %q: %+v", len(plainText), w.stream, err)
+	}
+}
+
+// applyLimits updates the counters of the output, and returns the prefix of p that is still within the limits.
+// The limit on the number of bytes never splits a UTF-8 encoded rune: the whole rune is dropped instead.
+func (w *jupyterStreamWriter) applyLimits(p []byte) []byte {
+	cut := len(p)
+	if !w.limitReached {
+		for ii, c := range p {
+			if w.maxBytes > 0 && w.numBytes >= w.maxBytes {
+				cut = ii
+				for cut > 0 && !utf8.RuneStart(p[cut]) {
+					cut--
+					w.numBytes--
+				}
+				w.limitReached = true
+				break
+			}
+			w.numBytes++
+			if c == '\n' {
+				w.numLines++
+				if w.maxLines > 0 && w.numLines >= w.maxLines {
+					cut = ii + 1
+					w.limitReached = true
+					break
+				}
+			}
+		}
+	} else {
+		cut = 0
+	}
+	if cut < len(p) {
+		dropped := p[cut:]
+		w.droppedBytes += len(dropped)
+		w.droppedLines += bytes.Count(dropped, []byte{'\n'})
+		w.lastDropped = dropped[len(dropped)-1]
+	}
+	return p[:cut]
+}
+
+// Close implements io.Closer. If any output was truncated, it publishes a notice with the
+// amount of output dropped.
+func (w *jupyterStreamWriter) Close() error {
+	if len(w.pendingLine) > 0 {
+		w.publish(string(w.pendingLine))
+		w.pendingLine = nil
+	}
+	if w.ansi != nil {
+		// Flush the last incomplete line, and an incomplete escape sequence, if any.
+		if len(w.ansiLine) > 0 {
+			w.publishAnsiLines(string(w.ansiLine))
+			w.ansiLine = nil
+		}
+		if w.ansi.pending != "" {
+			w.publish(w.ansi.pending)
+			w.ansi.pending = ""
+		}
+	}
+	if w.droppedBytes == 0 {
+		return nil
+	}
+	moreLines := w.droppedLines
+	if w.lastDropped != '\n' {
+		moreLines++ // Last line was not terminated with a new-line.
+	}
+	notice := fmt.Sprintf("... output truncated (%d more lines)\n", moreLines)
+	if w.lastWritten != 0 && w.lastWritten != '\n' {
+		notice = "\n" + notice
+	}
+	w.droppedBytes, w.droppedLines = 0, 0
+	return PublishWriteStream(w.msg, w.stream, notice)
+}
+
 // PublishKernelStatus publishes a status message notifying front-ends of the state the kernel
 // is in. It supports the states "starting", "busy", and "idle".
 func PublishKernelStatus(msg Message, status string) error {
@@ -576,6 +804,23 @@ func SendKernelInfo(msg Message, version string) error {
 	)
 }
 
+// PublishClearOutput publishes a "clear_output" message, that clears the output of the cell being executed.
+//
+// If wait is true, the front-end only clears the output when new output is available, avoiding flickering.
+func PublishClearOutput(msg Message, wait bool) error {
+	if msg == nil {
+		klog.Infof("PublishClearOutput(nil, wait=%v)", wait)
+		return nil
+	}
+	return msg.Publish("clear_output",
+		struct {
+			Wait bool `json:"wait"`
+		}{
+			Wait: wait,
+		},
+	)
+}
+
 // PublishExecuteInput publishes a status message notifying front-ends of what code is
 // currently being executed.
 func PublishExecuteInput(msg Message, code string) error {
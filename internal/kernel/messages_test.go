@@ -0,0 +1,118 @@
+package kernel
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeMessage implements Message, collecting the published messages.
+type fakeMessage struct {
+	Message // Not implemented, calls to unimplemented methods will panic.
+	kernel  *Kernel
+
+	// output collects the text of "stream" messages.
+	output strings.Builder
+
+	// published collects the type and the JSON content of all messages published.
+	published []publishedMsg
+}
+
+type publishedMsg struct {
+	msgType, content string
+}
+
+func (m *fakeMessage) Kernel() *Kernel { return m.kernel }
+
+func (m *fakeMessage) Publish(msgType string, content interface{}) error {
+	contentJson, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	m.published = append(m.published, publishedMsg{msgType, string(contentJson)})
+	if msgType != "stream" {
+		return nil
+	}
+	var decoded map[string]string
+	if err = json.Unmarshal(contentJson, &decoded); err != nil {
+		return err
+	}
+	m.output.WriteString(decoded["text"])
+	return nil
+}
+
+func TestJupyterStreamWriterLimits(t *testing.T) {
+	// No limits.
+	msg := &fakeMessage{kernel: &Kernel{}}
+	w := NewJupyterStreamWriter(msg, StreamStdout)
+	for ii := 0; ii < 10; ii++ {
+		_, _ = w.Write([]byte("line\n"))
+	}
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, strings.Repeat("line\n", 10), msg.output.String())
+
+	// Limit on the number of lines.
+	msg = &fakeMessage{kernel: &Kernel{OutputMaxLines: 3}}
+	w = NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("a\nb\n"))
+	_, _ = w.Write([]byte("c\nd\ne\n"))
+	_, _ = w.Write([]byte("f\ng"))
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "a\nb\nc\n... output truncated (4 more lines)\n", msg.output.String())
+
+	// Limit on the number of bytes.
+	msg = &fakeMessage{kernel: &Kernel{OutputMaxBytes: 5}}
+	w = NewJupyterStreamWriter(msg, StreamStderr)
+	n, err := w.Write([]byte("abc\ndefg\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 9, n, "Write should report all bytes as written, even if truncated")
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "abc\nd\n... output truncated (1 more lines)\n", msg.output.String())
+
+	// Limit on the number of bytes doesn't split multibyte runes: "é" takes 2 bytes.
+	msg = &fakeMessage{kernel: &Kernel{OutputMaxBytes: 4}}
+	w = NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("abcé\n"))
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "abc\n... output truncated (1 more lines)\n", msg.output.String())
+}
+
+func TestJupyterStreamWriterAnsi(t *testing.T) {
+	// Conversion disabled (the default): escape sequences are streamed as is.
+	msg := &fakeMessage{kernel: &Kernel{}}
+	w := NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("a \033[31mred\033[0m\n"))
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "a \033[31mred\033[0m\n", msg.output.String())
+
+	// Interleaved plain and colored writes: colored lines are converted as whole lines (and published as HTML),
+	// and plain lines are streamed, including incomplete ones.
+	msg = &fakeMessage{kernel: &Kernel{AnsiHtmlOutput: true}}
+	w = NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("plain 1\na \033[3"))
+	assert.Equal(t, "plain 1\n", msg.output.String())
+	_, _ = w.Write([]byte("1mred"))
+	_, _ = w.Write([]byte(" text\033[0m and more\nplain 2\n"))
+	_, _ = w.Write([]byte("partial"))
+	assert.Equal(t, "plain 1\nplain 2\npartial", msg.output.String())
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "plain 1\nplain 2\npartial", msg.output.String())
+
+	// Incomplete escape sequence at the end is flushed on Close.
+	msg = &fakeMessage{kernel: &Kernel{AnsiHtmlOutput: true}}
+	w = NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("end\033["))
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "\033[", msg.output.String())
+}
+
+func TestPublishClearOutput(t *testing.T) {
+	msg := &fakeMessage{kernel: &Kernel{}}
+	require.NoError(t, PublishClearOutput(msg, true))
+	require.Len(t, msg.published, 1)
+	assert.Equal(t, "clear_output", msg.published[0].msgType)
+	assert.JSONEq(t, `{"wait": true}`, msg.published[0].content)
+}
@@ -0,0 +1,38 @@
+package kernel
+
+// quietMessage wraps a Message, dropping the outputs published with it, see NewQuietMessage.
+type quietMessage struct {
+	Message
+}
+
+// quietMsgTypes are the types of messages dropped by a quietMessage.
+var quietMsgTypes = map[string]bool{
+	"display_data":        true,
+	"update_display_data": true,
+	"execute_result":      true,
+}
+
+// NewQuietMessage returns msg wrapped such that the standard output and the data displayed with it are dropped.
+// Errors and the standard error are still published.
+//
+// It's used to suppress the output of special commands ending with ";". It returns nil if msg is nil.
+func NewQuietMessage(msg Message) Message {
+	if msg == nil {
+		return nil
+	}
+	return &quietMessage{Message: msg}
+}
+
+// isQuiet returns whether the outputs published with msg are dropped, see NewQuietMessage.
+func isQuiet(msg Message) bool {
+	_, ok := msg.(*quietMessage)
+	return ok
+}
+
+// Publish implements Message, dropping the outputs.
+func (m *quietMessage) Publish(msgType string, content interface{}) error {
+	if quietMsgTypes[msgType] {
+		return nil
+	}
+	return m.Message.Publish(msgType, content)
+}
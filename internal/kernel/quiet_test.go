@@ -0,0 +1,22 @@
+package kernel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestQuietMessage(t *testing.T) {
+	assert.Nil(t, NewQuietMessage(nil))
+
+	msg := &fakeMessage{kernel: &Kernel{}}
+	quiet := NewQuietMessage(msg)
+	require.NoError(t, PublishWriteStream(quiet, StreamStdout, "dropped\n"))
+	require.NoError(t, PublishHtml(quiet, "<b>dropped</b>"))
+	require.NoError(t, PublishWriteStream(quiet, StreamStderr, "warning\n"))
+	require.NoError(t, PublishExecutionError(quiet, "failed", nil, "ERROR"))
+	assert.Equal(t, "warning\n", msg.output.String())
+	require.Len(t, msg.published, 2)
+	assert.Equal(t, "error", msg.published[1].msgType)
+	assert.Equal(t, msg.kernel, quiet.Kernel())
+}
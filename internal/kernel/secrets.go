@@ -0,0 +1,90 @@
+package kernel
+
+import (
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+// This file implements the redaction of secrets from the output published to the front-end, so they are not
+// saved in the notebook.
+
+// RedactedSecret replaces the secrets in the output, see Kernel.AddSecret.
+const RedactedSecret = "****"
+
+// MinSecretLength is the minimum length of a secret registered with Kernel.AddSecret: shorter values would
+// redact unrelated parts of the output.
+const MinSecretLength = 4
+
+// AddSecret registers a secret value (e.g.: a token) to be redacted from the output published to the front-end:
+// the output streams (stdout and stderr), errors and the textual content displayed (e.g.: HTML).
+// It returns an error if the value is shorter than MinSecretLength.
+//
+// While there are secrets registered, the output of programs is published line by line, so a secret split
+// across writes is still redacted.
+func (k *Kernel) AddSecret(value string) error {
+	if len(value) < MinSecretLength {
+		return errors.Errorf("secrets must have at least %d characters, shorter values would redact unrelated "+
+			"parts of the output", MinSecretLength)
+	}
+	k.muSecrets.Lock()
+	defer k.muSecrets.Unlock()
+	for _, secret := range k.secrets {
+		if secret == value {
+			return nil
+		}
+	}
+	k.secrets = append(k.secrets, value)
+	// Longer secrets first, so a secret that contains another one is fully redacted.
+	sort.SliceStable(k.secrets, func(i, j int) bool { return len(k.secrets[i]) > len(k.secrets[j]) })
+	return nil
+}
+
+// ClearSecrets removes all secrets registered with AddSecret.
+func (k *Kernel) ClearSecrets() {
+	k.muSecrets.Lock()
+	defer k.muSecrets.Unlock()
+	k.secrets = nil
+}
+
+// NumSecrets returns the number of secrets registered with AddSecret.
+func (k *Kernel) NumSecrets() int {
+	k.muSecrets.Lock()
+	defer k.muSecrets.Unlock()
+	return len(k.secrets)
+}
+
+// Redact returns the text with the secrets registered with AddSecret replaced by RedactedSecret.
+func (k *Kernel) Redact(text string) string {
+	k.muSecrets.Lock()
+	defer k.muSecrets.Unlock()
+	for _, secret := range k.secrets {
+		text = strings.ReplaceAll(text, secret, RedactedSecret)
+	}
+	return text
+}
+
+// redact is like Kernel.Redact, but it accepts a nil msg or kernel.
+func redact(msg Message, text string) string {
+	if msg == nil || msg.Kernel() == nil {
+		return text
+	}
+	return msg.Kernel().Redact(text)
+}
+
+// redactMIMEMap returns a copy of data with the secrets redacted from the textual contents (MIME types
+// "text/..."). Other contents, e.g.: base64 encoded images, are not changed. It returns data itself if there are
+// no secrets registered.
+func redactMIMEMap(msg Message, data MIMEMap) MIMEMap {
+	if msg == nil || msg.Kernel() == nil || msg.Kernel().NumSecrets() == 0 {
+		return data
+	}
+	redacted := make(MIMEMap, len(data))
+	for mimeType, content := range data {
+		if text, ok := content.(string); ok && strings.HasPrefix(mimeType, "text/") {
+			content = msg.Kernel().Redact(text)
+		}
+		redacted[mimeType] = content
+	}
+	return redacted
+}
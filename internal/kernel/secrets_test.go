@@ -0,0 +1,44 @@
+package kernel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	k := &Kernel{}
+	require.NoError(t, k.AddSecret("abcd"))
+	require.NoError(t, k.AddSecret("abcdef"))
+	require.NoError(t, k.AddSecret("abcd"))
+	assert.Error(t, k.AddSecret(""))
+	assert.Error(t, k.AddSecret("a"), "short secrets would redact unrelated output")
+	assert.Equal(t, 2, k.NumSecrets())
+	assert.Equal(t, "token=****, other=****, a", k.Redact("token=abcdef, other=abcd, a"))
+
+	msg := &fakeMessage{kernel: k}
+	require.NoError(t, PublishWriteStream(msg, StreamStderr, "secret: abcd\n"))
+	assert.Equal(t, "secret: ****\n", msg.output.String())
+
+	// Secrets split across writes are still redacted.
+	msg = &fakeMessage{kernel: k}
+	w := NewJupyterStreamWriter(msg, StreamStdout)
+	_, _ = w.Write([]byte("token=ab"))
+	_, _ = w.Write([]byte("cdef\nlast ab"))
+	_, _ = w.Write([]byte("cd"))
+	require.NoError(t, w.(io.Closer).Close())
+	assert.Equal(t, "token=****\nlast ****", msg.output.String())
+
+	// Errors and textual display data are also redacted, but not other contents.
+	msg = &fakeMessage{kernel: k}
+	require.NoError(t, PublishExecutionError(msg, "failed with abcd", []string{"abcdef"}, "ERROR"))
+	require.NoError(t, PublishDisplayData(msg, Data{Data: MIMEMap{"text/html": "<b>abcd</b>", "image/png": "abcd"}}))
+	require.Len(t, msg.published, 2)
+	assert.JSONEq(t, `{"ename": "ERROR", "evalue": "failed with ****", "traceback": ["****"]}`, msg.published[0].content)
+	assert.JSONEq(t, `{"data": {"text/html": "<b>****</b>", "image/png": "abcd"}, "metadata": {}, "transient": {}}`,
+		msg.published[1].content)
+
+	k.ClearSecrets()
+	assert.Equal(t, "abcd", k.Redact("abcd"))
+}
@@ -25,27 +25,20 @@ func TestWritefile(t *testing.T) {
 
 	// Run notebook test.
 	notebook := "writefile"
-	f := executeNotebook(t, notebook)
-	err = Check(f,
-		Sequence(
-			Match(
-				OutputLine(1),
-				Separator,
-				fmt.Sprintf(`Cell contents written to "%s/poetry.txt".`, testDir),
-				Separator,
-			),
-			Match(
-				OutputLine(2),
-				Separator,
-				fmt.Sprintf(`Cell contents appended to "%s/poetry.txt".`, testDir),
-				Separator,
-			),
-		), *flagPrintNotebook)
-
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+	runNotebookTest(t, notebook,
+		Match(
+			OutputLine(1),
+			Separator,
+			fmt.Sprintf(`Cell contents written to "%s/poetry.txt".`, testDir),
+			Separator,
+		),
+		Match(
+			OutputLine(2),
+			Separator,
+			fmt.Sprintf(`Cell contents appended to "%s/poetry.txt".`, testDir),
+			Separator,
+		),
+	)
 
 	// Checks the file was written.
 	filePath := path.Join(testDir, "poetry.txt")
@@ -75,41 +68,34 @@ func TestScript(t *testing.T) {
 
 	// Run notebook test.
 	notebook := "script"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			Match(
-				OutputLine(1),
-				Separator,
-				"1 : a",
-				"2 : b",
-				"3 : c",
-				Separator,
-			),
-			Match(
-				OutputLine(2),
-				Separator,
-				"18",
-				Separator,
-			),
-			Match(
-				OutputLine(3),
-				Separator,
-				"19",
-				Separator,
-			),
-			Match(
-				OutputLine(4),
-				Separator,
-				"",
-				"can only appear at the start", // ... can only appear ...
-				"",
-				Separator,
-			),
-		), *flagPrintNotebook)
-
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+	runNotebookTest(t, notebook,
+		Match(
+			OutputLine(1),
+			Separator,
+			"1 : a",
+			"2 : b",
+			"3 : c",
+			Separator,
+		),
+		Match(
+			OutputLine(2),
+			Separator,
+			"18",
+			Separator,
+		),
+		Match(
+			OutputLine(3),
+			Separator,
+			"19",
+			Separator,
+		),
+		Match(
+			OutputLine(4),
+			Separator,
+			"",
+			"can only appear at the start", // ... can only appear ...
+			"",
+			Separator,
+		),
+	)
 }
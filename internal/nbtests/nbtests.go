@@ -16,6 +16,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
 	"runtime"
 	"strings"
 )
@@ -106,6 +107,8 @@ func InstallTmpGonbKernel(runArgs, extraInstallArgs []string) (tmpJupyterDir str
 // See the following functions that return `ExpectFn` that can be used:
 //
 // Match()
+// MatchRegexp()
+// NotMatch()
 // Sequence()
 type ExpectFn func(line string, eof bool) (done bool, err error)
 
@@ -150,18 +153,61 @@ func Check(r io.Reader, expectation ExpectFn, print bool) error {
 // If more than one string is given, they are expected to match consecutively,
 // exactly one line after another.
 func Match(search ...string) ExpectFn {
-	current := 0
 	if len(search) == 0 {
 		panic("Match() requires at least one string.")
 	}
+	return matchConsecutive("Match", search, func(line string, ii int) bool {
+		return strings.Contains(line, search[ii])
+	})
+}
+
+// MatchRegexp is like Match, but each pattern is a regular expression (see package `regexp`) that
+// should match the line (or part of it), e.g.: `/tmp/gonb_\w+/main.go` or `^Elapsed: \d+ms$`.
+// It is useful for variable output, like timestamps or temporary paths.
+//
+// It panics if any of the patterns is not a valid regular expression.
+func MatchRegexp(patterns ...string) ExpectFn {
+	if len(patterns) == 0 {
+		panic("MatchRegexp() requires at least one pattern.")
+	}
+	regexps := make([]*regexp.Regexp, len(patterns))
+	for ii, pattern := range patterns {
+		regexps[ii] = regexp.MustCompile(pattern)
+	}
+	return matchConsecutive("MatchRegexp", patterns, func(line string, ii int) bool {
+		return regexps[ii].MatchString(line)
+	})
+}
+
+// NotMatch returns an ExpectFn that fails if any line contains the search string, until the `until`
+// expectation is done, e.g.: `NotMatch("lost", Match(InputLine(5)))` checks that "lost" is not in the output
+// before the input of cell 5 -- the line matched by `until` is also checked.
+//
+// If `until` is nil, the search string must not appear until the end of the notebook.
+func NotMatch(search string, until ExpectFn) ExpectFn {
+	return func(line string, eof bool) (done bool, err error) {
+		if !eof && strings.Contains(line, search) {
+			return false, errors.Errorf("NotMatch(%q): unexpected line %q", search, line)
+		}
+		if until == nil {
+			return eof, nil
+		}
+		return until(line, eof)
+	}
+}
+
+// matchConsecutive implements Match and MatchRegexp: matchFn(line, ii) reports whether the line matches
+// the search[ii], which are expected to match consecutively.
+func matchConsecutive(name string, search []string, matchFn func(line string, ii int) bool) ExpectFn {
+	current := 0
 	return func(line string, eof bool) (done bool, err error) {
 		if eof {
-			return false, errors.Errorf("Match(%q): search string #%d never matched", search, current)
+			return false, errors.Errorf("%s(%q): search string #%d never matched", name, search, current)
 		}
-		found := strings.Contains(line, search[current])
+		found := matchFn(line, current)
 		if !found {
 			if current != 0 {
-				return false, errors.Errorf("Match(%q): search string #%d not matched in sequence", search, current)
+				return false, errors.Errorf("%s(%q): search string #%d not matched in sequence", name, search, current)
 			}
 			return false, nil
 		}
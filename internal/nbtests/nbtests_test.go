@@ -10,30 +10,38 @@ package nbtests
 // The notebooks used for testing are all in `.../gonb/examples/tests` directory.
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/janpfeifer/must"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"io"
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 var panicf = common.Panicf
 
 var (
-	flagClear         = flag.Bool("clear", false, "Clear test notebooks output after test")
-	flagLogExec       = flag.Bool("log_exec", false, "Log the execution of the notebook")
+	flagClear   = flag.Bool("clear", false, "Clear test notebooks output after test")
+	flagLogExec = flag.Bool("log_exec", false, "Log the execution of the notebook, and attach the last lines "+
+		"of the logs of the kernel (and of `jupyter notebook`) to the output of the test, if it fails.")
 	flagPrintNotebook = flag.Bool("print_notebook", false, "Print tested notebooks, useful if debugging unexpected results.")
 	flagExtraFlags    = flag.String("kernel_args", "--logtostderr",
 		"extra arguments passed to `gonb --install` that eventually gets passed to the kernel. "+
 			"Commonly for debugging one will want to set \"--logtostderr --vmodule=...\"")
+	flagNotebookTimeout = flag.Duration("notebook_timeout", 5*time.Minute,
+		"Timeout to execute (or convert) each notebook: if it doesn't finish in time the test fails, "+
+			"instead of hanging. Set to 0 for no timeout.")
 
 	// gonbRunArgs is passed to `go run` when building the gonb kernel to be tested.
 	gonbRunArgs []string
@@ -139,6 +147,26 @@ func executeNotebook(t *testing.T, notebook string) *os.File {
 	return executeNotebookWithInputBoxes(t, notebook, nil)
 }
 
+// runNotebookTest executes the notebook (in `examples/tests`), and checks that its output matches the expectations,
+// in sequence (see Check and Sequence). Afterward, the converted output is removed and the notebook is cleared
+// (see --clear).
+//
+// Adding a new integration test is as simple as adding a notebook and calling runNotebookTest from a new test.
+func runNotebookTest(t *testing.T, notebook string, expectations ...ExpectFn) {
+	runNotebookTestWithInputBoxes(t, notebook, nil, expectations...)
+}
+
+// runNotebookTestWithInputBoxes is like runNotebookTest, but takes a list of values to be used in input boxes,
+// see executeNotebookWithInputBoxes.
+func runNotebookTestWithInputBoxes(t *testing.T, notebook string, inputBoxValues []string, expectations ...ExpectFn) {
+	f := executeNotebookWithInputBoxes(t, notebook, inputBoxValues)
+	err := Check(f, Sequence(expectations...), *flagPrintNotebook)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Remove(f.Name()))
+	clearNotebook(t, notebook)
+}
+
 // executeNotebookWithInputBoxes is like executeNotebook, but takes a list of values to be used
 // in input boxes.
 func executeNotebookWithInputBoxes(t *testing.T, notebook string, inputBoxValues []string) *os.File {
@@ -158,12 +186,15 @@ func executeNotebookWithInputBoxes(t *testing.T, notebook string, inputBoxValues
 		}
 		args = append(args, fmt.Sprintf("-input_boxes=%s", strings.Join(inputBoxValues, ",")))
 	}
-	nbexec := exec.Command(
-		path.Join(jupyterDir, "nbexec"), args...)
+	nbexec, runNbexec := notebookCommand(t, path.Join(jupyterDir, "nbexec"), args...)
 	nbexec.Stderr = os.Stderr
 	nbexec.Stdout = os.Stdout
-	require.NoErrorf(t, nbexec.Run(), "Failed to execute notebook %q with %q",
-		path.Join(rootDir, notebookRelPath), nbexec)
+	if *flagLogExec {
+		logFile := createKernelLog(t, notebook)
+		nbexec.Stderr = io.MultiWriter(os.Stderr, logFile)
+		nbexec.Stdout = io.MultiWriter(os.Stdout, logFile)
+	}
+	runNbexec("Failed to execute notebook %q with %q", path.Join(rootDir, notebookRelPath), nbexec)
 
 	// Convert notebook output to text ("asciidoc").
 	tmpOutput := must.M1(os.CreateTemp("", "gonb_nbtests_output"))
@@ -171,14 +202,13 @@ func executeNotebookWithInputBoxes(t *testing.T, notebook string, inputBoxValues
 	must.M(tmpOutput.Close())
 	must.M(os.Remove(nbconvertOutputName))
 	nbconvertOutputPath := nbconvertOutputName + ".asciidoc" // nbconvert adds this suffix.
-	nbconvert := exec.Command(
+	nbconvert, runNbconvert := notebookCommand(t,
 		jupyterExecPath, "nbconvert", "--to", "asciidoc",
 		"--output", nbconvertOutputName,
 		path.Join(rootDir, notebookRelPath))
 	nbconvert.Stdout, nbconvert.Stderr = os.Stderr, os.Stdout
 	klog.Infof("Executing: %q", nbconvert)
-	err := nbconvert.Run()
-	require.NoError(t, err)
+	runNbconvert("Failed to convert notebook %q with %q", path.Join(rootDir, notebookRelPath), nbconvert)
 
 	// Open converted output:
 	f, err := os.Open(nbconvertOutputPath)
@@ -186,6 +216,35 @@ func executeNotebookWithInputBoxes(t *testing.T, notebook string, inputBoxValues
 	return f
 }
 
+// maxAttachedLogLines is the maximum number of lines of the kernel logs attached to the output of failed tests,
+// see --log_exec.
+const maxAttachedLogLines = 200
+
+// createKernelLog creates a temporary file to capture the logs of the execution of the notebook. If the test
+// fails, the last lines are attached to its output and the file is kept, otherwise it is removed.
+func createKernelLog(t *testing.T, notebook string) *os.File {
+	f := must.M1(os.CreateTemp("", "gonb_nbtests_"+path.Base(notebook)+"_*.log"))
+	t.Cleanup(func() {
+		_ = f.Close()
+		if !t.Failed() {
+			_ = os.Remove(f.Name())
+			return
+		}
+		contents, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Logf("Failed to read the kernel logs in %q: %+v", f.Name(), err)
+			return
+		}
+		lines := strings.Split(strings.TrimRight(string(contents), "\n"), "\n")
+		if len(lines) > maxAttachedLogLines {
+			lines = lines[len(lines)-maxAttachedLogLines:]
+		}
+		t.Logf("Last %d lines of the logs of the execution of notebook %q (full logs in %q):\n%s",
+			len(lines), notebook, f.Name(), strings.Join(lines, "\n"))
+	})
+	return f
+}
+
 func clearNotebook(t *testing.T, notebook string) {
 	if !*flagClear {
 		// Keep outputs.
@@ -193,13 +252,40 @@ func clearNotebook(t *testing.T, notebook string) {
 	}
 	// Execute notebook.
 	notebookRelPath := path.Join("examples", "tests", notebook+".ipynb")
-	nbexec := exec.Command(
+	nbexec, runNbexec := notebookCommand(t,
 		path.Join(jupyterDir, "nbexec"), "-n="+notebookRelPath,
 		"-jupyter_dir="+rootDir, "-clear")
 	nbexec.Stderr = os.Stderr
 	nbexec.Stdout = os.Stdout
-	require.NoErrorf(t, nbexec.Run(), "Failed to clear notebook %q with %q",
-		path.Join(rootDir, notebookRelPath), nbexec)
+	runNbexec("Failed to clear notebook %q with %q", path.Join(rootDir, notebookRelPath), nbexec)
+}
+
+// notebookCommand creates the command to execute, convert or clear a notebook, which is killed if it doesn't
+// finish within --notebook_timeout: a kernel deadlock fails the test instead of hanging it.
+//
+// The returned run function runs the command, and fails the test (with the given message) if it fails or
+// times out.
+func notebookCommand(t *testing.T, name string, args ...string) (cmd *exec.Cmd, run func(msg string, args ...any)) {
+	ctx := context.Background()
+	if *flagNotebookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagNotebookTimeout)
+		t.Cleanup(cancel)
+	}
+	cmd = exec.CommandContext(ctx, name, args...)
+	// Processes spawned by the command (e.g.: the kernel) may hold on to its output after it is killed.
+	cmd.WaitDelay = 10 * time.Second
+	run = func(msg string, args ...any) {
+		start := time.Now()
+		err := cmd.Run()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.Fatalf("%s: %q was killed after running for %s (--notebook_timeout=%s), the kernel may be deadlocked. "+
+				"Run with --log_exec to see the kernel logs, or increase --notebook_timeout if it is just slow.",
+				fmt.Sprintf(msg, args...), cmd, time.Since(start).Round(time.Second), *flagNotebookTimeout)
+		}
+		require.NoErrorf(t, err, msg, args...)
+	}
+	return
 }
 
 func TestInstallation(t *testing.T) {
@@ -214,18 +300,12 @@ func TestHello(t *testing.T) {
 		t.Skip("Skipping integration (nbconvert) test for short tests.")
 		return
 	}
-	f := executeNotebook(t, "hello")
-	err := Check(f,
+	runNotebookTest(t, "hello",
 		Match(OutputLine(2),
 			Separator,
 			"Hello World!",
 			Separator),
-		*flagPrintNotebook)
-
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, "hello")
+	)
 }
 
 func TestFunctions(t *testing.T) {
@@ -234,19 +314,37 @@ func TestFunctions(t *testing.T) {
 		return
 	}
 	notebook := "functions"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
+	runNotebookTest(t, notebook,
 		Match(
 			OutputLine(3),
 			Separator,
 			"incr: x=2, y=4.14",
 			Separator,
-		), *flagPrintNotebook)
+		),
+	)
+}
 
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+// TestGenerics checks generic functions, types and methods defined and redefined across cells.
+func TestGenerics(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration (nbconvert) test for short tests.")
+		return
+	}
+	runNotebookTest(t, "generics",
+		Match(
+			OutputLine(3),
+			Separator,
+			"Map: [1 2 3]",
+			"Pair: a=1",
+			Separator,
+		),
+		Match(
+			OutputLine(5),
+			Separator,
+			"Map doubled: [2 4]",
+			Separator,
+		),
+	)
 }
 
 func TestInit(t *testing.T) {
@@ -255,58 +353,50 @@ func TestInit(t *testing.T) {
 		return
 	}
 	notebook := "init"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			Match(
-				OutputLine(2),
-				Separator,
-				"init_a",
-				Separator,
-			),
-			Match(
-				OutputLine(3),
-				Separator,
-				"init_a",
-				"init_b",
-				Separator,
-			),
-			Match(
-				OutputLine(4),
-				Separator,
-				"init: v0",
-				"init_a",
-				"init_b",
-				Separator,
-			),
-			Match(
-				OutputLine(5),
-				Separator,
-				"init: v1",
-				"init_a",
-				"init_b",
-				Separator,
-			),
-			Match(
-				OutputLine(6),
-				Separator,
-				"removed func init_a",
-				"removed func init_b",
-				Separator),
-			Match(
-				OutputLine(7),
-				Separator,
-				"init: v1",
-				"Done",
-				Separator,
-			),
+	runNotebookTest(t, notebook,
+		Match(
+			OutputLine(2),
+			Separator,
+			"init_a",
+			Separator,
 		),
-		*flagPrintNotebook)
-
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+		Match(
+			OutputLine(3),
+			Separator,
+			"init_a",
+			"init_b",
+			Separator,
+		),
+		Match(
+			OutputLine(4),
+			Separator,
+			"init: v0",
+			"init_a",
+			"init_b",
+			Separator,
+		),
+		Match(
+			OutputLine(5),
+			Separator,
+			"init: v1",
+			"init_a",
+			"init_b",
+			Separator,
+		),
+		Match(
+			OutputLine(6),
+			Separator,
+			"removed func init_a",
+			"removed func init_b",
+			Separator),
+		Match(
+			OutputLine(7),
+			Separator,
+			"init: v1",
+			"Done",
+			Separator,
+		),
+	)
 }
 
 // TestGoWork tests support for `go.work` and `%goworkfix` as well as management
@@ -317,47 +407,40 @@ func TestGoWork(t *testing.T) {
 		return
 	}
 	notebook := "gowork"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			Match(
-				OutputLine(5),
-				Separator,
-				`Added replace rule for module "a.com/a/pkg" to local directory`,
-				Separator,
-			),
-			Match(
-				OutputLine(6),
-				Separator,
-				"module gonb_",
-				"",
-				"go ",
-				"",
-				"replace a.com/a/pkg => TMP_PKG",
-				Separator,
-			),
-			Match(
-				OutputLine(7),
-				Separator,
-				"List of files/directories being tracked",
-				"",
-				"/tmp/gonb_tests_gowork_",
-				Separator,
-			),
-			Match(
-				OutputLine(9),
-				Separator,
-				`Untracked "/tmp/gonb_tests_gowork_..."`,
-				"",
-				"No files or directory being tracked yet",
-				Separator,
-			),
-		), *flagPrintNotebook)
-
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+	runNotebookTest(t, notebook,
+		Match(
+			OutputLine(5),
+			Separator,
+			`Added replace rule for module "a.com/a/pkg" to local directory`,
+			Separator,
+		),
+		Match(
+			OutputLine(6),
+			Separator,
+			"module gonb_",
+			"",
+			"go ",
+			"",
+			"replace a.com/a/pkg => TMP_PKG",
+			Separator,
+		),
+		Match(
+			OutputLine(7),
+			Separator,
+			"List of files/directories being tracked",
+			"",
+			"/tmp/gonb_tests_gowork_",
+			Separator,
+		),
+		Match(
+			OutputLine(9),
+			Separator,
+			`Untracked "/tmp/gonb_tests_gowork_..."`,
+			"",
+			"No files or directory being tracked yet",
+			Separator,
+		),
+	)
 }
 
 // TestGoFlags tests `%goflags` special command support.
@@ -367,58 +450,51 @@ func TestGoFlags(t *testing.T) {
 		return
 	}
 	notebook := "goflags"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			// Check `%goflags` is correctly keeping/erasing state.
-			Match(
-				OutputLine(1),
-				Separator,
-				"%goflags=[\"-cover\"]",
-				Separator,
-			),
-			Match(
-				OutputLine(2),
-				Separator,
-				"%goflags=[\"-cover\"]",
-				Separator,
-			),
-			Match(
-				OutputLine(3),
-				Separator,
-				"%goflags=[]",
-				Separator,
-			),
-
-			// Check that `-cover` actually had an effect: this it tied to the how go coverage works, and will break
-			// the the Go tools change -- probably ok, if it doesn't happen to often.
-			// If it does change, just manually run the notebook, see what is the updated output, and if correct,
-			// copy over here.
-			Match(
-				OutputLine(7),
-				Separator,
-				"A\t\t100.0%",
-				"B\t\t0.0%",
-			),
+	runNotebookTest(t, notebook,
+		// Check `%goflags` is correctly keeping/erasing state.
+		Match(
+			OutputLine(1),
+			Separator,
+			"%goflags=[\"-cover\"]",
+			Separator,
+		),
+		Match(
+			OutputLine(2),
+			Separator,
+			"%goflags=[\"-cover\"]",
+			Separator,
+		),
+		Match(
+			OutputLine(3),
+			Separator,
+			"%goflags=[]",
+			Separator,
+		),
 
-			// Check full reset.
-			Match(
-				OutputLine(8),
-				Separator,
-				"State reset: all memorized declarations discarded",
-				Separator,
-			),
+		// Check that `-cover` actually had an effect: this it tied to the how go coverage works, and will break
+		// the the Go tools change -- probably ok, if it doesn't happen to often.
+		// If it does change, just manually run the notebook, see what is the updated output, and if correct,
+		// copy over here.
+		Match(
+			OutputLine(7),
+			Separator,
+			"A\t\t100.0%",
+			"B\t\t0.0%",
+		),
 
-			// Check manual running of `go build -gcflags=-m`.
-			Match(OutputLine(10), Separator),
-			Match("can inline (*Point).ManhattanLen"),
-			Match("p does not escape"),
-		), *flagPrintNotebook)
+		// Check full reset.
+		Match(
+			OutputLine(8),
+			Separator,
+			"State reset: all memorized declarations discarded",
+			Separator,
+		),
 
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+		// Check manual running of `go build -gcflags=-m`.
+		Match(OutputLine(10), Separator),
+		Match("can inline (*Point).ManhattanLen"),
+		Match("p does not escape"),
+	)
 }
 
 // TestGoTest tests support for `%test` to run cells with `go test`.
@@ -428,85 +504,77 @@ func TestGoTest(t *testing.T) {
 		return
 	}
 	notebook := "gotest"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			// Trivial Incr function defined.
-			Match(
-				OutputLine(2),
-				Separator,
-				"55",
-				"2178309",
-				"2178309",
-				Separator,
-			),
-
-			// TestA checks Incr.
-			Match(
-				OutputLine(3),
-				Separator,
-				"RUN   TestA",
-				"Testing A",
-				"PASS: TestA",
-				"PASS",
-				// There is some output about coverage that follows.
-			),
-
-			// Checks TestA declaration is memorized.
-			Match(OutputLine(4), Separator),
-			Match("TestA"),
-			Match(InputLine(5)),
+	runNotebookTest(t, notebook,
+		// Trivial Incr function defined.
+		Match(
+			OutputLine(2),
+			Separator,
+			"55",
+			"2178309",
+			"2178309",
+			Separator,
+		),
 
-			// If no test is defined in cell, all tests are run (TestA in this case).
-			Match(
-				OutputLine(5),
-				Separator,
-				"RUN   TestA",
-				"Testing A",
-				"PASS: TestA",
-				"PASS",
-				// There is some output about coverage that follows.
-			),
+		// TestA checks Incr.
+		Match(
+			OutputLine(3),
+			Separator,
+			"RUN   TestA",
+			"Testing A",
+			"PASS: TestA",
+			"PASS",
+			// There is some output about coverage that follows.
+		),
 
-			// If cells are defined in cell, only tests of cell are run, TestA
-			// should be excluded.
-			Match(
-				OutputLine(6),
-				Separator,
-				"RUN   TestAB",
-				"Testing AB",
-				"PASS: TestAB",
-				"RUN   TestB",
-				"Testing B",
-				"PASS: TestB",
-				"PASS",
-				// There is some output about coverage that follows.
-			),
+		// Checks TestA declaration is memorized.
+		Match(OutputLine(4), Separator),
+		Match("TestA"),
+		Match(InputLine(5)),
 
-			// Passed args to `go test`, so `--test.v` is disabled.
-			Match(
-				OutputLine(7),
-				Separator,
-				"Testing A",
-				"Testing AB",
-				"Testing B",
-				"PASS",
-				// There is some output about coverage that follows.
-			),
+		// If no test is defined in cell, all tests are run (TestA in this case).
+		Match(
+			OutputLine(5),
+			Separator,
+			"RUN   TestA",
+			"Testing A",
+			"PASS: TestA",
+			"PASS",
+			// There is some output about coverage that follows.
+		),
 
-			// Check that both benchmarks run.
-			Match(OutputLine(8), Separator),
-			Match("BenchmarkFibonacciA32"),
-			Match("BenchmarkFibonacciB32"),
-			Match("PASS"),
+		// If cells are defined in cell, only tests of cell are run, TestA
+		// should be excluded.
+		Match(
+			OutputLine(6),
+			Separator,
+			"RUN   TestAB",
+			"Testing AB",
+			"PASS: TestAB",
+			"RUN   TestB",
+			"Testing B",
+			"PASS: TestB",
+			"PASS",
 			// There is some output about coverage that follows.
+		),
 
-		), *flagPrintNotebook)
+		// Passed args to `go test`, so `--test.v` is disabled.
+		Match(
+			OutputLine(7),
+			Separator,
+			"Testing A",
+			"Testing AB",
+			"Testing B",
+			"PASS",
+			// There is some output about coverage that follows.
+		),
 
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+		// Check that both benchmarks run.
+		Match(OutputLine(8), Separator),
+		MatchRegexp(`^BenchmarkFibonacciA32(-\d+)?\s+\d+\s+[\d.]+ ns/op`),
+		MatchRegexp(`^BenchmarkFibonacciB32(-\d+)?\s+\d+\s+[\d.]+ ns/op`),
+		Match("PASS"),
+		// There is some output about coverage that follows.,
+	)
 }
 
 func TestBashScript(t *testing.T) {
@@ -515,42 +583,35 @@ func TestBashScript(t *testing.T) {
 		return
 	}
 	notebook := "bash_script"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-
-			// Trivial "echo hello" .
-			Match(
-				OutputLine(1),
-				Separator,
-				"hello",
-				Separator,
-			),
+	runNotebookTest(t, notebook,
 
-			// Trivial "echo hello" .
-			Match(
-				OutputLine(2),
-				Separator,
-				"/gonb_", // gonb_??? directory created in a temporary subdirectory, usually "/tmp".
-				Separator,
-			),
+		// Trivial "echo hello" .
+		Match(
+			OutputLine(1),
+			Separator,
+			"hello",
+			Separator,
+		),
 
-			// GoNB environment variables:
-			Match(
-				OutputLine(3),
-				Separator,
-				rootDir+"/examples/tests", // subdirectory where it is executed.
-				"/gonb_",                  // within a temporary directory.
-				rootDir,                   // root directory where jupyter (nbconvert) was executed.
-				rootDir,                   // Git root directory used for testing.
-				Separator,
-			),
-		), *flagPrintNotebook)
+		// Trivial "echo hello" .
+		Match(
+			OutputLine(2),
+			Separator,
+			"/gonb_", // gonb_??? directory created in a temporary subdirectory, usually "/tmp".
+			Separator,
+		),
 
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+		// GoNB environment variables:
+		Match(
+			OutputLine(3),
+			Separator,
+			rootDir+"/examples/tests", // subdirectory where it is executed.
+			"/gonb_",                  // within a temporary directory.
+			rootDir,                   // root directory where jupyter (nbconvert) was executed.
+			rootDir,                   // Git root directory used for testing.
+			Separator,
+		),
+	)
 }
 
 // TestWasm checks that the environment variables are created.
@@ -615,49 +676,95 @@ func TestGonbui(t *testing.T) {
 
 	require.NoError(t, os.Setenv("GONB_GIT_ROOT", rootDir))
 	notebook := "gonbui"
-	f := executeNotebook(t, notebook)
-	err := Check(f,
-		Sequence(
-			// Check GONB_GIT_ROOT was recognized.
-			Match(
-				OutputLine(2),
-				Separator,
-				"ok",
-				Separator,
-			),
+	runNotebookTest(t, notebook,
+		// Check GONB_GIT_ROOT was recognized.
+		Match(
+			OutputLine(2),
+			Separator,
+			"ok",
+			Separator,
+		),
 
-			// Check replace rule was created.
-			Match(
-				OutputLine(3),
-				Separator,
-				"Added replace rule for module",
-				Separator,
-			),
+		// Check replace rule was created.
+		Match(
+			OutputLine(3),
+			Separator,
+			"Added replace rule for module",
+			Separator,
+		),
 
-			// Check DisplayHTML.
-			Match(
-				OutputLine(4),
-				Separator,
-				"html displayed",
-				Separator,
-			),
+		// Check DisplayHTML.
+		Match(
+			OutputLine(4),
+			Separator,
+			"html displayed",
+			Separator,
+		),
 
-			// Check DisplayMarkdown.
-			// It doesn't always work on nbconvert (but it works in the Jupyter notebook).
-			// Oddly it used to work earlier. Test disabled for now.
-			// Issue report:
-			// https://github.com/jupyter/nbconvert/issues/2017
-			//
-			//Match(
-			//	OutputLine(5),
-			//	Separator,
-			//	"markdown displayed",
-			//	Separator,
-			//),
-		), *flagPrintNotebook)
+		// Check DisplayMarkdown.
+		// nbconvert doesn't always render markdown (see https://github.com/jupyter/nbconvert/issues/2017),
+		// but DisplayMarkdown also sends a "text/plain" alternative that it falls back to.
+		Match(
+			OutputLine(5),
+			Separator,
+			"markdown displayed",
+			Separator,
+		),
+	)
+}
 
-	require.NoError(t, err)
-	require.NoError(t, f.Close())
-	require.NoError(t, os.Remove(f.Name()))
-	clearNotebook(t, notebook)
+// TestMacro tests recording and replaying cells with Go code with `%macro`.
+func TestMacro(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration (nbconvert) test for short tests.")
+		return
+	}
+	notebook := "macro"
+	runNotebookTest(t, notebook,
+		Match(
+			OutputLine(2),
+			Separator,
+			"Hello macro!",
+			Separator,
+		),
+
+		// Replayed cell uses its own `%args`, and the outer cell keeps its own.
+		Match(
+			OutputLine(4),
+			Separator,
+			"Hello macro!",
+			"Outer outer!",
+			Separator,
+		),
+	)
+}
+
+func TestKeepDiscard(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration (nbconvert) test for short tests.")
+		return
+	}
+	notebook := "keep_discard"
+	runNotebookTest(t, notebook,
+		// `%discard` cell is executed.
+		Match(
+			OutputLine(1),
+			Separator,
+			"lost",
+			Separator,
+		),
+
+		// `%ls` lists the function memorized with `%keep` in a `%show` cell, but not the discarded one
+		// (which would sort in between).
+		Match(OutputLine(4)),
+		Match("kept", "shown"),
+		NotMatch("lost", Match(InputLine(5))),
+
+		Match(
+			OutputLine(5),
+			Separator,
+			"kept shown",
+			Separator,
+		),
+	)
 }
@@ -0,0 +1,108 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file handles the commands %alias and %unalias, which allow the user to define shortcuts
+// for special commands.
+
+// execAlias executes the "%alias" special command. The parameter `def` excludes "%alias", and
+// it is expected to be in the format `<name> = <expansion>`.
+//
+// If `def` is empty, it lists the currently defined aliases.
+func execAlias(msg kernel.Message, goExec *goexec.State, def string) error {
+	def = strings.TrimSpace(def)
+	if def == "" {
+		listAliases(msg, goExec)
+		return nil
+	}
+	eqPos := strings.Index(def, "=")
+	if eqPos < 0 {
+		return errors.Errorf("`%%alias <name> = <expansion>`: missing \"=\" in %q", def)
+	}
+	name := strings.TrimSpace(def[:eqPos])
+	expansion := strings.TrimSpace(def[eqPos+1:])
+	expansion = strings.TrimPrefix(expansion, "%")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return errors.Errorf("`%%alias <name> = <expansion>`: invalid alias name %q", name)
+	}
+	if name == "alias" || name == "unalias" {
+		return errors.Errorf("`%%alias`: %q cannot be aliased", name)
+	}
+	if expansion == "" {
+		return errors.Errorf("`%%alias <name> = <expansion>`: empty expansion for alias %q", name)
+	}
+	goExec.Aliases[name] = expansion
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Alias: %%%s = %%%s\n", name, expansion))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execUnalias executes the "%unalias" special command. The parameter `args` excludes
+// "%unalias".
+func execUnalias(msg kernel.Message, goExec *goexec.State, args []string) {
+	if len(args) == 0 {
+		listAliases(msg, goExec)
+		return
+	}
+	for _, name := range args {
+		var err error
+		if _, found := goExec.Aliases[name]; !found {
+			err = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+				fmt.Sprintf("Alias %q not defined.\n", name))
+		} else {
+			delete(goExec.Aliases, name)
+			err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+				fmt.Sprintf(". removed alias %s\n", name))
+		}
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+			return
+		}
+	}
+}
+
+// listAliases publishes the currently defined aliases.
+func listAliases(msg kernel.Message, goExec *goexec.State) {
+	var sb strings.Builder
+	if len(goExec.Aliases) == 0 {
+		sb.WriteString("No aliases defined.\n")
+	} else {
+		for _, name := range common.SortedKeys(goExec.Aliases) {
+			sb.WriteString(fmt.Sprintf("%%alias %s = %s\n", name, goExec.Aliases[name]))
+		}
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+	if err != nil {
+		klog.Errorf("Failed to publish list of aliases to Jupyter: %+v", err)
+	}
+}
+
+// expandAlias replaces the command name in `cmdStr` (without the leading "%") by its alias
+// expansion, if one is defined. The remaining arguments are appended to the expansion.
+//
+// Aliases are expanded only once, that is, an alias expansion is not checked for further aliases.
+func expandAlias(goExec *goexec.State, cmdStr string) string {
+	if len(goExec.Aliases) == 0 {
+		return cmdStr
+	}
+	name, rest := cmdStr, ""
+	if pos := strings.IndexAny(cmdStr, " \t\n"); pos >= 0 {
+		name, rest = cmdStr[:pos], cmdStr[pos:]
+	}
+	expansion, found := goExec.Aliases[name]
+	if !found {
+		return cmdStr
+	}
+	return expansion + rest
+}
@@ -0,0 +1,35 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "alias gv = goflags -gcflags=-m", status))
+	assert.Equal(t, "goflags -gcflags=-m", s.Aliases["gv"])
+	assert.Equal(t, "goflags -gcflags=-m -v", expandAlias(s, "gv -v"))
+	assert.Equal(t, "goflags", expandAlias(s, "goflags"))
+
+	// Executing the alias.
+	require.NoError(t, execSpecialConfig(msg, s, 0, "gv", status))
+	assert.Equal(t, []string{"-gcflags=-m"}, s.GoBuildFlags)
+
+	// Invalid definitions.
+	assert.Error(t, execSpecialConfig(msg, s, 0, "alias gv goflags", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "alias = goflags", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "alias gv =", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "alias alias = goflags", status))
+
+	// Removing it.
+	require.NoError(t, execSpecialConfig(msg, s, 0, "unalias gv", status))
+	assert.Empty(t, s.Aliases)
+	assert.Equal(t, "gv -v", expandAlias(s, "gv -v"))
+}
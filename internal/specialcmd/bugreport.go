@@ -0,0 +1,126 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+)
+
+// This file implements `%bugreport`, which collects the information useful to file an issue.
+
+// maxBugReportErrorLines is the maximum number of lines of each of the recent errors included in `%bugreport`.
+const maxBugReportErrorLines = 20
+
+// execBugReport executes the "%bugreport" special command: it displays a markdown block, ready to be copied
+// to an issue, with the versions of GoNB and Go, the notebook's `go.mod`, the tracked paths, the status of
+// `gopls` and the recent errors. The parameter `args` excludes "%bugreport".
+func execBugReport(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("`%%bugreport` takes no arguments, got %q", args)
+	}
+	report := redactReport(msg, goExec, bugReport(goExec))
+	err := kernel.PublishMarkdown(msg, "Copy the report below to a new issue in "+
+		"https://github.com/janpfeifer/gonb/issues, along with the steps to reproduce the problem. "+
+		"Secrets are redacted, but please review it before posting.\n\n"+
+		"````markdown\n"+report+"````\n")
+	if err != nil {
+		klog.Errorf("Failed to publish %%bugreport results back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// bugReport returns the contents of the `%bugreport`, formatted as markdown.
+func bugReport(goExec *goexec.State) string {
+	var sb strings.Builder
+	w := func(format string, args ...any) {
+		sb.WriteString(fmt.Sprintf(format, args...))
+	}
+	w("### GoNB bug report\n\n")
+	kernelVersion := goexec.KernelVersion()
+	if kernelVersion == "" {
+		kernelVersion = "unknown (development build)"
+	}
+	w("- GoNB version: %s, compiled with %s\n", kernelVersion, runtime.Version())
+	w("- OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	goVersion, err := goExec.GoVersion()
+	if err != nil {
+		goVersion = "unknown: " + err.Error()
+	}
+	w("- Go toolchain: %s (%s)\n", goExec.GoBinary(), goVersion)
+	required, replacement, err := goExec.GonbuiVersion()
+	switch {
+	case err != nil:
+		w("- gonbui: unknown: %v\n", err)
+	case replacement != "":
+		w("- gonbui: replaced by %s\n", replacement)
+	case required == "":
+		w("- gonbui: not used by the notebook\n")
+	default:
+		w("- gonbui: %s\n", required)
+	}
+	if goExec.HasGopls() {
+		w("- gopls: available\n")
+	} else {
+		w("- gopls: not found\n")
+	}
+
+	for _, fileName := range []string{"go.mod", "go.work"} {
+		contents, err := os.ReadFile(path.Join(goExec.TempDir, fileName))
+		if err != nil {
+			if fileName == "go.mod" || !os.IsNotExist(err) {
+				w("\n#### %s\n\nFailed to read: %v\n", fileName, err)
+			}
+			continue
+		}
+		w("\n#### %s\n\n```\n%s\n```\n", fileName, strings.TrimSpace(string(contents)))
+	}
+
+	w("\n#### Tracked paths\n\n")
+	statuses := goExec.ListTrackedStatus()
+	if len(statuses) == 0 {
+		w("None.\n")
+	}
+	for _, status := range statuses {
+		module := status.Module
+		if module == "" {
+			module = "no module"
+		}
+		w("- %s (%s, %d file(s) loaded by gopls)\n", status.Path, module, status.NumLoaded)
+	}
+
+	w("\n#### Recent errors\n\n")
+	if len(goExec.RecentErrors) == 0 {
+		w("None.\n")
+	}
+	for _, cellErr := range goExec.RecentErrors {
+		lines := strings.Split(strings.TrimSpace(cellErr.Value), "\n")
+		if len(lines) > maxBugReportErrorLines {
+			lines = append(lines[:maxBugReportErrorLines], "...")
+		}
+		w("- Cell %d at %s, %s:\n  ```\n  %s\n  ```\n", cellErr.ExecCount, cellErr.Time.Format("15:04:05"),
+			cellErr.Name, strings.Join(lines, "\n  "))
+	}
+	return sb.String()
+}
+
+// redactReport masks the secrets in the report: the values of the environment variables named like secrets
+// (see `%config secret_env_patterns`) and the secrets registered with `%secret add`.
+func redactReport(msg kernel.Message, goExec *goexec.State, report string) string {
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		// Very short values would mask unrelated parts of the report.
+		if len(value) >= 4 && isSecretEnvName(goExec, name) {
+			report = strings.ReplaceAll(report, value, maskedValue)
+		}
+	}
+	if msg != nil && msg.Kernel() != nil {
+		report = msg.Kernel().Redact(report)
+	}
+	return report
+}
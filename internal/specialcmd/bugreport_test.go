@@ -0,0 +1,32 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBugReport(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	t.Setenv("GONB_TEST_TOKEN", "my-very-secret-token")
+	for ii := 0; ii < 7; ii++ {
+		s.RecordCellError(ii+1, errors.Errorf("failure of cell #%d: my-very-secret-token", ii+1))
+	}
+	require.Len(t, s.RecentErrors, 5)
+	assert.Equal(t, 3, s.RecentErrors[0].ExecCount)
+
+	var msg kernel.Message
+	report := redactReport(msg, s, bugReport(s))
+	assert.Contains(t, report, "GoNB version")
+	assert.Contains(t, report, "#### go.mod")
+	assert.Contains(t, report, "module gonb_"+s.UniqueID)
+	assert.NotContains(t, report, "failure of cell #2")
+	assert.Contains(t, report, "failure of cell #7: "+maskedValue)
+	assert.NotContains(t, report, "my-very-secret-token")
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "bugreport", &cellStatus{}))
+	require.Error(t, execSpecialConfig(msg, s, 0, "bugreport now", &cellStatus{}))
+}
@@ -0,0 +1,30 @@
+package specialcmd
+
+import (
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// execBuild executes the "%build [--output <path>]" special command. The parameter `args` excludes "%build".
+// It only marks the cell to be compiled without executing it, the build itself happens in goexec.
+func execBuild(goExec *goexec.State, args []string) error {
+	goExec.CellIsBuildOnly = true
+	goExec.CellBuildOutput = ""
+	switch {
+	case len(args) == 0:
+		return nil
+	case len(args) == 2 && args[0] == "--output":
+		goExec.CellBuildOutput = args[1]
+	case len(args) == 1 && strings.HasPrefix(args[0], "--output="):
+		goExec.CellBuildOutput = strings.TrimPrefix(args[0], "--output=")
+	default:
+		return errors.Errorf("invalid `%%build` arguments %q, use `%%build [--output <path>]`", args)
+	}
+	if goExec.CellBuildOutput == "" {
+		return errors.Errorf("`%%build --output` requires the path where to write the binary")
+	}
+	goExec.CellBuildOutput = ReplaceEnvVars(ReplaceTildeInDir(goExec.CellBuildOutput))
+	return nil
+}
@@ -4,13 +4,17 @@ import (
 	. "github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/jpyexec"
 
+	"bytes"
 	"fmt"
+	"github.com/janpfeifer/gonb/gonbui/protocol"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/pkg/errors"
 	"k8s.io/klog/v2"
 	"os"
+	"strconv"
 	"strings"
+	"text/template"
 )
 
 var (
@@ -18,7 +22,8 @@ var (
 		"%%writefile",
 		"%%script",
 		"%%bash",
-		"%%sh")
+		"%%sh",
+		"%%sql")
 )
 
 // IsGoCell returns whether the cell is expected to be a Go cell, based on the first line.
@@ -61,6 +66,9 @@ func ExecuteSpecialCell(msg kernel.Message, goExec *goexec.State, lines []string
 		}
 		err = cellCmdScript(msg, goExec, args, lines[1:])
 
+	case "%%sql":
+		err = cellCmdSql(msg, goExec, parts[1:], lines[1:])
+
 	default:
 		err = errors.Errorf("special cell command %q not implemented", parts[0])
 	}
@@ -69,29 +77,175 @@ func ExecuteSpecialCell(msg kernel.Message, goExec *goexec.State, lines []string
 
 // cellCmdWritefile implements `%%writefile`.
 func cellCmdWritefile(msg kernel.Message, goExec *goexec.State, args []string, lines []string) error {
-	var appendToFile bool
-	if len(args) > 1 && args[0] == "-a" {
-		appendToFile = true
-		args = args[1:]
-	}
-	if len(args) != 1 {
-		return errors.Errorf("expected \"%%%%writefile [-a] <file_name>\", but got %q instead", args)
+	opts, err := parseWritefileArgs(args)
+	if err != nil {
+		return err
 	}
-	filePath := args[0]
+	filePath := opts.filePath
 	filePath = ReplaceTildeInDir(filePath)
 	filePath = ReplaceEnvVars(filePath)
-	err := writeLinesToFile(filePath, lines, appendToFile)
+	if opts.template {
+		// Expand the whole content first, so template errors don't leave partially written files.
+		lines, err = expandWritefileTemplate(goExec, lines)
+		if err != nil {
+			return err
+		}
+	}
+	err = writeLinesToFile(filePath, lines, opts.appendToFile)
 	if err != nil {
 		return err
 	}
-	if appendToFile {
-		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("Cell contents appended to %q.\n", filePath))
+	var modeReport string
+	if opts.mode != nil || opts.addExec {
+		mode, err := chmodWrittenFile(filePath, opts.mode, opts.addExec)
+		if err != nil {
+			return err
+		}
+		modeReport = fmt.Sprintf(" (mode %s)", mode)
+	}
+	if opts.appendToFile {
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("Cell contents appended to %q%s.\n", filePath, modeReport))
 	} else {
-		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("Cell contents written to %q.\n", filePath))
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("Cell contents written to %q%s.\n", filePath, modeReport))
 	}
 	return nil
 }
 
+// writefileOptions are the options of `%%writefile`, see parseWritefileArgs.
+type writefileOptions struct {
+	filePath     string
+	appendToFile bool
+	mode         *os.FileMode // If set, the permissions of the file.
+	addExec      bool         // If set, adds the executable bit to the file (wherever it is readable).
+	template     bool         // If set, the contents are expanded as a text/template, see expandWritefileTemplate.
+}
+
+// writefileUsage is the usage reported in errors of `%%writefile`.
+const writefileUsage = "%%writefile [-a] [--template] [--mode <octal_mode>] [--chmod +x] <file_name>"
+
+// parseWritefileArgs parses the arguments of `%%writefile`, excluding the "%%writefile" itself.
+func parseWritefileArgs(args []string) (opts writefileOptions, err error) {
+	var positional []string
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		var value string
+		if name, v, found := strings.Cut(arg, "="); found && (name == "--mode" || name == "--chmod") {
+			arg, value = name, v
+		} else if arg == "--mode" || arg == "--chmod" {
+			if ii+1 >= len(args) {
+				err = errors.Errorf("missing value for %s, expected %q", arg, writefileUsage)
+				return
+			}
+			ii++
+			value = args[ii]
+		}
+		switch arg {
+		case "-a":
+			opts.appendToFile = true
+		case "--template":
+			opts.template = true
+		case "--mode":
+			var mode uint64
+			mode, err = strconv.ParseUint(value, 8, 32)
+			if err != nil || mode > 0777 {
+				err = errors.Errorf("invalid --mode %q, expected an octal permission like 0644 or 0755", value)
+				return
+			}
+			fileMode := os.FileMode(mode)
+			opts.mode = &fileMode
+		case "--chmod":
+			if value != "+x" {
+				err = errors.Errorf("invalid --chmod %q, only \"+x\" is supported", value)
+				return
+			}
+			opts.addExec = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 1 {
+		err = errors.Errorf("expected %q, but got %q instead", writefileUsage, args)
+		return
+	}
+	opts.filePath = positional[0]
+	return
+}
+
+// chmodWrittenFile changes the permissions of filePath to mode, if given, and then adds the executable
+// bit wherever the file is readable, if addExec is set. It returns the final mode of the file.
+func chmodWrittenFile(filePath string, mode *os.FileMode, addExec bool) (os.FileMode, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to stat %q", filePath)
+	}
+	newMode := info.Mode().Perm()
+	if mode != nil {
+		newMode = *mode
+	}
+	if addExec {
+		newMode |= (newMode & 0444) >> 2
+	}
+	if err = os.Chmod(filePath, newMode); err != nil {
+		return 0, errors.Wrapf(err, "failed to change mode of %q to %s", filePath, newMode)
+	}
+	return newMode, nil
+}
+
+// writefileTemplateData is the data available to the contents of `%%writefile --template`.
+type writefileTemplateData struct {
+	// GONB_DIR is the directory from where commands are executed, see `%cd`.
+	GONB_DIR string
+
+	// TempDir is the temporary directory where the cells are compiled. Also available as GONB_TMP_DIR.
+	TempDir, GONB_TMP_DIR string
+
+	// Env holds the environment variables, e.g.: `{{.Env.HOME}}`.
+	Env map[string]string
+
+	// Vars holds the memorized variables and constants, e.g.: `{{.Vars.myVar}}`. Since they are not evaluated,
+	// the values are their definition in Go, except for string literals, which are unquoted.
+	Vars map[string]string
+}
+
+// expandWritefileTemplate expands the lines as a text/template, with writefileTemplateData.
+func expandWritefileTemplate(goExec *goexec.State, lines []string) ([]string, error) {
+	tmpl, err := template.New("%%writefile").Option("missingkey=error").Parse(strings.Join(lines, "\n"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse %%writefile template, nothing written")
+	}
+	data := writefileTemplateData{
+		GONB_DIR:     os.Getenv(protocol.GONB_DIR_ENV),
+		TempDir:      goExec.TempDir,
+		GONB_TMP_DIR: goExec.TempDir,
+		Env:          make(map[string]string),
+		Vars:         make(map[string]string),
+	}
+	for _, keyValue := range os.Environ() {
+		if key, value, found := strings.Cut(keyValue, "="); found {
+			data.Env[key] = value
+		}
+	}
+	for _, variable := range goExec.Definitions.Variables {
+		data.Vars[variable.Name] = unquoteDefinition(variable.ValueDefinition)
+	}
+	for _, constant := range goExec.Definitions.Constants {
+		data.Vars[constant.Key] = unquoteDefinition(constant.ValueDefinition)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, &data); err != nil {
+		return nil, errors.Wrap(err, "failed to execute %%writefile template, nothing written")
+	}
+	return strings.Split(buf.String(), "\n"), nil
+}
+
+// unquoteDefinition returns the value of definition if it is a Go string literal, or definition otherwise.
+func unquoteDefinition(definition string) string {
+	if value, err := strconv.Unquote(definition); err == nil {
+		return value
+	}
+	return definition
+}
+
 // writeLinesToFile. If `append` is true open the file with append.
 func writeLinesToFile(filePath string, lines []string, appendToFile bool) error {
 	var f *os.File
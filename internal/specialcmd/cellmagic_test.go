@@ -0,0 +1,82 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseWritefileArgs(t *testing.T) {
+	opts, err := parseWritefileArgs([]string{"-a", "--mode", "0755", "run.sh"})
+	require.NoError(t, err)
+	assert.Equal(t, "run.sh", opts.filePath)
+	assert.True(t, opts.appendToFile)
+	require.NotNil(t, opts.mode)
+	assert.Equal(t, os.FileMode(0755), *opts.mode)
+
+	opts, err = parseWritefileArgs([]string{"--chmod=+x", "run.sh"})
+	require.NoError(t, err)
+	assert.True(t, opts.addExec)
+	assert.Nil(t, opts.mode)
+
+	for _, args := range [][]string{{}, {"--mode", "0755"}, {"--mode=999", "a"}, {"--mode=01777", "a"}, {"--chmod", "-x", "a"}, {"a", "b"}} {
+		_, err = parseWritefileArgs(args)
+		assert.Errorf(t, err, "parseWritefileArgs(%q) should have failed", args)
+	}
+}
+
+func TestWritefileMode(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	dir := t.TempDir()
+
+	filePath := path.Join(dir, "run.sh")
+	require.NoError(t, ExecuteCell(msg, s, 1, []string{"%%writefile --chmod +x " + filePath, "#!/bin/sh", "echo hello"}))
+	info, err := os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equalf(t, os.FileMode(0100), info.Mode().Perm()&0100, "File should be executable, got mode %s", info.Mode())
+
+	filePath = path.Join(dir, "secret.txt")
+	require.NoError(t, ExecuteCell(msg, s, 2, []string{"%%writefile --mode=0600 " + filePath, "secret"}))
+	info, err = os.Stat(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWritefileTemplate(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	dir := t.TempDir()
+	t.Setenv("GONB_TEST_TEMPLATE", "from_env")
+	s.Definitions.Variables["greeting"] = &goexec.Variable{Key: "greeting", Name: "greeting", ValueDefinition: `"hello"`}
+	s.Definitions.Constants["answer"] = &goexec.Constant{Key: "answer", ValueDefinition: "42"}
+
+	filePath := path.Join(dir, "config.yaml")
+	require.NoError(t, ExecuteCell(msg, s, 1, []string{
+		"%%writefile --template " + filePath,
+		"tmp: {{.TempDir}}",
+		"env: {{.Env.GONB_TEST_TEMPLATE}}",
+		"vars: {{.Vars.greeting}} {{.Vars.answer}}",
+	}))
+	contents, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "tmp: "+s.TempDir+"\nenv: from_env\nvars: hello 42\n", string(contents))
+
+	// Raw writing is the default.
+	require.NoError(t, ExecuteCell(msg, s, 2, []string{"%%writefile " + filePath, "{{.TempDir}}"}))
+	contents, err = os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "{{.TempDir}}\n", string(contents))
+
+	// Template errors don't write anything.
+	filePath = path.Join(dir, "missing.txt")
+	assert.Error(t, ExecuteCell(msg, s, 3, []string{"%%writefile --template " + filePath, "{{.Vars.unknown}}"}))
+	assert.Error(t, ExecuteCell(msg, s, 4, []string{"%%writefile --template " + filePath, "{{.Vars"}))
+	assert.NoFileExists(t, filePath)
+}
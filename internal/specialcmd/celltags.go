@@ -0,0 +1,149 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"k8s.io/klog/v2"
+	"strings"
+	"time"
+)
+
+// This file handles the Jupyter cell tags that change how a cell is executed, following the nbconvert/papermill
+// conventions.
+//
+// Standard front-ends (Jupyter Notebook, JupyterLab) don't send the cell tags in the "execute_request" message,
+// only some clients do (e.g.: papermill). So the tags can also be set with `%tag <tags...>` in the cell itself.
+
+// cellTags holds the cell tags honored by GoNB. Other tags are ignored.
+type cellTags struct {
+	// skip the execution of the cell: tags "skip" or "skip-execution".
+	skip bool
+
+	// raisesException makes a failure of the cell not be reported as an error: tag "raises-exception".
+	raisesException bool
+
+	// parameters marks the cell whose variables can be overridden by injected values (papermill convention): tag
+	// "parameters".
+	parameters bool
+
+	// timeout, if > 0, overrides `%config exec_timeout` for the cell: tag "timeout=<duration>", e.g.: "timeout=10s".
+	timeout time.Duration
+}
+
+// metadataTags returns the tags in the metadata of an "execute_request" message, under the key "tags".
+func metadataTags(metadata map[string]any) []string {
+	values, _ := metadata["tags"].([]any)
+	var tags []string
+	for _, value := range values {
+		if tag, ok := value.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// extractTagLines returns the tags given by the `%tag <tags...>` lines of the cell, and replaces these lines
+// by empty ones, so the line numbers of the cell are preserved.
+//
+// Only the leading special command lines (starting with "%" or "!") of the cell are considered: it stops at the
+// first cell magic (e.g.: `%%writefile` or the `%%go` fence) or at the first line of code, whose contents are
+// left untouched.
+func extractTagLines(lines []string) (tags []string) {
+	for ii, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%%") || (trimmed[0] != '%' && trimmed[0] != '!') {
+			break
+		}
+		fields := strings.Fields(trimmed)
+		if fields[0] != "%tag" {
+			continue
+		}
+		tags = append(tags, fields[1:]...)
+		lines[ii] = ""
+	}
+	return
+}
+
+// parseCellTags parses the tags of the cell.
+func parseCellTags(values []string) (tags cellTags, err error) {
+	for _, tag := range values {
+		switch {
+		case tag == "skip" || tag == "skip-execution":
+			tags.skip = true
+		case tag == "raises-exception":
+			tags.raisesException = true
+		case tag == "parameters":
+			tags.parameters = true
+		case strings.HasPrefix(tag, "timeout="):
+			tags.timeout, err = time.ParseDuration(strings.TrimPrefix(tag, "timeout="))
+			if err != nil || tags.timeout <= 0 {
+				err = errors.Errorf("invalid cell tag %q, expected a positive duration, e.g.: timeout=10s", tag)
+				return
+			}
+		}
+	}
+	return
+}
+
+// ExecuteTaggedCell executes the cell like ExecuteCell, but honoring the tags of the cell, passed in the metadata of
+// the "execute_request" message, or set with `%tag <tags...>` lines in the cell (see parseCellTags):
+//
+//   - "skip" or "skip-execution": the cell is not executed.
+//   - "raises-exception": if the cell fails, the error is displayed, but the execution is reported as successful.
+//   - "timeout=<duration>": the program of the cell is interrupted after the given duration.
+//   - "parameters": the values of the variables declared in the cell are replaced by the parameters injected, see
+//     injectedParameters.
+func ExecuteTaggedCell(msg kernel.Message, goExec *goexec.State, cellId int, lines []string) error {
+	var metadata map[string]any
+	if msg != nil {
+		metadata = msg.ComposedMsg().Metadata
+	}
+	lines = slices.Clone(lines)
+	tags, err := parseCellTags(append(metadataTags(metadata), extractTagLines(lines)...))
+	if err != nil {
+		return err
+	}
+	if tags.skip {
+		klog.V(1).Infof("Cell %d skipped, tagged with \"skip\"", cellId)
+		return nil
+	}
+	if tags.parameters {
+		goExec.CellParameters, err = injectedParameters(metadata)
+		if err != nil {
+			return err
+		}
+		defer func() { goExec.CellParameters = nil }()
+	}
+	if tags.timeout > 0 {
+		previousTimeout := goExec.ExecTimeout
+		goExec.ExecTimeout = tags.timeout
+		defer func() { goExec.ExecTimeout = previousTimeout }()
+	}
+	err = executeRepeatedCell(msg, goExec, cellId, lines)
+	if err != nil && tags.raisesException {
+		name, value, _ := goexec.JupyterErrorSplit(err)
+		err = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("%s: %s\n(error expected, cell tagged with \"raises-exception\")\n", name, value))
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+		return nil
+	}
+	return err
+}
+
+// injectedParameters returns the parameters to inject in the cell tagged "parameters", converted to Go literals:
+// they are taken from the "parameters" entry of the metadata of the "execute_request" message, or, if not
+// present, from the environment variable goexec.ParametersEnv.
+func injectedParameters(metadata map[string]any) (map[string]string, error) {
+	if values, ok := metadata["parameters"].(map[string]any); ok {
+		return goexec.ParametersToGo(values)
+	}
+	return goexec.ParametersFromEnv()
+}
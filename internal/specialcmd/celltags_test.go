@@ -0,0 +1,96 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestParseCellTags(t *testing.T) {
+	tags, err := parseCellTags(nil)
+	require.NoError(t, err)
+	assert.Equal(t, cellTags{}, tags)
+
+	tags, err = parseCellTags(metadataTags(map[string]any{
+		"tags": []any{"raises-exception", "timeout=10s", "skip-execution", "unrelated", 3}}))
+	require.NoError(t, err)
+	assert.Equal(t, cellTags{skip: true, raisesException: true, timeout: 10 * time.Second}, tags)
+
+	_, err = parseCellTags([]string{"timeout=never"})
+	assert.Error(t, err)
+}
+
+func TestExtractTagLines(t *testing.T) {
+	lines := []string{"%tag skip", "!echo hello", "", "  %tag raises-exception timeout=1s", "%tags", "x := 1",
+		"%tag parameters"}
+	assert.Equal(t, []string{"skip", "raises-exception", "timeout=1s"}, extractTagLines(lines))
+	assert.Equal(t, []string{"", "!echo hello", "", "", "%tags", "x := 1", "%tag parameters"}, lines,
+		"line numbers should be preserved, and lines after the first line of code untouched")
+
+	// Lines after a cell magic are its contents.
+	lines = []string{"%tag skip", "%%go", "%tag parameters"}
+	assert.Equal(t, []string{"skip"}, extractTagLines(lines))
+	assert.Equal(t, []string{"", "%%go", "%tag parameters"}, lines)
+}
+
+func TestExecuteTaggedCellWriteFile(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	filePath := path.Join(t.TempDir(), "notes.txt")
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, ExecuteTaggedCell(msg, s, 1, []string{
+		"%%writefile " + filePath, "%tag timeout=x", "notes"}))
+	contents, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "%tag timeout=x\nnotes\n", string(contents))
+}
+
+func TestExecuteTaggedCell(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	failingCell := []string{"%readfile /nonexistent/file.txt"}
+	withTags := func(tags ...any) *fakeMessage {
+		return &fakeMessage{kernel: &kernel.Kernel{}, metadata: map[string]any{"tags": tags}}
+	}
+
+	assert.Error(t, ExecuteTaggedCell(withTags(), s, 1, failingCell))
+	assert.NoError(t, ExecuteTaggedCell(withTags("raises-exception"), s, 1, failingCell))
+	assert.NoError(t, ExecuteTaggedCell(withTags("skip"), s, 1, failingCell))
+
+	// Tags set with `%tag`, in the cell.
+	assert.NoError(t, ExecuteTaggedCell(withTags(), s, 1, append([]string{"%tag raises-exception"}, failingCell...)))
+
+	// The timeout is only changed for the cell.
+	s.ExecTimeout = time.Minute
+	assert.Error(t, ExecuteTaggedCell(withTags("timeout=1s"), s, 1, failingCell))
+	assert.Equal(t, time.Minute, s.ExecTimeout)
+}
+
+func TestExecuteTaggedCellTimeout(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	// A no-op goimports is enough, since the cell declares its imports, and no dependencies are fetched.
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(binDir, "goimports"), []byte("#!/bin/sh\nexit 0\n"), 0755))
+	t.Setenv("PATH", binDir+":"+os.Getenv("PATH"))
+	s.AutoGet = false
+
+	cell := []string{
+		"%tag timeout=500ms",
+		`import "time"`,
+		"func main() {",
+		"\ttime.Sleep(time.Minute)",
+		"}",
+	}
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	start := time.Now()
+	require.NoError(t, ExecuteTaggedCell(msg, s, 1, cell))
+	assert.Contains(t, msg.output(), "Timed out after 500ms")
+	assert.Less(t, time.Since(start), 30*time.Second, "the program should have been interrupted by the timeout")
+	assert.Zero(t, s.ExecTimeout, "the timeout is only changed for the cell")
+}
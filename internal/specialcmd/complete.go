@@ -0,0 +1,76 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strconv"
+)
+
+// This file implements `%complete`, which controls the auto-complete suggestions provided by `gopls`.
+
+// completionSettingNames lists the settings of `%complete`, in the order they are reported.
+var completionSettingNames = []string{"deep", "unimported", "limit"}
+
+// getCompletionSetting returns the current value of the completion setting name, one of completionSettingNames.
+func getCompletionSetting(goExec *goexec.State, name string) string {
+	settings := goExec.CompletionSettings()
+	switch name {
+	case "deep":
+		return onOffToString(settings.Deep)
+	case "unimported":
+		return onOffToString(settings.Unimported)
+	case "limit":
+		return strconv.Itoa(settings.MaxResults)
+	}
+	return ""
+}
+
+// setCompletionSetting sets the completion setting name, one of completionSettingNames, to value.
+func setCompletionSetting(goExec *goexec.State, name, value string) error {
+	settings := goExec.CompletionSettings()
+	var err error
+	switch name {
+	case "deep":
+		err = parseOnOff(value, &settings.Deep)
+	case "unimported":
+		err = parseOnOff(value, &settings.Unimported)
+	case "limit":
+		err = parseLimit(value, &settings.MaxResults)
+	default:
+		return errors.Errorf("unknown completion setting %q, valid settings are \"deep\", \"unimported\" and \"limit\"", name)
+	}
+	if err != nil {
+		return err
+	}
+	return goExec.SetCompletionSettings(settings)
+}
+
+// execComplete executes the "%complete [<setting> <value>]" special command. The parameter `args` excludes
+// "%complete". It sets the given completion setting, and reports the current settings.
+func execComplete(msg kernel.Message, goExec *goexec.State, args []string) error {
+	switch len(args) {
+	case 0:
+	case 2:
+		if err := setCompletionSetting(goExec, args[0], args[1]); err != nil {
+			return errors.WithMessagef(err, "`%%complete %s %s`", args[0], args[1])
+		}
+	default:
+		return errors.Errorf("`%%complete [<setting> <value>]` takes a setting and its value, e.g.: " +
+			"`%%complete deep off` or `%%complete limit 20`")
+	}
+	var report string
+	for _, name := range completionSettingNames {
+		report += fmt.Sprintf("%s: %s\n", name, getCompletionSetting(goExec, name))
+	}
+	if !goExec.HasGopls() {
+		report += "(`gopls` is not available, install it with `%install_tool gopls`)\n"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
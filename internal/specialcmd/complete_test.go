@@ -0,0 +1,33 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec/goplsclient"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	assert.Equal(t, goplsclient.DefaultCompletionSettings, s.CompletionSettings())
+	require.NoError(t, execSpecialConfig(msg, s, 0, "complete", status))
+	require.NoError(t, execSpecialConfig(msg, s, 0, "complete deep off", status))
+	require.NoError(t, execSpecialConfig(msg, s, 0, "complete limit 20", status))
+	assert.Equal(t, goplsclient.CompletionSettings{Deep: false, Unimported: true, MaxResults: 20}, s.CompletionSettings())
+
+	// Also available with `%config`.
+	require.NoError(t, execSpecialConfig(msg, s, 0, "config complete_unimported=off complete_limit=0", status))
+	assert.Equal(t, goplsclient.CompletionSettings{}, s.CompletionSettings())
+	assert.Contains(t, configTable(nil, s), "| `complete_deep` | `off` |")
+
+	// Invalid settings and values.
+	assert.Error(t, execSpecialConfig(msg, s, 0, "complete deep", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "complete deep maybe", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "complete limit -1", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "complete fast on", status))
+}
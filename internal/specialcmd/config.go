@@ -0,0 +1,411 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/config"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file handles the command %config, that lists and sets the kernel tunables in one place,
+// and persists them in the user's configuration file (see package config).
+
+// configEntry is a kernel tunable that can be viewed and set with `%config`.
+//
+// The kernel k may be nil (e.g. in tests), in which case the tunables stored in the kernel
+// (needsKernel set to true) are not available.
+type configEntry struct {
+	key, description string
+	needsKernel      bool
+	get              func(k *kernel.Kernel, goExec *goexec.State) string
+	set              func(k *kernel.Kernel, goExec *goexec.State, value string) error
+}
+
+// configEntries lists the tunables available to `%config`, in the order they are displayed.
+var configEntries = []configEntry{
+	{
+		key:         "autoget",
+		description: "Run `go get` before compiling, to fetch missing modules. Same as `%autoget` and `%noautoget`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return onOffToString(goExec.AutoGet)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return parseOnOff(value, &goExec.AutoGet)
+		},
+	},
+	{
+		key:         "build_cache",
+		description: "Use the Go build cache. If off, all packages are rebuilt (`go build -a`) at every execution.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return onOffToString(goExec.BuildCache)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return parseOnOff(value, &goExec.BuildCache)
+		},
+	},
+	{
+		key:         "complete_deep",
+		description: "Deep auto-complete, also suggesting fields and methods of the values in scope. Same as `%complete deep`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return getCompletionSetting(goExec, "deep")
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return setCompletionSetting(goExec, "deep", value)
+		},
+	},
+	{
+		key:         "complete_limit",
+		description: "Maximum number of auto-complete suggestions. 0 for unlimited. Same as `%complete limit`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return getCompletionSetting(goExec, "limit")
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return setCompletionSetting(goExec, "limit", value)
+		},
+	},
+	{
+		key:         "complete_unimported",
+		description: "Auto-complete packages not imported yet. Same as `%complete unimported`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return getCompletionSetting(goExec, "unimported")
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return setCompletionSetting(goExec, "unimported", value)
+		},
+	},
+	{
+		key:         "exec_timeout",
+		description: "Maximum time a cell program runs before it is interrupted, e.g. \"30s\" or \"5m\". 0 for no limit.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return goExec.ExecTimeout.String()
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout < 0 {
+				return errors.Errorf("invalid duration %q", value)
+			}
+			goExec.ExecTimeout = timeout
+			return nil
+		},
+	},
+	{
+		key:         "goget_attempts",
+		description: "Number of attempts to run `go get` (see `autoget`), if it fails due to transient network errors.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return strconv.Itoa(goExec.GoGetAttempts)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			attempts, err := strconv.Atoi(value)
+			if err != nil || attempts < 1 {
+				return errors.Errorf("invalid number of attempts %q, it must be at least 1", value)
+			}
+			goExec.GoGetAttempts = attempts
+			return nil
+		},
+	},
+	{
+		key:         "goget_backoff",
+		description: "Wait before retrying a failed `go get`, e.g. \"1s\". It is doubled for each subsequent retry.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return goExec.GoGetBackoff.String()
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			backoff, err := time.ParseDuration(value)
+			if err != nil || backoff < 0 {
+				return errors.Errorf("invalid duration %q", value)
+			}
+			goExec.GoGetBackoff = backoff
+			return nil
+		},
+	},
+	{
+		key:         "goflags",
+		description: "Flags passed to `go build`, separated by spaces. Same as `%goflags`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return joinCmd(goExec.GoBuildFlags)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			goExec.GoBuildFlags = splitCmd(value)
+			return nil
+		},
+	},
+	{
+		key:         "gowork_auto_use",
+		description: "Add `use` rules to `go.work` (if there is one) for tracked directories with Go modules not yet used.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return onOffToString(goExec.GoWorkAutoUse)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return parseOnOff(value, &goExec.GoWorkAutoUse)
+		},
+	},
+	{
+		key:         "lenient",
+		description: "Downgrade compile errors about unused variables and imports to warnings. Same as `%lenient`.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return onOffToString(goExec.Lenient)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return parseOnOff(value, &goExec.Lenient)
+		},
+	},
+	{
+		key:         "silence_unused_vars",
+		description: "Downgrade compile errors about unused variables to warnings. `lenient` also covers unused imports.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return onOffToString(goExec.SilenceUnusedVariables)
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			return parseOnOff(value, &goExec.SilenceUnusedVariables)
+		},
+	},
+	{
+		key:         "output_max_lines",
+		description: "Maximum number of lines of output displayed per cell. 0 for unlimited. Same as `%output_max_lines`.",
+		needsKernel: true,
+		get: func(k *kernel.Kernel, _ *goexec.State) string {
+			return strconv.Itoa(k.OutputMaxLines)
+		},
+		set: func(k *kernel.Kernel, _ *goexec.State, value string) error {
+			return parseLimit(value, &k.OutputMaxLines)
+		},
+	},
+	{
+		key:         "output_max_bytes",
+		description: "Maximum number of bytes of output displayed per cell. 0 for unlimited.",
+		needsKernel: true,
+		get: func(k *kernel.Kernel, _ *goexec.State) string {
+			return strconv.Itoa(k.OutputMaxBytes)
+		},
+		set: func(k *kernel.Kernel, _ *goexec.State, value string) error {
+			return parseLimit(value, &k.OutputMaxBytes)
+		},
+	},
+	{
+		key:         "secret_env_patterns",
+		description: "Patterns of names of environment variables whose values are masked by `%env`, separated by commas.",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return strings.Join(goExec.SecretEnvPatterns, ",")
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			var patterns []string
+			for _, pattern := range strings.Split(value, ",") {
+				pattern = strings.TrimSpace(pattern)
+				if pattern == "" {
+					continue
+				}
+				if _, err := path.Match(pattern, ""); err != nil {
+					return errors.Errorf("invalid pattern %q", pattern)
+				}
+				patterns = append(patterns, pattern)
+			}
+			goExec.SecretEnvPatterns = patterns
+			return nil
+		},
+	},
+	{
+		key:         "shell",
+		description: "Interpreter used to execute shell commands (lines starting with `!`), invoked with `-c <command>` (`/C` for cmd.exe, `-Command` for PowerShell).",
+		get: func(_ *kernel.Kernel, goExec *goexec.State) string {
+			return goExec.Shell
+		},
+		set: func(_ *kernel.Kernel, goExec *goexec.State, value string) error {
+			if value == "" {
+				return errors.New("shell can't be empty")
+			}
+			goExec.Shell = value
+			return nil
+		},
+	},
+}
+
+// findConfigEntry returns the configuration entry for the given key, or nil if not found.
+func findConfigEntry(key string) *configEntry {
+	for ii := range configEntries {
+		if configEntries[ii].key == key {
+			return &configEntries[ii]
+		}
+	}
+	return nil
+}
+
+// setConfig sets the tunable `key` to the given value.
+func setConfig(k *kernel.Kernel, goExec *goexec.State, key, value string) error {
+	entry := findConfigEntry(key)
+	if entry == nil {
+		return errors.Errorf("unknown configuration key %q", key)
+	}
+	if entry.needsKernel && k == nil {
+		return errors.Errorf("setting %q requires a connection to the kernel", key)
+	}
+	if err := entry.set(k, goExec, value); err != nil {
+		return errors.WithMessagef(err, "setting %q", key)
+	}
+	return nil
+}
+
+// getConfig returns the current value of the configuration entry, and false if it is not available.
+func getConfig(k *kernel.Kernel, goExec *goexec.State, entry *configEntry) (string, bool) {
+	if entry.needsKernel && k == nil {
+		return "", false
+	}
+	return entry.get(k, goExec), true
+}
+
+// execConfig executes the "%config" special command. The parameter `args` excludes "%config".
+//
+// Each argument should be of the form `key=value`. Without arguments, it displays the current
+// configuration as a table. `%config save` saves the current configuration in the user's
+// configuration file.
+func execConfig(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var k *kernel.Kernel
+	if msg != nil {
+		k = msg.Kernel()
+	}
+	if len(args) == 1 && args[0] == "save" {
+		filePath, err := saveConfig(k, goExec)
+		if err != nil {
+			return errors.WithMessagef(err, "`%%config save`")
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Configuration saved to %q.\n", filePath))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		return nil
+	}
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			return errors.Errorf("`%%config %s`: expected `key=value`, see `%%config` for the list of keys", arg)
+		}
+		if err := setConfig(k, goExec, strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return errors.WithMessagef(err, "`%%config %s`", arg)
+		}
+	}
+	if len(args) > 0 {
+		return nil
+	}
+	err := kernel.PublishMarkdown(msg, configTable(k, goExec))
+	if err != nil {
+		klog.Errorf("Failed to publish configuration to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// configTable returns the current configuration formatted as a Markdown table.
+func configTable(k *kernel.Kernel, goExec *goexec.State) string {
+	var sb strings.Builder
+	sb.WriteString("| Key | Value | Description |\n|---|---|---|\n")
+	for ii := range configEntries {
+		entry := &configEntries[ii]
+		value, ok := getConfig(k, goExec, entry)
+		if !ok {
+			value = "n/a"
+		}
+		sb.WriteString(fmt.Sprintf("| `%s` | `%s` | %s |\n", entry.key, value, entry.description))
+	}
+	return sb.String()
+}
+
+// LoadConfig loads the user's configuration file (see config.DefaultPath), if it exists, and
+// applies it to the kernel k and to goExec. It should be called when the kernel starts, so
+// commands executed in the notebook take precedence.
+//
+// Unknown keys or invalid values are logged and skipped, so the kernel can still start.
+func LoadConfig(k *kernel.Kernel, goExec *goexec.State) error {
+	filePath, err := config.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(filePath)
+	if err != nil {
+		return err
+	}
+	for _, key := range SortedKeys(cfg) {
+		if err := setConfig(k, goExec, key, cfg[key]); err != nil {
+			klog.Warningf("Configuration file %q: %+v", filePath, err)
+		}
+	}
+	if len(cfg) > 0 {
+		klog.Infof("Configuration loaded from %q", filePath)
+	}
+	return nil
+}
+
+// saveConfig saves the current configuration to the user's configuration file, and returns its path.
+// The entries that are not available (see getConfig) are not saved.
+func saveConfig(k *kernel.Kernel, goExec *goexec.State) (string, error) {
+	filePath, err := config.DefaultPath()
+	if err != nil {
+		return "", err
+	}
+	cfg := make(config.Config)
+	for ii := range configEntries {
+		entry := &configEntries[ii]
+		if value, ok := getConfig(k, goExec, entry); ok {
+			cfg[entry.key] = value
+		}
+	}
+	return filePath, cfg.Save(filePath)
+}
+
+// parseOnOff parses a boolean configuration value, "on"/"off" or any value accepted by
+// strconv.ParseBool, and stores it in b.
+func parseOnOff(value string, b *bool) error {
+	switch strings.ToLower(value) {
+	case "on":
+		*b = true
+		return nil
+	case "off":
+		*b = false
+		return nil
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return errors.Errorf("invalid value %q, expected \"on\" or \"off\"", value)
+	}
+	*b = v
+	return nil
+}
+
+// onOffToString is the inverse of parseOnOff.
+func onOffToString(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// parseLimit parses a non-negative limit, where 0 means unlimited, and stores it in limit.
+func parseLimit(value string, limit *int) error {
+	if value == "unlimited" {
+		*limit = 0
+		return nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 0 {
+		return errors.Errorf("invalid limit %q, expected a non-negative number (0 for unlimited)", value)
+	}
+	*limit = v
+	return nil
+}
+
+// joinCmd is the inverse of splitCmd: it joins the parts with spaces, quoting those that
+// contain spaces or quotes.
+func joinCmd(parts []string) string {
+	quoted := make([]string, len(parts))
+	for ii, part := range parts {
+		if part == "" || strings.ContainsAny(part, " \t\n\"\\") {
+			part = strconv.Quote(part)
+		}
+		quoted[ii] = part
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,73 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/config"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestConfig(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	assert.True(t, s.AutoGet)
+	assert.True(t, s.BuildCache)
+	assert.Equal(t, goexec.DefaultShell, s.Shell)
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "config autoget=off build_cache=false exec_timeout=30s shell=/bin/sh", status))
+	assert.False(t, s.AutoGet)
+	assert.False(t, s.BuildCache)
+	assert.Equal(t, 30*time.Second, s.ExecTimeout)
+	assert.Equal(t, "/bin/sh", s.Shell)
+
+	// The individual commands are still available.
+	require.NoError(t, execSpecialConfig(msg, s, 0, "autoget", status))
+	assert.True(t, s.AutoGet)
+
+	// Invalid keys and values.
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config unknown=1", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config autoget", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config autoget=maybe", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config exec_timeout=-1s", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config shell=", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "config output_max_lines=10", status), "Requires a kernel")
+
+	table := configTable(nil, s)
+	assert.Contains(t, table, "| `autoget` | `on` |")
+	assert.Contains(t, table, "| `exec_timeout` | `30s` |")
+	assert.Contains(t, table, "| `output_max_lines` | `n/a` |")
+	assert.Contains(t, table, "| `shell` | `/bin/sh` |")
+}
+
+func TestConfigSaveAndLoad(t *testing.T) {
+	filePath := path.Join(t.TempDir(), config.FileName)
+	t.Setenv(config.PathEnvName, filePath)
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	require.NoError(t, execSpecialConfig(msg, s, 0, `config autoget=off "goflags=-race -ldflags \"-s -w\""`, status))
+	assert.Equal(t, []string{"-race", "-ldflags", "-s -w"}, s.GoBuildFlags)
+	require.NoError(t, execSpecialConfig(msg, s, 0, "config save", status))
+	cfg, err := config.Load(filePath)
+	require.NoError(t, err)
+	assert.Equal(t, "off", cfg["autoget"])
+	assert.NotContains(t, cfg, "output_max_lines", "Not available without a kernel")
+
+	// A new kernel loads the saved configuration, skipping invalid entries.
+	cfg["unknown"] = "1"
+	require.NoError(t, cfg.Save(filePath))
+	s2 := newEmptyState(t)
+	defer func() { require.NoError(t, s2.Stop()) }()
+	require.NoError(t, LoadConfig(nil, s2))
+	assert.False(t, s2.AutoGet)
+	assert.Equal(t, s.GoBuildFlags, s2.GoBuildFlags)
+}
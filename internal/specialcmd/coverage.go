@@ -0,0 +1,69 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// This file implements `%capture_coverage` and `%coverage`, to accumulate coverage across cell executions.
+
+// execCaptureCoverage executes the "%capture_coverage [on|off]" special command. The parameter `args` excludes
+// "%capture_coverage". Without arguments, it displays the current setting.
+//
+// If on, programs and tests are compiled with `-cover`, and their coverage data is accumulated, until reported
+// with `%coverage report`.
+func execCaptureCoverage(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%capture_coverage [on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			goExec.CaptureCoverage = true
+		case "off":
+			goExec.CaptureCoverage = false
+		default:
+			return errors.Errorf("`%%capture_coverage [on|off]`: invalid argument %q", args[0])
+		}
+	}
+	state := "off"
+	if goExec.CaptureCoverage {
+		state = "on"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Capture of coverage: %s\n", state))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execCoverage executes the "%coverage report|reset" special command. The parameter `args` excludes "%coverage".
+func execCoverage(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%coverage report|reset`: it takes exactly one argument, but %d were given", len(args))
+	}
+	var report string
+	switch args[0] {
+	case "report":
+		var err error
+		report, err = goExec.CoverageReport()
+		if err != nil {
+			return err
+		}
+	case "reset":
+		if err := goExec.ResetCoverage(); err != nil {
+			return err
+		}
+		report = "Coverage data reset.\n"
+	default:
+		return errors.Errorf("`%%coverage report|reset`: invalid argument %q", args[0])
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
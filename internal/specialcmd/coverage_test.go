@@ -0,0 +1,27 @@
+package specialcmd
+
+import (
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCaptureCoverage(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	require.NoError(t, Parse(nil, s, true, []string{"%capture_coverage on"}, MakeSet[int]()))
+	assert.True(t, s.CaptureCoverage)
+	assert.Error(t, Parse(nil, s, true, []string{"%capture_coverage maybe"}, MakeSet[int]()))
+	assert.Error(t, Parse(nil, s, true, []string{"%coverage report"}, MakeSet[int]()), "No coverage data yet")
+
+	require.NoError(t, os.MkdirAll(s.CoverageDir(), 0755))
+	require.NoError(t, os.WriteFile(path.Join(s.CoverageDir(), "covmeta.0"), nil, 0644))
+	require.NoError(t, Parse(nil, s, true, []string{"%coverage reset"}, MakeSet[int]()))
+	assert.NoDirExists(t, s.CoverageDir())
+
+	require.NoError(t, Parse(nil, s, true, []string{"%capture_coverage off"}, MakeSet[int]()))
+	assert.False(t, s.CaptureCoverage)
+}
@@ -0,0 +1,40 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execGoTarget executes the "%goos [<os>]" and "%goarch [<arch>]" special commands, given by `cmd`. The
+// parameter `args` excludes the command. Without arguments, the default of the Go toolchain is used.
+func execGoTarget(msg kernel.Message, goExec *goexec.State, cmd string, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%%s` takes at most one argument, but %d were given", cmd, len(args))
+	}
+	var value string
+	if len(args) == 1 {
+		value = args[0]
+	}
+	goos, goarch := goExec.GoOS, goExec.GoArch
+	if cmd == "goos" {
+		goos = value
+	} else {
+		goarch = value
+	}
+	if err := goExec.SetTarget(goos, goarch); err != nil {
+		return errors.WithMessagef(err, "`%%%s`", cmd)
+	}
+	report := fmt.Sprintf("Compiling for %s.\n", goExec.Target())
+	if goExec.IsCrossCompiling() {
+		report = fmt.Sprintf("Compiling for %s: cells are cross-compiled, but not executed. "+
+			"Use `%%goos` and `%%goarch` without arguments to compile for the host platform again.\n", goExec.Target())
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
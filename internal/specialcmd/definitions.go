@@ -5,12 +5,14 @@ import (
 	"github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"html"
 	"k8s.io/klog/v2"
 	"strings"
 )
 
-// This file handles the commands %list (or %ls), %remove (%rm) and %reset, which help manipulate
-// memorized definitions.
+// This file handles the commands %list (or %ls), %cat, %remove (%rm), %rename, %reset and %goimports, which
+// help manipulate memorized definitions.
 
 // reset removes all definitions memorized, as if the kernel had been reset.
 func resetDefinitions(msg kernel.Message, goExec *goexec.State) {
@@ -38,14 +40,27 @@ func displayEnumeration(msg kernel.Message, title string, items []string) {
 	}
 }
 
-// listDefinitions lists all memorized definitions. It implements the "%list" (or "%ls") command.
+// listDefinitions lists all memorized definitions, and where they were defined. It implements the "%list"
+// (or "%ls") command.
 func listDefinitions(msg kernel.Message, goExec *goexec.State) {
 	_ = kernel.PublishHtml(msg, "<h3>Memorized Definitions</h3>\n")
-	displayEnumeration(msg, "Imports", common.SortedKeys(goExec.Definitions.Imports))
-	displayEnumeration(msg, "Constants", common.SortedKeys(goExec.Definitions.Constants))
-	displayEnumeration(msg, "Types", common.SortedKeys(goExec.Definitions.Types))
-	displayEnumeration(msg, "Variables", common.SortedKeys(goExec.Definitions.Variables))
-	displayEnumeration(msg, "Functions", common.SortedKeys(goExec.Definitions.Functions))
+	displayEnumeration(msg, "Imports", definitionItems(goExec.Definitions.Imports))
+	displayEnumeration(msg, "Constants", definitionItems(goExec.Definitions.Constants))
+	displayEnumeration(msg, "Types", definitionItems(goExec.Definitions.Types))
+	displayEnumeration(msg, "Variables", definitionItems(goExec.Definitions.Variables))
+	displayEnumeration(msg, "Functions", definitionItems(goExec.Definitions.Functions))
+}
+
+// definitionItems returns the sorted keys of the definitions, each followed by where it was defined, if known,
+// e.g.: "MyFunc  // cell 4, line 2".
+func definitionItems[T interface{ Origin() string }](definitions map[string]T) []string {
+	keys := common.SortedKeys(definitions)
+	for ii, key := range keys {
+		if origin := definitions[key].Origin(); origin != "" {
+			keys[ii] = key + "  // " + origin
+		}
+	}
+	return keys
 }
 
 func removeDefinitionImpl[T any](msg kernel.Message, mapName string, m *map[string]*T, key string) bool {
@@ -63,9 +78,20 @@ func removeDefinitionImpl[T any](msg kernel.Message, mapName string, m *map[stri
 }
 
 // removeDefinitions from the memorized list. It implements the "%remove" (or "%rm") command.
+//
+// The keys following `--type` are types, removed along with their methods.
 func removeDefinitions(msg kernel.Message, goExec *goexec.State, keys []string) {
 	klog.V(1).Infof("removing definitions %v", keys)
+	isType := false
 	for _, key := range keys {
+		if key == "--type" {
+			isType = true
+			continue
+		}
+		if isType {
+			removeType(msg, goExec, key)
+			continue
+		}
 		var found bool
 		found = found || removeDefinitionImpl(msg, "import", &goExec.Definitions.Imports, key)
 		found = found || removeDefinitionImpl(msg, "const", &goExec.Definitions.Constants, key)
@@ -81,3 +107,83 @@ func removeDefinitions(msg kernel.Message, goExec *goexec.State, keys []string)
 		}
 	}
 }
+
+// removeType removes the memorized type typeName and its methods. It implements `%rm --type <type>`.
+func removeType(msg kernel.Message, goExec *goexec.State, typeName string) {
+	methods, found := goExec.Definitions.RemoveType(typeName)
+	var output string
+	if found {
+		output = fmt.Sprintf(". removed type %s\n", typeName)
+	}
+	for _, key := range methods {
+		output += fmt.Sprintf(". removed func %s\n", key)
+	}
+	stream := kernel.StreamStdout
+	if output == "" {
+		stream, output = kernel.StreamStderr, fmt.Sprintf(". type %q not found, not removed\n", typeName)
+	}
+	if err := kernel.PublishWriteStream(msg, stream, output); err != nil {
+		klog.Errorf("Failed to publish back to jupyter output of removing definitions: %+v", err)
+	}
+}
+
+// catDefinition displays the source of a memorized declaration, with syntax highlighting, or of all of them if
+// args is `--all`. It implements the "%cat" command.
+func catDefinition(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%cat <name>` or `%%cat --all` takes exactly one parameter.")
+	}
+	name, title := args[0], args[0]
+	if name == "--all" {
+		name, title = "", "Memorized Definitions"
+	}
+	src, err := goExec.DeclarationSource(name)
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishHtml(msg, fmt.Sprintf("<b>%s</b>\n<pre style=\"margin: 0\">%s</pre>\n",
+		html.EscapeString(title), goexec.HighlightGo(src)))
+	if err != nil {
+		klog.Errorf("Failed to publish back to jupyter the source of declarations: %+v", err)
+	}
+	return nil
+}
+
+// renameDefinition renames a memorized function, type, variable or constant, and updates the references to it in
+// the other memorized declarations. It implements the "%rename" command.
+func renameDefinition(msg kernel.Message, goExec *goexec.State, oldName, newName string) error {
+	numChanged, err := goExec.Rename(oldName, newName)
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf(". renamed %s to %s: %d declaration(s) changed\n", oldName, newName, numChanged))
+	if err != nil {
+		klog.Errorf("Failed to publish back to jupyter output of renaming definitions: %+v", err)
+	}
+	return nil
+}
+
+// fixImports runs `goimports` over the memorized declarations, to add the missing imports and remove the
+// unused ones. It implements the "%goimports" command.
+func fixImports(msg kernel.Message, goExec *goexec.State) error {
+	added, removed, err := goExec.FixImports(msg)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	for _, key := range added {
+		sb.WriteString(fmt.Sprintf(". added import %s\n", key))
+	}
+	for _, key := range removed {
+		sb.WriteString(fmt.Sprintf(". removed import %s\n", key))
+	}
+	if sb.Len() == 0 {
+		sb.WriteString(". memorized imports are up-to-date\n")
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+	if err != nil {
+		klog.Errorf("Failed to publish back to jupyter output of fixing imports: %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"html"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file implements `%deps`, which displays the dependencies of the notebook module.
+
+// execDeps executes the "%deps [why <module>]" special command. The parameter `args` excludes "%deps".
+func execDeps(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 0 {
+		if len(args) != 2 || args[0] != "why" {
+			return errors.Errorf("invalid `%%deps` arguments %q, use `%%deps` or `%%deps why <module>`", args)
+		}
+		report, err := goExec.WhyModule(args[1])
+		if err != nil {
+			return err
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		return nil
+	}
+	graph, err := goExec.DependencyGraph()
+	if err != nil {
+		return err
+	}
+	if len(graph.Requires[graph.Root]) == 0 {
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("Module %s has no dependencies.\n", graph.Root))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		return nil
+	}
+	return kernel.PublishHtml(msg, dependencyTreeHtml(graph))
+}
+
+// dependencyTreeHtml renders the requirement graph as a tree of collapsible HTML `<details>` blocks, starting
+// from the notebook module. The modules required directly by the notebook are highlighted. Modules that appear
+// more than once are only expanded the first time.
+func dependencyTreeHtml(graph *goexec.ModuleGraph) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>Dependencies of <code>%s</code></b> (%d modules required directly, in bold)\n",
+		html.EscapeString(graph.Root), len(graph.Direct)))
+	expanded := common.MakeSet[string]()
+	var render func(module string)
+	render = func(module string) {
+		label := fmt.Sprintf("<code>%s</code>", html.EscapeString(module))
+		if graph.Direct.Has(module) {
+			label = "<b>" + label + "</b>"
+		}
+		requires := graph.Requires[module]
+		switch {
+		case len(requires) == 0:
+			sb.WriteString(fmt.Sprintf("<div>%s</div>\n", label))
+		case expanded.Has(module):
+			sb.WriteString(fmt.Sprintf("<div>%s <i>(%d requirements, listed above)</i></div>\n", label, len(requires)))
+		default:
+			expanded.Insert(module)
+			sb.WriteString(fmt.Sprintf("<details><summary>%s <i>(%d requirements)</i></summary>\n"+
+				"<div style=\"margin-left: 1.5em\">\n", label, len(requires)))
+			for _, required := range requires {
+				render(required)
+			}
+			sb.WriteString("</div></details>\n")
+		}
+	}
+	expanded.Insert(graph.Root)
+	sb.WriteString("<div style=\"margin-left: 0.5em\">\n")
+	for _, required := range graph.Requires[graph.Root] {
+		render(required)
+	}
+	sb.WriteString("</div>\n")
+	return sb.String()
+}
@@ -0,0 +1,27 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDependencyTreeHtml(t *testing.T) {
+	graph := &goexec.ModuleGraph{
+		Root: "gonb_1234",
+		Requires: map[string][]string{
+			"gonb_1234":            {"example.com/a@v1.0.0", "example.com/b@v1.2.0"},
+			"example.com/a@v1.0.0": {"example.com/c@v0.1.0"},
+			"example.com/b@v1.2.0": {"example.com/a@v1.0.0"},
+		},
+		Direct: common.SetWithValues("example.com/a@v1.0.0", "example.com/b@v1.2.0"),
+	}
+	got := dependencyTreeHtml(graph)
+	assert.Contains(t, got, "<b><code>example.com/a@v1.0.0</code></b>")
+	assert.Contains(t, got, "<div><code>example.com/c@v0.1.0</code></div>")
+	assert.Equal(t, 1, strings.Count(got, "<code>example.com/c@v0.1.0</code>"),
+		"requirements of a module should be expanded only once")
+	assert.Contains(t, got, "(1 requirements, listed above)")
+}
@@ -0,0 +1,25 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execDoc executes the "%doc <symbol>" special command, which displays the documentation of a Go symbol, as
+// markdown. The parameter `args` excludes "%doc".
+func execDoc(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%doc <symbol>` takes exactly one parameter, e.g.: `%%doc fmt.Println` or `%%doc MyType`.")
+	}
+	markdown, err := goExec.Documentation(msg, args[0])
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishMarkdown(msg, markdown)
+	if err != nil {
+		klog.Errorf("Failed to publish documentation back to jupyter: %+v", err)
+	}
+	return nil
+}
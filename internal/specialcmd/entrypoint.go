@@ -0,0 +1,34 @@
+package specialcmd
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// EntryPointFlag selects the entry point in the arguments of `%main` (or `%%`). It's namespaced, so it doesn't
+// collide with the flags of the program, which receives all the other arguments.
+const EntryPointFlag = "--gonb_entry"
+
+// extractEntryPoint extracts the `--gonb_entry <name>` (or `--gonb_entry=<name>`) flag from the arguments of
+// `%main` (or `%%`), and returns the entry point and the remaining arguments, to be passed to the program.
+func extractEntryPoint(args []string) (entry string, programArgs []string, err error) {
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		switch {
+		case arg == EntryPointFlag:
+			if ii+1 >= len(args) {
+				return "", nil, errors.Errorf("`%%main %s` requires the name of the entry point function", EntryPointFlag)
+			}
+			ii++
+			entry = args[ii]
+		case strings.HasPrefix(arg, EntryPointFlag+"="):
+			entry = strings.TrimPrefix(arg, EntryPointFlag+"=")
+			if entry == "" {
+				return "", nil, errors.Errorf("`%%main %s` requires the name of the entry point function", EntryPointFlag)
+			}
+		default:
+			programArgs = append(programArgs, arg)
+		}
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package specialcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestExtractEntryPoint(t *testing.T) {
+	entry, args, err := extractEntryPoint([]string{"--gonb_entry", "Fast", "-n=3"})
+	require.NoError(t, err)
+	assert.Equal(t, "Fast", entry)
+	assert.Equal(t, []string{"-n=3"}, args)
+
+	entry, args, err = extractEntryPoint([]string{"-n=3", "--gonb_entry=Slow"})
+	require.NoError(t, err)
+	assert.Equal(t, "Slow", entry)
+	assert.Equal(t, []string{"-n=3"}, args)
+
+	entry, args, err = extractEntryPoint([]string{"-n=3"})
+	require.NoError(t, err)
+	assert.Equal(t, "", entry)
+	assert.Equal(t, []string{"-n=3"}, args)
+
+	// The program's own `--entry` flag is passed along.
+	entry, args, err = extractEntryPoint([]string{"--entry=x", "--gonb_entry", "Fast"})
+	require.NoError(t, err)
+	assert.Equal(t, "Fast", entry)
+	assert.Equal(t, []string{"--entry=x"}, args)
+
+	_, _, err = extractEntryPoint([]string{"--gonb_entry"})
+	assert.Error(t, err)
+	_, _, err = extractEntryPoint([]string{"--gonb_entry="})
+	assert.Error(t, err)
+}
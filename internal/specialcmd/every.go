@@ -0,0 +1,43 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"time"
+)
+
+// This file implements `%every`, which executes the cell again at every interval.
+
+var (
+	// EveryMinInterval and EveryMaxInterval bound the interval of `%every`: intervals out of the range are capped.
+	EveryMinInterval = 100 * time.Millisecond
+	EveryMaxInterval = 24 * time.Hour
+)
+
+// execEvery executes the "%every <interval>" special command. The parameter `args` excludes "%every".
+// It only records the interval, the repetition itself is done by executeRepeatedCell, once the cell finishes
+// executing.
+func execEvery(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%every <interval>` takes exactly one argument, the interval, e.g.: `%%every 2s`")
+	}
+	interval, err := time.ParseDuration(args[0])
+	if err != nil || interval <= 0 {
+		return errors.Errorf("`%%every`: invalid interval %q, it should be a positive duration, e.g.: 2s or 1m",
+			args[0])
+	}
+	capped := min(max(interval, EveryMinInterval), EveryMaxInterval)
+	if capped != interval {
+		err = kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("`%%every`: interval %s out of range [%s, %s], using %s instead\n",
+				interval, EveryMinInterval, EveryMaxInterval, capped))
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+	}
+	goExec.CellRefreshInterval = capped
+	return nil
+}
@@ -0,0 +1,50 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecEvery(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	assert.Error(t, execEvery(msg, s, nil))
+	assert.Error(t, execEvery(msg, s, []string{"-2s"}))
+	assert.Error(t, execEvery(msg, s, []string{"often"}))
+	require.NoError(t, execEvery(msg, s, []string{"2s"}))
+	assert.Equal(t, 2*time.Second, s.CellRefreshInterval)
+	require.NoError(t, execEvery(msg, s, []string{"1ns"}))
+	assert.Equal(t, EveryMinInterval, s.CellRefreshInterval)
+	require.NoError(t, execEvery(msg, s, []string{"1000h"}))
+	assert.Equal(t, EveryMaxInterval, s.CellRefreshInterval)
+}
+
+func TestExecuteEveryCell(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	// The cell counts its executions, and interrupts itself on the third one.
+	var count atomic.Int32
+	require.NoError(t, RegisterMagic("count_executions", func(msg kernel.Message, _ *goexec.State, _ []string) error {
+		if count.Add(1) == 3 {
+			msg.Kernel().Interrupt()
+		}
+		return nil
+	}))
+	defer UnregisterMagic("count_executions")
+
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, executeRepeatedCell(msg, s, 1, []string{"%every 100ms", "%count_executions"}))
+	assert.Equal(t, int32(3), count.Load())
+	assert.Equal(t, time.Duration(0), s.CellRefreshInterval)
+
+	msg = &fakeMessage{kernel: &kernel.Kernel{}}
+	err := executeRepeatedCell(msg, s, 2, []string{"%every 100ms", "%watch " + t.TempDir()})
+	assert.Errorf(t, err, "`%%watch` and `%%every` can't be used together")
+}
@@ -0,0 +1,150 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/jpyexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"k8s.io/klog/v2"
+	"os/exec"
+	"strings"
+)
+
+// This file implements the registry of special commands added by extensions, and `%load_ext` to load
+// extensions implemented as executables.
+
+// Magic implements a special command (`%<name> <args...>`) added by an extension.
+// The parameter `args` excludes the command name.
+type Magic func(msg kernel.Message, goExec *goexec.State, args []string) error
+
+// registeredMagics maps the names of the special commands added by extensions to their implementation.
+var registeredMagics = make(map[string]Magic)
+
+// builtinCommands are the names of the built-in special commands (handled by execSpecialConfig), which can't
+// be replaced by the special commands registered by extensions.
+var builtinCommands = SetWithValues(
+	"%", "main", "args", "test", "wasm", "show", "build", "keep", "discard", "asm", "escape", "export",
+	"export_module", "http", "http_header", "secret", "sql_connect", "capture_coverage", "coverage", "watch",
+	"every", "fuzz", "pgo", "lenient", "widgets", "widgets_hb", "env", "env_persist", "goprivate", "goproxy",
+	"gocache", "pwd", "cd", "readfile", "load_ext", "snapshot", "diff", "goflags", "goos", "goarch", "goroot",
+	"go", "goversion", "install_tool", "config", "autoget", "noautoget", "help", "memlimit", "profile_startup",
+	"bugreport", "output_max_lines", "ansi", "autoprint", "repl", "imports", "clear", "reset", "ls", "list",
+	"rm", "remove", "doc", "hover", "complete", "cat", "rename", "goimports", "with_inputs", "with_password",
+	"with_env", "track", "untrack", "alias", "unalias", "macro", "%cell", "run", "deps", "vendor",
+	"gonbui_version", "replace", "replace_local", "gomod", "gowork", "goworkfix", "tag")
+
+// RegisterMagic registers the special command `%<name>`, implemented by magic. Registering a name again
+// replaces the previous one.
+//
+// It returns an error if `name` is a built-in special command, since those can't be replaced.
+//
+// It is the extension point for binaries built on top of GoNB: extensions loaded with `%load_ext` also use it.
+func RegisterMagic(name string, magic Magic) error {
+	if builtinCommands.Has(name) {
+		return errors.Errorf("special command %%%s is built-in, it can't be registered by an extension", name)
+	}
+	registeredMagics[name] = magic
+	return nil
+}
+
+// UnregisterMagic removes the special command `%<name>` registered with RegisterMagic. It's a no-op if it
+// wasn't registered.
+func UnregisterMagic(name string) {
+	delete(registeredMagics, name)
+}
+
+const (
+	// ExtensionPrefix is prepended to the name given to `%load_ext <name>` to find the executable
+	// implementing the extension in the PATH.
+	ExtensionPrefix = "gonb-ext-"
+
+	// ExtensionListMagicsArg is the argument passed to an extension executable, when loaded, for it to output
+	// the names of the special commands it implements.
+	ExtensionListMagicsArg = "--gonb_list_magics"
+)
+
+// execLoadExt executes the "%load_ext" special command. The parameter `args` excludes "%load_ext".
+//
+// An extension is an executable, given by its path, or by its name `<name>`, in which case the executable
+// `gonb-ext-<name>` is searched in the PATH. When loaded, it is executed with ExtensionListMagicsArg and it must
+// output the names of the special commands it implements, separated by spaces or new lines. Each of these
+// special commands, `%<magic> <args...>`, is then executed as `<executable> <magic> <args...>`, with the same
+// support as the programs of the cells: output is streamed to the notebook and it can use `gonbui` to display
+// rich content.
+//
+// Without arguments, it lists the special commands registered by extensions.
+func execLoadExt(msg kernel.Message, args []string) error {
+	if len(args) == 0 {
+		names := make([]string, 0, len(registeredMagics))
+		for name := range registeredMagics {
+			names = append(names, "%"+name)
+		}
+		slices.Sort(names)
+		report := "No special commands registered by extensions.\n"
+		if len(names) > 0 {
+			report = fmt.Sprintf("Special commands registered by extensions: %s\n", strings.Join(names, ", "))
+		}
+		err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+		return nil
+	}
+	if len(args) != 1 {
+		return errors.Errorf("`%%load_ext <path-or-name>` takes one argument, got %q", args)
+	}
+	extPath := ReplaceEnvVars(ReplaceTildeInDir(args[0]))
+	if !strings.Contains(extPath, "/") {
+		var err error
+		extPath, err = exec.LookPath(ExtensionPrefix + extPath)
+		if err != nil {
+			return errors.Wrapf(err, "`%%load_ext`: extension %q not found in PATH as %q", args[0], ExtensionPrefix+args[0])
+		}
+	}
+	output, err := exec.Command(extPath, ExtensionListMagicsArg).Output()
+	if err != nil {
+		return errors.Wrapf(err, "`%%load_ext`: failed to list the special commands of the extension %q", extPath)
+	}
+	magicNames := strings.Fields(string(output))
+	if len(magicNames) == 0 {
+		return errors.Errorf("`%%load_ext`: extension %q implements no special commands", extPath)
+	}
+	for _, name := range magicNames {
+		if builtinCommands.Has(name) {
+			return errors.Errorf("`%%load_ext`: extension %q implements %%%s, which is a built-in special command",
+				extPath, name)
+		}
+	}
+	for _, name := range magicNames {
+		if err = RegisterMagic(name, executableMagic(extPath, name)); err != nil {
+			return err
+		}
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Loaded extension %q: %%%s\n", extPath, strings.Join(magicNames, ", %")))
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+// executableMagic returns the Magic that executes the special command `name` implemented by the extension
+// executable in extPath.
+func executableMagic(extPath, name string) Magic {
+	return func(msg kernel.Message, goExec *goexec.State, args []string) error {
+		// Named pipes are used, so the extension can use `gonbui` (and widgets, if connected to the front-end).
+		var commsHandler jpyexec.CommsHandler
+		if goExec != nil && goExec.Comms != nil {
+			commsHandler = goExec.Comms
+		}
+		executor := jpyexec.New(msg, extPath, append([]string{name}, args...)...).
+			UseNamedPipes(commsHandler)
+		if msg != nil {
+			executor = executor.ExecutionCount(msg.Kernel().ExecCounter)
+		}
+		return executor.Exec()
+	}
+}
@@ -0,0 +1,94 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+)
+
+func TestLoadExt(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	status := &cellStatus{}
+
+	// Extension that implements `%greet <output_file> <name>`.
+	dir := t.TempDir()
+	extPath := path.Join(dir, ExtensionPrefix+"greeter")
+	require.NoError(t, os.WriteFile(extPath, []byte(`#!/bin/sh
+if [ "$1" = "`+ExtensionListMagicsArg+`" ] ; then
+  echo greet
+  exit 0
+fi
+echo "hello $3" > "$2"
+`), 0755))
+	t.Setenv("PATH", dir+":"+os.Getenv("PATH"))
+	defer UnregisterMagic("greet")
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "load_ext greeter", status))
+	require.Contains(t, registeredMagics, "greet")
+	outputPath := path.Join(dir, "output.txt")
+	require.NoError(t, execSpecialConfig(msg, s, 0, "greet "+outputPath+" gopher", status))
+	output, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "hello gopher\n", string(output))
+
+	assert.Error(t, execSpecialConfig(msg, s, 0, "load_ext nonexistent_extension", status))
+
+	// Extension that implements a built-in special command is rejected.
+	badExtPath := path.Join(dir, ExtensionPrefix+"bad")
+	require.NoError(t, os.WriteFile(badExtPath, []byte(`#!/bin/sh
+echo other help
+`), 0755))
+	defer UnregisterMagic("other")
+	assert.ErrorContains(t, execSpecialConfig(msg, s, 0, "load_ext bad", status), "built-in")
+	assert.NotContains(t, registeredMagics, "other")
+	assert.NotContains(t, registeredMagics, "help")
+}
+
+func TestRegisterMagicBuiltin(t *testing.T) {
+	noop := func(kernel.Message, *goexec.State, []string) error { return nil }
+	require.Error(t, RegisterMagic("help", noop))
+	require.Error(t, RegisterMagic("load_ext", noop))
+	assert.NotContains(t, registeredMagics, "help")
+}
+
+// TestBuiltinCommands checks that builtinCommands lists all the special commands handled by execSpecialConfig.
+func TestBuiltinCommands(t *testing.T) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "specialcmd.go", nil, 0)
+	require.NoError(t, err)
+	var names []string
+	for _, decl := range file.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != "execSpecialConfig" {
+			continue
+		}
+		for _, stmt := range funcDecl.Body.List {
+			switchStmt, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			for _, clause := range switchStmt.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					name, err := strconv.Unquote(expr.(*ast.BasicLit).Value)
+					require.NoError(t, err)
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	require.NotEmpty(t, names)
+	for _, name := range names {
+		assert.Truef(t, builtinCommands.Has(name), "built-in special command %q missing from builtinCommands", name)
+	}
+	assert.Len(t, builtinCommands, len(names))
+}
@@ -0,0 +1,64 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements `%fuzz`, which runs Go's native fuzzing on a memorized `Fuzz*` function.
+
+var regexpFuzzTarget = regexp.MustCompile(`^Fuzz[\p{L}\p{N}_]*$`)
+
+// execFuzz executes the "%fuzz FuzzXxx [--fuzztime <duration|Nx>] [test flags...]" special command.
+// The parameter `args` excludes "%fuzz".
+// It marks the cell as a test: whether the fuzz target is memorized is only checked when compiling, after the
+// cell's declarations are parsed.
+func execFuzz(goExec *goexec.State, args []string) error {
+	if len(args) == 0 || !regexpFuzzTarget.MatchString(args[0]) {
+		return errors.Errorf("`%%fuzz FuzzXxx [--fuzztime 10s]` requires the name of a fuzz target, " +
+			"a function named `Fuzz*`")
+	}
+	goExec.CellFuzzTarget = args[0]
+	goExec.CellFuzzTime = ""
+	goExec.Args = nil
+	for ii := 1; ii < len(args); ii++ {
+		arg := args[ii]
+		var fuzzTime string
+		switch {
+		case arg == "--fuzztime" || arg == "-fuzztime":
+			if ii+1 >= len(args) {
+				return errors.Errorf("`%%fuzz`: missing value for %s", arg)
+			}
+			ii++
+			fuzzTime = args[ii]
+		case strings.HasPrefix(arg, "--fuzztime=") || strings.HasPrefix(arg, "-fuzztime="):
+			fuzzTime = arg[strings.Index(arg, "=")+1:]
+		default:
+			// Other flags are passed to the test binary.
+			goExec.Args = append(goExec.Args, arg)
+			continue
+		}
+		if !isValidFuzzTime(fuzzTime) {
+			return errors.Errorf("`%%fuzz`: invalid --fuzztime %q, it should be a duration (e.g.: 30s) "+
+				"or a number of iterations (e.g.: 1000x)", fuzzTime)
+		}
+		goExec.CellFuzzTime = fuzzTime
+	}
+	goExec.CellIsTest = true
+	return nil
+}
+
+// isValidFuzzTime returns whether value is accepted by `-test.fuzztime`: a duration or a number of iterations
+// followed by "x".
+func isValidFuzzTime(value string) bool {
+	if count, found := strings.CutSuffix(value, "x"); found {
+		n, err := strconv.Atoi(count)
+		return err == nil && n > 0
+	}
+	d, err := time.ParseDuration(value)
+	return err == nil && d > 0
+}
@@ -0,0 +1,29 @@
+package specialcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestExecFuzz(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	assert.Error(t, execFuzz(s, nil))
+	assert.Error(t, execFuzz(s, []string{"TestSomething"}))
+	assert.Error(t, execFuzz(s, []string{"FuzzParse", "--fuzztime"}))
+	assert.Error(t, execFuzz(s, []string{"FuzzParse", "--fuzztime", "forever"}))
+	assert.Error(t, execFuzz(s, []string{"FuzzParse", "--fuzztime=0x"}))
+
+	require.NoError(t, execFuzz(s, []string{"FuzzParse"}))
+	assert.Equal(t, "FuzzParse", s.CellFuzzTarget)
+	assert.Equal(t, "", s.CellFuzzTime)
+	assert.True(t, s.CellIsTest)
+
+	require.NoError(t, execFuzz(s, []string{"FuzzParse", "--fuzztime", "30s", "-test.v"}))
+	assert.Equal(t, "30s", s.CellFuzzTime)
+	assert.Equal(t, []string{"-test.v"}, s.Args)
+	require.NoError(t, execFuzz(s, []string{"FuzzParse", "--fuzztime=1000x"}))
+	assert.Equal(t, "1000x", s.CellFuzzTime)
+	assert.Empty(t, s.Args)
+}
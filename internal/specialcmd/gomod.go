@@ -0,0 +1,39 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os"
+	"path"
+	"strings"
+)
+
+// execShowModFile executes the "%gomod" and "%gowork" special commands, which display the contents of the
+// notebook's `go.mod` or `go.work` file (given by fileName). With `--path`, only its location is displayed.
+// The parameter `args` excludes the command.
+func execShowModFile(msg kernel.Message, goExec *goexec.State, fileName string, args []string) error {
+	cmd := strings.Replace(fileName, ".", "", 1) // E.g.: "gomod" for "go.mod".
+	showPath := len(args) == 1 && args[0] == "--path"
+	if len(args) > 0 && !showPath {
+		return errors.Errorf("`%%%s [--path]`: invalid arguments %q", cmd, args)
+	}
+	filePath := path.Join(goExec.TempDir, fileName)
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.Errorf("`%%%s`: the notebook has no %s file", cmd, fileName)
+		}
+		return errors.Wrapf(err, "`%%%s`: failed to read %q", cmd, filePath)
+	}
+	output := string(contents)
+	if showPath {
+		output = filePath + "\n"
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, output)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
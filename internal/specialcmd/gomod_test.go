@@ -0,0 +1,28 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestGoModAndGoWork(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "gomod", status))
+	require.NoError(t, execSpecialConfig(msg, s, 0, "gomod --path", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "gomod --other", status))
+
+	// No go.work until one is created.
+	err := execSpecialConfig(msg, s, 0, "gowork", status)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no go.work")
+	require.NoError(t, os.WriteFile(path.Join(s.TempDir, "go.work"), []byte("go 1.21\n\nuse .\n"), 0644))
+	require.NoError(t, execSpecialConfig(msg, s, 0, "gowork", status))
+}
@@ -0,0 +1,57 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// execGonbuiVersion executes the "%gonbui_version" special command, which reports the version of the kernel and
+// the version of `gonbui` used by the notebook. The parameter `args` excludes "%gonbui_version".
+func execGonbuiVersion(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("`%%gonbui_version` takes no arguments, got %q", args)
+	}
+	report, err := gonbuiVersionReport(goExec)
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// gonbuiVersionReport describes the version of the kernel and of `gonbui` used by the notebook, and whether
+// they match.
+func gonbuiVersionReport(goExec *goexec.State) (string, error) {
+	required, replacement, err := goExec.GonbuiVersion()
+	if err != nil {
+		return "", err
+	}
+	kernelVersion := goexec.KernelVersion()
+	var sb strings.Builder
+	if kernelVersion == "" {
+		sb.WriteString("Kernel version: unknown (development build)\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Kernel version: %s\n", kernelVersion))
+	}
+	switch {
+	case replacement != "":
+		sb.WriteString(fmt.Sprintf("gonbui: replaced by %s\n", replacement))
+	case required == "":
+		sb.WriteString("gonbui: not used by the notebook\n")
+	case kernelVersion == "":
+		sb.WriteString(fmt.Sprintf("gonbui: %s\n", required))
+	case required == kernelVersion:
+		sb.WriteString(fmt.Sprintf("gonbui: %s, matching the kernel\n", required))
+	default:
+		sb.WriteString(fmt.Sprintf("gonbui: %s, it doesn't match the kernel: it is pinned to %s with the next `go get`\n",
+			required, kernelVersion))
+	}
+	return sb.String(), nil
+}
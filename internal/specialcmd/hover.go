@@ -0,0 +1,25 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execHover executes the "%hover <symbol>" special command, which displays the `gopls` hover information of a
+// Go symbol, as markdown. The parameter `args` excludes "%hover".
+func execHover(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%hover <symbol>` takes exactly one parameter, e.g.: `%%hover fmt.Println` or `%%hover MyType`.")
+	}
+	markdown, err := goExec.Hover(args[0])
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishMarkdown(msg, markdown)
+	if err != nil {
+		klog.Errorf("Failed to publish hover information back to jupyter: %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package specialcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/token"
+	"html"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// This file implements `%http` and `%http_header`, to send HTTP requests from the notebook.
+
+// httpUsage is the usage reported in errors of `%http`.
+const httpUsage = "%http [--var <name>] [--timeout <duration>] <METHOD> <url> [<body>]"
+
+// HttpDefaultTimeout is the default timeout of the requests sent with `%http`.
+var HttpDefaultTimeout = 30 * time.Second
+
+// execHttpHeader executes the "%http_header" special command. The parameter `args` excludes "%http_header".
+//
+// `%http_header <name> <value>` sets a header sent with every `%http` request, `%http_header --clear` removes them
+// all, and without arguments it lists the names of the headers set (values are not shown, since they often hold
+// credentials).
+func execHttpHeader(msg kernel.Message, goExec *goexec.State, args []string) error {
+	switch {
+	case len(args) == 0:
+		names := SortedKeys(goExec.HttpHeaders)
+		report := "No HTTP headers set.\n"
+		if len(names) > 0 {
+			report = fmt.Sprintf("HTTP headers set: %s\n", strings.Join(names, ", "))
+		}
+		err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+	case len(args) == 1 && args[0] == "--clear":
+		goExec.HttpHeaders = make(http.Header)
+	case len(args) == 2:
+		goExec.HttpHeaders.Set(strings.TrimSuffix(args[0], ":"), ReplaceEnvVars(args[1]))
+	default:
+		return errors.Errorf("`%%http_header <name> <value>` or `%%http_header --clear`, but got %q", args)
+	}
+	return nil
+}
+
+// execHttp executes the "%http" special command. The parameter `args` excludes "%http".
+//
+// It sends the request with the headers set with `%http_header`, and displays the status, the time it took and
+// the body of the response: JSON bodies are displayed as JSON, that the front-end can render as a tree.
+// With `--var <name>`, the body is memorized in the Go string variable `<name>` instead of displayed.
+func execHttp(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var varName string
+	timeout := HttpDefaultTimeout
+	var positional []string
+	for ii := 0; ii < len(args); ii++ {
+		switch args[ii] {
+		case "--var", "--timeout":
+			if ii+1 >= len(args) {
+				return errors.Errorf("missing value for %s, expected %q", args[ii], httpUsage)
+			}
+			ii++
+			if args[ii-1] == "--var" {
+				varName = args[ii]
+				if !token.IsIdentifier(varName) {
+					return errors.Errorf("invalid --var %q, expected a Go identifier", varName)
+				}
+			} else {
+				var err error
+				timeout, err = time.ParseDuration(args[ii])
+				if err != nil || timeout <= 0 {
+					return errors.Errorf("invalid --timeout %q, expected a positive duration, e.g.: 10s", args[ii])
+				}
+			}
+		default:
+			positional = append(positional, args[ii])
+		}
+	}
+	if len(positional) < 2 || len(positional) > 3 {
+		return errors.Errorf("expected %q, but got %q instead", httpUsage, args)
+	}
+	method, url := strings.ToUpper(positional[0]), ReplaceEnvVars(positional[1])
+	var body io.Reader
+	if len(positional) == 3 {
+		body = strings.NewReader(positional[2])
+	}
+
+	// The request is canceled if the cell is interrupted.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if msg != nil && msg.Kernel() != nil {
+		subscriptionId := msg.Kernel().SubscribeInterrupt(func(kernel.SubscriptionId) { cancel() })
+		defer msg.Kernel().UnsubscribeInterrupt(subscriptionId)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return errors.Wrapf(err, "`%%http`: invalid request")
+	}
+	for name, values := range goExec.HttpHeaders {
+		req.Header[name] = values
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "`%%http`: request failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	contents, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "`%%http`: failed to read the response body")
+	}
+	elapsed := time.Since(start)
+
+	report := fmt.Sprintf("%s %s: %s (%s, %d bytes)\n", method, url, resp.Status,
+		elapsed.Round(time.Millisecond), len(contents))
+	if varName != "" {
+		goExec.Definitions.Variables[varName] = &goexec.Variable{
+			Cursor:          goexec.NoCursor,
+			CellLines:       goexec.CellLines{Id: -1},
+			Key:             varName,
+			Name:            varName,
+			ValueDefinition: strconv.Quote(string(contents)),
+		}
+		report += fmt.Sprintf("Body memorized in variable %s.\n", varName)
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	if varName != "" || len(contents) == 0 {
+		return nil
+	}
+	err = kernel.PublishData(msg, httpBodyData(contents))
+	if err != nil {
+		klog.Errorf("Failed to publish %%http response back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// httpBodyData returns how to display the body of a response: JSON bodies are displayed as JSON (with an indented
+// text alternative), and anything else as text.
+func httpBodyData(contents []byte) kernel.Data {
+	var value any
+	if json.Unmarshal(contents, &value) == nil {
+		var indented bytes.Buffer
+		if json.Indent(&indented, contents, "", "  ") == nil {
+			return kernel.Data{Data: kernel.MIMEMap{
+				"application/json": value,
+				"text/plain":       indented.String(),
+			}}
+		}
+	}
+	return kernel.Data{Data: kernel.MIMEMap{
+		"text/html":  fmt.Sprintf("<pre style=\"margin: 0\">%s</pre>", html.EscapeString(string(contents))),
+		"text/plain": string(contents),
+	}}
+}
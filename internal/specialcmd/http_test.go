@@ -0,0 +1,52 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestExecHttp(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"method": %q, "token": %q, "body": %q}`, r.Method, r.Header.Get("X-Token"), body)
+	}))
+	defer server.Close()
+
+	t.Setenv("GONB_TEST_TOKEN", "secret")
+	require.NoError(t, execHttpHeader(nil, s, []string{"X-Token:", "$GONB_TEST_TOKEN"}))
+	require.NoError(t, execHttp(nil, s, []string{"--var", "response", "post", server.URL, "hello"}))
+	require.Contains(t, s.Definitions.Variables, "response")
+	assert.Equal(t, strconv.Quote(`{"method": "POST", "token": "secret", "body": "hello"}`),
+		s.Definitions.Variables["response"].ValueDefinition)
+
+	require.NoError(t, execHttpHeader(nil, s, []string{"--clear"}))
+	require.NoError(t, execHttp(nil, s, []string{"--var", "response", "GET", server.URL}))
+	assert.Equal(t, strconv.Quote(`{"method": "GET", "token": "", "body": ""}`),
+		s.Definitions.Variables["response"].ValueDefinition)
+
+	assert.Error(t, execHttp(nil, s, []string{"GET"}))
+	assert.Error(t, execHttp(nil, s, []string{"--var", "not-an-identifier", "GET", server.URL}))
+	assert.Error(t, execHttpHeader(nil, s, []string{"X-Token"}))
+}
+
+func TestHttpBodyData(t *testing.T) {
+	data := httpBodyData([]byte(`{"a":[1,2]}`))
+	assert.Equal(t, map[string]any{"a": []any{1.0, 2.0}}, data.Data["application/json"])
+	assert.Equal(t, "{\n  \"a\": [\n    1,\n    2\n  ]\n}", data.Data["text/plain"])
+
+	data = httpBodyData([]byte("<b>not json</b>"))
+	assert.Equal(t, kernel.MIMEMap{
+		"text/html":  "<pre style=\"margin: 0\">&lt;b&gt;not json&lt;/b&gt;</pre>",
+		"text/plain": "<b>not json</b>",
+	}, data.Data)
+}
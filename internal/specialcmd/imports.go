@@ -0,0 +1,47 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"html"
+	"k8s.io/klog/v2"
+)
+
+// execImports executes the "%imports" special command. The parameter `args` excludes "%imports", and it accepts
+// "--group" or "--flat" to set how the import block of the generated program is rendered, see
+// goexec.State.GroupImports. It then displays the import block of the memorized declarations.
+func execImports(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%imports [--group|--flat]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "--group":
+			goExec.GroupImports = true
+		case "--flat":
+			goExec.GroupImports = false
+		default:
+			return errors.Errorf("`%%imports [--group|--flat]`: invalid argument %q", args[0])
+		}
+	}
+	mode := "flat, sorted by name"
+	if goExec.GroupImports {
+		mode = "grouped: standard library, third-party and local packages"
+	}
+	src, err := goExec.ImportsSource()
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("<b>Imports (%s)</b>\n", html.EscapeString(mode))
+	if src == "" {
+		content += "<p>No memorized imports.</p>\n"
+	} else {
+		content += fmt.Sprintf("<pre style=\"margin: 0\">%s</pre>\n", goexec.HighlightGo(src))
+	}
+	if err = kernel.PublishHtml(msg, content); err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
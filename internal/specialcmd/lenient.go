@@ -0,0 +1,40 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execLenient executes the "%lenient [on|off]" special command. The parameter `args` excludes "%lenient".
+// Without arguments, it displays the current setting.
+//
+// If on, the compile errors about unused variables and imports are displayed as warnings, and the generated
+// program is fixed, see goexec.State.Lenient.
+func execLenient(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%lenient [on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			goExec.Lenient = true
+		case "off":
+			goExec.Lenient = false
+		default:
+			return errors.Errorf("`%%lenient [on|off]`: invalid argument %q", args[0])
+		}
+	}
+	state := "off"
+	if goExec.Lenient {
+		state = "on"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Unused variables and imports reported as warnings: %s\n", state))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
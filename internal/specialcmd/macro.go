@@ -0,0 +1,129 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file handles the command %macro, which records the source of executed cells, so they
+// can later be replayed.
+
+// RecordMacroCell records the lines of a cell about to be executed, if a macro is being recorded
+// (see `%macro start`). It should be called before the cell is executed.
+//
+// The cellId is the execution number of the cell, used to map errors to the cell lines when it is replayed.
+// The cell with the `%macro stop` command is not recorded.
+func RecordMacroCell(goExec *goexec.State, cellId int, lines []string) {
+	name := goExec.MacroRecording
+	if name == "" {
+		return
+	}
+	for _, line := range lines {
+		parts := splitCmd(line)
+		if len(parts) >= 2 && parts[0] == "%macro" && parts[1] == "stop" {
+			return
+		}
+	}
+	goExec.Macros[name] = append(goExec.Macros[name], goexec.RecordedCell{CellId: cellId, Lines: lines})
+}
+
+// execMacro executes the "%macro" special command. The parameter `args` excludes "%macro".
+func execMacro(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		listMacros(msg, goExec)
+		return nil
+	}
+	var output string
+	switch args[0] {
+	case "start":
+		if len(args) != 2 {
+			return errors.Errorf("`%%macro start <name>`: it takes one argument, the name of the macro, but %d were given", len(args)-1)
+		}
+		if goExec.MacroRecording != "" {
+			return errors.Errorf("`%%macro start %s`: already recording macro %q, use `%%macro stop` first", args[1], goExec.MacroRecording)
+		}
+		goExec.MacroRecording = args[1]
+		goExec.Macros[args[1]] = nil
+		output = fmt.Sprintf("Recording macro %q, starting with the next cell.\n", args[1])
+
+	case "stop":
+		if goExec.MacroRecording == "" {
+			return errors.New("`%macro stop`: no macro being recorded")
+		}
+		name := goExec.MacroRecording
+		goExec.MacroRecording = ""
+		output = fmt.Sprintf("Recorded macro %q with %d cell(s).\n", name, len(goExec.Macros[name]))
+
+	case "run":
+		if len(args) != 2 {
+			return errors.Errorf("`%%macro run <name>`: it takes one argument, the name of the macro, but %d were given", len(args)-1)
+		}
+		return runMacro(msg, goExec, args[1])
+
+	default:
+		return errors.Errorf("`%%macro %s`: unknown sub-command, use one of \"start\", \"stop\", \"run\" or \"list\"", args[0])
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, output)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// listMacros publishes the names of the macros recorded so far.
+func listMacros(msg kernel.Message, goExec *goexec.State) {
+	var sb strings.Builder
+	if len(goExec.Macros) == 0 {
+		sb.WriteString("No macros recorded.\n")
+	} else {
+		for _, name := range SortedKeys(goExec.Macros) {
+			sb.WriteString(fmt.Sprintf("%s: %d cell(s)\n", name, len(goExec.Macros[name])))
+		}
+	}
+	if goExec.MacroRecording != "" {
+		sb.WriteString(fmt.Sprintf("Currently recording macro %q.\n", goExec.MacroRecording))
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+	if err != nil {
+		klog.Errorf("Failed to publish list of macros to Jupyter: %+v", err)
+	}
+}
+
+// runMacro replays the cells recorded for the macro `name`, in order, as if they were executed
+// by the user. It stops at the first error.
+//
+// Each cell is replayed with its own per-cell configuration (e.g.: `%test`, `%args`), and the
+// configuration of the current cell is restored afterward.
+//
+// Macros can't be run from within another macro.
+func runMacro(msg kernel.Message, goExec *goexec.State, name string) error {
+	cells, found := goExec.Macros[name]
+	if !found {
+		return errors.Errorf("`%%macro run %s`: macro not defined", name)
+	}
+	if goExec.MacroRunning != "" {
+		return errors.Errorf("`%%macro run %s`: can't run a macro from within macro %q", name, goExec.MacroRunning)
+	}
+	goExec.MacroRunning = name
+	cellState := goExec.CellState
+	defer func() {
+		goExec.MacroRunning = ""
+		goExec.CellState = cellState
+	}()
+
+	for ii, cell := range cells {
+		if msg != nil && msg.Kernel().Interrupted.Load() {
+			return errors.Errorf("`%%macro run %s`: interrupted", name)
+		}
+		goExec.CellState = goexec.CellState{}
+		if err := ExecuteCell(msg, goExec, cell.CellId, cell.Lines); err != nil {
+			return errors.WithMessagef(err, "`%%macro run %s`: cell #%d", name, ii+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMacro(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	// Cells executed before `%macro start` are not recorded.
+	RecordMacroCell(s, 1, []string{"%goflags -v"})
+	require.NoError(t, execSpecialConfig(msg, s, 0, "macro start setup", status))
+	assert.Equal(t, "setup", s.MacroRecording)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "macro start other", status), "Can't record two macros at once")
+
+	RecordMacroCell(s, 2, []string{"%goflags -race", "%env GONB_MACRO_TEST=1"})
+	RecordMacroCell(s, 3, []string{"%macro stop"})
+	require.NoError(t, execSpecialConfig(msg, s, 0, "macro stop", status))
+	assert.Empty(t, s.MacroRecording)
+	require.Len(t, s.Macros["setup"], 1)
+	assert.Equal(t, goexec.RecordedCell{CellId: 2, Lines: []string{"%goflags -race", "%env GONB_MACRO_TEST=1"}}, s.Macros["setup"][0])
+	assert.Error(t, execSpecialConfig(msg, s, 0, "macro stop", status), "No macro being recorded")
+
+	// Replay: the per-cell configuration of the current cell is preserved.
+	s.GoBuildFlags = nil
+	s.CellIsTest = true
+	s.Args = []string{"-outer"}
+	s.Macros["setup"] = append(s.Macros["setup"], goexec.RecordedCell{CellId: 4, Lines: []string{"%args -inner"}})
+	require.NoError(t, execSpecialConfig(msg, s, 0, "macro run setup", status))
+	assert.Equal(t, []string{"-race"}, s.GoBuildFlags)
+	assert.True(t, s.CellIsTest)
+	assert.Equal(t, []string{"-outer"}, s.Args)
+	assert.Empty(t, s.MacroRunning)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "macro run unknown", status))
+
+	// Macros can't run recursively.
+	s.Macros["loop"] = []goexec.RecordedCell{{Lines: []string{"%macro run loop"}}}
+	assert.Error(t, execSpecialConfig(msg, s, 0, "macro run loop", status))
+}
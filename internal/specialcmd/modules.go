@@ -0,0 +1,164 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+	"html"
+	"k8s.io/klog/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This file handles the commands that configure how modules are fetched and built, e.g.: %goprivate, %goproxy.
+
+// privateModulesEnv are the environment variables set by `%goprivate`.
+var privateModulesEnv = []string{"GOPRIVATE", "GONOSUMDB"}
+
+// execGoPrivate executes the "%goprivate" special command. The parameter `args` excludes "%goprivate".
+//
+// It sets GOPRIVATE and GONOSUMDB to the given module path patterns, see setGoPrivate. Without
+// arguments, it only displays the current configuration.
+func execGoPrivate(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var hosts []string
+	if len(args) > 0 {
+		var err error
+		hosts, err = setGoPrivate(args)
+		if err != nil {
+			return errors.WithMessagef(err, "`%%goprivate %s`", strings.Join(args, " "))
+		}
+	}
+
+	var extraHtml string
+	if len(hosts) > 0 {
+		htmlParts := []string{"<p>If the modules are in private git repositories, git needs credentials to fetch them: " +
+			"add them to <code>~/.netrc</code>, or configure git to use SSH, e.g.:</p><pre>"}
+		for _, host := range hosts {
+			htmlParts = append(htmlParts, html.EscapeString(
+				fmt.Sprintf("!git config --global url.\"git@%s:\".insteadOf \"https://%s/\"", host, host)))
+		}
+		htmlParts = append(htmlParts, "</pre>")
+		extraHtml = strings.Join(htmlParts, "\n")
+	}
+	return publishGoEnv(msg, goExec, append([]string{"GONOPROXY"}, privateModulesEnv...), extraHtml)
+}
+
+// publishGoEnv displays a table with the effective values (as reported by `go env`) of the given variables,
+// followed by the extraHtml content.
+func publishGoEnv(msg kernel.Message, goExec *goexec.State, keys []string, extraHtml string) error {
+	env, err := goExec.GoEnv(keys...)
+	if err != nil {
+		return err
+	}
+	htmlParts := []string{"<table>"}
+	for _, key := range keys {
+		htmlParts = append(htmlParts, fmt.Sprintf("<tr><td><b>%s</b></td><td><code>%s</code></td></tr>",
+			key, html.EscapeString(env[key])))
+	}
+	htmlParts = append(htmlParts, "</table>", extraHtml)
+	err = kernel.PublishHtml(msg, strings.Join(htmlParts, "\n"))
+	if err != nil {
+		klog.Errorf("Failed to publish `go env` values back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// setGoPrivate sets GOPRIVATE and GONOSUMDB to the given module path patterns (comma or space separated),
+// so the private modules are fetched directly (not through the module proxy), and are not checked against
+// the checksum database.
+//
+// It returns the hosts of the patterns, excluding the ones with wildcards, to hint how to configure the
+// git credentials.
+func setGoPrivate(args []string) (hosts []string, err error) {
+	var patterns []string
+	for _, arg := range args {
+		for _, pattern := range strings.Split(arg, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern == "" {
+				continue
+			}
+			patterns = append(patterns, pattern)
+			if host := strings.Split(pattern, "/")[0]; !strings.ContainsAny(host, "*?[") && !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("no module path pattern given")
+	}
+	for _, key := range privateModulesEnv {
+		if err = os.Setenv(key, strings.Join(patterns, ",")); err != nil {
+			return nil, errors.Wrapf(err, "failed to set %s", key)
+		}
+	}
+	return hosts, nil
+}
+
+// execGoProxy executes the "%goproxy" special command. The parameter `args` excludes "%goproxy".
+//
+// It sets GOPROXY to the given value, after validating it, see setGoProxy. Without arguments, it only
+// displays the current value.
+func execGoProxy(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%goproxy [<url>[,<url>...]]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		if err := setGoProxy(args[0]); err != nil {
+			return errors.WithMessagef(err, "`%%goproxy %s`", args[0])
+		}
+	}
+	return publishGoEnv(msg, goExec, []string{"GOPROXY", "GONOPROXY", "GOFLAGS"}, "")
+}
+
+// setGoProxy validates and sets GOPROXY: a list of proxy URLs (http, https or file schemes), or the
+// keywords "direct" and "off", separated by "," or "|" (see `go help goproxy`).
+func setGoProxy(value string) error {
+	for _, proxy := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '|' }) {
+		if proxy == "direct" || proxy == "off" {
+			continue
+		}
+		proxyUrl, err := url.Parse(proxy)
+		if err != nil {
+			return errors.Wrapf(err, "invalid proxy URL %q", proxy)
+		}
+		if !slices.Contains([]string{"http", "https", "file"}, proxyUrl.Scheme) || (proxyUrl.Host == "" && proxyUrl.Path == "") {
+			return errors.Errorf("invalid proxy %q: it must be an http(s):// or file:// URL, \"direct\" or \"off\"", proxy)
+		}
+	}
+	if strings.Trim(value, ",|") == "" {
+		return errors.New("no proxy given")
+	}
+	return errors.Wrap(os.Setenv("GOPROXY", value), "failed to set GOPROXY")
+}
+
+// execGoCache executes the "%gocache" special command. The parameter `args` excludes "%gocache".
+//
+// It sets GOCACHE to the given directory, creating it if needed. Without arguments, it only displays
+// the current value.
+func execGoCache(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%gocache [<directory>]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		if err := setGoCache(args[0]); err != nil {
+			return errors.WithMessagef(err, "`%%gocache %s`", args[0])
+		}
+	}
+	return publishGoEnv(msg, goExec, []string{"GOCACHE", "GOMODCACHE"}, "")
+}
+
+// setGoCache sets GOCACHE to the absolute path of dir, creating the directory if needed.
+func setGoCache(dir string) error {
+	dir, err := filepath.Abs(ReplaceTildeInDir(dir))
+	if err != nil {
+		return errors.Wrapf(err, "invalid directory")
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create cache directory %q", dir)
+	}
+	return errors.Wrap(os.Setenv("GOCACHE", dir), "failed to set GOCACHE")
+}
@@ -0,0 +1,43 @@
+package specialcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSetGoPrivate(t *testing.T) {
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOSUMDB", "")
+	hosts, err := setGoPrivate([]string{"example.com/private,*.corp.example.com", "example.com/other"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, hosts)
+	for _, key := range privateModulesEnv {
+		assert.Equal(t, "example.com/private,*.corp.example.com,example.com/other", os.Getenv(key))
+	}
+
+	_, err = setGoPrivate([]string{","})
+	assert.Error(t, err)
+}
+
+func TestSetGoProxy(t *testing.T) {
+	t.Setenv("GOPROXY", "https://proxy.golang.org,direct")
+	for _, value := range []string{"https://proxy.example.com", "http://localhost:3000|direct", "file:///var/goproxy,off"} {
+		require.NoErrorf(t, setGoProxy(value), "setGoProxy(%q)", value)
+		assert.Equal(t, value, os.Getenv("GOPROXY"))
+	}
+	for _, value := range []string{"", ",", "proxy.example.com", "ftp://proxy.example.com", "https://"} {
+		assert.Errorf(t, setGoProxy(value), "setGoProxy(%q) should have failed", value)
+	}
+	assert.Equal(t, "file:///var/goproxy,off", os.Getenv("GOPROXY"), "Invalid values should not change GOPROXY")
+}
+
+func TestSetGoCache(t *testing.T) {
+	t.Setenv("GOCACHE", "")
+	dir := path.Join(t.TempDir(), "cache")
+	require.NoError(t, setGoCache(dir))
+	assert.Equal(t, dir, os.Getenv("GOCACHE"))
+	assert.DirExists(t, dir)
+}
@@ -0,0 +1,99 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file handles named cells: cells labeled with `%%cell <name>`, that can be executed
+// again later with `%run <name>`.
+
+// recordNamedCell records the lines of a cell about to be executed, if it starts with the
+// `%%cell <name>` directive (see goexec.CellNameDirective).
+//
+// The directive line is recorded as an empty line, so running the cell again doesn't redefine it,
+// while preserving the line numbers used to map errors to the cell lines.
+//
+// If a cell with the same name was already defined, it is overwritten, and a warning is published.
+func recordNamedCell(msg kernel.Message, goExec *goexec.State, cellId int, lines []string) error {
+	if len(lines) == 0 || !goexec.IsCellNameDirective(lines[0]) {
+		return nil
+	}
+	parts := splitCmd(lines[0])
+	if len(parts) != 2 {
+		return errors.Errorf("`%%%%cell <name>`: it takes one argument, the name of the cell, but %d were given", len(parts)-1)
+	}
+	name := parts[1]
+	if _, found := goExec.NamedCells[name]; found {
+		err := kernel.PublishWriteStream(msg, kernel.StreamStderr,
+			fmt.Sprintf("Warning: cell %q was already defined, overwriting it.\n", name))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+	}
+	recorded := make([]string, len(lines))
+	copy(recorded[1:], lines[1:])
+	goExec.NamedCells[name] = goexec.RecordedCell{CellId: cellId, Lines: recorded}
+	return nil
+}
+
+// execRun executes the "%run" special command. The parameter `args` excludes "%run".
+func execRun(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 {
+		listNamedCells(msg, goExec)
+		return nil
+	}
+	if len(args) != 1 {
+		return errors.Errorf("`%%run <name>`: it takes one argument, the name of the cell, but %d were given", len(args))
+	}
+	return runNamedCell(msg, goExec, args[0])
+}
+
+// listNamedCells publishes the names of the cells defined so far.
+func listNamedCells(msg kernel.Message, goExec *goexec.State) {
+	var sb strings.Builder
+	if len(goExec.NamedCells) == 0 {
+		sb.WriteString("No named cells defined, use `%%cell <name>` in the first line of a cell to name it.\n")
+	} else {
+		for _, name := range SortedKeys(goExec.NamedCells) {
+			sb.WriteString(fmt.Sprintf("%s: defined in cell %d\n", name, goExec.NamedCells[name].CellId))
+		}
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, sb.String())
+	if err != nil {
+		klog.Errorf("Failed to publish list of named cells to Jupyter: %+v", err)
+	}
+}
+
+// runNamedCell executes again the cell named `name`, as if it was executed by the user.
+//
+// The cell is executed with its own per-cell configuration (e.g.: `%test`, `%args`), and the
+// configuration of the current cell is restored afterward.
+//
+// A named cell can run other named cells, but not itself, directly or indirectly.
+func runNamedCell(msg kernel.Message, goExec *goexec.State, name string) error {
+	cell, found := goExec.NamedCells[name]
+	if !found {
+		return errors.Errorf("`%%run %s`: cell not defined", name)
+	}
+	if goExec.NamedCellsRunning.Has(name) {
+		return errors.Errorf("`%%run %s`: cell is already running, it can't be run recursively", name)
+	}
+	goExec.NamedCellsRunning.Insert(name)
+	cellState := goExec.CellState
+	defer func() {
+		goExec.NamedCellsRunning.Delete(name)
+		goExec.CellState = cellState
+	}()
+
+	goExec.CellState = goexec.CellState{}
+	if err := ExecuteCell(msg, goExec, cell.CellId, cell.Lines); err != nil {
+		return errors.WithMessagef(err, "`%%run %s`", name)
+	}
+	return nil
+}
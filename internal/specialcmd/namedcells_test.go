@@ -0,0 +1,47 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNamedCells(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	// Define a named cell: it is executed and recorded, with the directive line blanked.
+	require.NoError(t, ExecuteCell(msg, s, 1, []string{"%%cell setup", "%goflags -race"}))
+	assert.Equal(t, []string{"-race"}, s.GoBuildFlags)
+	require.Contains(t, s.NamedCells, "setup")
+	assert.Equal(t, 1, s.NamedCells["setup"].CellId)
+	assert.Equal(t, []string{"", "%goflags -race"}, s.NamedCells["setup"].Lines)
+
+	// Re-execute it by name, preserving the per-cell configuration of the current cell.
+	s.GoBuildFlags = nil
+	s.CellIsTest = true
+	require.NoError(t, execSpecialConfig(msg, s, 0, "run setup", status))
+	assert.Equal(t, []string{"-race"}, s.GoBuildFlags)
+	assert.True(t, s.CellIsTest)
+	s.PostExecuteCell()
+	assert.Empty(t, s.NamedCellsRunning)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "run unknown", status))
+
+	// Redefining a name overwrites it.
+	require.NoError(t, ExecuteCell(msg, s, 2, []string{"%%cell setup", "%goflags -v"}))
+	assert.Equal(t, 2, s.NamedCells["setup"].CellId)
+
+	// The directive must come first, and takes exactly one name.
+	assert.Error(t, ExecuteCell(msg, s, 3, []string{"%%cell"}))
+	assert.Error(t, ExecuteCell(msg, s, 3, []string{"%env X=1", "%%cell late"}))
+	assert.NotContains(t, s.NamedCells, "late")
+
+	// Named cells can't run recursively.
+	require.NoError(t, ExecuteCell(msg, s, 4, []string{"%%cell loop"}))
+	s.NamedCells["loop"].Lines[0] = "%run loop"
+	assert.Error(t, execSpecialConfig(msg, s, 0, "run loop", status))
+	assert.Empty(t, s.NamedCellsRunning)
+}
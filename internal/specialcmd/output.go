@@ -0,0 +1,148 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strconv"
+	"strings"
+)
+
+// This file handles the commands that configure how the output of executed programs is displayed.
+
+// execOutputMaxLines executes the "%output_max_lines" special command. The parameter `args` excludes
+// "%output_max_lines". It accepts the maximum number of lines, and optionally the flag `--max-bytes=<value>`.
+//
+// Without arguments, it displays the current settings.
+func execOutputMaxLines(msg kernel.Message, args []string) error {
+	if msg == nil || msg.Kernel() == nil {
+		return errors.New("`%output_max_lines` requires a connection to the kernel")
+	}
+	k := msg.Kernel()
+	usage := "`%output_max_lines <num_lines> [--max-bytes=<num_bytes>]`"
+	if len(args) > 0 {
+		maxLines, maxBytes := -1, k.OutputMaxBytes
+		for ii := 0; ii < len(args); ii++ {
+			arg := args[ii]
+			if strings.HasPrefix(arg, "--max-bytes") {
+				value := strings.TrimPrefix(arg, "--max-bytes")
+				if value == "" && ii+1 < len(args) {
+					ii++
+					value = args[ii]
+				} else {
+					value = strings.TrimPrefix(value, "=")
+				}
+				var err error
+				maxBytes, err = strconv.Atoi(value)
+				if err != nil {
+					return errors.Errorf("%s: invalid value for --max-bytes %q", usage, value)
+				}
+				continue
+			}
+			if maxLines >= 0 {
+				return errors.Errorf("%s: unexpected argument %q", usage, arg)
+			}
+			var err error
+			maxLines, err = strconv.Atoi(arg)
+			if err != nil || maxLines < 0 {
+				return errors.Errorf("%s: invalid number of lines %q", usage, arg)
+			}
+		}
+		if maxLines >= 0 {
+			k.OutputMaxLines = maxLines
+		}
+		k.OutputMaxBytes = maxBytes
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Output limits: max lines=%s, max bytes=%s\n",
+			limitToString(k.OutputMaxLines), limitToString(k.OutputMaxBytes)))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// limitToString returns the limit value as a string, or "unlimited" if it is <= 0.
+func limitToString(limit int) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(limit)
+}
+
+// execAnsi executes the "%ansi" special command. The parameter `args` excludes "%ansi", and it
+// accepts either "on" or "off". Without arguments, it displays the current setting.
+func execAnsi(msg kernel.Message, args []string) error {
+	if msg == nil || msg.Kernel() == nil {
+		return errors.New("`%ansi` requires a connection to the kernel")
+	}
+	k := msg.Kernel()
+	if len(args) > 1 {
+		return errors.Errorf("`%%ansi [on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			k.AnsiHtmlOutput = true
+		case "off":
+			k.AnsiHtmlOutput = false
+		default:
+			return errors.Errorf("`%%ansi [on|off]`: invalid argument %q", args[0])
+		}
+	}
+	state := "off"
+	if k.AnsiHtmlOutput {
+		state = "on"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Conversion of ANSI escape sequences to HTML: %s\n", state))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execClear executes the "%clear" special command. The parameter `args` excludes "%clear", and it
+// accepts only the optional flag "--wait".
+func execClear(msg kernel.Message, args []string) error {
+	var wait bool
+	for _, arg := range args {
+		if arg != "--wait" {
+			return errors.Errorf("`%%clear [--wait]`: invalid argument %q", arg)
+		}
+		wait = true
+	}
+	return kernel.PublishClearOutput(msg, wait)
+}
+
+// execAutoPrint executes the "%autoprint" special command. The parameter `args` excludes "%autoprint", and it
+// accepts "on" or "off". Without arguments, it displays the current setting.
+//
+// If on, the value of a bare expression at the end of `func main()` is displayed, see gonbui.DisplayValue.
+func execAutoPrint(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%autoprint [on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			goExec.AutoPrint = true
+		case "off":
+			goExec.AutoPrint = false
+		default:
+			return errors.Errorf("`%%autoprint [on|off]`: invalid argument %q", args[0])
+		}
+	}
+	state := "off"
+	if goExec.AutoPrint {
+		state = "on"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Automatic display of the last expression of the cell: %s\n", state))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
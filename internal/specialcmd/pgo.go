@@ -0,0 +1,53 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"os"
+)
+
+// This file implements `%pgo`, the profile-guided optimization (PGO) workflow.
+
+// execPgo executes the "%pgo [capture|on|off]" special command. The parameter `args` excludes "%pgo".
+// Without arguments, it displays the current setting.
+func execPgo(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%pgo [capture|on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "capture":
+			if goExec.CellIsWasm {
+				return errors.Errorf("`%%pgo capture` cannot be used in a `%%wasm` cell.")
+			}
+			// The profile is captured when the cell is executed.
+			goExec.CellPgoCapture = true
+			return nil
+		case "on":
+			if _, err := os.Stat(goExec.PgoProfilePath()); err != nil {
+				return errors.Errorf("`%%pgo on`: no CPU profile captured yet, execute a representative cell " +
+					"(e.g.: a benchmark) with `%%pgo capture` first")
+			}
+			goExec.PGO = true
+		case "off":
+			goExec.PGO = false
+		default:
+			return errors.Errorf("`%%pgo [capture|on|off]`: invalid argument %q", args[0])
+		}
+	}
+	report := "Profile-guided optimization: off\n"
+	if goExec.PGO {
+		report = fmt.Sprintf("Profile-guided optimization: on, builds use `-pgo=%s`\n", goExec.PgoProfilePath())
+		if len(args) == 1 {
+			report += "Rerun the cell used to capture the profile, and compare the results.\n"
+		}
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestExecPgo(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+
+	assert.Error(t, execPgo(msg, s, []string{"sometimes"}))
+	assert.Error(t, execPgo(msg, s, []string{"on"}), "no profile captured yet")
+	require.NoError(t, execPgo(msg, s, []string{"capture"}))
+	assert.True(t, s.CellPgoCapture)
+
+	require.NoError(t, os.WriteFile(s.PgoProfilePath(), []byte("profile"), 0644))
+	require.NoError(t, execPgo(msg, s, []string{"on"}))
+	assert.True(t, s.PGO)
+	assert.Contains(t, msg.output(), "-pgo="+s.PgoProfilePath())
+	require.NoError(t, execPgo(msg, s, []string{"off"}))
+	assert.False(t, s.PGO)
+}
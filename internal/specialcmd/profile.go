@@ -0,0 +1,50 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"html"
+	"k8s.io/klog/v2"
+	"strings"
+	"time"
+)
+
+// This file implements `%profile_startup`, a diagnostic command that reports where the kernel time goes.
+
+// execProfileStartup executes the "%profile_startup" special command: it displays a table with the time spent
+// in each phase of the kernel startup and of the execution of the last Go cell.
+func execProfileStartup(msg kernel.Message, goExec *goexec.State) {
+	htmlParts := []string{"<table>"}
+	htmlParts = append(htmlParts, timingsTableRows("Kernel startup", goExec.StartupTimings)...)
+	if len(goExec.LastCellTimings) > 0 {
+		htmlParts = append(htmlParts, timingsTableRows("Last Go cell executed", goExec.LastCellTimings)...)
+	} else {
+		htmlParts = append(htmlParts, `<tr><th colspan="2" style="text-align:left">No Go cell executed yet.</th></tr>`)
+	}
+	htmlParts = append(htmlParts, "</table>")
+	err := kernel.PublishHtml(msg, strings.Join(htmlParts, "\n"))
+	if err != nil {
+		klog.Errorf("Failed to publish %%profile_startup results back to jupyter: %+v", err)
+	}
+}
+
+// timingsTableRows returns the HTML rows of the table of timings displayed by `%profile_startup`,
+// with a header with the title, and a final row with the total.
+func timingsTableRows(title string, timings []goexec.Timing) []string {
+	rows := []string{fmt.Sprintf(`<tr><th colspan="2" style="text-align:left">%s</th></tr>`, html.EscapeString(title))}
+	var total time.Duration
+	for _, timing := range timings {
+		rows = append(rows, fmt.Sprintf(`<tr><td>%s</td><td style="text-align:right">%s</td></tr>`,
+			html.EscapeString(timing.Phase), formatTiming(timing.Duration)))
+		total += timing.Duration
+	}
+	rows = append(rows, fmt.Sprintf(`<tr><td><b>Total</b></td><td style="text-align:right"><b>%s</b></td></tr>`,
+		formatTiming(total)))
+	return rows
+}
+
+// formatTiming formats a duration with millisecond precision.
+func formatTiming(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
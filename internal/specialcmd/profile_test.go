@@ -0,0 +1,21 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTimingsTableRows(t *testing.T) {
+	rows := timingsTableRows("Kernel <startup>", []goexec.Timing{
+		{Phase: "go.mod init", Duration: 1500 * time.Microsecond},
+		{Phase: "gopls launch", Duration: 20 * time.Millisecond},
+	})
+	assert.Equal(t, []string{
+		`<tr><th colspan="2" style="text-align:left">Kernel &lt;startup&gt;</th></tr>`,
+		`<tr><td>go.mod init</td><td style="text-align:right">2ms</td></tr>`,
+		`<tr><td>gopls launch</td><td style="text-align:right">20ms</td></tr>`,
+		`<tr><td><b>Total</b></td><td style="text-align:right"><b>22ms</b></td></tr>`,
+	}, rows)
+}
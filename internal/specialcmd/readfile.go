@@ -0,0 +1,182 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"go/token"
+	"html"
+	"k8s.io/klog/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// This file implements `%readfile`, the complement of `%%writefile`.
+
+// readfileUsage is the usage reported in errors of `%readfile`.
+const readfileUsage = "%readfile [--range <from>:<to>] [--tail <num_lines>] [--var <name>] <file_path>"
+
+// readfileOptions are the options of `%readfile`, see parseReadfileArgs.
+type readfileOptions struct {
+	filePath string
+
+	// fromLine, toLine are the range of lines (1-based, inclusive) to read. 0 means the start/end of the file.
+	fromLine, toLine int
+
+	// tail, if > 0, selects only the last lines.
+	tail int
+
+	// varName, if set, is the name of the Go variable where to memorize the contents.
+	varName string
+}
+
+// execReadFile executes the "%readfile" special command. The parameter `args` excludes "%readfile".
+//
+// It displays the contents of a file (Go files are highlighted), or with `--var <name>` it memorizes them as
+// a Go string variable instead.
+func execReadFile(msg kernel.Message, goExec *goexec.State, args []string) error {
+	opts, err := parseReadfileArgs(args)
+	if err != nil {
+		return err
+	}
+	filePath := ReplaceEnvVars(ReplaceTildeInDir(opts.filePath))
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrapf(err, "`%%readfile` failed to read %q", filePath)
+	}
+	text, firstLine := selectLines(string(contents), opts)
+
+	if opts.varName != "" {
+		goExec.Definitions.Variables[opts.varName] = &goexec.Variable{
+			Cursor:          goexec.NoCursor,
+			CellLines:       goexec.CellLines{Id: -1},
+			Key:             opts.varName,
+			Name:            opts.varName,
+			ValueDefinition: strconv.Quote(text),
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("Contents of %q (%d bytes) memorized in variable %s.\n", filePath, len(text), opts.varName))
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+		return nil
+	}
+
+	var content string
+	if filepath.Ext(filePath) == ".go" {
+		content = goexec.HighlightGo(text)
+	} else {
+		content = html.EscapeString(text)
+	}
+	title := html.EscapeString(filePath)
+	if firstLine > 1 {
+		title += fmt.Sprintf(" (from line %d)", firstLine)
+	}
+	err = kernel.PublishHtml(msg, fmt.Sprintf("<b>%s</b>\n<pre style=\"margin: 0\">%s</pre>\n", title, content))
+	if err != nil {
+		klog.Errorf("Failed to publish %%readfile contents back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// parseReadfileArgs parses the arguments of `%readfile`, excluding the "%readfile" itself.
+func parseReadfileArgs(args []string) (opts readfileOptions, err error) {
+	var positional []string
+	for ii := 0; ii < len(args); ii++ {
+		arg := args[ii]
+		var value string
+		if name, v, found := strings.Cut(arg, "="); found && strings.HasPrefix(name, "--") {
+			arg, value = name, v
+		} else if strings.HasPrefix(arg, "--") {
+			if ii+1 >= len(args) {
+				err = errors.Errorf("missing value for %s, expected %q", arg, readfileUsage)
+				return
+			}
+			ii++
+			value = args[ii]
+		}
+		switch arg {
+		case "--range":
+			from, to, found := strings.Cut(value, ":")
+			if !found {
+				err = errors.Errorf("invalid --range %q, expected <from>:<to>, e.g.: 10:20, 10: or :20", value)
+				return
+			}
+			if opts.fromLine, err = parseLineNumber(from); err == nil {
+				opts.toLine, err = parseLineNumber(to)
+			}
+			if err != nil || (opts.toLine > 0 && opts.toLine < opts.fromLine) {
+				err = errors.Errorf("invalid --range %q, expected <from>:<to> line numbers (starting from 1), e.g.: 10:20, 10: or :20", value)
+				return
+			}
+		case "--tail":
+			opts.tail, err = strconv.Atoi(value)
+			if err != nil || opts.tail <= 0 {
+				err = errors.Errorf("invalid --tail %q, expected a positive number of lines", value)
+				return
+			}
+		case "--var":
+			if !token.IsIdentifier(value) {
+				err = errors.Errorf("invalid --var %q, expected a Go identifier", value)
+				return
+			}
+			opts.varName = value
+		default:
+			if strings.HasPrefix(arg, "--") {
+				err = errors.Errorf("unknown flag %q, expected %q", arg, readfileUsage)
+				return
+			}
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 1 {
+		err = errors.Errorf("expected %q, but got %q instead", readfileUsage, args)
+		return
+	}
+	opts.filePath = positional[0]
+	return
+}
+
+// parseLineNumber parses a 1-based line number, where empty means 0 (no limit).
+func parseLineNumber(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	lineNum, err := strconv.Atoi(value)
+	if err != nil || lineNum < 1 {
+		return 0, errors.Errorf("invalid line number %q", value)
+	}
+	return lineNum, nil
+}
+
+// selectLines returns the lines of text selected by `--range` and `--tail`, and the number (1-based) of the first
+// line returned.
+func selectLines(text string, opts readfileOptions) (selected string, firstLine int) {
+	if opts.fromLine == 0 && opts.toLine == 0 && opts.tail == 0 {
+		return text, 1
+	}
+	hasFinalNewLine := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	from, to := 0, len(lines)
+	if opts.fromLine > 0 {
+		from = min(opts.fromLine-1, len(lines))
+	}
+	if opts.toLine > 0 {
+		to = min(opts.toLine, len(lines))
+	}
+	if opts.tail > 0 && to-from > opts.tail {
+		from = to - opts.tail
+	}
+	if from >= to {
+		return "", from + 1
+	}
+	selected = strings.Join(lines[from:to], "\n")
+	if hasFinalNewLine || to < len(lines) {
+		selected += "\n"
+	}
+	return selected, from + 1
+}
@@ -0,0 +1,57 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseReadfileArgs(t *testing.T) {
+	opts, err := parseReadfileArgs([]string{"--range", "3:5", "--var=data", "file.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, readfileOptions{filePath: "file.txt", fromLine: 3, toLine: 5, varName: "data"}, opts)
+
+	opts, err = parseReadfileArgs([]string{"--tail", "10", "file.txt"})
+	require.NoError(t, err)
+	assert.Equal(t, readfileOptions{filePath: "file.txt", tail: 10}, opts)
+
+	for _, args := range [][]string{{}, {"a", "b"}, {"--range", "5:3", "a"}, {"--range=0:3", "a"}, {"--range", "3", "a"},
+		{"--tail", "0", "a"}, {"--var", "not-a-name", "a"}, {"--unknown=1", "a"}, {"a", "--tail"}} {
+		_, err = parseReadfileArgs(args)
+		assert.Errorf(t, err, "parseReadfileArgs(%q) should have failed", args)
+	}
+}
+
+func TestSelectLines(t *testing.T) {
+	text := "1\n2\n3\n4\n5\n"
+	selected, first := selectLines(text, readfileOptions{})
+	assert.Equal(t, text, selected)
+	assert.Equal(t, 1, first)
+	selected, first = selectLines(text, readfileOptions{fromLine: 2, toLine: 3})
+	assert.Equal(t, "2\n3\n", selected)
+	assert.Equal(t, 2, first)
+	selected, first = selectLines(text, readfileOptions{tail: 2})
+	assert.Equal(t, "4\n5\n", selected)
+	assert.Equal(t, 4, first)
+	selected, _ = selectLines("1\n2\n3", readfileOptions{fromLine: 2})
+	assert.Equal(t, "2\n3", selected)
+	selected, _ = selectLines(text, readfileOptions{fromLine: 10})
+	assert.Equal(t, "", selected)
+}
+
+func TestReadfileVar(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	filePath := path.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(filePath, []byte("a,b\n1,\"2\"\n3,4\n"), 0644))
+
+	status := &cellStatus{}
+	require.NoError(t, execSpecialConfig(msg, s, 0, "readfile --range 1:2 --var data "+filePath, status))
+	require.Contains(t, s.Definitions.Variables, "data")
+	assert.Equal(t, `"a,b\n1,\"2\"\n"`, s.Definitions.Variables["data"].ValueDefinition)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "readfile --var data "+filePath+".missing", status))
+}
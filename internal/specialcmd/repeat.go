@@ -0,0 +1,98 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"sync"
+	"time"
+)
+
+// This file implements the repeated execution of a cell, used by `%watch` and `%every`.
+
+// executeRepeatedCell executes the cell with ExecuteCell and, if it used `%watch` or `%every`, executes it again
+// whenever the watched files change, or at every interval, until the cell is interrupted. The output of the previous
+// execution is cleared at each execution, so the output is updated in place.
+//
+// Errors of the executions are displayed, and they don't stop the repetition.
+func executeRepeatedCell(msg kernel.Message, goExec *goexec.State, cellId int, lines []string) error {
+	err := ExecuteCell(msg, goExec, cellId, lines)
+	paths, interval := goExec.CellWatchPaths, goExec.CellRefreshInterval
+	goExec.CellWatchPaths, goExec.CellRefreshInterval = nil, 0
+	if (len(paths) == 0 && interval == 0) || msg == nil {
+		return err
+	}
+	if len(paths) > 0 && interval > 0 {
+		return errors.Errorf("`%%watch` and `%%every` can't be used in the same cell")
+	}
+	reportRepeatedCellError(msg, err)
+
+	interrupted := make(chan struct{})
+	var once sync.Once
+	subscriptionId := msg.Kernel().SubscribeInterrupt(func(kernel.SubscriptionId) {
+		once.Do(func() { close(interrupted) })
+	})
+	defer msg.Kernel().UnsubscribeInterrupt(subscriptionId)
+
+	// waitNext waits for the next execution, and returns its reason, or "" if interrupted.
+	var waitNext func(lastExecution time.Time) (string, error)
+	if len(paths) > 0 {
+		watcher, err := newPathsWatcher(paths)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = watcher.Close() }()
+		waitNext = func(lastExecution time.Time) (string, error) {
+			changed, err := watcher.waitChange(interrupted, lastExecution)
+			if changed != "" {
+				changed = fmt.Sprintf("%q changed", changed)
+			}
+			return changed, err
+		}
+	} else {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		waitNext = func(time.Time) (string, error) {
+			select {
+			case <-interrupted:
+				return "", nil
+			case <-ticker.C:
+				return fmt.Sprintf("every %s", interval), nil
+			}
+		}
+	}
+
+	lastExecution := time.Now()
+	for !msg.Kernel().Interrupted.Load() {
+		reason, err := waitNext(lastExecution)
+		if err != nil || reason == "" {
+			return err
+		}
+		if msg.Kernel().Interrupted.Load() {
+			break
+		}
+		klog.V(1).Infof("Executing cell %d again: %s", cellId, reason)
+		if err = kernel.PublishClearOutput(msg, true); err != nil {
+			klog.Errorf("Failed to clear output: %+v", err)
+		}
+		lastExecution = time.Now()
+		err = ExecuteCell(msg, goExec, cellId, lines)
+		goExec.CellWatchPaths, goExec.CellRefreshInterval = nil, 0
+		reportRepeatedCellError(msg, err)
+	}
+	return nil
+}
+
+// reportRepeatedCellError displays the error of one execution of a repeated cell, if any.
+func reportRepeatedCellError(msg kernel.Message, err error) {
+	if err == nil {
+		return
+	}
+	name, value, _ := goexec.JupyterErrorSplit(err)
+	err = kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("%s: %s\n", name, value))
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+}
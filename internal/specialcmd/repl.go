@@ -0,0 +1,39 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execRepl executes the "%repl" special command. The parameter `args` excludes "%repl", and it accepts "on" or
+// "off". Without arguments, it displays the current setting.
+//
+// If on, the variables declared at the top level of `func main()` are memorized, see goexec.State.Repl.
+func execRepl(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%repl [on|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		switch args[0] {
+		case "on":
+			goExec.Repl = true
+		case "off":
+			goExec.Repl = false
+		default:
+			return errors.Errorf("`%%repl [on|off]`: invalid argument %q", args[0])
+		}
+	}
+	state := "off"
+	if goExec.Repl {
+		state = "on"
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("Memorization of the variables declared in `func main()` (REPL mode): %s\n", state))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
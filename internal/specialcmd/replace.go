@@ -0,0 +1,50 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execReplace executes the "%replace <module_path> => <target_module_path>@<ref>" special command, which
+// replaces a module by a version, branch, tag or commit of another (e.g. a fork). The "=>" is optional.
+// The parameter `args` excludes "%replace".
+func execReplace(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 3 && args[1] == "=>" {
+		args = []string{args[0], args[2]}
+	}
+	if len(args) != 2 {
+		return errors.Errorf("`%%replace <module_path> => <module_path>@<ref>`: invalid arguments %q, e.g.: "+
+			"`%%replace example.com/mod => github.com/fork/mod@main`", args)
+	}
+	replaceLine, err := goExec.ReplaceWithRef(msg, args[0], args[1])
+	if err != nil {
+		return errors.WithMessagef(err, "`%%replace`")
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, "\t"+replaceLine+"\n")
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execReplaceLocal executes the "%replace_local <module_path> <directory>" special command, which replaces a
+// module by a local checkout, and tracks it for `gopls`. The parameter `args` excludes "%replace_local".
+func execReplaceLocal(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 2 {
+		return errors.Errorf("`%%replace_local <module_path> <directory>`: invalid arguments %q, e.g.: "+
+			"`%%replace_local example.com/mod /home/user/src/mod`", args)
+	}
+	dir, err := goExec.ReplaceLocal(args[0], args[1])
+	if err != nil {
+		return errors.WithMessagef(err, "`%%replace_local`")
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("\t- Added replace rule for module %q to local directory %q.\n", args[0], dir))
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
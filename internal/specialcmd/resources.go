@@ -0,0 +1,113 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file handles the commands that control the resources used by the cell programs, e.g.: %memlimit.
+
+const (
+	// memLimitEnv is the environment variable read by the Go runtime with the soft memory limit.
+	memLimitEnv = "GOMEMLIMIT"
+
+	// MinMemLimit is the smallest memory limit accepted by `%memlimit`: values below it are raised to it,
+	// since programs can hardly run with less.
+	MinMemLimit = 4 << 20
+)
+
+// execMemLimit executes the "%memlimit" special command. The parameter `args` excludes "%memlimit".
+//
+// It sets GOMEMLIMIT, the soft memory limit of the Go runtime, for the programs executed by the
+// following cells. "off" removes the limit, and without arguments it only reports the current value.
+func execMemLimit(msg kernel.Message, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%memlimit [<size>|off]`: it takes at most one argument, but %d were given", len(args))
+	}
+	var report string
+	if len(args) == 1 {
+		if strings.ToLower(args[0]) == "off" {
+			if err := os.Unsetenv(memLimitEnv); err != nil {
+				return errors.Wrapf(err, "failed to unset %s", memLimitEnv)
+			}
+		} else {
+			limit, err := parseMemSize(args[0])
+			if err != nil {
+				return errors.WithMessagef(err, "`%%memlimit %s`", args[0])
+			}
+			if limit < MinMemLimit {
+				report = fmt.Sprintf("Memory limit %s is too small, raised to %s.\n",
+					formatMemSize(limit), formatMemSize(MinMemLimit))
+				limit = MinMemLimit
+			}
+			if err = os.Setenv(memLimitEnv, formatMemSize(limit)); err != nil {
+				return errors.Wrapf(err, "failed to set %s", memLimitEnv)
+			}
+		}
+	}
+	if value := os.Getenv(memLimitEnv); value != "" {
+		report += fmt.Sprintf("%s=%s\n", memLimitEnv, value)
+	} else {
+		report += fmt.Sprintf("%s not set: no memory limit.\n", memLimitEnv)
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+var (
+	memSizeRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]*)$`)
+
+	// memSizeUnits maps the accepted (lower-case) units to their size in bytes. Both the binary units
+	// (KiB, MiB, ...) and the decimal ones (KB, MB, ...) are accepted, and single letters are taken as binary.
+	memSizeUnits = map[string]int64{
+		"": 1, "b": 1,
+		"k": 1 << 10, "kib": 1 << 10, "kb": 1e3,
+		"m": 1 << 20, "mib": 1 << 20, "mb": 1e6,
+		"g": 1 << 30, "gib": 1 << 30, "gb": 1e9,
+		"t": 1 << 40, "tib": 1 << 40, "tb": 1e12,
+	}
+)
+
+// parseMemSize parses a human-readable memory size, e.g.: "512MiB", "1.5GB", "2g" or "1048576".
+func parseMemSize(value string) (int64, error) {
+	matches := memSizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0, errors.Errorf("invalid memory size %q, use for instance 512MiB or 2GB", value)
+	}
+	unit, found := memSizeUnits[strings.ToLower(matches[2])]
+	if !found {
+		return 0, errors.Errorf("unknown memory size unit %q in %q, use B, KiB, MiB, GiB, TiB (or KB, MB, GB, TB)",
+			matches[2], value)
+	}
+	number, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid memory size %q", value)
+	}
+	size := number * float64(unit)
+	if size >= math.MaxInt64 {
+		return 0, errors.Errorf("memory size %q is too large", value)
+	}
+	return int64(size), nil
+}
+
+// formatMemSize formats size in the format accepted by GOMEMLIMIT, using the largest binary unit that
+// represents it exactly, e.g.: "512MiB".
+func formatMemSize(size int64) string {
+	for _, unit := range []string{"TiB", "GiB", "MiB", "KiB"} {
+		unitSize := memSizeUnits[strings.ToLower(unit)]
+		if size >= unitSize && size%unitSize == 0 {
+			return fmt.Sprintf("%d%s", size/unitSize, unit)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
@@ -0,0 +1,34 @@
+package specialcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseMemSize(t *testing.T) {
+	for value, want := range map[string]int64{
+		"512MiB":  512 << 20,
+		"512mib":  512 << 20,
+		"2g":      2 << 30,
+		"1.5GB":   1_500_000_000,
+		"100 KB":  100_000,
+		"1048576": 1 << 20,
+		"64B":     64,
+	} {
+		got, err := parseMemSize(value)
+		require.NoErrorf(t, err, "parseMemSize(%q)", value)
+		assert.Equalf(t, want, got, "parseMemSize(%q)", value)
+	}
+	for _, value := range []string{"", "MiB", "-1GiB", "12 parsecs", "1e30TB"} {
+		_, err := parseMemSize(value)
+		assert.Errorf(t, err, "parseMemSize(%q) should have failed", value)
+	}
+}
+
+func TestFormatMemSize(t *testing.T) {
+	assert.Equal(t, "512MiB", formatMemSize(512<<20))
+	assert.Equal(t, "3GiB", formatMemSize(3<<30))
+	assert.Equal(t, "1500000KiB", formatMemSize(1_536_000_000))
+	assert.Equal(t, "1001B", formatMemSize(1001))
+}
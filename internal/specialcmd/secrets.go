@@ -0,0 +1,77 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"path"
+	"strings"
+)
+
+// This file implements the masking of secrets in the output: `%secret`, and the masking of the values of
+// secrets set with `%env`.
+
+// maskedValue replaces the values of secrets in the output.
+const maskedValue = kernel.RedactedSecret
+
+// isSecretEnvName returns whether the environment variable name matches one of goExec.SecretEnvPatterns.
+func isSecretEnvName(goExec *goexec.State, name string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range goExec.SecretEnvPatterns {
+		if matched, _ := path.Match(strings.ToUpper(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// execSecret executes the "%secret" special command. The parameter `args` excludes "%secret".
+//
+//   - `%secret add <value>...`: registers the values as secrets, redacted from the output published to the
+//     front-end. Environment variables are expanded, so `%secret add $API_TOKEN` registers its value.
+//   - `%secret clear`: removes all secrets registered.
+//   - `%secret` or `%secret list`: reports how many secrets are registered (not their values).
+func execSecret(msg kernel.Message, args []string) error {
+	if msg == nil || msg.Kernel() == nil {
+		return errors.Errorf("`%%secret` requires a connection to the kernel")
+	}
+	k := msg.Kernel()
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			return errors.Errorf("`%%secret add <value>...` requires at least one value, e.g.: `%%secret add $API_TOKEN`")
+		}
+		for _, arg := range args[1:] {
+			value := ReplaceEnvVars(arg)
+			if value == "" {
+				return errors.Errorf("`%%secret add`: %q is empty, is the environment variable set?", arg)
+			}
+			if err := k.AddSecret(value); err != nil {
+				return errors.WithMessagef(err, "`%%secret add %s`", arg)
+			}
+		}
+	case "clear":
+		if len(args) != 1 {
+			return errors.Errorf("`%%secret clear` takes no arguments")
+		}
+		k.ClearSecrets()
+	case "list":
+		if len(args) != 1 {
+			return errors.Errorf("`%%secret list` takes no arguments")
+		}
+	default:
+		return errors.Errorf("`%%secret`: unknown sub-command %q, expected add, clear or list", args[0])
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("%d secret(s) redacted from the output.\n", k.NumSecrets()))
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/exp/slices"
+	"html"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file implements `%snapshot` and `%diff`, to compare the memorized declarations against a previous state.
+
+// diffContextLines is the number of unchanged lines displayed around the changes by `%diff`.
+const diffContextLines = 3
+
+// execSnapshot executes the "%snapshot" special command. The parameter `args` excludes "%snapshot".
+//
+// `%snapshot save <name>` saves the source of the current memorized declarations under the given name,
+// and `%snapshot` (or `%snapshot list`) lists the snapshots saved.
+func execSnapshot(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 || (len(args) == 1 && args[0] == "list") {
+		var report string
+		if len(goExec.Snapshots) == 0 {
+			report = "No snapshots saved, use `%snapshot save <name>` to save one.\n"
+		} else {
+			names := make([]string, 0, len(goExec.Snapshots))
+			for name := range goExec.Snapshots {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			report = fmt.Sprintf("Snapshots: %s\n", strings.Join(names, ", "))
+		}
+		err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+		return nil
+	}
+	if len(args) != 2 || args[0] != "save" {
+		return errors.Errorf("`%%snapshot` expects `save <name>` or `list`, got %q instead", args)
+	}
+	source, err := goExec.DeclarationsSource()
+	if err != nil {
+		return errors.WithMessagef(err, "`%%snapshot save %s` failed to render the memorized declarations", args[1])
+	}
+	goExec.Snapshots[args[1]] = source
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Snapshot %q saved.\n", args[1]))
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+// execDiff executes the "%diff" special command. The parameter `args` excludes "%diff".
+//
+// It displays a unified diff between the snapshot saved with the given name and the current memorized declarations.
+func execDiff(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("`%%diff <snapshot_name>` takes exactly one argument, got %q instead", args)
+	}
+	snapshot, found := goExec.Snapshots[args[0]]
+	if !found {
+		return errors.Errorf("`%%diff`: snapshot %q not found, save it first with `%%snapshot save %s`", args[0], args[0])
+	}
+	current, err := goExec.DeclarationsSource()
+	if err != nil {
+		return errors.WithMessage(err, "`%diff` failed to render the memorized declarations")
+	}
+	lines := unifiedDiff(snapshot, current, diffContextLines)
+	if len(lines) == 0 {
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("No changes since snapshot %q.\n", args[0]))
+		if err != nil {
+			klog.Errorf("Failed to output: %+v", err)
+		}
+		return nil
+	}
+	err = kernel.PublishHtml(msg, diffToHtml(args[0], lines))
+	if err != nil {
+		klog.Errorf("Failed to publish %%diff results back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// diffToHtml renders the lines of a unified diff, colored.
+func diffToHtml(snapshotName string, lines []string) string {
+	parts := []string{fmt.Sprintf(`<b>Changes since snapshot %s</b>`, html.EscapeString(snapshotName)),
+		`<pre style="margin: 0">`}
+	for _, line := range lines {
+		var style string
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			style = "color: gray"
+		case strings.HasPrefix(line, "+"):
+			style = "color: green"
+		case strings.HasPrefix(line, "-"):
+			style = "color: red"
+		}
+		if style == "" {
+			parts = append(parts, html.EscapeString(line))
+		} else {
+			parts = append(parts, fmt.Sprintf(`<span style="%s">%s</span>`, style, html.EscapeString(line)))
+		}
+	}
+	parts = append(parts, "</pre>")
+	return strings.Join(parts, "\n")
+}
+
+// unifiedDiff returns the lines of the unified diff (without the file headers) from a to b, with the given
+// number of context lines around the changes. It returns nil if a and b are the same.
+func unifiedDiff(a, b string, context int) []string {
+	if a == b {
+		return nil
+	}
+	linesA, linesB := splitLines(a), splitLines(b)
+	for ii := range linesA {
+		linesA[ii] += "\n"
+	}
+	for ii := range linesB {
+		linesB[ii] += "\n"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{A: linesA, B: linesB, Context: context})
+	if err != nil {
+		// It only fails if writing to the buffer fails.
+		klog.Errorf("Failed to diff: %+v", err)
+		return nil
+	}
+	return splitLines(diff)
+}
+
+// splitLines splits text in lines, ignoring the final new line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
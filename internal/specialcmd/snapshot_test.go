@@ -0,0 +1,40 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Nil(t, unifiedDiff("a\nb\n", "a\nb\n", 3))
+	assert.Equal(t, []string{"@@ -1,3 +1,3 @@", " a", "-b", "+B", " c"},
+		unifiedDiff("a\nb\nc\n", "a\nB\nc\n", 3))
+	assert.Equal(t, []string{"@@ -0,0 +1 @@", "+a"}, unifiedDiff("", "a\n", 3))
+
+	// Changes far apart are split in separate hunks, with 1 line of context.
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	b := "0\n1\n2\n3\n4\n5\n6\n7\n"
+	assert.Equal(t, []string{"@@ -1 +1,2 @@", "+0", " 1", "@@ -7,2 +8 @@", " 7", "-8"}, unifiedDiff(a, b, 1))
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	status := &cellStatus{}
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "snapshot save before", status))
+	require.Contains(t, s.Snapshots, "before")
+	s.Definitions.Constants["answer"] = &goexec.Constant{
+		Cursor: goexec.NoCursor, CellLines: goexec.CellLines{Id: -1},
+		Key: "answer", ValueDefinition: "42"}
+	current, err := s.DeclarationsSource()
+	require.NoError(t, err)
+	assert.NotEqual(t, s.Snapshots["before"], current)
+	require.NoError(t, execSpecialConfig(msg, s, 0, "diff before", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "diff unknown", status))
+	assert.Error(t, execSpecialConfig(msg, s, 0, "snapshot remove before", status))
+}
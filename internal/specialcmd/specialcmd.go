@@ -13,6 +13,7 @@ import (
 	"github.com/janpfeifer/gonb/internal/jpyexec"
 	"golang.org/x/exp/slices"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -35,6 +36,32 @@ var HelpMessage string
 // cellStatus holds temporary status for the execution of the current cell.
 type cellStatus struct {
 	withInputs, withPassword bool
+
+	// withEnv holds the environment variables ("KEY=VALUE") set with `%with_env` for the next shell command only.
+	withEnv []string
+}
+
+// ExecuteCell executes the lines of a cell: either a special cell (see ExecuteSpecialCell), or the
+// special commands in it followed by its Go code, if there is any.
+//
+// cellId is the execution number of the cell, see goexec.State.ExecuteCell.
+// If the cell is named with `%%cell <name>`, it is recorded so it can be executed again with `%run <name>`.
+func ExecuteCell(msg kernel.Message, goExec *goexec.State, cellId int, lines []string) error {
+	if err := recordNamedCell(msg, goExec, cellId, lines); err != nil {
+		return err
+	}
+	if specialCell, err := ExecuteSpecialCell(msg, goExec, lines); specialCell {
+		return err // err may be nil here, if magic cell command was executed correctly.
+	}
+	specialLines := MakeSet[int]() // lines that are special commands and not Go.
+	if err := Parse(msg, goExec, true, lines, specialLines); err != nil {
+		return errors.WithMessagef(err, "executing special commands in cell")
+	}
+	hasMoreToRun := !goexec.IsEmptyLines(lines, specialLines) || goExec.CellState.RequiresExecution()
+	if msg != nil && msg.Kernel().Interrupted.Load() || !hasMoreToRun {
+		return nil
+	}
+	return goExec.ExecuteCell(msg, cellId, lines, specialLines)
 }
 
 // Parse will check whether the given code to be executed has any special commands.
@@ -42,7 +69,14 @@ type cellStatus struct {
 // Any special commands found in the code will be executed (if execute is set to true) and the corresponding lines used
 // from the code will be returned in usedLines -- so they can be excluded from other executors (goexec).
 //
-// If any errors happen, it is returned in err.
+// Lines starting with an escaped special command character (`\%` or `\!`) are not interpreted,
+// and are left for goexec, which removes the escape -- see goexec.IsEscapedSpecialCmd.
+//
+// The lines after a `%%go` fence (see goexec.GoFence) are all Go code, and are not interpreted. The
+// fence line itself is included in usedLines.
+//
+// If any errors happen, it is returned in err, prefixed with the (1-based) line number in the cell
+// where the offending command started.
 func Parse(msg kernel.Message, goExec *goexec.State, execute bool, codeLines []string, usedLines Set[int]) (err error) {
 	status := &cellStatus{}
 
@@ -50,6 +84,10 @@ func Parse(msg kernel.Message, goExec *goexec.State, execute bool, codeLines []s
 		if usedLines.Has(lineNum) {
 			continue
 		}
+		if goexec.IsGoFence(line) {
+			usedLines.Insert(lineNum)
+			break
+		}
 		if len(line) > 1 && (line[0] == '%' || line[0] == '!') {
 			var cmdStr string
 			cmdStr = joinLine(codeLines, lineNum, usedLines)
@@ -65,12 +103,21 @@ func Parse(msg kernel.Message, goExec *goexec.State, execute bool, codeLines []s
 			if execute {
 				switch cmdType {
 				case '%':
-					err = execSpecialConfig(msg, goExec, cmdStr, status)
+					// A trailing ";" suppresses the output of the special command, as in IPython.
+					cmdMsg := msg
+					if quietCmdStr, found := strings.CutSuffix(strings.TrimRight(cmdStr, " \t"), ";"); found {
+						cmdStr, cmdMsg = quietCmdStr, kernel.NewQuietMessage(msg)
+						if strings.TrimSpace(cmdStr) == "" {
+							continue
+						}
+					}
+					err = execSpecialConfig(cmdMsg, goExec, lineNum, cmdStr, status)
 					if err != nil {
+						err = errors.WithMessagef(err, "line %d", lineNum+1)
 						return
 					}
 				case '!':
-					err = execShell(msg, goExec, cmdStr, status)
+					err = execShell(msg, goExec, lineNum, cmdStr, status)
 					if err != nil {
 						return
 					}
@@ -110,24 +157,53 @@ func joinLine(lines []string, fromLine int, usedLines Set[int]) (cmdStr string)
 // It only returns errors for system errors that will lead to the kernel restart. Syntax errors
 // on the command themselves are simply reported back to jupyter and are not returned here.
 //
+// The lineNum (0-based) is the line in the cell where the command started, and it is used when
+// reporting issues back to jupyter.
+//
 // It supports msg == nil for testing.
-func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string, status *cellStatus) error {
+func execSpecialConfig(msg kernel.Message, goExec *goexec.State, lineNum int, cmdStr string, status *cellStatus) error {
 	_ = goExec
 	var content map[string]any
 	if msg != nil && msg.ComposedMsg().Content != nil {
 		content = msg.ComposedMsg().Content.(map[string]any)
 	}
+	cmdStr = expandAlias(goExec, cmdStr)
 	parts := splitCmd(cmdStr)
+	if magic, found := registeredMagics[parts[0]]; found {
+		// Special commands registered by extensions: their names never collide with the built-in ones.
+		return magic(msg, goExec, parts[1:])
+	}
 	switch parts[0] {
 
 	// Configures how cell will be executed.
 	case "%", "main", "args", "test":
 		// Set arguments for execution, allows one to set flags, etc.
 		goExec.Args = parts[1:]
-		klog.V(2).Infof("Program args to use (%%%s): %+q", parts[0], goExec.Args)
 		if parts[0] == "test" {
 			goExec.CellIsTest = true
+			goExec.Args = nil
+			for _, arg := range parts[1:] {
+				if arg == "--race" || arg == "-race" {
+					goExec.CellIsRace = true
+					continue
+				}
+				goExec.Args = append(goExec.Args, arg)
+			}
+			if goExec.CellIsRace {
+				if err := goExec.CheckRaceSupport(); err != nil {
+					return errors.WithMessagef(err, "`%%test --race`")
+				}
+			}
+		}
+		if parts[0] == "%" || parts[0] == "main" {
+			entry, args, err := extractEntryPoint(parts[1:])
+			if err != nil {
+				return err
+			}
+			goExec.Args = args
+			goExec.CellEntryPoint = entry
 		}
+		klog.V(2).Infof("Program args to use (%%%s): %+q", parts[0], goExec.Args)
 		// %% and %main are also handled specially by goexec, where it starts a main() clause.
 	case "wasm":
 		if len(parts) > 1 {
@@ -141,6 +217,101 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 		}
 		goExec.WasmDivId = UniqueId() // Unique ID for this cell.
 
+	case "show":
+		if len(parts) > 1 {
+			return errors.Errorf("`%%show` takes no extra parameters.")
+		}
+		goExec.CellIsDryRun = true
+
+	case "build":
+		return execBuild(goExec, parts[1:])
+
+	case "keep", "discard":
+		if len(parts) > 1 {
+			return errors.Errorf("`%%%s` takes no extra parameters.", parts[0])
+		}
+		if parts[0] == "keep" {
+			goExec.CellKeepDecls = true
+		} else {
+			goExec.CellDiscardDecls = true
+		}
+		if goExec.CellKeepDecls && goExec.CellDiscardDecls {
+			return errors.Errorf("`%%keep` and `%%discard` can't be used in the same cell.")
+		}
+
+	case "asm":
+		if len(parts) != 2 {
+			return errors.Errorf("`%%asm <function>` takes exactly one parameter, the name of the function or " +
+				"method (e.g.: `MyType.MyMethod`) whose assembly to display.")
+		}
+		if goExec.CellIsWasm {
+			return errors.Errorf("`%%asm` cannot be used in a `%%wasm` cell.")
+		}
+		goExec.CellAsmFunction = parts[1]
+
+	case "escape":
+		if len(parts) > 2 {
+			return errors.Errorf("`%%escape [<function>]` takes at most one parameter, the name of the function or " +
+				"method (e.g.: `MyType.MyMethod`) whose escape analysis to display.")
+		}
+		if goExec.CellIsWasm {
+			return errors.Errorf("`%%escape` cannot be used in a `%%wasm` cell.")
+		}
+		goExec.CellIsEscapeAnalysis = true
+		if len(parts) == 2 {
+			goExec.CellEscapeFunction = parts[1]
+		}
+
+	case "export":
+		if len(parts) != 2 || !strings.HasSuffix(parts[1], ".go") {
+			return errors.Errorf("`%%export <file.go>` takes exactly one parameter, the path of the Go file to create.")
+		}
+		if goExec.CellIsTest {
+			return errors.Errorf("`%%export` cannot export a `%%test` cell, only programs with a `main` function.")
+		}
+		goExec.CellExportPath = ReplaceEnvVars(ReplaceTildeInDir(parts[1]))
+
+	case "export_module":
+		if len(parts) != 2 {
+			return errors.Errorf("`%%export_module <dir>` takes exactly one parameter, the directory where to create the module.")
+		}
+		if goExec.CellIsTest {
+			return errors.Errorf("`%%export_module` cannot export a `%%test` cell, only programs with a `main` function.")
+		}
+		goExec.CellExportModuleDir = ReplaceEnvVars(ReplaceTildeInDir(parts[1]))
+
+	case "http":
+		return execHttp(msg, goExec, parts[1:])
+
+	case "http_header":
+		return execHttpHeader(msg, goExec, parts[1:])
+
+	case "secret":
+		return execSecret(msg, parts[1:])
+
+	case "sql_connect":
+		return execSqlConnect(msg, goExec, parts[1:])
+
+	case "capture_coverage":
+		return execCaptureCoverage(msg, goExec, parts[1:])
+
+	case "coverage":
+		return execCoverage(msg, goExec, parts[1:])
+
+	case "watch":
+		return execWatch(goExec, parts[1:])
+
+	case "every":
+		return execEvery(msg, goExec, parts[1:])
+
+	case "fuzz":
+		return execFuzz(goExec, parts[1:])
+
+	case "pgo":
+		return execPgo(msg, goExec, parts[1:])
+	case "lenient":
+		return execLenient(msg, goExec, parts[1:])
+
 	case "widgets":
 		return goExec.Comms.InstallWebSocket(msg)
 
@@ -157,7 +328,11 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 		}
 
 	case "env":
-		// Set environment variables.
+		// Set environment variables. The values of secrets are masked, unless `--show` is given.
+		show := len(parts) > 1 && parts[1] == "--show"
+		if show {
+			parts = append(parts[:1], parts[2:]...)
+		}
 		if len(parts) == 2 {
 			// Adjust parts if one uses `%env KEY=VALUE` format instead.
 			if eqPos := strings.Index(parts[1], "="); eqPos > 1 {
@@ -167,18 +342,45 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 			}
 		}
 		if len(parts) != 3 {
-			return errors.Errorf("`%%env <VAR_NAME> <value>` (or `%%env <VAR_NAME>=<value>`): it takes 2 arguments, the variable name and it's content, but %d were given", len(parts)-1)
+			return errors.Errorf("`%%env [--show] <VAR_NAME> <value>` (or `%%env [--show] <VAR_NAME>=<value>`): it takes 2 arguments, the variable name and it's content, but %d were given", len(parts)-1)
 		}
 		err := os.Setenv(parts[1], parts[2])
 		if err != nil {
-			return errors.Wrapf(err, "`%%env %q %q` failed", parts[1], parts[2])
+			return errors.Wrapf(err, "`%%env %s` failed", parts[1])
+		}
+		value := strconv.Quote(parts[2])
+		if !show && isSecretEnvName(goExec, parts[1]) {
+			value = maskedValue
+			if msg != nil && msg.Kernel() != nil {
+				// Also redact it from any output, see `%secret`, unless it's too short.
+				if msg.Kernel().AddSecret(parts[2]) != nil {
+					value += fmt.Sprintf(" (shorter than %d characters, not redacted from the output)",
+						kernel.MinSecretLength)
+				}
+			}
 		}
 		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
-			fmt.Sprintf("Set: %s=%q\n", parts[1], parts[2]))
+			fmt.Sprintf("Set: %s=%s\n", parts[1], value))
 		if err != nil {
 			klog.Errorf("Failed to output: %+v", err)
 		}
 
+	case "env_persist":
+		return execEnvPersist(msg, goExec, parts[1:])
+	case "goprivate":
+		return execGoPrivate(msg, goExec, parts[1:])
+	case "goproxy":
+		return execGoProxy(msg, goExec, parts[1:])
+	case "gocache":
+		return execGoCache(msg, goExec, parts[1:])
+
+	case "pwd":
+		if len(parts) > 1 {
+			return errors.Errorf("`%%pwd` takes no arguments, but %d were given", len(parts)-1)
+		}
+		pwd, _ := os.Getwd()
+		_ = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("Current directory: %q\n", pwd))
 	case "cd":
 		if len(parts) == 1 {
 			pwd, _ := os.Getwd()
@@ -187,13 +389,14 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 		} else if len(parts) > 2 {
 			return errors.Errorf("`%%cd [<directory>]`: it takes none or one argument, but %d were given", len(parts)-1)
 		} else {
+			oldPwd, _ := os.Getwd()
 			err := os.Chdir(ReplaceTildeInDir(parts[1]))
 			if err != nil {
 				return errors.Wrapf(err, "`%%cd %q` failed", parts[1])
 			}
 			pwd, _ := os.Getwd()
 			err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
-				fmt.Sprintf("Changed directory to %q\n", pwd))
+				fmt.Sprintf("Changed directory from %q to %q\n", oldPwd, pwd))
 			if err != nil {
 				klog.Errorf("Failed to output: %+v", err)
 			}
@@ -203,6 +406,15 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 			}
 		}
 
+	case "readfile":
+		return execReadFile(msg, goExec, parts[1:])
+	case "load_ext":
+		return execLoadExt(msg, parts[1:])
+	case "snapshot":
+		return execSnapshot(msg, goExec, parts[1:])
+	case "diff":
+		return execDiff(msg, goExec, parts[1:])
+
 		// Flags for `go build`:
 	case "goflags":
 		if len(parts) > 1 {
@@ -215,6 +427,22 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 			klog.Errorf("Failed publishing contents: %+v", err)
 		}
 
+		// Selection of the Go toolchain:
+	case "goos", "goarch":
+		return execGoTarget(msg, goExec, parts[0], parts[1:])
+	case "goroot":
+		return execGoRoot(msg, goExec, parts[1:])
+	case "go":
+		return execGo(msg, goExec, parts[1:])
+	case "goversion":
+		return execGoVersion(msg, goExec)
+	case "install_tool":
+		return execInstallTool(msg, goExec, parts[1:])
+
+		// Kernel configuration:
+	case "config":
+		return execConfig(msg, goExec, parts[1:])
+
 		// Automatic `go get` control:
 	case "autoget":
 		goExec.AutoGet = true
@@ -227,6 +455,30 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 			klog.Errorf("Failed publishing help contents: %+v", err)
 		}
 
+		// Resources of the cell programs.
+	case "memlimit":
+		return execMemLimit(msg, parts[1:])
+
+		// Diagnostics.
+	case "profile_startup":
+		execProfileStartup(msg, goExec)
+	case "bugreport":
+		return execBugReport(msg, goExec, parts[1:])
+
+		// Output configuration.
+	case "output_max_lines":
+		return execOutputMaxLines(msg, parts[1:])
+	case "ansi":
+		return execAnsi(msg, parts[1:])
+	case "autoprint":
+		return execAutoPrint(msg, goExec, parts[1:])
+	case "repl":
+		return execRepl(msg, goExec, parts[1:])
+	case "imports":
+		return execImports(msg, goExec, parts[1:])
+	case "clear":
+		return execClear(msg, parts[1:])
+
 		// Definitions management.
 	case "reset":
 		if len(parts) == 1 {
@@ -241,6 +493,24 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 		listDefinitions(msg, goExec)
 	case "rm", "remove":
 		removeDefinitions(msg, goExec, parts[1:])
+	case "doc":
+		return execDoc(msg, goExec, parts[1:])
+	case "hover":
+		return execHover(msg, goExec, parts[1:])
+	case "complete":
+		return execComplete(msg, goExec, parts[1:])
+	case "cat":
+		return catDefinition(msg, goExec, parts[1:])
+	case "rename":
+		if len(parts) != 3 {
+			return errors.Errorf("`%%rename <old_name> <new_name>` takes exactly two parameters.")
+		}
+		return renameDefinition(msg, goExec, parts[1], parts[2])
+	case "goimports":
+		if len(parts) > 1 {
+			return errors.Errorf("`%%goimports` takes no extra parameters.")
+		}
+		return fixImports(msg, goExec)
 
 		// Input handling.
 	case "with_inputs":
@@ -255,6 +525,8 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 			return errors.Errorf("%%with_password not available in this notebook, it doesn't allow input prompting")
 		}
 		status.withPassword = true
+	case "with_env":
+		return execWithEnv(parts[1:], status)
 
 		// Files that need tracking for `gopls` (for auto-complete and contextual help).
 	case "track":
@@ -262,9 +534,52 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 	case "untrack":
 		execUntrack(msg, goExec, parts[1:])
 
+		// User defined shortcuts for special commands.
+	case "alias":
+		return execAlias(msg, goExec, strings.TrimPrefix(cmdStr, parts[0]))
+	case "unalias":
+		execUnalias(msg, goExec, parts[1:])
+
+		// Recording and replaying of cells.
+	case "macro":
+		return execMacro(msg, goExec, parts[1:])
+
+		// Named cells: the name is recorded by ExecuteCell, before the cell is parsed.
+	case "%cell":
+		if lineNum != 0 {
+			return errors.Errorf("\"%%%%cell\" can only appear at the start of the cell")
+		}
+	case "run":
+		return execRun(msg, goExec, parts[1:])
+
+		// Dependencies of the notebook module.
+	case "deps":
+		return execDeps(msg, goExec, parts[1:])
+	case "vendor":
+		return execVendor(msg, goExec, parts[1:])
+
+	case "gonbui_version":
+		return execGonbuiVersion(msg, goExec, parts[1:])
+	case "replace":
+		return execReplace(msg, goExec, parts[1:])
+	case "replace_local":
+		return execReplaceLocal(msg, goExec, parts[1:])
+	case "gomod":
+		return execShowModFile(msg, goExec, "go.mod", parts[1:])
+	case "gowork":
+		return execShowModFile(msg, goExec, "go.work", parts[1:])
+
 		// Fix issues with `go work`.
 	case "goworkfix":
-		return goExec.GoWorkFix(msg)
+		dryRun := len(parts) == 2 && parts[1] == "--dry-run"
+		if len(parts) > 1 && !dryRun {
+			return errors.Errorf("`%%goworkfix [--dry-run]`: invalid arguments %q", parts[1:])
+		}
+		return goExec.GoWorkFix(msg, dryRun)
+
+	case "tag":
+		// Cell tags are handled by ExecuteTaggedCell, before the cell is executed. They are ignored in the
+		// cells replayed, e.g. with `%run`.
 
 	default:
 		if CellSpecialCommands.Has("%" + parts[0]) {
@@ -274,7 +589,7 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 		}
 
 		// Unknown special command.
-		err := kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("\"%%%s\" unknown or not implemented yet.", parts[0]))
+		err := kernel.PublishWriteStream(msg, kernel.StreamStderr, fmt.Sprintf("line %d: \"%%%s\" unknown or not implemented yet.", lineNum+1, parts[0]))
 		if err != nil {
 			klog.Errorf("Error while reporting back on unimplemented message command \"%%%s\" kernel: %+v", parts[0], err)
 		}
@@ -286,29 +601,65 @@ func execSpecialConfig(msg kernel.Message, goExec *goexec.State, cmdStr string,
 //
 // It only returns errors for system errors that will lead to the kernel restart. Syntax errors
 // on the command themselves are simply reported back to jupyter and are not returned here.
-func execShell(msg kernel.Message, goExec *goexec.State, cmdStr string, status *cellStatus) error {
+//
+// The lineNum (0-based) is the line in the cell where the command started: errors are prefixed with it.
+func execShell(msg kernel.Message, goExec *goexec.State, lineNum int, cmdStr string, status *cellStatus) error {
+	if err := runShell(msg, goExec, cmdStr, status); err != nil {
+		return errors.WithMessagef(err, "line %d", lineNum+1)
+	}
+	return nil
+}
+
+// runShell implements execShell.
+func runShell(msg kernel.Message, goExec *goexec.State, cmdStr string, status *cellStatus) error {
+	if err := goExec.CheckShell(); err != nil {
+		return err
+	}
 	var execDir string // Default "", means current directory.
-	if cmdStr[0] == '*' {
+	inTempDir := cmdStr[0] == '*'
+	if inTempDir {
 		cmdStr = cmdStr[1:]
 		execDir = goExec.TempDir
 	}
+	executor := jpyexec.New(msg, goExec.Shell, goExec.ShellArgs(cmdStr)...).
+		ExecutionCount(msg.Kernel().ExecCounter).
+		InDir(execDir).
+		WithEnv(status.withEnv...)
+	status.withEnv = nil
+	if inTempDir {
+		// Map references to the generated code (e.g.: output of `go vet`) to the cell lines.
+		executor = executor.
+			WithStdout(goExec.NewCellLinesWriter(msg, "stdout")).
+			WithStderr(goExec.NewCellLinesWriter(msg, "stderr"))
+	}
 	if status.withInputs {
 		status.withInputs = false
 		status.withPassword = false
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).WithInputs(MillisecondsWaitForInput).Exec()
+		return executor.WithInputs(MillisecondsWaitForInput).Exec()
 	} else if status.withPassword {
 		status.withInputs = false
 		status.withPassword = false
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).WithPassword(MillisecondsWaitForInput).Exec()
+		return executor.WithPassword(MillisecondsWaitForInput).Exec()
 	} else {
-		return jpyexec.New(msg, "/bin/bash", "-c", cmdStr).
-			ExecutionCount(msg.Kernel().ExecCounter).
-			InDir(execDir).Exec()
+		return executor.Exec()
+	}
+}
+
+// execWithEnv executes the "%with_env" special command. The parameter `args` excludes "%with_env".
+//
+// It sets environment variables, given as "KEY=VALUE", only for the next shell command in the cell.
+// See `%env` to set environment variables for the kernel.
+func execWithEnv(args []string, status *cellStatus) error {
+	if len(args) == 0 {
+		return errors.New("`%with_env <VAR_NAME>=<value> ...`: no variable given")
 	}
+	for _, arg := range args {
+		if eqPos := strings.Index(arg, "="); eqPos < 1 {
+			return errors.Errorf("`%%with_env <VAR_NAME>=<value> ...`: invalid argument %q, expected <VAR_NAME>=<value>", arg)
+		}
+	}
+	status.withEnv = append(status.withEnv, args...)
+	return nil
 }
 
 // splitCmd split the special command into it's parts separated by space(s). It also
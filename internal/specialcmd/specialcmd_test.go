@@ -9,7 +9,9 @@ import (
 	"github.com/janpfeifer/gonb/internal/kernel"
 	"github.com/stretchr/testify/require"
 	"os"
+	"path"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,5 +61,277 @@ func TestDirEnv(t *testing.T) {
 	err = Parse(msg, s, true, []string{"%cd /tmp"}, usedLines)
 	require.NoError(t, err)
 	assert.Equal(t, "/tmp", os.Getenv(protocol.GONB_DIR_ENV))
+
+	// `%pwd` doesn't change it, and takes no arguments.
+	require.NoError(t, Parse(msg, s, true, []string{"%pwd"}, MakeSet[int]()))
+	assert.Equal(t, "/tmp", os.Getenv(protocol.GONB_DIR_ENV))
+	require.Error(t, Parse(msg, s, true, []string{"%pwd /tmp"}, MakeSet[int]()))
 	require.NoError(t, s.Stop())
 }
+
+func TestTrackExpandsPath(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+
+	// Tilde is replaced by the home directory, so the absolute path is tracked.
+	homeDir := ReplaceTildeInDir("~")
+	dir, err := os.MkdirTemp(homeDir, "gonb_track_test_")
+	if err != nil {
+		t.Skipf("Can't create a temporary directory in the home directory %q: %v", homeDir, err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	require.NoError(t, Parse(msg, s, true, []string{"%track ~/" + path.Base(dir)}, MakeSet[int]()))
+	assert.Equal(t, []string{dir}, s.ListTracked())
+	require.NoError(t, Parse(msg, s, true, []string{"%untrack ~/" + path.Base(dir)}, MakeSet[int]()))
+	assert.Empty(t, s.ListTracked())
+
+	// Environment variables are expanded.
+	t.Setenv("GONB_TEST_TRACK_DIR", dir)
+	require.NoError(t, Parse(msg, s, true, []string{"%track ${GONB_TEST_TRACK_DIR}"}, MakeSet[int]()))
+	assert.Equal(t, []string{dir}, s.ListTracked())
+	require.NoError(t, Parse(msg, s, true, []string{"%untrack $GONB_TEST_TRACK_DIR..."}, MakeSet[int]()))
+	assert.Empty(t, s.ListTracked())
+}
+
+func TestParseEscapedLines(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	lines := []string{
+		"%env GONB_TEST_ESCAPED=1",
+		"\\%d items",
+		"\\!important",
+	}
+	var msg kernel.Message
+	usedLines := MakeSet[int]()
+	err := Parse(msg, s, false, lines, usedLines)
+	require.NoError(t, err)
+	assert.True(t, usedLines.Has(0), "env special command should have been parsed")
+	assert.False(t, usedLines.Has(1), "escaped special command line should be left for Go")
+	assert.False(t, usedLines.Has(2), "escaped shell command line should be left for Go")
+}
+
+func TestParseErrorLineNumber(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	pwd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.Chdir(pwd)) }()
+
+	var msg kernel.Message
+	lines := []string{
+		"%cd /tmp",
+		"",
+		"%cd /tmp /usr",
+	}
+	err = Parse(msg, s, true, lines, MakeSet[int]())
+	require.Error(t, err)
+	assert.Truef(t, strings.HasPrefix(err.Error(), "line 3: "), "Error should report the line of the offending command, got %q", err.Error())
+}
+
+func TestParseGoFence(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	lines := []string{
+		"%env GONB_TEST_FENCE=1",
+		"var x = 1",
+		"%env GONB_TEST_FENCE=2",
+		"%%go",
+		"var y = `",
+		"%env not a command",
+		"!not a shell command",
+		"`",
+	}
+	var msg kernel.Message
+	usedLines := MakeSet[int]()
+	err := Parse(msg, s, false, lines, usedLines)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 3}, SortedKeys(usedLines))
+}
+
+// fakeMessage implements kernel.Message, to execute shell commands in tests: the contents of the published messages
+// are recorded.
+type fakeMessage struct {
+	kernel.Message // Not implemented, calls to unimplemented methods will panic.
+	kernel         *kernel.Kernel
+	metadata       map[string]any // Metadata of the message, e.g.: cell tags.
+
+	mu        sync.Mutex
+	published []string // Contents of the published messages, formatted with "%+v".
+}
+
+func (m *fakeMessage) Kernel() *kernel.Kernel { return m.kernel }
+
+func (m *fakeMessage) ComposedMsg() kernel.ComposedMsg {
+	return kernel.ComposedMsg{Metadata: m.metadata}
+}
+
+func (m *fakeMessage) Publish(_ string, content interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published = append(m.published, fmt.Sprintf("%+v", content))
+	return nil
+}
+
+// output returns the contents of the published messages, concatenated.
+func (m *fakeMessage) output() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return strings.Join(m.published, "\n")
+}
+
+func TestWithEnv(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	t.Setenv("GONB_TEST_WITH_ENV", "")
+	require.NoError(t, os.Unsetenv("GONB_TEST_WITH_ENV"))
+
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	outputPath := path.Join(t.TempDir(), "output.txt")
+	lines := []string{
+		"%with_env GONB_TEST_WITH_ENV=hello \"GONB_TEST_OTHER=a b\"",
+		fmt.Sprintf("!echo \"$GONB_TEST_WITH_ENV,$GONB_TEST_OTHER\" >> %s", outputPath),
+		fmt.Sprintf("!echo \"$GONB_TEST_WITH_ENV,$GONB_TEST_OTHER\" >> %s", outputPath),
+	}
+	require.NoError(t, Parse(msg, s, true, lines, MakeSet[int]()))
+	output, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "hello,a b\n,\n", string(output), "Variables should only be set for the first shell command")
+	_, found := os.LookupEnv("GONB_TEST_WITH_ENV")
+	assert.False(t, found, "%with_env should not change the kernel's environment")
+
+	assert.Error(t, Parse(msg, s, true, []string{"%with_env"}, MakeSet[int]()))
+	assert.Error(t, Parse(msg, s, true, []string{"%with_env =hello"}, MakeSet[int]()))
+}
+
+func TestQuietSpecialCommand(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	t.Setenv(memLimitEnv, "")
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	usedLines := MakeSet[int]()
+	require.NoError(t, Parse(msg, s, true, []string{"%memlimit 64MiB ;"}, usedLines))
+	assert.Equal(t, "64MiB", os.Getenv(memLimitEnv))
+}
+
+func TestEnvMasksSecrets(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	t.Setenv("GONB_TEST_API_TOKEN", "")
+	t.Setenv("GONB_TEST_NAME", "")
+
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, Parse(msg, s, true, []string{"%env GONB_TEST_API_TOKEN=abc123"}, MakeSet[int]()))
+	assert.Equal(t, "abc123", os.Getenv("GONB_TEST_API_TOKEN"))
+	assert.NotContains(t, msg.output(), "abc123")
+	assert.Contains(t, msg.output(), "GONB_TEST_API_TOKEN=****")
+
+	msg = &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, Parse(msg, s, true, []string{"%env --show GONB_TEST_API_TOKEN def456"}, MakeSet[int]()))
+	assert.Contains(t, msg.output(), `GONB_TEST_API_TOKEN="def456"`)
+
+	msg = &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, Parse(msg, s, true, []string{"%env GONB_TEST_NAME gopher"}, MakeSet[int]()))
+	assert.Contains(t, msg.output(), `GONB_TEST_NAME="gopher"`)
+
+	require.NoError(t, setConfig(nil, s, "secret_env_patterns", "*_NAME"))
+	msg = &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, Parse(msg, s, true, []string{"%env GONB_TEST_NAME gopher"}, MakeSet[int]()))
+	assert.Contains(t, msg.output(), "GONB_TEST_NAME=****")
+}
+
+func TestSecret(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	t.Setenv("GONB_TEST_SECRET_VALUE", "xyz789")
+	assert.Error(t, Parse(nil, s, true, []string{"%secret add abc"}, MakeSet[int]()))
+
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	require.NoError(t, Parse(msg, s, true, []string{"%secret add $GONB_TEST_SECRET_VALUE"}, MakeSet[int]()))
+	assert.Equal(t, 1, msg.kernel.NumSecrets())
+	require.NoError(t, Parse(msg, s, true, []string{"!echo token=$GONB_TEST_SECRET_VALUE"}, MakeSet[int]()))
+	assert.NotContains(t, msg.output(), "xyz789")
+	assert.Contains(t, msg.output(), "token=****")
+
+	assert.Error(t, Parse(msg, s, true, []string{"%secret add $GONB_TEST_UNSET_VARIABLE"}, MakeSet[int]()))
+	require.NoError(t, Parse(msg, s, true, []string{"%secret clear"}, MakeSet[int]()))
+	assert.Equal(t, 0, msg.kernel.NumSecrets())
+
+	// Secrets set with %env are also redacted.
+	t.Setenv("GONB_TEST_TOKEN", "")
+	require.NoError(t, Parse(msg, s, true, []string{"%env GONB_TEST_TOKEN=abc123"}, MakeSet[int]()))
+	assert.Equal(t, 1, msg.kernel.NumSecrets())
+}
+
+func TestKeepAndDiscard(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	var msg kernel.Message
+	status := &cellStatus{}
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "discard", status))
+	assert.True(t, s.CellDiscardDecls)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "keep", status), "keep and discard are exclusive")
+	s.PostExecuteCell()
+	assert.False(t, s.CellDiscardDecls)
+	assert.False(t, s.CellKeepDecls)
+
+	require.NoError(t, execSpecialConfig(msg, s, 0, "keep", status))
+	assert.True(t, s.CellKeepDecls)
+	s.PostExecuteCell()
+	assert.Error(t, execSpecialConfig(msg, s, 0, "keep now", status))
+}
+
+func TestRemoveType(t *testing.T) {
+	var msg kernel.Message
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	decls := s.Definitions
+	decls.Types["T"] = &goexec.TypeDecl{Key: "T", TypeDefinition: "T struct{}"}
+	decls.Types["TT"] = &goexec.TypeDecl{Key: "TT", TypeDefinition: "TT struct{}"}
+	decls.Functions["T~A"] = &goexec.Function{Key: "T~A", Definition: "func (t T) A() {}"}
+	decls.Functions["T~B"] = &goexec.Function{Key: "T~B", Definition: "func (t *T) B() {}"}
+	decls.Functions["TT~A"] = &goexec.Function{Key: "TT~A", Definition: "func (t TT) A() {}"}
+	decls.Functions["f"] = &goexec.Function{Key: "f", Definition: "func f() {}"}
+
+	removeDefinitions(msg, s, []string{"f", "--type", "T"})
+	assert.Equal(t, []string{"TT"}, SortedKeys(decls.Types))
+	assert.Equal(t, []string{"TT~A"}, SortedKeys(decls.Functions))
+}
+
+func TestImports(t *testing.T) {
+	var msg kernel.Message
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	status := &cellStatus{}
+	require.NoError(t, execSpecialConfig(msg, s, 0, "imports --group", status))
+	assert.True(t, s.GroupImports)
+	require.NoError(t, execSpecialConfig(msg, s, 0, "imports", status))
+	assert.True(t, s.GroupImports)
+	require.NoError(t, execSpecialConfig(msg, s, 0, "imports --flat", status))
+	assert.False(t, s.GroupImports)
+	assert.Error(t, execSpecialConfig(msg, s, 0, "imports --sorted", status))
+}
+
+func TestDefinitionItems(t *testing.T) {
+	functions := map[string]*goexec.Function{
+		"f":    {Key: "f", CellLines: goexec.CellLines{Id: 4, Lines: []int{2, 3}}},
+		"T~Do": {Key: "T~Do", CellLines: goexec.CellLines{Id: 2, Lines: []int{0}}},
+		"auto": {Key: "auto", CellLines: goexec.CellLines{Id: -1}},
+	}
+	assert.Equal(t, []string{"T~Do  // cell 2, line 1", "auto", "f  // cell 4, line 3"}, definitionItems(functions))
+}
+
+func TestExecShellMissingInterpreter(t *testing.T) {
+	var msg kernel.Message
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	s.Shell = path.Join(t.TempDir(), "bash") // Doesn't exist.
+	err := execShell(msg, s, 2, "echo hello", &cellStatus{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was not found")
+	assert.Truef(t, strings.HasPrefix(err.Error(), "line 3: "), "Error should report the line of the shell command, got %q", err.Error())
+}
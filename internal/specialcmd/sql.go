@@ -0,0 +1,154 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+// This file implements `%sql_connect` and the `%%sql` cell magic, which runs SQL queries with `database/sql`.
+
+// sqlDriverImports maps the names of common `database/sql` drivers to the import path of the package that
+// registers them. Other drivers require `%sql_connect --import <path>`.
+var sqlDriverImports = map[string]string{
+	"postgres":  "github.com/lib/pq",
+	"pgx":       "github.com/jackc/pgx/v5/stdlib",
+	"mysql":     "github.com/go-sql-driver/mysql",
+	"sqlite3":   "github.com/mattn/go-sqlite3",
+	"sqlite":    "modernc.org/sqlite",
+	"sqlserver": "github.com/microsoft/go-mssqldb",
+}
+
+// sqlDsnEnv is the environment variable that holds the data source name for the program generated for `%%sql`
+// cells: it's not written in the generated code, since it may include credentials.
+const sqlDsnEnv = "GONB_SQL_DSN"
+
+// execSqlConnect executes the "%sql_connect [--import <path>] <driver> <dsn>" special command. The parameter
+// `args` excludes "%sql_connect".
+//
+// It only configures the connection, used by the following `%%sql` cells: the connection is opened by the
+// program generated for each of them.
+func execSqlConnect(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var driverImport string
+	if len(args) > 0 && args[0] == "--import" {
+		if len(args) < 2 {
+			return errors.Errorf("`%%sql_connect`: --import requires the import path of the driver package")
+		}
+		driverImport, args = args[1], args[2:]
+	}
+	if len(args) != 2 {
+		return errors.Errorf("`%%sql_connect [--import <path>] <driver> <dsn>` takes the driver name and the " +
+			"data source name, use quotes if the data source name has spaces")
+	}
+	driver, dsn := args[0], ReplaceEnvVars(args[1])
+	if driverImport == "" {
+		var found bool
+		driverImport, found = sqlDriverImports[driver]
+		if !found {
+			return errors.Errorf("`%%sql_connect`: unknown driver %q, use `--import <path>` to give the import "+
+				"path of the package that registers it", driver)
+		}
+	}
+	goExec.SqlConnection = goexec.SqlConnection{Driver: driver, DriverImport: driverImport, DSN: dsn}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		fmt.Sprintf("SQL connection configured: driver %q, imported from %q.\n", driver, driverImport))
+	if err != nil {
+		klog.Errorf("Failed to output: %+v", err)
+	}
+	return nil
+}
+
+// cellCmdSql implements `%%sql`: it runs the SQL in the cell's lines with the connection configured with
+// `%sql_connect`, and displays the resulting rows as a table.
+//
+// It's executed as a Go program using `database/sql`, see sqlProgram.
+func cellCmdSql(msg kernel.Message, goExec *goexec.State, args []string, lines []string) error {
+	if len(args) != 0 {
+		return errors.Errorf("`%%%%sql` takes no arguments, %q given", args)
+	}
+	if goExec.SqlConnection.Driver == "" {
+		return errors.Errorf("`%%%%sql` requires a connection, configure one first with `%%sql_connect <driver> <dsn>`")
+	}
+	query := strings.TrimSpace(strings.Join(lines, "\n"))
+	if query == "" {
+		return nil
+	}
+	cellId := -1
+	if msg != nil {
+		cellId = msg.Kernel().ExecCounter
+	}
+	goExec.CellEnv = append(goExec.CellEnv, sqlDsnEnv+"="+goExec.SqlConnection.DSN)
+	goExec.CellIsGenerated = true
+	return goExec.ExecuteCell(msg, cellId, sqlProgram(goExec.SqlConnection, query), MakeSet[int]())
+}
+
+// sqlProgram returns the lines of the Go program that runs the query with the connection, and displays the
+// resulting rows with gonbui.DisplayTable. The imports it uses (including the driver) are memorized, as with
+// any other Go cell, which saves fetching the driver again.
+//
+// The data source name is read from the environment variable sqlDsnEnv, see cellCmdSql.
+func sqlProgram(conn goexec.SqlConnection, query string) []string {
+	program := fmt.Sprintf(`import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/janpfeifer/gonb/gonbui"
+	_ %q
+)
+
+func main() {
+	log.SetFlags(0)
+	db, err := sql.Open(%q, os.Getenv(%q))
+	if err != nil {
+		log.Fatalf("%%%%%%%%sql: failed to open connection: %%v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query(%q)
+	if err != nil {
+		log.Fatalf("%%%%%%%%sql: query failed: %%v", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		log.Fatalf("%%%%%%%%sql: failed to read columns: %%v", err)
+	}
+	if len(columns) == 0 {
+		fmt.Println("OK")
+		return
+	}
+	var table [][]string
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for ii := range values {
+		pointers[ii] = &values[ii]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			log.Fatalf("%%%%%%%%sql: failed to read row: %%v", err)
+		}
+		row := make([]string, len(columns))
+		for ii, value := range values {
+			switch v := value.(type) {
+			case nil:
+				row[ii] = "NULL"
+			case []byte:
+				row[ii] = string(v)
+			default:
+				row[ii] = fmt.Sprint(v)
+			}
+		}
+		table = append(table, row)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatalf("%%%%%%%%sql: failed to read rows: %%v", err)
+	}
+	gonbui.DisplayTable(columns, table)
+}`, conn.DriverImport, conn.Driver, sqlDsnEnv, query)
+	return strings.Split(program, "\n")
+}
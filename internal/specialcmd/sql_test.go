@@ -0,0 +1,42 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestExecSqlConnect(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+
+	assert.Errorf(t, cellCmdSql(nil, s, nil, []string{"SELECT 1"}), "%%%%sql requires a connection")
+	assert.Error(t, execSqlConnect(nil, s, []string{"postgres"}))
+	assert.Error(t, execSqlConnect(nil, s, []string{"unknown", "dsn"}))
+
+	require.NoError(t, execSqlConnect(nil, s, []string{"postgres", "postgres://localhost/db"}))
+	assert.Equal(t, goexec.SqlConnection{Driver: "postgres", DriverImport: "github.com/lib/pq",
+		DSN: "postgres://localhost/db"}, s.SqlConnection)
+
+	t.Setenv("GONB_TEST_DSN", "file:test.db")
+	require.NoError(t, execSqlConnect(nil, s, []string{"--import", "example.com/driver", "mydriver", "$GONB_TEST_DSN"}))
+	assert.Equal(t, goexec.SqlConnection{Driver: "mydriver", DriverImport: "example.com/driver",
+		DSN: "file:test.db"}, s.SqlConnection)
+}
+
+func TestSqlProgram(t *testing.T) {
+	conn := goexec.SqlConnection{Driver: "sqlite", DriverImport: "modernc.org/sqlite", DSN: "file:test.db"}
+	lines := sqlProgram(conn, "SELECT \"name\"\nFROM users")
+	src := "package main\n" + strings.Join(lines, "\n")
+	_, err := parser.ParseFile(token.NewFileSet(), "main.go", src, 0)
+	require.NoError(t, err)
+	assert.Contains(t, src, `_ "modernc.org/sqlite"`)
+	assert.Contains(t, src, `sql.Open("sqlite", os.Getenv("GONB_SQL_DSN"))`)
+	assert.NotContains(t, src, "file:test.db")
+	assert.Contains(t, src, `db.Query("SELECT \"name\"\nFROM users")`)
+	assert.Contains(t, src, `log.Fatalf("%%%%sql: query failed: %v", err)`)
+}
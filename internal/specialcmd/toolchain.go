@@ -0,0 +1,200 @@
+package specialcmd
+
+import (
+	"fmt"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"html"
+	"k8s.io/klog/v2"
+	"os"
+	"strings"
+)
+
+// This file handles the commands %goroot and %go, that select the Go toolchain used to compile the cells,
+// %goversion that displays information about it, %install_tool that installs auxiliary Go tools, and
+// %env_persist that persists `go env` variables.
+
+// execGoRoot executes the "%goroot" special command. The parameter `args` excludes "%goroot".
+func execGoRoot(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%goroot [<path>|default]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		goRoot := args[0]
+		if goRoot == "default" {
+			goRoot = ""
+		}
+		if err := goExec.SetGoRoot(goRoot); err != nil {
+			return errors.WithMessagef(err, "`%%goroot %s` failed", args[0])
+		}
+	}
+	return publishGoToolchain(msg, goExec)
+}
+
+// execGo executes the "%go" special command. The parameter `args` excludes "%go".
+func execGo(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("`%%go [<version>|default]`: it takes at most one argument, but %d were given", len(args))
+	}
+	if len(args) == 1 {
+		version := args[0]
+		if version == "default" {
+			version = ""
+		}
+		if err := goExec.SetGoVersion(version); err != nil {
+			return errors.WithMessagef(err, "`%%go %s` failed", args[0])
+		}
+	}
+	return publishGoToolchain(msg, goExec)
+}
+
+// publishGoToolchain reports the Go toolchain in use.
+func publishGoToolchain(msg kernel.Message, goExec *goexec.State) error {
+	version, err := goExec.GoVersion()
+	if err != nil {
+		return err
+	}
+	err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+		"Go toolchain: "+goExec.GoBinary()+" ("+version+")\n")
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execGoVersion executes the "%goversion" special command: it displays a table with the version of the Go
+// toolchain, GOROOT, GOPATH and the `go` directive of the notebook's `go.mod`.
+func execGoVersion(msg kernel.Message, goExec *goexec.State) error {
+	version, err := goExec.GoVersion()
+	if err != nil {
+		return err
+	}
+	env, err := goExec.GoEnv("GOROOT", "GOPATH")
+	if err != nil {
+		return err
+	}
+	goDirective, err := goExec.GoModGoDirective()
+	if err != nil {
+		klog.Warningf("%%goversion: %+v", err)
+		goDirective = "unknown"
+	}
+	rows := [][2]string{
+		{"go version", version},
+		{"GOROOT", env["GOROOT"]},
+		{"GOPATH", env["GOPATH"]},
+		{"go.mod go directive", goDirective},
+	}
+	htmlParts := make([]string, 0, len(rows)+2)
+	htmlParts = append(htmlParts, "<table>")
+	for _, row := range rows {
+		htmlParts = append(htmlParts, fmt.Sprintf("<tr><td><b>%s</b></td><td><code>%s</code></td></tr>",
+			html.EscapeString(row[0]), html.EscapeString(row[1])))
+	}
+	htmlParts = append(htmlParts, "</table>")
+	err = kernel.PublishHtml(msg, strings.Join(htmlParts, "\n"))
+	if err != nil {
+		klog.Errorf("Failed to publish %%goversion results back to jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execInstallTool executes the "%install_tool" special command. The parameter `args` excludes "%install_tool".
+func execInstallTool(msg kernel.Message, goExec *goexec.State, args []string) error {
+	if len(args) == 0 || args[0] == "--list" {
+		return listTools(msg, goExec)
+	}
+	for _, tool := range args {
+		name, pkg, err := goexec.ToolPackage(tool)
+		if err != nil {
+			return errors.WithMessagef(err, "`%%install_tool %s`", tool)
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Installing %s (%s) ...\n", name, pkg))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		binPath, err := goExec.InstallTool(tool)
+		if err != nil {
+			return errors.WithMessagef(err, "`%%install_tool %s`", tool)
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout, fmt.Sprintf("Installed %s in %s\n", name, binPath))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+	}
+	return nil
+}
+
+// listTools publishes a table with the tools known by `%install_tool`, and where they are installed.
+func listTools(msg kernel.Message, goExec *goexec.State) error {
+	htmlParts := []string{"<table>", "<tr><th>Tool</th><th>Package</th><th>Installed</th></tr>"}
+	for _, name := range SortedKeys(goexec.KnownTools) {
+		binPath, found := goExec.ToolPath(name)
+		if !found {
+			binPath = "-"
+		}
+		htmlParts = append(htmlParts, fmt.Sprintf("<tr><td><b>%s</b></td><td><code>%s</code></td><td><code>%s</code></td></tr>",
+			html.EscapeString(name), html.EscapeString(goexec.KnownTools[name]), html.EscapeString(binPath)))
+	}
+	htmlParts = append(htmlParts, "</table>")
+	err := kernel.PublishHtml(msg, strings.Join(htmlParts, "\n"))
+	if err != nil {
+		klog.Errorf("Failed to publish list of tools to Jupyter: %+v", err)
+	}
+	return nil
+}
+
+// execEnvPersist executes the "%env_persist" special command. The parameter `args` excludes "%env_persist".
+//
+// It accepts `<VAR_NAME> <value>`, `<VAR_NAME>=<value>` or `-u <VAR_NAME>`. Without arguments, it
+// displays the variables persisted.
+func execEnvPersist(msg kernel.Message, goExec *goexec.State, args []string) error {
+	usage := "`%env_persist <VAR_NAME> <value>` (or `%env_persist <VAR_NAME>=<value>`, or `%env_persist -u <VAR_NAME>`)"
+	if len(args) == 0 {
+		filePath, contents, err := goExec.GoEnvFile()
+		if err != nil {
+			return err
+		}
+		if contents == "" {
+			contents = "(none)\n"
+		}
+		err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+			fmt.Sprintf("Variables persisted in %s:\n%s", filePath, contents))
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		return nil
+	}
+	if len(args) == 1 {
+		// Adjust args if one uses `%env_persist KEY=VALUE` format instead.
+		if key, value, found := strings.Cut(args[0], "="); found && key != "" {
+			args = []string{key, value}
+		}
+	}
+	if len(args) != 2 {
+		return errors.Errorf("%s: it takes 2 arguments, but %d were given", usage, len(args))
+	}
+	key, value := args[0], args[1]
+	unset := key == "-u"
+	if unset {
+		key, value = value, ""
+	} else if value == "" {
+		return errors.Errorf("%s: empty value for %q, use `%%env_persist -u %s` to remove it", usage, key, key)
+	}
+	if err := goExec.GoEnvWrite(key, value); err != nil {
+		return errors.WithMessagef(err, "`%%env_persist %s`", strings.Join(args, " "))
+	}
+	report := fmt.Sprintf("Persisted: %s=%q\n", key, value)
+	if unset {
+		report = fmt.Sprintf("Removed persisted %s\n", key)
+	}
+	if envValue, found := os.LookupEnv(key); found {
+		report += fmt.Sprintf("Warning: the environment variable %s=%q is set, and it takes precedence over the persisted value.\n", key, envValue)
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
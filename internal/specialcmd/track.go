@@ -2,26 +2,55 @@ package specialcmd
 
 import (
 	"fmt"
+	. "github.com/janpfeifer/gonb/common"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"html"
 	"k8s.io/klog/v2"
 	"strings"
 )
 
 // execTrack executes the "%track" special command. The parameter `args` excludes
-// "%track".
+// "%track". Arguments are files or directories, or `--module <module_path>` to track the
+// source of a module the notebook depends on. `%track --verbose` lists the tracked paths with
+// their status.
+//
+// Paths starting with `~` and environment variables (e.g.: `$GOPATH/src/...`) are expanded, see expandTrackPath.
 func execTrack(msg kernel.Message, goExec *goexec.State, args []string) {
 	if len(args) == 0 {
 		showTrackedList(msg, goExec)
 		return
 	}
-	for _, fileOrDirPath := range args {
-		err := goExec.Track(fileOrDirPath)
-		if err != nil {
-			err = kernel.PublishWriteStream(msg, kernel.StreamStderr, err.Error()+"\n")
+	if len(args) == 1 && args[0] == "--verbose" {
+		showTrackedTable(msg, goExec)
+		return
+	}
+	for ii := 0; ii < len(args); ii++ {
+		var err error
+		if args[ii] == "--module" {
+			if ii+1 == len(args) {
+				err = kernel.PublishWriteStream(msg, kernel.StreamStderr, "`%track --module` requires a module path\n")
+			} else {
+				ii++
+				modulePath := args[ii]
+				var dir string
+				dir, err = goExec.TrackModule(modulePath)
+				if err != nil {
+					err = kernel.PublishWriteStream(msg, kernel.StreamStderr, err.Error()+"\n")
+				} else {
+					err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+						fmt.Sprintf("\tTracking module %q in %q\n", modulePath, dir))
+				}
+			}
 		} else {
-			err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
-				fmt.Sprintf("\tTracking %q\n", fileOrDirPath))
+			fileOrDirPath := expandTrackPath(args[ii])
+			err = goExec.Track(fileOrDirPath)
+			if err != nil {
+				err = kernel.PublishWriteStream(msg, kernel.StreamStderr, err.Error()+"\n")
+			} else {
+				err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+					fmt.Sprintf("\tTracking %q\n", fileOrDirPath))
+			}
 		}
 		if err != nil {
 			klog.Errorf("Failed to publish to Jupyter: %+v", err)
@@ -30,14 +59,28 @@ func execTrack(msg kernel.Message, goExec *goexec.State, args []string) {
 	}
 }
 
-// execUntrack executes the "%track" special command. The parameter `args` excludes
-// "%untrack".
+// execUntrack executes the "%untrack" special command. The parameter `args` excludes
+// "%untrack". `%untrack --all` untracks everything. Paths are expanded as in `%track`.
 func execUntrack(msg kernel.Message, goExec *goexec.State, args []string) {
 	if len(args) == 0 {
 		showTrackedList(msg, goExec)
 		return
 	}
-	for _, fileOrDirPath := range args {
+	if len(args) == 1 && args[0] == "--all" {
+		numUntracked, err := goExec.UntrackAll()
+		if err != nil {
+			err = kernel.PublishWriteStream(msg, kernel.StreamStderr, err.Error()+"\n")
+		} else {
+			err = kernel.PublishWriteStream(msg, kernel.StreamStdout,
+				fmt.Sprintf("\tUntracked %d files and directories\n", numUntracked))
+		}
+		if err != nil {
+			klog.Errorf("Failed to publish to Jupyter: %+v", err)
+		}
+		return
+	}
+	for _, arg := range args {
+		fileOrDirPath := expandTrackPath(arg)
 		err := goExec.Untrack(fileOrDirPath)
 		if err != nil {
 			err = kernel.PublishWriteStream(msg, kernel.StreamStderr, err.Error()+"\n")
@@ -50,7 +93,16 @@ func execUntrack(msg kernel.Message, goExec *goexec.State, args []string) {
 			return
 		}
 	}
+}
 
+// expandTrackPath replaces a leading `~` by the user's home directory (or `~user` by the home directory
+// of user), and the environment variables (`$VAR` or `${VAR}`) by their values, the same way `%cd` and
+// `%watch` handle paths.
+func expandTrackPath(fileOrDirPath string) string {
+	if fileOrDirPath == "" {
+		return fileOrDirPath
+	}
+	return ReplaceEnvVars(ReplaceTildeInDir(fileOrDirPath))
 }
 
 func showTrackedList(msg kernel.Message, goExec *goexec.State) {
@@ -71,3 +123,42 @@ func showTrackedList(msg kernel.Message, goExec *goexec.State) {
 		klog.Errorf("Failed to publish track results back to jupyter: %+v", err)
 	}
 }
+
+// showTrackedTable displays the tracked files and directories in a table, with the module they belong to
+// and the number of files loaded by `gopls`.
+func showTrackedTable(msg kernel.Message, goExec *goexec.State) {
+	statuses := goExec.ListTrackedStatus()
+	if len(statuses) == 0 {
+		showTrackedList(msg, goExec)
+		return
+	}
+	htmlParts := make([]string, 0, len(statuses)+5)
+	htmlParts = append(htmlParts, "<b>List of files/directories being tracked:</b>")
+	htmlParts = append(htmlParts, "<table>")
+	htmlParts = append(htmlParts, "<tr><th>Path</th><th>Module</th><th>gopls</th></tr>")
+	for _, status := range statuses {
+		module := html.EscapeString(status.Module)
+		if module == "" {
+			module = "<i>no go.mod</i>"
+		}
+		var loaded string
+		switch {
+		case status.NumLoaded < 0:
+			loaded = "not available"
+		case status.NumLoaded == 0:
+			loaded = "not loaded yet"
+		default:
+			loaded = fmt.Sprintf("%d files loaded", status.NumLoaded)
+		}
+		htmlParts = append(htmlParts, fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(status.Path), module, loaded))
+	}
+	htmlParts = append(htmlParts, "</table>")
+	if goExec.HasGopls() {
+		htmlParts = append(htmlParts, "<i>Files are loaded by gopls with the next contextual help or auto-complete request.</i>")
+	}
+	err := kernel.PublishHtml(msg, strings.Join(htmlParts, "\n")+"\n")
+	if err != nil {
+		klog.Errorf("Failed to publish track results back to jupyter: %+v", err)
+	}
+}
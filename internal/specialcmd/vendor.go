@@ -0,0 +1,40 @@
+package specialcmd
+
+import (
+	"fmt"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+// execVendor executes the "%vendor [off]" special command. The parameter `args` excludes "%vendor".
+//
+// While the dependencies are vendored, `%autoget` is disabled, since `go get` would make the `go.mod`
+// inconsistent with the vendored dependencies.
+func execVendor(msg kernel.Message, goExec *goexec.State, args []string) error {
+	var report string
+	switch {
+	case len(args) == 0:
+		numModules, err := goExec.Vendor()
+		if err != nil {
+			return err
+		}
+		goExec.AutoGet = false
+		report = fmt.Sprintf("Vendored %d modules in %q: programs are compiled with `-mod=vendor`, "+
+			"and `%%autoget` is disabled.\n", numModules, goExec.VendorDir())
+	case len(args) == 1 && args[0] == "off":
+		if err := goExec.Unvendor(); err != nil {
+			return err
+		}
+		goExec.AutoGet = true
+		report = "Vendored dependencies removed, and `%autoget` re-enabled.\n"
+	default:
+		return errors.Errorf("invalid `%%vendor` arguments %q, use `%%vendor` or `%%vendor off`", args)
+	}
+	err := kernel.PublishWriteStream(msg, kernel.StreamStdout, report)
+	if err != nil {
+		klog.Errorf("Failed to publish to Jupyter: %+v", err)
+	}
+	return nil
+}
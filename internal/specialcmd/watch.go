@@ -0,0 +1,129 @@
+package specialcmd
+
+import (
+	"github.com/fsnotify/fsnotify"
+	. "github.com/janpfeifer/gonb/common"
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// This file implements `%watch`, which executes the cell again whenever the watched files change.
+
+// WatchMinInterval is the minimum interval between executions of a cell with `%watch`: changes within the
+// interval are coalesced into one execution.
+var WatchMinInterval = time.Second
+
+// execWatch executes the "%watch" special command. The parameter `args` excludes "%watch".
+// It only validates and records the paths to watch, the watching itself is done by executeRepeatedCell, once the
+// cell finishes executing.
+func execWatch(goExec *goexec.State, args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("`%%watch <file-or-dir>...` requires at least one file or directory to watch")
+	}
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		watchPath, err := filepath.Abs(ReplaceEnvVars(ReplaceTildeInDir(arg)))
+		if err != nil {
+			return errors.Wrapf(err, "`%%watch`: invalid path %q", arg)
+		}
+		if _, err = os.Stat(watchPath); err != nil {
+			return errors.Wrapf(err, "`%%watch`: can't watch %q", arg)
+		}
+		paths = append(paths, watchPath)
+	}
+	goExec.CellWatchPaths = paths
+	return nil
+}
+
+// waitChange waits for a change in the watched paths, and returns the path that changed. Changes are coalesced
+// until WatchMinInterval has elapsed since lastExecution.
+// It returns "" if interrupted is closed first.
+func (w *pathsWatcher) waitChange(interrupted <-chan struct{}, lastExecution time.Time) (string, error) {
+	changed, err := w.wait(interrupted, nil)
+	if err != nil || changed == "" {
+		return "", err
+	}
+	timer := time.NewTimer(WatchMinInterval - time.Since(lastExecution))
+	defer timer.Stop()
+	for {
+		more, err := w.wait(interrupted, timer.C)
+		if err != nil {
+			return "", err
+		}
+		if more == "" {
+			return changed, nil
+		}
+	}
+}
+
+// pathsWatcher watches a set of files and directories for changes.
+type pathsWatcher struct {
+	*fsnotify.Watcher
+
+	// files holds the files watched. Their directory is watched instead, since editors often replace files
+	// (as opposed to writing to them), and events for other files in the directory are filtered out.
+	files Set[string]
+
+	// dirs holds the directories watched: changes to any of their files are reported.
+	dirs Set[string]
+}
+
+// newPathsWatcher creates a pathsWatcher for the given absolute paths.
+func newPathsWatcher(paths []string) (*pathsWatcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create a filesystem watcher for `%%watch`")
+	}
+	watcher := &pathsWatcher{Watcher: fsWatcher, files: MakeSet[string](), dirs: MakeSet[string]()}
+	for _, watchPath := range paths {
+		info, err := os.Stat(watchPath)
+		if err == nil {
+			if info.IsDir() {
+				watcher.dirs.Insert(watchPath)
+			} else {
+				watcher.files.Insert(watchPath)
+				watchPath = filepath.Dir(watchPath)
+			}
+		}
+		if err == nil {
+			err = watcher.Add(watchPath)
+		}
+		if err != nil {
+			_ = watcher.Close()
+			return nil, errors.Wrapf(err, "`%%watch`: failed to watch %q", watchPath)
+		}
+	}
+	return watcher, nil
+}
+
+// wait for the next change in the watched paths, and returns the path that changed.
+// It returns "" if interrupted is closed or if timeout (if not nil) fires first.
+func (w *pathsWatcher) wait(interrupted <-chan struct{}, timeout <-chan time.Time) (string, error) {
+	for {
+		select {
+		case <-interrupted:
+			return "", nil
+		case <-timeout:
+			return "", nil
+		case event, ok := <-w.Events:
+			if !ok {
+				return "", nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if !w.files.Has(event.Name) && !w.dirs.Has(filepath.Dir(event.Name)) {
+				continue // Another file in the directory of a watched file.
+			}
+			return event.Name, nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return "", nil
+			}
+			return "", errors.Wrapf(err, "`%%watch` failed while watching for changes")
+		}
+	}
+}
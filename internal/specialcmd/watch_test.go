@@ -0,0 +1,56 @@
+package specialcmd
+
+import (
+	"github.com/janpfeifer/gonb/internal/goexec"
+	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecWatch(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	assert.Error(t, execWatch(s, nil))
+	assert.Error(t, execWatch(s, []string{"/nonexistent/file.txt"}))
+	dir := t.TempDir()
+	require.NoError(t, execWatch(s, []string{dir}))
+	assert.Equal(t, []string{dir}, s.CellWatchPaths)
+}
+
+func TestExecuteWatchedCell(t *testing.T) {
+	s := newEmptyState(t)
+	defer func() { require.NoError(t, s.Stop()) }()
+	defer func(previous time.Duration) { WatchMinInterval = previous }(WatchMinInterval)
+	WatchMinInterval = 10 * time.Millisecond
+
+	// The cell counts its executions, and interrupts itself on the second one.
+	var count atomic.Int32
+	require.NoError(t, RegisterMagic("count_executions", func(msg kernel.Message, _ *goexec.State, _ []string) error {
+		if count.Add(1) == 2 {
+			msg.Kernel().Interrupt()
+		}
+		return nil
+	}))
+	defer UnregisterMagic("count_executions")
+
+	dataPath := path.Join(t.TempDir(), "data.txt")
+	require.NoError(t, os.WriteFile(dataPath, []byte("0"), 0644))
+	msg := &fakeMessage{kernel: &kernel.Kernel{}}
+	go func() {
+		// Change the watched file until the cell is executed again.
+		for count.Load() < 2 {
+			if count.Load() == 1 {
+				_ = os.WriteFile(dataPath, []byte("1"), 0644)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	require.NoError(t, executeRepeatedCell(msg, s, 1, []string{"%watch " + dataPath, "%count_executions"}))
+	assert.Equal(t, int32(2), count.Load())
+	assert.Empty(t, s.CellWatchPaths)
+}
@@ -3,15 +3,19 @@ package main
 import (
 	"flag"
 	"fmt"
+	"github.com/go-logr/logr/funcr"
 	"github.com/gofrs/uuid"
 	"github.com/janpfeifer/gonb/internal/dispatcher"
 	"github.com/janpfeifer/gonb/internal/goexec"
 	"github.com/janpfeifer/gonb/internal/kernel"
+	"github.com/janpfeifer/gonb/internal/specialcmd"
 	"io"
 	klog "k8s.io/klog/v2"
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +28,7 @@ var (
 	flagRawError  = flag.Bool("raw_error", false, "When GoNB executes cells, force raw text errors instead of HTML errors, which facilitates command line testing of notebooks.")
 	flagWork      = flag.Bool("work", false, "Print name of temporary work directory and preserve it at exit. ")
 	flagCommsLog  = flag.Bool("comms_log", false, "Enable verbose logging from communication library in Javascript console.")
+	flagLogJson   = flag.Bool("log_json", false, "Output the kernel logs as structured JSON, one object per line, for automated log processing.")
 )
 
 var (
@@ -96,6 +101,9 @@ func main() {
 		if glogFlag := flag.Lookup("comms_log"); glogFlag != nil && glogFlag.Value.String() != "false" {
 			extraArgs = append(extraArgs, "--comms_log")
 		}
+		if glogFlag := flag.Lookup("log_json"); glogFlag != nil && glogFlag.Value.String() != "false" {
+			extraArgs = append(extraArgs, "--log_json")
+		}
 		err := kernel.Install(extraArgs, *flagForceDeps, *flagForceCopy)
 		if err != nil {
 			log.Fatalf("Installation failed: %+v\n", err)
@@ -134,6 +142,11 @@ func main() {
 	}
 	goExec.Comms.LogWebSocket = *flagCommsLog
 
+	// Load the user's configuration: commands executed in the notebook take precedence.
+	if err = specialcmd.LoadConfig(k, goExec); err != nil {
+		klog.Warningf("Failed to load configuration, using defaults: %+v", err)
+	}
+
 	// Orchestrate dispatching of messages.
 	dispatcher.RunKernel(k, goExec)
 	klog.V(1).Infof("Dispatcher exited.")
@@ -159,6 +172,13 @@ var (
 // SetUpLogging creates a UniqueID, uses it as a prefix for logging, and sets up --extra_log if
 // requested.
 func SetUpLogging() {
+	if *flagLogJson {
+		// Logs from the "log" package are converted to JSON by the klog logger, see SetUpKlog.
+		log.SetPrefix("")
+		log.SetFlags(0)
+		log.SetOutput(klogWriter{})
+		return
+	}
 	log.SetPrefix(coloredUniqueID)
 	if logWriter != nil {
 		log.SetOutput(logWriter)
@@ -194,9 +214,38 @@ func (_ UniqueIDFilter) FilterS(msg string, keysAndValues []interface{}) (string
 }
 
 // SetUpKlog to include prefix with kernel's UniqueID.
+//
+// If --log_json is set, the logs are instead output as JSON objects, one per line, with the kernel's
+// UniqueID in the field "kernel".
 func SetUpKlog() {
+	if *flagLogJson {
+		out := logWriter
+		if out == nil {
+			out = os.Stderr
+		}
+		verbosity := 0
+		if vFlag := flag.Lookup("v"); vFlag != nil {
+			verbosity, _ = strconv.Atoi(vFlag.Value.String())
+		}
+		logger := funcr.NewJSON(func(obj string) { _, _ = fmt.Fprintln(out, obj) }, funcr.Options{
+			LogTimestamp:    true,
+			TimestampFormat: time.RFC3339Nano,
+			Verbosity:       verbosity,
+		})
+		klog.SetLogger(logger.WithValues("kernel", UniqueID))
+		return
+	}
 	if logWriter != nil {
 		klog.SetOutput(logWriter)
 	}
 	klog.SetLogFilter(UniqueIDFilter{})
 }
+
+// klogWriter is an io.Writer that logs each write with klog.Info: used to redirect the "log" package.
+type klogWriter struct{}
+
+// Write implements io.Writer.
+func (klogWriter) Write(p []byte) (int, error) {
+	klog.Info(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}